@@ -8,7 +8,10 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/SarthakJariwala/grove/internal/config"
 	"github.com/SarthakJariwala/grove/internal/configfile"
+	"github.com/SarthakJariwala/grove/internal/logfile"
+	"github.com/SarthakJariwala/grove/internal/pty"
 	"github.com/SarthakJariwala/grove/internal/tmux"
 	"github.com/SarthakJariwala/grove/internal/tmuxconfig"
 	"github.com/SarthakJariwala/grove/internal/ui"
@@ -34,27 +37,78 @@ func defaultConfigPath() string {
 	return filepath.Join(configDir, "grove", "config.toml")
 }
 
+// runInternal runs the hidden subcommands grove starts itself: the PTY
+// daemon, the PTY attach client and the log sink.
+func runInternal(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	switch args[0] {
+	case pty.DaemonArg:
+		if len(args) != 2 {
+			return true, fmt.Errorf("usage: grove %s <socket>", pty.DaemonArg)
+		}
+		return true, pty.Serve(args[1])
+	case pty.AttachArg:
+		if len(args) != 3 {
+			return true, fmt.Errorf("usage: grove %s <socket> <session>", pty.AttachArg)
+		}
+		return true, pty.Attach(args[1], args[2])
+	case logfile.SinkArg:
+		if len(args) != 2 {
+			return true, fmt.Errorf("usage: grove %s <log>", logfile.SinkArg)
+		}
+		return true, logfile.Sink(args[1], os.Stdin)
+	}
+	return false, nil
+}
+
 func run() error {
+	if ok, err := runInternal(os.Args[1:]); ok {
+		return err
+	}
+
 	configPath := flag.String("config", defaultConfigPath(), "path to config.toml")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: grove [-config path] [command [args]]\n\nWithout a command, grove opens the UI.\n\n%s\n\nFlags:\n", ui.CommandUsage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	if err := configfile.EnsureTemplate(*configPath); err != nil {
 		return fmt.Errorf("could not initialize config template: %w", err)
 	}
 
-	if created, path, err := tmuxconfig.EnsureDefault(); err != nil {
-		fmt.Fprintln(os.Stderr, "grove: warning: could not install default tmux config:", err)
-	} else if created {
-		fmt.Fprintln(os.Stderr, "grove: installed default tmux config at", path)
-	}
-
 	cfg, err := configfile.Load(*configPath)
 	if err != nil {
 		return fmt.Errorf("config error: %w", err)
 	}
 
-	client := tmux.NewClient()
+	var client interface {
+		ui.SessionManager
+		Close() error
+	}
+	if cfg.Backend == config.BackendPTY {
+		client = pty.NewClient(pty.DefaultSocket())
+	} else {
+		if created, path, err := tmuxconfig.EnsureDefault(); err != nil {
+			fmt.Fprintln(os.Stderr, "grove: warning: could not install default tmux config:", err)
+		} else if created {
+			fmt.Fprintln(os.Stderr, "grove: installed default tmux config at", path)
+		}
+		client = tmux.NewClient(cfg.TmuxSocket)
+	}
+	defer client.Close()
+
+	if flag.NArg() > 0 {
+		if err := ui.RunCommand(cfg, *configPath, client, flag.Args(), os.Stdout); err != nil {
+			return fmt.Errorf("grove: %w", err)
+		}
+		return nil
+	}
+
 	model := ui.NewModel(cfg, *configPath, client)
+	defer model.Close()
 
 	p := tea.NewProgram(model, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
@@ -3,39 +3,194 @@ package config
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 )
 
 type Agent struct {
-	Name    string `toml:"name"`
-	Command string `toml:"command"`
+	Name    string            `toml:"name"`
+	Command string            `toml:"command"`
+	Env     map[string]string `toml:"env,omitempty"`
+	EnvFile string            `toml:"env_file,omitempty"`
+	// ResumeCommand runs instead of Command when grove restores an instance,
+	// so the agent can pick up its previous conversation.
+	ResumeCommand string `toml:"resume_command,omitempty"`
+	// NeedsInput, Working and Finished are regular expressions matched
+	// against the agent's visible pane to tell what it is doing.
+	NeedsInput []string `toml:"needs_input,omitempty"`
+	Working    []string `toml:"working,omitempty"`
+	Finished   []string `toml:"finished,omitempty"`
+	// Spinner lists the pane-title glyphs the agent shows while it works.
+	// Braille spinners count when it is empty.
+	Spinner string `toml:"spinner,omitempty"`
+	// IdleAfter is how long the agent may print nothing before it counts
+	// as idle.
+	IdleAfter time.Duration `toml:"idle_after,omitempty"`
+	Source    string        `toml:"-"`
+}
+
+const defaultIdleAfter = 10 * time.Second
+
+// IdleTimeout returns how long the agent may stay quiet and still count as
+// working.
+func (a Agent) IdleTimeout() time.Duration {
+	if a.IdleAfter > 0 {
+		return a.IdleAfter
+	}
+	return defaultIdleAfter
 }
 
 type Command struct {
-	Name    string `toml:"name"`
-	Command string `toml:"command"`
+	Name           string            `toml:"name"`
+	Command        string            `toml:"command"`
+	Env            map[string]string `toml:"env,omitempty"`
+	EnvFile        string            `toml:"env_file,omitempty"`
+	DependsOn      []string          `toml:"depends_on,omitempty"`
+	Restart        string            `toml:"restart,omitempty"`
+	RestartBackoff time.Duration     `toml:"restart_backoff,omitempty"`
+	MaxRetries     int               `toml:"max_retries,omitempty"`
+	StopKeys       []string          `toml:"stop_keys,omitempty"`
+	StopCommand    string            `toml:"stop_command,omitempty"`
+	StopTimeout    time.Duration     `toml:"stop_timeout,omitempty"`
+	Source         string            `toml:"-"`
+}
+
+const (
+	RestartNo        = "no"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+const (
+	defaultRestartBackoff = time.Second
+	maxRestartBackoff     = 5 * time.Minute
+	defaultStopTimeout    = 10 * time.Second
+)
+
+// defaultStopKeys is what a command is sent to stop it when neither
+// stop_keys nor stop_command is set.
+var defaultStopKeys = []string{"C-c"}
+
+// ShouldRestart reports whether the command is relaunched after exiting.
+// attempt counts the automatic restarts already made since the last manual
+// start; MaxRetries of zero means no limit.
+func (c Command) ShouldRestart(attempt int, failed bool) bool {
+	switch c.Restart {
+	case RestartAlways:
+	case RestartOnFailure:
+		if !failed {
+			return false
+		}
+	default:
+		return false
+	}
+	return c.MaxRetries == 0 || attempt < c.MaxRetries
+}
+
+// RestartDelay returns how long to wait before the given restart attempt
+// (starting at 0). The backoff doubles with each attempt up to a cap.
+func (c Command) RestartDelay(attempt int) time.Duration {
+	delay := c.RestartBackoff
+	if delay <= 0 {
+		delay = defaultRestartBackoff
+	}
+	for i := 0; i < attempt && delay < maxRestartBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRestartBackoff {
+		delay = maxRestartBackoff
+	}
+	return delay
+}
+
+// StopKeySequence returns the keys sent to ask the command to exit, in tmux
+// key names. Without stop_keys it is C-c, unless a stop_command is set.
+func (c Command) StopKeySequence() []string {
+	if len(c.StopKeys) > 0 {
+		return c.StopKeys
+	}
+	if c.StopCommand != "" {
+		return nil
+	}
+	return defaultStopKeys
+}
+
+// StopWait returns how long to wait for the command to exit after asking it
+// to, before its session is killed.
+func (c Command) StopWait() time.Duration {
+	if c.StopTimeout <= 0 {
+		return defaultStopTimeout
+	}
+	return c.StopTimeout
 }
 
 type Config struct {
-	EditorCommand string   `toml:"editor_command"`
-	Agents        []Agent  `toml:"agent"`
-	Folders       []Folder `toml:"folder"`
+	EditorCommand string `toml:"editor_command"`
+	// Backend runs local sessions in tmux (the default) or in grove's own
+	// PTY daemon.
+	Backend string `toml:"backend,omitempty"`
+	// TmuxSocket selects the tmux server grove uses: a socket name as for
+	// tmux -L, or a path as for tmux -S when it contains a slash.
+	TmuxSocket string   `toml:"tmux_socket,omitempty"`
+	Agents     []Agent  `toml:"agent"`
+	Folders    []Folder `toml:"folder"`
 }
 
+const (
+	BackendTmux = "tmux"
+	BackendPTY  = "pty"
+)
+
 type Folder struct {
-	Name          string    `toml:"name"`
-	Path          string    `toml:"path"`
-	EditorCommand string    `toml:"editor_command"`
-	Agents        []Agent   `toml:"agent"`
-	Commands      []Command `toml:"command"`
-	Namespace     string    `toml:"-"`
+	Name string `toml:"name"`
+	Path string `toml:"path"`
+	// Host is the ssh destination whose tmux server runs the folder's
+	// sessions. Path is then a path on that host.
+	Host          string            `toml:"host,omitempty"`
+	EditorCommand string            `toml:"editor_command"`
+	Env           map[string]string `toml:"env,omitempty"`
+	EnvFile       string            `toml:"env_file,omitempty"`
+	Agents        []Agent           `toml:"agent"`
+	Commands      []Command         `toml:"command"`
+	Tasks         []Task            `toml:"task"`
+	// TrustProjectSchedules lets the tasks in the folder's ProjectFile run
+	// on their schedules. Without it, anyone who can change the repo could
+	// run code unattended, so project tasks only run when started.
+	TrustProjectSchedules bool   `toml:"trust_project_schedules,omitempty"`
+	Namespace             string `toml:"-"`
 }
 
 func (c *Config) Normalize(baseDir string) error {
+	return c.NormalizeWithProjects(baseDir, nil)
+}
+
+// NormalizeWithProjects normalizes the config and merges each folder's
+// project config from load. Command dependencies are validated after the
+// merge, so user and project commands may depend on each other.
+func (c *Config) NormalizeWithProjects(baseDir string, load ProjectLoader) error {
 	c.EditorCommand = strings.TrimSpace(c.EditorCommand)
+	c.Backend = strings.ToLower(strings.TrimSpace(c.Backend))
+	switch c.Backend {
+	case "", BackendTmux, BackendPTY:
+	default:
+		return fmt.Errorf("backend must be %q or %q", BackendTmux, BackendPTY)
+	}
+	c.TmuxSocket = strings.TrimSpace(c.TmuxSocket)
+	if strings.Contains(c.TmuxSocket, "/") {
+		c.TmuxSocket = ExpandHome(c.TmuxSocket)
+		if !filepath.IsAbs(c.TmuxSocket) {
+			c.TmuxSocket = filepath.Join(baseDir, c.TmuxSocket)
+		}
+	}
 	for i := range c.Agents {
-		if err := normalizeAgent(&c.Agents[i], fmt.Sprintf("agent[%d]", i)); err != nil {
+		scope := fmt.Sprintf("agent[%d]", i)
+		if err := normalizeAgent(&c.Agents[i], scope); err != nil {
+			return err
+		}
+		if err := normalizeEnv(c.Agents[i].Env, &c.Agents[i].EnvFile, baseDir, scope); err != nil {
 			return err
 		}
 	}
@@ -45,6 +200,7 @@ func (c *Config) Normalize(baseDir string) error {
 		folder := &c.Folders[i]
 		folder.Name = strings.TrimSpace(folder.Name)
 		folder.Path = strings.TrimSpace(folder.Path)
+		folder.Host = strings.TrimSpace(folder.Host)
 		folder.EditorCommand = strings.TrimSpace(folder.EditorCommand)
 
 		if folder.Name == "" {
@@ -54,28 +210,77 @@ func (c *Config) Normalize(baseDir string) error {
 			return fmt.Errorf("folder[%d] path is required", i)
 		}
 
+		// Env files inside a folder resolve relative to the folder itself,
+		// except on remote hosts: env files are always read locally.
+		envDir := baseDir
+		if folder.Host == "" {
+			folder.Path = ExpandHome(folder.Path)
+
+			if !filepath.IsAbs(folder.Path) {
+				folder.Path = filepath.Join(baseDir, folder.Path)
+			}
+
+			absPath, err := filepath.Abs(folder.Path)
+			if err != nil {
+				return fmt.Errorf("resolve path for folder %q: %w", folder.Name, err)
+			}
+			folder.Path = absPath
+			envDir = folder.Path
+		} else if !path.IsAbs(folder.Path) {
+			return fmt.Errorf("folder %q path must be absolute on host %q", folder.Name, folder.Host)
+		}
+
+		if err := normalizeEnv(folder.Env, &folder.EnvFile, envDir, fmt.Sprintf("folder[%d]", i)); err != nil {
+			return err
+		}
 		for j := range folder.Agents {
-			if err := normalizeAgent(&folder.Agents[j], fmt.Sprintf("folder[%d] agent[%d]", i, j)); err != nil {
+			agent := &folder.Agents[j]
+			scope := fmt.Sprintf("folder[%d] agent[%d]", i, j)
+			if err := normalizeAgent(agent, scope); err != nil {
+				return err
+			}
+			if err := normalizeEnv(agent.Env, &agent.EnvFile, envDir, scope); err != nil {
 				return err
 			}
 		}
 		for j := range folder.Commands {
-			if err := normalizeCommand(&folder.Commands[j], fmt.Sprintf("folder[%d] command[%d]", i, j)); err != nil {
+			command := &folder.Commands[j]
+			scope := fmt.Sprintf("folder[%d] command[%d]", i, j)
+			if err := normalizeCommand(command, scope); err != nil {
+				return err
+			}
+			if err := normalizeEnv(command.Env, &command.EnvFile, envDir, scope); err != nil {
 				return err
 			}
 		}
-
-		folder.Path = ExpandHome(folder.Path)
-
-		if !filepath.IsAbs(folder.Path) {
-			folder.Path = filepath.Join(baseDir, folder.Path)
+		for j := range folder.Tasks {
+			task := &folder.Tasks[j]
+			scope := fmt.Sprintf("folder[%d] task[%d]", i, j)
+			if err := normalizeTask(task, scope); err != nil {
+				return err
+			}
+			if err := normalizeEnv(task.Env, &task.EnvFile, envDir, scope); err != nil {
+				return err
+			}
 		}
-
-		absPath, err := filepath.Abs(folder.Path)
-		if err != nil {
-			return fmt.Errorf("resolve path for folder %q: %w", folder.Name, err)
+		// Project files of remote folders are not readable from here.
+		if load != nil && folder.Host == "" {
+			project, projectPath, ok, err := load(*folder)
+			if err != nil {
+				return err
+			}
+			if ok {
+				if err := folder.mergeProject(project, projectPath); err != nil {
+					return err
+				}
+			}
+		}
+		if err := validateCommandDeps(*folder); err != nil {
+			return err
+		}
+		if err := validateTaskNames(*folder); err != nil {
+			return err
 		}
-		folder.Path = absPath
 
 		namespace := Slug(folder.Name)
 		if namespace == "" {
@@ -100,18 +305,65 @@ func normalizeAgent(agent *Agent, scope string) error {
 	if agent.Command == "" {
 		return fmt.Errorf("%s command is required", scope)
 	}
+	agent.ResumeCommand = strings.TrimSpace(agent.ResumeCommand)
+	agent.Spinner = strings.TrimSpace(agent.Spinner)
+	for _, rule := range []struct {
+		key      string
+		patterns []string
+	}{
+		{"needs_input", agent.NeedsInput},
+		{"working", agent.Working},
+		{"finished", agent.Finished},
+	} {
+		for _, pattern := range rule.patterns {
+			if pattern == "" {
+				return fmt.Errorf("%s %s has an empty pattern", scope, rule.key)
+			}
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("%s %s pattern %q: %w", scope, rule.key, pattern, err)
+			}
+		}
+	}
+	if agent.IdleAfter < 0 {
+		return fmt.Errorf("%s idle_after must not be negative", scope)
+	}
 	return nil
 }
 
 func normalizeCommand(command *Command, scope string) error {
 	command.Name = strings.TrimSpace(command.Name)
 	command.Command = strings.TrimSpace(command.Command)
+	for i := range command.DependsOn {
+		command.DependsOn[i] = strings.TrimSpace(command.DependsOn[i])
+	}
+	command.Restart = strings.ToLower(strings.TrimSpace(command.Restart))
+	command.StopCommand = strings.TrimSpace(command.StopCommand)
+	for i := range command.StopKeys {
+		command.StopKeys[i] = strings.TrimSpace(command.StopKeys[i])
+		if command.StopKeys[i] == "" {
+			return fmt.Errorf("%s stop_keys must not contain empty keys", scope)
+		}
+	}
 	if command.Name == "" {
 		return fmt.Errorf("%s name is required", scope)
 	}
 	if command.Command == "" {
 		return fmt.Errorf("%s command is required", scope)
 	}
+	switch command.Restart {
+	case "", RestartNo, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("%s restart must be %q, %q or %q", scope, RestartNo, RestartOnFailure, RestartAlways)
+	}
+	if command.RestartBackoff < 0 {
+		return fmt.Errorf("%s restart_backoff must not be negative", scope)
+	}
+	if command.MaxRetries < 0 {
+		return fmt.Errorf("%s max_retries must not be negative", scope)
+	}
+	if command.StopTimeout < 0 {
+		return fmt.Errorf("%s stop_timeout must not be negative", scope)
+	}
 	return nil
 }
 
@@ -137,6 +389,16 @@ func Slug(s string) string {
 	return out
 }
 
+// StateDir returns $XDG_STATE_HOME/grove, falling back to
+// ~/.local/state/grove. Logs and other runtime state live under it.
+func StateDir() string {
+	state := os.Getenv("XDG_STATE_HOME")
+	if state == "" {
+		state = ExpandHome("~/.local/state")
+	}
+	return filepath.Join(state, "grove")
+}
+
 func ExpandHome(path string) string {
 	if path == "~" {
 		home, err := os.UserHomeDir()
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSlug(t *testing.T) {
@@ -93,6 +96,79 @@ func TestConfigNormalize(t *testing.T) {
 	}
 }
 
+func TestConfigNormalizeTmuxSocket(t *testing.T) {
+	t.Parallel()
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("UserHomeDir() error = %v", err)
+	}
+	tests := []struct {
+		socket string
+		want   string
+	}{
+		{socket: "", want: ""},
+		{socket: " grove ", want: "grove"},
+		{socket: "./tmux.sock", want: "/base/tmux.sock"},
+		{socket: "/tmp/grove.sock", want: "/tmp/grove.sock"},
+		{socket: "~/.grove/tmux.sock", want: filepath.Join(home, ".grove/tmux.sock")},
+	}
+	for _, tt := range tests {
+		cfg := Config{TmuxSocket: tt.socket}
+		if err := cfg.Normalize("/base"); err != nil {
+			t.Fatalf("Normalize() error = %v", err)
+		}
+		if cfg.TmuxSocket != tt.want {
+			t.Fatalf("TmuxSocket for %q = %q, want %q", tt.socket, cfg.TmuxSocket, tt.want)
+		}
+	}
+}
+
+func TestConfigNormalizeBackend(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{Backend: " PTY "}
+	if err := cfg.Normalize("/base"); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	if cfg.Backend != BackendPTY {
+		t.Fatalf("Backend = %q, want %q", cfg.Backend, BackendPTY)
+	}
+
+	cfg = Config{Backend: "screen"}
+	if err := cfg.Normalize("/base"); err == nil || err.Error() != `backend must be "tmux" or "pty"` {
+		t.Fatalf("Normalize() error = %v, want backend error", err)
+	}
+}
+
+func TestConfigNormalizeRemoteFolder(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{Folders: []Folder{{
+		Name:     "VM",
+		Path:     "/srv/app",
+		Host:     " devbox ",
+		EnvFile:  "vm.env",
+		Commands: []Command{{Name: "web", Command: "make web", EnvFile: "web.env"}},
+	}}}
+	if err := cfg.Normalize("/base"); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	folder := cfg.Folders[0]
+	if folder.Host != "devbox" || folder.Path != "/srv/app" {
+		t.Fatalf("folder = %+v, want host devbox and the path untouched", folder)
+	}
+	// Env files are read locally, so they resolve against the config dir.
+	if folder.EnvFile != "/base/vm.env" || folder.Commands[0].EnvFile != "/base/web.env" {
+		t.Fatalf("env files = %q, %q, want them under /base", folder.EnvFile, folder.Commands[0].EnvFile)
+	}
+
+	cfg = Config{Folders: []Folder{{Name: "VM", Path: "~/app", Host: "devbox"}}}
+	if err := cfg.Normalize("/base"); err == nil || err.Error() != `folder "VM" path must be absolute on host "devbox"` {
+		t.Fatalf("Normalize() error = %v, want absolute path error", err)
+	}
+}
+
 func TestConfigNormalizeErrors(t *testing.T) {
 	t.Parallel()
 
@@ -123,6 +199,21 @@ func TestConfigNormalizeErrors(t *testing.T) {
 			cfg:     Config{Folders: []Folder{{Name: "---", Path: "./a"}}},
 			wantErr: "produced empty namespace",
 		},
+		{
+			name:    "invalid agent pattern",
+			cfg:     Config{Agents: []Agent{{Name: "Claude", Command: "claude", NeedsInput: []string{"Allow ("}}}},
+			wantErr: `agent[0] needs_input pattern "Allow ("`,
+		},
+		{
+			name:    "empty agent pattern",
+			cfg:     Config{Agents: []Agent{{Name: "Claude", Command: "claude", Working: []string{""}}}},
+			wantErr: "agent[0] working has an empty pattern",
+		},
+		{
+			name:    "negative idle_after",
+			cfg:     Config{Agents: []Agent{{Name: "Claude", Command: "claude", IdleAfter: -time.Second}}},
+			wantErr: "idle_after must not be negative",
+		},
 	}
 
 	for _, tt := range tests {
@@ -247,3 +338,196 @@ func TestConfigNormalizeRejectsEmptyNestedEntries(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigNormalizeValidatesRestartPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		command Command
+		wantErr string
+	}{
+		{
+			name:    "unknown policy",
+			command: Command{Name: "web", Command: "make web", Restart: "sometimes"},
+			wantErr: `folder[0] command[0] restart must be "no", "on-failure" or "always"`,
+		},
+		{
+			name:    "negative backoff",
+			command: Command{Name: "web", Command: "make web", Restart: "always", RestartBackoff: -time.Second},
+			wantErr: "folder[0] command[0] restart_backoff must not be negative",
+		},
+		{
+			name:    "negative max retries",
+			command: Command{Name: "web", Command: "make web", Restart: "always", MaxRetries: -1},
+			wantErr: "folder[0] command[0] max_retries must not be negative",
+		},
+		{
+			name:    "negative stop timeout",
+			command: Command{Name: "web", Command: "make web", StopTimeout: -time.Second},
+			wantErr: "folder[0] command[0] stop_timeout must not be negative",
+		},
+		{
+			name:    "empty stop key",
+			command: Command{Name: "web", Command: "make web", StopKeys: []string{"C-c", " "}},
+			wantErr: "folder[0] command[0] stop_keys must not contain empty keys",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := Config{Folders: []Folder{{Name: "API", Path: "./api", Commands: []Command{tt.command}}}}
+			err := cfg.Normalize(t.TempDir())
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("Normalize() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	cfg := Config{Folders: []Folder{{Name: "API", Path: "./api", Commands: []Command{{Name: "web", Command: "make web", Restart: " On-Failure "}}}}}
+	if err := cfg.Normalize(t.TempDir()); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	if got := cfg.Folders[0].Commands[0].Restart; got != RestartOnFailure {
+		t.Fatalf("Restart = %q, want %q", got, RestartOnFailure)
+	}
+}
+
+func TestConfigNormalizeValidatesTasks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		tasks   []Task
+		wantErr string
+	}{
+		{
+			name:    "missing command",
+			tasks:   []Task{{Name: "audit"}},
+			wantErr: "folder[0] task[0] command is required",
+		},
+		{
+			name:    "bad schedule",
+			tasks:   []Task{{Name: "audit", Command: "npm audit", Schedule: "0 25 * * *"}},
+			wantErr: `folder[0] task[0] schedule: cron hour "25" must be between 0 and 23`,
+		},
+		{
+			name:    "duplicate names",
+			tasks:   []Task{{Name: "Audit", Command: "npm audit"}, {Name: "audit", Command: "cargo audit"}},
+			wantErr: `folder "API" tasks "Audit" and "audit" have the same name`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := Config{Folders: []Folder{{Name: "API", Path: "./api", Tasks: tt.tasks}}}
+			err := cfg.Normalize(t.TempDir())
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("Normalize() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	cfg := Config{Folders: []Folder{{Name: "API", Path: "./api", Tasks: []Task{{Name: " audit ", Command: " npm audit ", Schedule: " 0 3 * * * "}}}}}
+	if err := cfg.Normalize(t.TempDir()); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	task := cfg.Folders[0].Tasks[0]
+	from := time.Date(2026, time.March, 4, 10, 0, 0, 0, time.UTC)
+	if got, want := task.NextRun(from), time.Date(2026, time.March, 5, 3, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("NextRun() = %v, want %v", got, want)
+	}
+	if got := (Task{Name: "lint", Command: "make lint"}).NextRun(from); !got.IsZero() {
+		t.Fatalf("NextRun() without a schedule = %v, want zero", got)
+	}
+}
+
+func TestCommandRestartPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		command Command
+		attempt int
+		failed  bool
+		want    bool
+	}{
+		{name: "default never restarts", command: Command{}, failed: true, want: false},
+		{name: "no never restarts", command: Command{Restart: RestartNo}, failed: true, want: false},
+		{name: "on-failure restarts failures", command: Command{Restart: RestartOnFailure}, failed: true, want: true},
+		{name: "on-failure skips clean exits", command: Command{Restart: RestartOnFailure}, failed: false, want: false},
+		{name: "always restarts clean exits", command: Command{Restart: RestartAlways}, failed: false, want: true},
+		{name: "under max retries", command: Command{Restart: RestartAlways, MaxRetries: 3}, attempt: 2, want: true},
+		{name: "max retries reached", command: Command{Restart: RestartAlways, MaxRetries: 3}, attempt: 3, want: false},
+		{name: "zero max retries is unlimited", command: Command{Restart: RestartAlways}, attempt: 100, want: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.command.ShouldRestart(tt.attempt, tt.failed); got != tt.want {
+				t.Fatalf("ShouldRestart(%d, %v) = %v, want %v", tt.attempt, tt.failed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandRestartDelay(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		backoff time.Duration
+		attempt int
+		want    time.Duration
+	}{
+		{name: "default first attempt", attempt: 0, want: time.Second},
+		{name: "default doubles", attempt: 3, want: 8 * time.Second},
+		{name: "custom backoff", backoff: 2 * time.Second, attempt: 1, want: 4 * time.Second},
+		{name: "capped", backoff: time.Minute, attempt: 10, want: 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			command := Command{RestartBackoff: tt.backoff}
+			if got := command.RestartDelay(tt.attempt); got != tt.want {
+				t.Fatalf("RestartDelay(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandStopDefaults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		command  Command
+		wantKeys []string
+		wantWait time.Duration
+	}{
+		{name: "defaults", command: Command{}, wantKeys: []string{"C-c"}, wantWait: 10 * time.Second},
+		{name: "stop command replaces keys", command: Command{StopCommand: "q"}, wantKeys: nil, wantWait: 10 * time.Second},
+		{name: "explicit keys", command: Command{StopKeys: []string{"C-c", "C-c"}, StopCommand: "q", StopTimeout: 3 * time.Second}, wantKeys: []string{"C-c", "C-c"}, wantWait: 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.command.StopKeySequence(); !reflect.DeepEqual(got, tt.wantKeys) {
+				t.Fatalf("StopKeySequence() = %v, want %v", got, tt.wantKeys)
+			}
+			if got := tt.command.StopWait(); got != tt.wantWait {
+				t.Fatalf("StopWait() = %v, want %v", got, tt.wantWait)
+			}
+		})
+	}
+}
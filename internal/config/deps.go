@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CommandStartOrder returns the commands that must run for the named command,
+// dependencies first and the command itself last.
+func CommandStartOrder(folder Folder, name string) ([]Command, error) {
+	g := newCommandGraph(folder)
+	key := Slug(name)
+	if _, ok := g.byKey[key]; !ok {
+		return nil, fmt.Errorf("folder %q has no command %q", folder.Name, name)
+	}
+	if err := g.visit(key, nil); err != nil {
+		return nil, err
+	}
+	return g.order, nil
+}
+
+// FolderStartOrder returns every command in the folder in dependency order.
+// Independent commands keep their configured order.
+func FolderStartOrder(folder Folder) ([]Command, error) {
+	g := newCommandGraph(folder)
+	for _, command := range folder.Commands {
+		if err := g.visit(Slug(command.Name), nil); err != nil {
+			return nil, err
+		}
+	}
+	return g.order, nil
+}
+
+func validateCommandDeps(folder Folder) error {
+	_, err := FolderStartOrder(folder)
+	return err
+}
+
+type commandGraph struct {
+	folder Folder
+	byKey  map[string]Command
+	state  map[string]int // 0 unvisited, 1 visiting, 2 done
+	order  []Command
+}
+
+func newCommandGraph(folder Folder) *commandGraph {
+	g := &commandGraph{
+		folder: folder,
+		byKey:  make(map[string]Command, len(folder.Commands)),
+		state:  make(map[string]int, len(folder.Commands)),
+	}
+	for _, command := range folder.Commands {
+		g.byKey[Slug(command.Name)] = command
+	}
+	return g
+}
+
+func (g *commandGraph) visit(key string, path []string) error {
+	command := g.byKey[key]
+	switch g.state[key] {
+	case 2:
+		return nil
+	case 1:
+		cycle := append(path, command.Name)
+		for i, name := range path {
+			if Slug(name) == key {
+				cycle = cycle[i:]
+				break
+			}
+		}
+		return fmt.Errorf("folder %q has a command dependency cycle: %s", g.folder.Name, strings.Join(cycle, " -> "))
+	}
+
+	g.state[key] = 1
+	path = append(path, command.Name)
+	for _, dep := range command.DependsOn {
+		depKey := Slug(dep)
+		if _, ok := g.byKey[depKey]; !ok {
+			return fmt.Errorf("folder %q command %q depends on unknown command %q", g.folder.Name, command.Name, dep)
+		}
+		if err := g.visit(depKey, path); err != nil {
+			return err
+		}
+	}
+	g.state[key] = 2
+	g.order = append(g.order, command)
+	return nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func commandOrderNames(commands []Command) string {
+	names := make([]string, 0, len(commands))
+	for _, command := range commands {
+		names = append(names, command.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestCommandStartOrder(t *testing.T) {
+	t.Parallel()
+
+	folder := Folder{Name: "API", Commands: []Command{
+		{Name: "web", Command: "make web", DependsOn: []string{"api", "redis"}},
+		{Name: "api", Command: "make api", DependsOn: []string{"db"}},
+		{Name: "db", Command: "make db"},
+		{Name: "redis", Command: "make redis"},
+		{Name: "docs", Command: "make docs"},
+	}}
+
+	got, err := CommandStartOrder(folder, "web")
+	if err != nil {
+		t.Fatalf("CommandStartOrder() error = %v", err)
+	}
+	if names := commandOrderNames(got); names != "db,api,redis,web" {
+		t.Fatalf("CommandStartOrder(web) = %s, want db,api,redis,web", names)
+	}
+
+	all, err := FolderStartOrder(folder)
+	if err != nil {
+		t.Fatalf("FolderStartOrder() error = %v", err)
+	}
+	if names := commandOrderNames(all); names != "db,api,redis,web,docs" {
+		t.Fatalf("FolderStartOrder() = %s, want db,api,redis,web,docs", names)
+	}
+}
+
+func TestConfigNormalizeRejectsBadDependencies(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		commands []Command
+		wantErr  string
+	}{
+		{
+			name:     "unknown dependency",
+			commands: []Command{{Name: "web", Command: "make web", DependsOn: []string{"db"}}},
+			wantErr:  `folder "API" command "web" depends on unknown command "db"`,
+		},
+		{
+			name: "cycle",
+			commands: []Command{
+				{Name: "web", Command: "make web", DependsOn: []string{"api"}},
+				{Name: "api", Command: "make api", DependsOn: []string{"db"}},
+				{Name: "db", Command: "make db", DependsOn: []string{"api"}},
+			},
+			wantErr: `folder "API" has a command dependency cycle: api -> db -> api`,
+		},
+		{
+			name:     "self dependency",
+			commands: []Command{{Name: "web", Command: "make web", DependsOn: []string{" web "}}},
+			wantErr:  "cycle: web -> web",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := Config{Folders: []Folder{{Name: "API", Path: "./api", Commands: tt.commands}}}
+			err := cfg.Normalize(t.TempDir())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Normalize() error = %v, want contains %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TerminalEnv returns the environment for plain terminals in the folder.
+func (f Folder) TerminalEnv() ([]string, error) {
+	return resolveEnv(envLayer{file: f.EnvFile, vars: f.Env})
+}
+
+// AgentEnv returns the environment for an agent launched in the folder.
+// Folder values come first and are overridden by the agent's own.
+func (f Folder) AgentEnv(agent Agent) ([]string, error) {
+	return resolveEnv(envLayer{file: f.EnvFile, vars: f.Env}, envLayer{file: agent.EnvFile, vars: agent.Env})
+}
+
+// CommandEnv returns the environment for a managed command in the folder.
+// Folder values come first and are overridden by the command's own.
+func (f Folder) CommandEnv(command Command) ([]string, error) {
+	return resolveEnv(envLayer{file: f.EnvFile, vars: f.Env}, envLayer{file: command.EnvFile, vars: command.Env})
+}
+
+// TaskEnv returns the environment for a task in the folder. Folder values
+// come first and are overridden by the task's own.
+func (f Folder) TaskEnv(task Task) ([]string, error) {
+	return resolveEnv(envLayer{file: f.EnvFile, vars: f.Env}, envLayer{file: task.EnvFile, vars: task.Env})
+}
+
+type envLayer struct {
+	file string
+	vars map[string]string
+}
+
+// resolveEnv merges layers in order. Within a layer the env table overrides
+// values read from the env file. The result is sorted KEY=VALUE pairs.
+func resolveEnv(layers ...envLayer) ([]string, error) {
+	merged := map[string]string{}
+	for _, layer := range layers {
+		if layer.file != "" {
+			fileVars, err := ReadEnvFile(layer.file)
+			if err != nil {
+				return nil, err
+			}
+			for key, value := range fileVars {
+				merged[key] = value
+			}
+		}
+		for key, value := range layer.vars {
+			merged[key] = value
+		}
+	}
+
+	env := make([]string, 0, len(merged))
+	for key, value := range merged {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// ReadEnvFile parses a dotenv-style file: KEY=VALUE lines, blank lines and
+// # comments, an optional "export " prefix, and single- or double-quoted
+// values.
+func ReadEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read env file %q: %w", path, err)
+	}
+	defer file.Close()
+
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !validEnvKey(key) {
+			return nil, fmt.Errorf("env file %q line %d: expected KEY=VALUE", path, lineNo)
+		}
+
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("env file %q line %d: %w", path, lineNo, err)
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		vars[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read env file %q: %w", path, err)
+	}
+	return vars, nil
+}
+
+func normalizeEnv(env map[string]string, envFile *string, baseDir, scope string) error {
+	for key := range env {
+		if !validEnvKey(key) {
+			return fmt.Errorf("%s env key %q is invalid", scope, key)
+		}
+	}
+
+	*envFile = strings.TrimSpace(*envFile)
+	if *envFile == "" {
+		return nil
+	}
+	*envFile = ExpandHome(*envFile)
+	if !filepath.IsAbs(*envFile) {
+		*envFile = filepath.Join(baseDir, *envFile)
+	}
+	*envFile = filepath.Clean(*envFile)
+	return nil
+}
+
+func validEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, r := range key {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
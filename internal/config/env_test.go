@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".env")
+	content := strings.Join([]string{
+		"# database",
+		"DATABASE_URL=postgres://localhost/dev",
+		"export PORT=3000",
+		`GREETING="hello\nworld"`,
+		"RAW='$HOME stays literal'",
+		"LOG_LEVEL=debug # trailing comment",
+		"",
+	}, "\n")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	got, err := ReadEnvFile(path)
+	if err != nil {
+		t.Fatalf("ReadEnvFile() error = %v", err)
+	}
+	want := map[string]string{
+		"DATABASE_URL": "postgres://localhost/dev",
+		"PORT":         "3000",
+		"GREETING":     "hello\nworld",
+		"RAW":          "$HOME stays literal",
+		"LOG_LEVEL":    "debug",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadEnvFile() = %#v, want %#v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Fatalf("ReadEnvFile()[%s] = %q, want %q", key, got[key], value)
+		}
+	}
+}
+
+func TestReadEnvFileRejectsMalformedLine(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("OK=1\nnot a pair\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	_, err := ReadEnvFile(path)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("ReadEnvFile() error = %v, want line 2 error", err)
+	}
+}
+
+func TestCommandEnvLayersFolderThenCommand(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	folderEnv := filepath.Join(dir, "folder.env")
+	commandEnv := filepath.Join(dir, "command.env")
+	if err := os.WriteFile(folderEnv, []byte("A=folder-file\nB=folder-file\nC=folder-file\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.WriteFile(commandEnv, []byte("C=command-file\nD=command-file\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	folder := Folder{
+		EnvFile: folderEnv,
+		Env:     map[string]string{"B": "folder-table"},
+	}
+	command := Command{
+		EnvFile: commandEnv,
+		Env:     map[string]string{"D": "command-table"},
+	}
+
+	got, err := folder.CommandEnv(command)
+	if err != nil {
+		t.Fatalf("CommandEnv() error = %v", err)
+	}
+	want := "A=folder-file B=folder-table C=command-file D=command-table"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("CommandEnv() = %v, want %s", got, want)
+	}
+}
+
+func TestConfigNormalizeResolvesEnvFiles(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	cfg := Config{
+		Agents: []Agent{{Name: "Codex", Command: "codex", EnvFile: "agents.env"}},
+		Folders: []Folder{{
+			Name:     "API",
+			Path:     "./api",
+			EnvFile:  " .env ",
+			Commands: []Command{{Name: "start", Command: "make start", EnvFile: "config/dev.env"}},
+		}},
+	}
+
+	if err := cfg.Normalize(base); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+
+	if got, want := cfg.Agents[0].EnvFile, filepath.Join(base, "agents.env"); got != want {
+		t.Fatalf("global agent EnvFile = %q, want %q", got, want)
+	}
+	folder := cfg.Folders[0]
+	if got, want := folder.EnvFile, filepath.Join(base, "api", ".env"); got != want {
+		t.Fatalf("folder EnvFile = %q, want %q", got, want)
+	}
+	if got, want := folder.Commands[0].EnvFile, filepath.Join(base, "api", "config", "dev.env"); got != want {
+		t.Fatalf("command EnvFile = %q, want %q", got, want)
+	}
+}
+
+func TestConfigNormalizeRejectsInvalidEnvKey(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{Folders: []Folder{{
+		Name:     "API",
+		Path:     "./api",
+		Commands: []Command{{Name: "start", Command: "make start", Env: map[string]string{"BAD-KEY": "1"}}},
+	}}}
+
+	err := cfg.Normalize(t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), `folder[0] command[0] env key "BAD-KEY" is invalid`) {
+		t.Fatalf("Normalize() error = %v, want invalid env key", err)
+	}
+}
@@ -82,8 +82,12 @@ func AppendFolder(cfg *Config, folder Folder) error {
 		return fmt.Errorf("folder path is required")
 	}
 	prepared.EditorCommand = strings.TrimSpace(folder.EditorCommand)
+	prepared.TrustProjectSchedules = folder.TrustProjectSchedules
+	prepared.Env = folder.Env
+	prepared.EnvFile = strings.TrimSpace(folder.EnvFile)
 	prepared.Agents = append([]Agent(nil), folder.Agents...)
 	prepared.Commands = append([]Command(nil), folder.Commands...)
+	prepared.Tasks = append([]Task(nil), folder.Tasks...)
 	cfg.Folders = append(cfg.Folders, prepared)
 	return nil
 }
@@ -142,3 +146,151 @@ func AppendCommand(cfg *Config, folderIndex int, command Command) error {
 	cfg.Folders[folderIndex].Commands = append(commands, prepared)
 	return nil
 }
+
+// UpdateFolder changes a folder's name, path and editor command. Renaming
+// the folder moves it to the namespace of its new name.
+func UpdateFolder(cfg *Config, folderIndex int, folder Folder) error {
+	if folderIndex < 0 || folderIndex >= len(cfg.Folders) {
+		return fmt.Errorf("select a folder")
+	}
+
+	others := make([]Folder, 0, len(cfg.Folders)-1)
+	others = append(others, cfg.Folders[:folderIndex]...)
+	others = append(others, cfg.Folders[folderIndex+1:]...)
+	prepared, err := PrepareFolderName(folder.Name, others)
+	if err != nil {
+		return err
+	}
+	path := strings.TrimSpace(folder.Path)
+	if path == "" {
+		return fmt.Errorf("folder path is required")
+	}
+
+	folders := append([]Folder(nil), cfg.Folders...)
+	updated := folders[folderIndex]
+	updated.Name = prepared.Name
+	updated.Namespace = prepared.Namespace
+	updated.Path = path
+	updated.EditorCommand = strings.TrimSpace(folder.EditorCommand)
+	folders[folderIndex] = updated
+	cfg.Folders = folders
+	return nil
+}
+
+// RemoveFolder drops a folder and everything configured under it.
+func RemoveFolder(cfg *Config, folderIndex int) error {
+	if folderIndex < 0 || folderIndex >= len(cfg.Folders) {
+		return fmt.Errorf("select a folder")
+	}
+	folders := make([]Folder, 0, len(cfg.Folders)-1)
+	folders = append(folders, cfg.Folders[:folderIndex]...)
+	cfg.Folders = append(folders, cfg.Folders[folderIndex+1:]...)
+	return nil
+}
+
+// RemoveAgent drops an agent from a folder.
+func RemoveAgent(cfg *Config, folderIndex int, name string) error {
+	if folderIndex < 0 || folderIndex >= len(cfg.Folders) {
+		return fmt.Errorf("select a folder")
+	}
+	folder := cfg.Folders[folderIndex]
+	index := -1
+	for i, agent := range folder.Agents {
+		if Slug(agent.Name) == Slug(name) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("agent %q not found", name)
+	}
+	if folder.Agents[index].Source == SourceProject {
+		return fmt.Errorf("agent %q is defined in %s", name, ProjectFile)
+	}
+
+	agents := make([]Agent, 0, len(folder.Agents)-1)
+	agents = append(agents, folder.Agents[:index]...)
+	cfg.Folders[folderIndex].Agents = append(agents, folder.Agents[index+1:]...)
+	return nil
+}
+
+// UpdateCommand replaces the named command. A rename is carried over to the
+// depends_on lists of the folder's other commands.
+func UpdateCommand(cfg *Config, folderIndex int, name string, command Command) error {
+	index, err := userCommandIndex(cfg, folderIndex, name)
+	if err != nil {
+		return err
+	}
+	prepared, err := PrepareCommand(command)
+	if err != nil {
+		return err
+	}
+
+	folder := cfg.Folders[folderIndex]
+	oldKey, newKey := Slug(folder.Commands[index].Name), Slug(prepared.Name)
+	if newKey != oldKey && CommandNameExists(folder, prepared.Name) {
+		return fmt.Errorf("command name already exists")
+	}
+
+	commands := append([]Command(nil), folder.Commands...)
+	commands[index] = prepared
+	if newKey != oldKey {
+		for i, other := range commands {
+			renamed := false
+			deps := append([]string(nil), other.DependsOn...)
+			for j, dep := range deps {
+				if Slug(dep) == oldKey {
+					deps[j] = prepared.Name
+					renamed = true
+				}
+			}
+			if renamed {
+				commands[i].DependsOn = deps
+			}
+		}
+	}
+	cfg.Folders[folderIndex].Commands = commands
+	return nil
+}
+
+// RemoveCommand drops the named command. Commands that other commands depend
+// on cannot be removed.
+func RemoveCommand(cfg *Config, folderIndex int, name string) error {
+	index, err := userCommandIndex(cfg, folderIndex, name)
+	if err != nil {
+		return err
+	}
+
+	folder := cfg.Folders[folderIndex]
+	key := Slug(folder.Commands[index].Name)
+	for _, other := range folder.Commands {
+		for _, dep := range other.DependsOn {
+			if Slug(dep) == key {
+				return fmt.Errorf("command %q is needed by %q", folder.Commands[index].Name, other.Name)
+			}
+		}
+	}
+
+	commands := make([]Command, 0, len(folder.Commands)-1)
+	commands = append(commands, folder.Commands[:index]...)
+	cfg.Folders[folderIndex].Commands = append(commands, folder.Commands[index+1:]...)
+	return nil
+}
+
+// userCommandIndex finds a command that can be changed through the user
+// config; commands merged from a project file are edited there instead.
+func userCommandIndex(cfg *Config, folderIndex int, name string) (int, error) {
+	if folderIndex < 0 || folderIndex >= len(cfg.Folders) {
+		return -1, fmt.Errorf("select a folder")
+	}
+	for i, command := range cfg.Folders[folderIndex].Commands {
+		if Slug(command.Name) != Slug(name) {
+			continue
+		}
+		if command.Source == SourceProject {
+			return -1, fmt.Errorf("command %q is defined in %s", name, ProjectFile)
+		}
+		return i, nil
+	}
+	return -1, fmt.Errorf("command %q not found", name)
+}
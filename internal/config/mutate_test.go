@@ -92,3 +92,122 @@ func TestAppendCommand(t *testing.T) {
 		t.Fatalf("duplicate AppendCommand() error = %v, want command name already exists", err)
 	}
 }
+
+func TestUpdateFolder(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{Folders: []Folder{
+		{Name: "API", Namespace: "api", Path: "/tmp/api", Commands: []Command{{Name: "start", Command: "make start"}}},
+		{Name: "Web", Namespace: "web", Path: "/tmp/web"},
+	}}
+	if err := UpdateFolder(&cfg, 0, Folder{Name: " Backend ", Path: " /srv/api ", EditorCommand: " zed . "}); err != nil {
+		t.Fatalf("UpdateFolder() error = %v", err)
+	}
+	got := cfg.Folders[0]
+	if got.Name != "Backend" || got.Namespace != "backend" || got.Path != "/srv/api" || got.EditorCommand != "zed ." {
+		t.Fatalf("folder = %#v, want renamed and repathed folder", got)
+	}
+	if len(got.Commands) != 1 {
+		t.Fatalf("commands = %#v, want commands kept", got.Commands)
+	}
+
+	if err := UpdateFolder(&cfg, 0, Folder{Name: "Backend", Path: "/srv/api"}); err != nil {
+		t.Fatalf("UpdateFolder() keeping own name error = %v", err)
+	}
+	err := UpdateFolder(&cfg, 0, Folder{Name: "web", Path: "/srv/api"})
+	if err == nil || !strings.Contains(err.Error(), `namespace "web" already exists`) {
+		t.Fatalf("UpdateFolder() error = %v, want namespace already exists", err)
+	}
+	if err := UpdateFolder(&cfg, 0, Folder{Name: "Backend", Path: " "}); err == nil || err.Error() != "folder path is required" {
+		t.Fatalf("UpdateFolder() error = %v, want folder path is required", err)
+	}
+}
+
+func TestRemoveFolder(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{Folders: []Folder{{Name: "API"}, {Name: "Web"}, {Name: "Docs"}}}
+	original := cfg.Folders
+	if err := RemoveFolder(&cfg, 1); err != nil {
+		t.Fatalf("RemoveFolder() error = %v", err)
+	}
+	if len(cfg.Folders) != 2 || cfg.Folders[0].Name != "API" || cfg.Folders[1].Name != "Docs" {
+		t.Fatalf("folders = %#v, want Web removed", cfg.Folders)
+	}
+	if original[1].Name != "Web" {
+		t.Fatalf("original folders modified: %#v", original)
+	}
+	if err := RemoveFolder(&cfg, 2); err == nil || err.Error() != "select a folder" {
+		t.Fatalf("RemoveFolder() error = %v, want select a folder", err)
+	}
+}
+
+func TestRemoveAgent(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{Folders: []Folder{{Name: "API", Agents: []Agent{
+		{Name: "Codex", Command: "codex"},
+		{Name: "Amp", Command: "amp", Source: SourceProject},
+	}}}}
+	if err := RemoveAgent(&cfg, 0, "codex"); err != nil {
+		t.Fatalf("RemoveAgent() error = %v", err)
+	}
+	if len(cfg.Folders[0].Agents) != 1 || cfg.Folders[0].Agents[0].Name != "Amp" {
+		t.Fatalf("agents = %#v, want Codex removed", cfg.Folders[0].Agents)
+	}
+	if err := RemoveAgent(&cfg, 0, "Amp"); err == nil || err.Error() != `agent "Amp" is defined in .grove.toml` {
+		t.Fatalf("RemoveAgent(project) error = %v, want defined in .grove.toml", err)
+	}
+	if err := RemoveAgent(&cfg, 0, "Pi"); err == nil || err.Error() != `agent "Pi" not found` {
+		t.Fatalf("RemoveAgent(missing) error = %v, want not found", err)
+	}
+}
+
+func TestUpdateCommandRenamesDependencies(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{Folders: []Folder{{Name: "API", Commands: []Command{
+		{Name: "db", Command: "postgres"},
+		{Name: "web", Command: "make web", DependsOn: []string{"db"}},
+		{Name: "worker", Command: "make worker"},
+	}}}}
+	if err := UpdateCommand(&cfg, 0, "db", Command{Name: " Postgres ", Command: " postgres -D data "}); err != nil {
+		t.Fatalf("UpdateCommand() error = %v", err)
+	}
+	commands := cfg.Folders[0].Commands
+	if commands[0].Name != "Postgres" || commands[0].Command != "postgres -D data" {
+		t.Fatalf("commands[0] = %#v, want updated command", commands[0])
+	}
+	if got := commands[1].DependsOn; len(got) != 1 || got[0] != "Postgres" {
+		t.Fatalf("web depends_on = %v, want renamed dependency", got)
+	}
+
+	if err := UpdateCommand(&cfg, 0, "worker", Command{Name: "web", Command: "x"}); err == nil || err.Error() != "command name already exists" {
+		t.Fatalf("UpdateCommand() error = %v, want command name already exists", err)
+	}
+	if err := UpdateCommand(&cfg, 0, "missing", Command{Name: "x", Command: "x"}); err == nil || err.Error() != `command "missing" not found` {
+		t.Fatalf("UpdateCommand() error = %v, want not found", err)
+	}
+}
+
+func TestRemoveCommand(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{Folders: []Folder{{Name: "API", Commands: []Command{
+		{Name: "db", Command: "postgres"},
+		{Name: "web", Command: "make web", DependsOn: []string{"db"}},
+		{Name: "seed", Command: "make seed", Source: SourceProject},
+	}}}}
+	if err := RemoveCommand(&cfg, 0, "db"); err == nil || err.Error() != `command "db" is needed by "web"` {
+		t.Fatalf("RemoveCommand(db) error = %v, want needed by web", err)
+	}
+	if err := RemoveCommand(&cfg, 0, "seed"); err == nil || err.Error() != `command "seed" is defined in .grove.toml` {
+		t.Fatalf("RemoveCommand(seed) error = %v, want defined in .grove.toml", err)
+	}
+	if err := RemoveCommand(&cfg, 0, "web"); err != nil {
+		t.Fatalf("RemoveCommand(web) error = %v", err)
+	}
+	if got := cfg.Folders[0].Commands; len(got) != 2 || got[0].Name != "db" || got[1].Name != "seed" {
+		t.Fatalf("commands = %#v, want web removed", got)
+	}
+}
@@ -0,0 +1,118 @@
+package config
+
+import "fmt"
+
+// ProjectFile is the repo-local config that a folder's path may contain.
+const ProjectFile = ".grove.toml"
+
+// SourceProject marks agents, commands and tasks merged from a folder's ProjectFile
+// rather than defined in the user config.
+const SourceProject = "project"
+
+// Project is the content of a ProjectFile.
+type Project struct {
+	Agents   []Agent   `toml:"agent"`
+	Commands []Command `toml:"command"`
+	Tasks    []Task    `toml:"task"`
+}
+
+// ProjectLoader returns the project config for a folder whose path has been
+// resolved. ok is false when the folder has none; path names the file in
+// error messages.
+type ProjectLoader func(folder Folder) (project Project, path string, ok bool, err error)
+
+// mergeProject adds the project's agents, commands and tasks to the folder. Entries
+// the user config already defines win, so a shared command can be overridden
+// locally.
+func (f *Folder) mergeProject(project Project, path string) error {
+	for i, agent := range project.Agents {
+		scope := fmt.Sprintf("%s agent[%d]", path, i)
+		if err := normalizeAgent(&agent, scope); err != nil {
+			return err
+		}
+		if err := normalizeEnv(agent.Env, &agent.EnvFile, f.Path, scope); err != nil {
+			return err
+		}
+		if agentNameExists(*f, agent.Name) {
+			continue
+		}
+		agent.Source = SourceProject
+		f.Agents = append(f.Agents, agent)
+	}
+
+	for i, command := range project.Commands {
+		scope := fmt.Sprintf("%s command[%d]", path, i)
+		if err := normalizeCommand(&command, scope); err != nil {
+			return err
+		}
+		if err := normalizeEnv(command.Env, &command.EnvFile, f.Path, scope); err != nil {
+			return err
+		}
+		if CommandNameExists(*f, command.Name) {
+			continue
+		}
+		command.Source = SourceProject
+		f.Commands = append(f.Commands, command)
+	}
+
+	for i, task := range project.Tasks {
+		scope := fmt.Sprintf("%s task[%d]", path, i)
+		if err := normalizeTask(&task, scope); err != nil {
+			return err
+		}
+		if err := normalizeEnv(task.Env, &task.EnvFile, f.Path, scope); err != nil {
+			return err
+		}
+		if TaskNameExists(*f, task.Name) {
+			continue
+		}
+		if !f.TrustProjectSchedules {
+			task.IgnoredSchedule, task.Schedule = task.Schedule, ""
+		}
+		task.Source = SourceProject
+		f.Tasks = append(f.Tasks, task)
+	}
+	return nil
+}
+
+// WithoutProjectEntries returns a copy of the config without merged project
+// entries, which is what gets written back to the user config.
+func (c Config) WithoutProjectEntries() Config {
+	out := c
+	out.Folders = make([]Folder, len(c.Folders))
+	for i, folder := range c.Folders {
+		var agents []Agent
+		for _, agent := range folder.Agents {
+			if agent.Source != SourceProject {
+				agents = append(agents, agent)
+			}
+		}
+		var commands []Command
+		for _, command := range folder.Commands {
+			if command.Source != SourceProject {
+				commands = append(commands, command)
+			}
+		}
+		var tasks []Task
+		for _, task := range folder.Tasks {
+			if task.Source != SourceProject {
+				tasks = append(tasks, task)
+			}
+		}
+		folder.Agents = agents
+		folder.Commands = commands
+		folder.Tasks = tasks
+		out.Folders[i] = folder
+	}
+	return out
+}
+
+func agentNameExists(folder Folder, name string) bool {
+	key := Slug(name)
+	for _, existing := range folder.Agents {
+		if Slug(existing.Name) == key {
+			return true
+		}
+	}
+	return false
+}
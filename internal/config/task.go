@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SarthakJariwala/grove/internal/cron"
+)
+
+// Task is a job that runs to completion, unlike a long-running Command. It
+// is started by hand, or by grove whenever its Schedule comes due.
+type Task struct {
+	Name    string `toml:"name"`
+	Command string `toml:"command"`
+	// Schedule is a five-field cron expression such as "0 3 * * *", or a
+	// macro such as @hourly. A task without one only runs when started.
+	Schedule string            `toml:"schedule,omitempty"`
+	Env      map[string]string `toml:"env,omitempty"`
+	EnvFile  string            `toml:"env_file,omitempty"`
+	Source   string            `toml:"-"`
+	// IgnoredSchedule is the schedule of a project task in a folder that
+	// does not trust project schedules. It is shown but never run.
+	IgnoredSchedule string `toml:"-"`
+}
+
+// NextRun returns when the task is next due after t, or the zero time when
+// it has no schedule.
+func (t Task) NextRun(after time.Time) time.Time {
+	if t.Schedule == "" {
+		return time.Time{}
+	}
+	schedule, err := cron.Parse(t.Schedule)
+	if err != nil {
+		return time.Time{}
+	}
+	return schedule.Next(after)
+}
+
+func normalizeTask(task *Task, scope string) error {
+	task.Name = strings.TrimSpace(task.Name)
+	task.Command = strings.TrimSpace(task.Command)
+	task.Schedule = strings.TrimSpace(task.Schedule)
+	if task.Name == "" {
+		return fmt.Errorf("%s name is required", scope)
+	}
+	if task.Command == "" {
+		return fmt.Errorf("%s command is required", scope)
+	}
+	if task.Schedule != "" {
+		if _, err := cron.Parse(task.Schedule); err != nil {
+			return fmt.Errorf("%s schedule: %w", scope, err)
+		}
+	}
+	return nil
+}
+
+// validateTaskNames rejects tasks whose names share a session name.
+func validateTaskNames(folder Folder) error {
+	seen := map[string]string{}
+	for _, task := range folder.Tasks {
+		key := Slug(task.Name)
+		if existing, ok := seen[key]; ok {
+			return fmt.Errorf("folder %q tasks %q and %q have the same name", folder.Name, existing, task.Name)
+		}
+		seen[key] = task.Name
+	}
+	return nil
+}
+
+func TaskNameExists(folder Folder, name string) bool {
+	key := Slug(name)
+	if key == "" {
+		return false
+	}
+	for _, existing := range folder.Tasks {
+		if Slug(existing.Name) == key {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,9 @@
 package configfile
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,32 +14,109 @@ import (
 )
 
 func Load(path string) (config.Config, error) {
+	cfg, _, err := LoadVersion(path)
+	return cfg, err
+}
+
+// LoadVersion loads the config along with the Version it was read at, so
+// callers can tell when the file changes underneath them.
+func LoadVersion(path string) (config.Config, Version, error) {
 	resolvedPath, err := resolveConfigPath(path)
 	if err != nil {
-		return config.Config{}, err
+		return config.Config{}, Version{}, err
+	}
+
+	data, err := os.ReadFile(resolvedPath)
+	if err != nil {
+		return config.Config{}, Version{}, fmt.Errorf("decode config %q: %w", resolvedPath, err)
+	}
+	cfg, err := decodeConfig(resolvedPath, data)
+	if err != nil {
+		return config.Config{}, Version{}, err
 	}
+	return cfg, versionOf(data), nil
+}
 
+func decodeConfig(resolvedPath string, data []byte) (config.Config, error) {
 	var cfg config.Config
-	if _, err := toml.DecodeFile(resolvedPath, &cfg); err != nil {
+	if _, err := toml.Decode(string(data), &cfg); err != nil {
 		return config.Config{}, fmt.Errorf("decode config %q: %w", resolvedPath, err)
 	}
 
-	if err := cfg.Normalize(filepath.Dir(resolvedPath)); err != nil {
+	if err := cfg.NormalizeWithProjects(filepath.Dir(resolvedPath), loadProjectFile); err != nil {
 		return config.Config{}, err
 	}
 
 	return cfg, nil
 }
 
+// loadProjectFile reads the repo-local config in a folder, when present.
+func loadProjectFile(folder config.Folder) (config.Project, string, bool, error) {
+	path := filepath.Join(folder.Path, config.ProjectFile)
+	var project config.Project
+	if _, err := toml.DecodeFile(path, &project); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return config.Project{}, path, false, nil
+		}
+		return config.Project{}, path, false, fmt.Errorf("decode project config %q: %w", path, err)
+	}
+	return project, path, true, nil
+}
+
+// Save writes cfg to the user config, replacing whatever is on disk. Entries
+// merged from project files stay in their repositories and are not written.
 func Save(path string, cfg config.Config) error {
 	resolvedPath, err := resolveConfigPath(path)
 	if err != nil {
 		return err
 	}
+	data, err := encodeConfig(resolvedPath, cfg)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(resolvedPath, data)
+}
+
+func encodeConfig(resolvedPath string, cfg config.Config) ([]byte, error) {
 	if err := cfg.Normalize(filepath.Dir(resolvedPath)); err != nil {
+		return nil, err
+	}
+	cfg = cfg.WithoutProjectEntries()
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+		return nil, fmt.Errorf("encode config %q: %w", resolvedPath, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// beforeConfigWrite lets tests change the file between the version check's
+// read and the write it guards.
+var beforeConfigWrite = func() {}
+
+// replaceConfig writes data only if the file still holds the version it was
+// read at. The check and the write happen under the config lock, so two grove
+// processes cannot both pass the check and overwrite each other.
+func replaceConfig(resolvedPath string, loaded Version, data []byte) error {
+	beforeConfigWrite()
+	unlock, err := lockConfig(resolvedPath)
+	if err != nil {
 		return err
 	}
+	defer unlock()
+	current, err := readVersion(resolvedPath)
+	if err != nil {
+		return err
+	}
+	if current != loaded {
+		return fmt.Errorf("save config %q: %w", resolvedPath, ErrChanged)
+	}
+	return writeConfigFile(resolvedPath, data)
+}
 
+// writeConfigFile replaces the config at resolvedPath through a temp file, so
+// readers never see a partial write.
+func writeConfigFile(resolvedPath string, data []byte) error {
 	dir := filepath.Dir(resolvedPath)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("create config directory %q: %w", dir, err)
@@ -55,8 +135,8 @@ func Save(path string, cfg config.Config) error {
 		_ = os.Remove(tempPath)
 	}()
 
-	if err := toml.NewEncoder(file).Encode(cfg); err != nil {
-		return fmt.Errorf("encode config %q: %w", resolvedPath, err)
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("write config %q: %w", resolvedPath, err)
 	}
 	if err := file.Close(); err != nil {
 		return fmt.Errorf("close temp config for %q: %w", resolvedPath, err)
@@ -69,6 +149,72 @@ func Save(path string, cfg config.Config) error {
 	return nil
 }
 
+// ErrChanged reports that the config file was modified by something else
+// after it was loaded.
+var ErrChanged = errors.New("config changed on disk since it was loaded")
+
+// Version identifies the contents of a config file, so callers can tell when
+// it has been changed by something else. A missing file has the zero Version.
+type Version struct {
+	Size int64
+	Sum  [sha256.Size]byte
+}
+
+func versionOf(data []byte) Version {
+	return Version{Size: int64(len(data)), Sum: sha256.Sum256(data)}
+}
+
+// Stat returns the Version of the config at path, following symlinks the same
+// way Load does.
+func Stat(path string) (Version, error) {
+	resolvedPath, err := resolveConfigPath(path)
+	if err != nil {
+		return Version{}, err
+	}
+	data, err := os.ReadFile(resolvedPath)
+	if err != nil {
+		return Version{}, fmt.Errorf("read config %q: %w", resolvedPath, err)
+	}
+	return versionOf(data), nil
+}
+
+// StatProjects returns one Version over the project files of cfg's local
+// folders, so a change to any of them, or one appearing or going away, shows
+// as a new Version.
+func StatProjects(cfg config.Config) Version {
+	sum := sha256.New()
+	var size int64
+	for _, folder := range cfg.Folders {
+		if folder.Host != "" {
+			continue
+		}
+		path := filepath.Join(folder.Path, config.ProjectFile)
+		fmt.Fprintf(sum, "%s\x00", path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			sum.Write([]byte{1})
+			continue
+		}
+		sum.Write([]byte{0})
+		sum.Write(data)
+		size += int64(len(data))
+	}
+	version := Version{Size: size}
+	sum.Sum(version.Sum[:0])
+	return version
+}
+
+func readVersion(resolvedPath string) (Version, error) {
+	data, err := os.ReadFile(resolvedPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return Version{}, nil
+	}
+	if err != nil {
+		return Version{}, fmt.Errorf("read config %q: %w", resolvedPath, err)
+	}
+	return versionOf(data), nil
+}
+
 func resolveConfigPath(path string) (string, error) {
 	resolvedPath := path
 	for i := 0; i < 32; i++ {
@@ -96,24 +242,6 @@ func resolveConfigPath(path string) (string, error) {
 	return "", fmt.Errorf("resolve symlink config %q: too many symlinks", path)
 }
 
-func AppendFolder(path string, f config.Folder) error {
-	var cfg config.Config
-	if _, err := os.Stat(path); err == nil {
-		loaded, loadErr := Load(path)
-		if loadErr != nil {
-			return loadErr
-		}
-		cfg = loaded
-	} else if !os.IsNotExist(err) {
-		return fmt.Errorf("check config %q: %w", path, err)
-	}
-
-	if err := config.AppendFolder(&cfg, f); err != nil {
-		return err
-	}
-	return Save(path, cfg)
-}
-
 func EnsureTemplate(path string) error {
 	resolvedPath, err := resolveConfigPath(path)
 	if err != nil {
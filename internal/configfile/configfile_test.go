@@ -377,3 +377,192 @@ func TestLoadNormalizesRelativePathsFromSymlinkTarget(t *testing.T) {
 		t.Fatalf("folder.Path = %q, want %q", got, wantPath)
 	}
 }
+
+func TestLoadParsesEnvTablesAndRoundTrips(t *testing.T) {
+	t.Parallel()
+
+	tmp := t.TempDir()
+	cfgPath := filepath.Join(tmp, "config.toml")
+	content := strings.Join([]string{
+		"[[folder]]",
+		"name = \"API\"",
+		"path = \".\"",
+		"env_file = \".env\"",
+		"",
+		"  [folder.env]",
+		"  RAILS_ENV = \"development\"",
+		"",
+		"  [[folder.command]]",
+		"  name = \"start\"",
+		"  command = \"make start\"",
+		"",
+		"    [folder.command.env]",
+		"    PORT = \"3000\"",
+	}, "\n")
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	folder := cfg.Folders[0]
+	if folder.Env["RAILS_ENV"] != "development" || folder.EnvFile != filepath.Join(tmp, ".env") {
+		t.Fatalf("folder env = %#v, env_file = %q; want parsed folder env", folder.Env, folder.EnvFile)
+	}
+	if folder.Commands[0].Env["PORT"] != "3000" {
+		t.Fatalf("command env = %#v, want PORT", folder.Commands[0].Env)
+	}
+
+	if err := Save(cfgPath, cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	reloaded, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() after Save() error = %v", err)
+	}
+	if reloaded.Folders[0].Commands[0].Env["PORT"] != "3000" || reloaded.Folders[0].Env["RAILS_ENV"] != "development" {
+		t.Fatalf("reloaded folder = %#v, want env preserved", reloaded.Folders[0])
+	}
+}
+
+func TestLoadMergesProjectFile(t *testing.T) {
+	t.Parallel()
+
+	tmp := t.TempDir()
+	repo := filepath.Join(tmp, "api")
+	if err := os.MkdirAll(repo, 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	project := strings.Join([]string{
+		"[[agent]]",
+		"name = \"Codex\"",
+		"command = \"codex --team\"",
+		"",
+		"[[command]]",
+		"name = \"db\"",
+		"command = \"docker compose up db\"",
+		"",
+		"[[command]]",
+		"name = \"start\"",
+		"command = \"make start-shared\"",
+		"",
+		"[[task]]",
+		"name = \"audit\"",
+		"command = \"npm audit\"",
+		"schedule = \"@daily\"",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(repo, ".grove.toml"), []byte(project), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cfgPath := filepath.Join(tmp, "config.toml")
+	content := strings.Join([]string{
+		"[[folder]]",
+		"name = \"API\"",
+		"path = \"api\"",
+		"",
+		"  [[folder.command]]",
+		"  name = \"start\"",
+		"  command = \"make start\"",
+		"  depends_on = [\"db\"]",
+	}, "\n")
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	folder := cfg.Folders[0]
+	if len(folder.Commands) != 2 || len(folder.Agents) != 1 {
+		t.Fatalf("folder = %#v, want merged project entries", folder)
+	}
+	if got := folder.Commands[0]; got.Command != "make start" || got.Source != "" {
+		t.Fatalf("commands[0] = %#v, want user command to win", got)
+	}
+	if got := folder.Commands[1]; got.Name != "db" || got.Source != config.SourceProject {
+		t.Fatalf("commands[1] = %#v, want project db command", got)
+	}
+	if got := folder.Agents[0]; got.Name != "Codex" || got.Source != config.SourceProject {
+		t.Fatalf("agents[0] = %#v, want project agent", got)
+	}
+	if len(folder.Tasks) != 1 || folder.Tasks[0].Schedule != "" || folder.Tasks[0].IgnoredSchedule != "@daily" || folder.Tasks[0].Source != config.SourceProject {
+		t.Fatalf("tasks = %#v, want project audit task with its schedule ignored", folder.Tasks)
+	}
+	trusted := strings.Replace(content, "path = \"api\"", "path = \"api\"\ntrust_project_schedules = true", 1)
+	if err := os.WriteFile(cfgPath, []byte(trusted), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	trustedCfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() trusted error = %v", err)
+	}
+	if got := trustedCfg.Folders[0].Tasks[0]; got.Schedule != "@daily" {
+		t.Fatalf("trusted task = %#v, want its schedule kept", got)
+	}
+
+	if err := Save(cfgPath, cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	b, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if strings.Contains(string(b), "docker compose") || strings.Contains(string(b), "codex") || strings.Contains(string(b), "audit") {
+		t.Fatalf("saved config = %q, should not contain project entries", b)
+	}
+}
+
+func TestLoadReportsInvalidProjectFile(t *testing.T) {
+	t.Parallel()
+
+	tmp := t.TempDir()
+	projectPath := filepath.Join(tmp, ".grove.toml")
+	if err := os.WriteFile(projectPath, []byte("[[command]]\nname = \"db\"\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	cfgPath := filepath.Join(tmp, "config.toml")
+	if err := os.WriteFile(cfgPath, []byte("[[folder]]\nname = \"API\"\npath = \".\"\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	_, err := Load(cfgPath)
+	want := projectPath + " command[0] command is required"
+	if err == nil || err.Error() != want {
+		t.Fatalf("Load() error = %v, want %q", err, want)
+	}
+}
+
+func TestStatChangesWhenConfigIsRewritten(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if _, err := Stat(path); err == nil {
+		t.Fatal("Stat() error = nil, want error for missing config")
+	}
+	if err := os.WriteFile(path, []byte("editor_command = \"vim\"\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	before, err := Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	again, err := Stat(path)
+	if err != nil || again != before {
+		t.Fatalf("Stat() = (%v, %v), want unchanged %v", again, err, before)
+	}
+
+	if err := os.WriteFile(path, []byte("editor_command = \"nvim\"\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	after, err := Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if after == before {
+		t.Fatalf("Stat() = %v after rewrite, want a new version", after)
+	}
+}
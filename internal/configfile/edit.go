@@ -0,0 +1,771 @@
+package configfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+)
+
+// A textEdit rewrites the lines of a config file, reporting false when it
+// cannot find where its change belongs.
+type textEdit func(lines []string) ([]string, bool)
+
+// AppendFolder adds a folder to the end of the config file.
+func AppendFolder(path string, f config.Folder) error {
+	return editConfig(path, func(cfg *config.Config) (textEdit, error) {
+		if err := config.AppendFolder(cfg, f); err != nil {
+			return nil, err
+		}
+		block := renderFolder(cfg.Folders[len(cfg.Folders)-1])
+		return func(lines []string) ([]string, bool) {
+			return insertBlock(lines, len(lines), block), true
+		}, nil
+	})
+}
+
+// AppendFolderAgent adds an agent to the folder with namespace. An agent with
+// the same name already in the folder leaves the file untouched.
+func AppendFolderAgent(path, namespace string, agent config.Agent) error {
+	return editConfig(path, func(cfg *config.Config) (textEdit, error) {
+		folderIndex, err := findFolder(cfg, namespace)
+		if err != nil {
+			return nil, err
+		}
+		before := len(cfg.Folders[folderIndex].Agents)
+		if err := config.AppendFolderAgent(cfg, folderIndex, agent); err != nil {
+			return nil, err
+		}
+		agents := cfg.Folders[folderIndex].Agents
+		if len(agents) == before {
+			return nil, nil
+		}
+		block := renderAgent(agents[len(agents)-1])
+		return func(lines []string) ([]string, bool) {
+			return insertIntoFolder(lines, folderIndex, block)
+		}, nil
+	})
+}
+
+// AppendCommand adds a managed command to the folder with namespace.
+func AppendCommand(path, namespace string, command config.Command) error {
+	return editConfig(path, func(cfg *config.Config) (textEdit, error) {
+		folderIndex, err := findFolder(cfg, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if err := config.AppendCommand(cfg, folderIndex, command); err != nil {
+			return nil, err
+		}
+		commands := cfg.Folders[folderIndex].Commands
+		block := renderCommand(commands[len(commands)-1])
+		return func(lines []string) ([]string, bool) {
+			return insertIntoFolder(lines, folderIndex, block)
+		}, nil
+	})
+}
+
+// UpdateFolder changes the name, path and editor command of the folder with
+// namespace, rewriting only the keys that changed.
+func UpdateFolder(path, namespace string, f config.Folder) error {
+	return editConfig(path, func(cfg *config.Config) (textEdit, error) {
+		folderIndex, err := findFolder(cfg, namespace)
+		if err != nil {
+			return nil, err
+		}
+		before := cfg.Folders[folderIndex]
+		if err := config.UpdateFolder(cfg, folderIndex, f); err != nil {
+			return nil, err
+		}
+		after := cfg.Folders[folderIndex]
+
+		var changes []keyChange
+		if after.Name != before.Name {
+			changes = append(changes, keyChange{"name", after.Name})
+		}
+		if after.Path != before.Path {
+			changes = append(changes, keyChange{"path", after.Path})
+		}
+		if after.EditorCommand != before.EditorCommand {
+			changes = append(changes, optionalKey("editor_command", after.EditorCommand))
+		}
+		if len(changes) == 0 {
+			return nil, nil
+		}
+		return func(lines []string) ([]string, bool) {
+			start, _, ok := folderSpan(lines, folderIndex)
+			if !ok {
+				return nil, false
+			}
+			return setKeys(lines, start, changes), true
+		}, nil
+	})
+}
+
+// RemoveFolder deletes the table of the folder with namespace, with its
+// agents and commands.
+func RemoveFolder(path, namespace string) error {
+	return editConfig(path, func(cfg *config.Config) (textEdit, error) {
+		folderIndex, err := findFolder(cfg, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if err := config.RemoveFolder(cfg, folderIndex); err != nil {
+			return nil, err
+		}
+		return func(lines []string) ([]string, bool) {
+			start, end, ok := folderSpan(lines, folderIndex)
+			if !ok {
+				return nil, false
+			}
+			return removeSpan(lines, start, end), true
+		}, nil
+	})
+}
+
+// RemoveAgent deletes the table of an agent in the folder with namespace.
+func RemoveAgent(path, namespace, name string) error {
+	return editConfig(path, func(cfg *config.Config) (textEdit, error) {
+		folderIndex, err := findFolder(cfg, namespace)
+		if err != nil {
+			return nil, err
+		}
+		index := -1
+		for i, agent := range cfg.Folders[folderIndex].Agents {
+			if config.Slug(agent.Name) == config.Slug(name) {
+				index = i
+				break
+			}
+		}
+		if err := config.RemoveAgent(cfg, folderIndex, name); err != nil {
+			return nil, err
+		}
+		return func(lines []string) ([]string, bool) {
+			start, end, ok := entrySpan(lines, folderIndex, "folder.agent", index)
+			if !ok {
+				return nil, false
+			}
+			return removeSpan(lines, start, end), true
+		}, nil
+	})
+}
+
+// UpdateCommand replaces a command in the folder with namespace, rewriting
+// only the keys that changed, including depends_on entries that follow a
+// rename. A rename that a project file's command depends on is refused with
+// ErrNotEditable, as only the repo can change that file.
+func UpdateCommand(path, namespace, name string, command config.Command) error {
+	return editConfig(path, func(cfg *config.Config) (textEdit, error) {
+		folderIndex, err := findFolder(cfg, namespace)
+		if err != nil {
+			return nil, err
+		}
+		before := cfg.Folders[folderIndex].Commands
+		if err := config.UpdateCommand(cfg, folderIndex, name, command); err != nil {
+			return nil, err
+		}
+		after := cfg.Folders[folderIndex].Commands
+
+		// Edits are keyed by the command's table among the user file's
+		// own; commands merged from a project file have none there.
+		edits := map[int][]keyChange{}
+		entry := 0
+		for i := range after {
+			changes, ok := commandKeyChanges(before[i], after[i])
+			if after[i].Source == config.SourceProject {
+				if !ok || len(changes) > 0 {
+					return nil, fmt.Errorf("%s command %q would change: %w", config.ProjectFile, after[i].Name, ErrNotEditable)
+				}
+				continue
+			}
+			if !ok {
+				// Env tables are not edited in place.
+				return func([]string) ([]string, bool) { return nil, false }, nil
+			}
+			if len(changes) > 0 {
+				edits[entry] = changes
+			}
+			entry++
+		}
+		if len(edits) == 0 {
+			return nil, nil
+		}
+		return func(lines []string) ([]string, bool) {
+			for i, changes := range edits {
+				start, _, ok := entrySpan(lines, folderIndex, "folder.command", i)
+				if !ok {
+					return nil, false
+				}
+				lines = setKeys(lines, start, changes)
+			}
+			return lines, true
+		}, nil
+	})
+}
+
+// RemoveCommand deletes the table of a command in the folder with namespace.
+func RemoveCommand(path, namespace, name string) error {
+	return editConfig(path, func(cfg *config.Config) (textEdit, error) {
+		folderIndex, err := findFolder(cfg, namespace)
+		if err != nil {
+			return nil, err
+		}
+		index := -1
+		for i, command := range cfg.Folders[folderIndex].Commands {
+			if config.Slug(command.Name) == config.Slug(name) {
+				index = i
+				break
+			}
+		}
+		if err := config.RemoveCommand(cfg, folderIndex, name); err != nil {
+			return nil, err
+		}
+		return func(lines []string) ([]string, bool) {
+			start, end, ok := entrySpan(lines, folderIndex, "folder.command", index)
+			if !ok {
+				return nil, false
+			}
+			return removeSpan(lines, start, end), true
+		}, nil
+	})
+}
+
+// findFolder locates the folder with namespace in the config as it was just
+// read, which may have gained or lost folders since the caller loaded it.
+func findFolder(cfg *config.Config, namespace string) (int, error) {
+	for i, folder := range cfg.Folders {
+		if folder.Namespace == namespace {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("folder %q is no longer in the config", namespace)
+}
+
+// commandKeyChanges lists the keys to rewrite to turn before into after. It
+// reports false when the env table changed.
+func commandKeyChanges(before, after config.Command) ([]keyChange, bool) {
+	if !reflect.DeepEqual(before.Env, after.Env) {
+		return nil, false
+	}
+	var changes []keyChange
+	if after.Name != before.Name {
+		changes = append(changes, keyChange{"name", after.Name})
+	}
+	if after.Command != before.Command {
+		changes = append(changes, keyChange{"command", after.Command})
+	}
+	if !reflect.DeepEqual(after.DependsOn, before.DependsOn) {
+		change := keyChange{"depends_on", nil}
+		if len(after.DependsOn) > 0 {
+			change.value = after.DependsOn
+		}
+		changes = append(changes, change)
+	}
+	if after.Restart != before.Restart {
+		changes = append(changes, optionalKey("restart", after.Restart))
+	}
+	if after.RestartBackoff != before.RestartBackoff {
+		change := keyChange{"restart_backoff", nil}
+		if after.RestartBackoff != 0 {
+			change.value = after.RestartBackoff
+		}
+		changes = append(changes, change)
+	}
+	if after.MaxRetries != before.MaxRetries {
+		change := keyChange{"max_retries", nil}
+		if after.MaxRetries != 0 {
+			change.value = after.MaxRetries
+		}
+		changes = append(changes, change)
+	}
+	if !reflect.DeepEqual(after.StopKeys, before.StopKeys) {
+		change := keyChange{"stop_keys", nil}
+		if len(after.StopKeys) > 0 {
+			change.value = after.StopKeys
+		}
+		changes = append(changes, change)
+	}
+	if after.StopCommand != before.StopCommand {
+		changes = append(changes, optionalKey("stop_command", after.StopCommand))
+	}
+	if after.StopTimeout != before.StopTimeout {
+		change := keyChange{"stop_timeout", nil}
+		if after.StopTimeout != 0 {
+			change.value = after.StopTimeout
+		}
+		changes = append(changes, change)
+	}
+	if after.EnvFile != before.EnvFile {
+		changes = append(changes, optionalKey("env_file", after.EnvFile))
+	}
+	return changes, true
+}
+
+func optionalKey(key, value string) keyChange {
+	if value == "" {
+		return keyChange{key, nil}
+	}
+	return keyChange{key, value}
+}
+
+// editAttempts bounds how often an edit is re-applied when another writer
+// keeps changing the file underneath it.
+const editAttempts = 3
+
+// editConfig applies a mutation to the config at path as a text edit, so
+// comments, ordering and formatting elsewhere in the file are kept. If the
+// edited file would not decode to the mutated config, it is rewritten in full
+// instead, but only when that loses nothing: a file with comments or a layout
+// of its own is left alone and ErrNotEditable returned. When the file changes
+// between reading and writing, the mutation is re-applied to the new
+// contents.
+func editConfig(path string, mutate func(cfg *config.Config) (textEdit, error)) error {
+	var err error
+	for attempt := 0; attempt < editAttempts; attempt++ {
+		if err = tryEditConfig(path, mutate); !errors.Is(err, ErrChanged) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w; gave up after %d attempts", err, editAttempts)
+}
+
+func tryEditConfig(path string, mutate func(cfg *config.Config) (textEdit, error)) error {
+	resolvedPath, err := resolveConfigPath(path)
+	if err != nil {
+		return err
+	}
+
+	var cfg config.Config
+	var version Version
+	data, err := os.ReadFile(resolvedPath)
+	switch {
+	case err == nil:
+		if cfg, err = decodeConfig(resolvedPath, data); err != nil {
+			return err
+		}
+		version = versionOf(data)
+	case errors.Is(err, os.ErrNotExist):
+	default:
+		return fmt.Errorf("read config %q: %w", resolvedPath, err)
+	}
+
+	edit, err := mutate(&cfg)
+	if err != nil || edit == nil {
+		return err
+	}
+
+	var out []byte
+	lines, ok := edit(splitLines(string(data)))
+	if text := strings.Join(lines, "\n") + "\n"; ok && decodesTo(text, cfg, filepath.Dir(resolvedPath)) {
+		out = []byte(text)
+	} else if !rewritable(resolvedPath, data) {
+		return fmt.Errorf("edit config %q: %w", resolvedPath, ErrNotEditable)
+	} else if out, err = encodeConfig(resolvedPath, cfg); err != nil {
+		return err
+	}
+	return replaceConfig(resolvedPath, version, out)
+}
+
+// ErrNotEditable reports a change that could only be made by rewriting a
+// config file that has comments or formatting the rewrite would lose.
+var ErrNotEditable = errors.New("change needs a full rewrite that would drop the file's comments and layout; make it by hand")
+
+// rewritable reports whether data can be rewritten in full without losing
+// anything: it is empty, or exactly what grove itself would write.
+func rewritable(resolvedPath string, data []byte) bool {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return true
+	}
+	cfg, err := decodeConfig(resolvedPath, data)
+	if err != nil {
+		return false
+	}
+	encoded, err := encodeConfig(resolvedPath, cfg)
+	return err == nil && bytes.Equal(encoded, data)
+}
+
+// decodesTo reports whether text holds the same user entries as want.
+func decodesTo(text string, want config.Config, baseDir string) bool {
+	var got config.Config
+	if _, err := toml.Decode(text, &got); err != nil {
+		return false
+	}
+	if err := got.NormalizeWithProjects(baseDir, loadProjectFile); err != nil {
+		return false
+	}
+	if err := want.Normalize(baseDir); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(got.WithoutProjectEntries(), want.WithoutProjectEntries())
+}
+
+func splitLines(text string) []string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// insertIntoFolder places block at the end of the folderIndex-th [[folder]]
+// table, after its last key or subtable and before any comments that lead
+// into the next table.
+func insertIntoFolder(lines []string, folderIndex int, block []string) ([]string, bool) {
+	_, end, ok := folderSpan(lines, folderIndex)
+	if !ok {
+		return nil, false
+	}
+	return insertBlock(lines, end, block), true
+}
+
+// folderSpan returns the lines of the folderIndex-th [[folder]] table, from
+// its header through its last key or subtable.
+func folderSpan(lines []string, folderIndex int) (int, int, bool) {
+	headers := scanHeaders(lines)
+	start, seen := -1, 0
+	for _, h := range headers {
+		if h.array && h.name == "folder" {
+			if seen == folderIndex {
+				start = h.line
+				break
+			}
+			seen++
+		}
+	}
+	if start < 0 {
+		return 0, 0, false
+	}
+
+	end := len(lines)
+	for _, h := range headers {
+		if h.line > start && !strings.HasPrefix(h.name, "folder.") {
+			end = h.line
+			break
+		}
+	}
+	return start, contentEnd(lines, start, end), true
+}
+
+// entrySpan returns the lines of the index-th [[table]] entry (such as
+// folder.command) inside a folder, including its own subtables.
+func entrySpan(lines []string, folderIndex int, table string, index int) (int, int, bool) {
+	folderStart, folderEnd, ok := folderSpan(lines, folderIndex)
+	if !ok {
+		return 0, 0, false
+	}
+	headers := scanHeaders(lines)
+	start, seen := -1, 0
+	for _, h := range headers {
+		if h.line <= folderStart || h.line >= folderEnd || !h.array || h.name != table {
+			continue
+		}
+		if seen == index {
+			start = h.line
+			break
+		}
+		seen++
+	}
+	if start < 0 {
+		return 0, 0, false
+	}
+
+	end := folderEnd
+	for _, h := range headers {
+		if h.line > start && h.line < folderEnd && !strings.HasPrefix(h.name, table+".") {
+			end = h.line
+			break
+		}
+	}
+	return start, contentEnd(lines, start, end), true
+}
+
+// contentEnd returns the index after the last key or header line in
+// (start, end), so trailing blank lines and comments stay with what follows.
+func contentEnd(lines []string, start, end int) int {
+	for i := end - 1; i > start; i-- {
+		if trimmed := strings.TrimSpace(lines[i]); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return i + 1
+		}
+	}
+	return start + 1
+}
+
+// removeSpan deletes lines[start:end] along with the comment lines directly
+// above it and one of the blank lines left around the gap.
+func removeSpan(lines []string, start, end int) []string {
+	for start > 0 && strings.HasPrefix(strings.TrimSpace(lines[start-1]), "#") {
+		start--
+	}
+	blank := func(i int) bool { return i >= 0 && i < len(lines) && strings.TrimSpace(lines[i]) == "" }
+	if blank(end) && (start == 0 || blank(start-1)) {
+		end++
+	}
+	out := append([]string(nil), lines[:start]...)
+	return append(out, lines[end:]...)
+}
+
+// A keyChange sets a key in a table section, or removes it when value is nil.
+type keyChange struct {
+	key   string
+	value any
+}
+
+// setKeys applies changes to the keys of the table whose header is at
+// lines[header], before its first subtable.
+func setKeys(lines []string, header int, changes []keyChange) []string {
+	for _, change := range changes {
+		lines = setKey(lines, header, change)
+	}
+	return lines
+}
+
+func setKey(lines []string, header int, change keyChange) []string {
+	end := len(lines)
+	for _, h := range scanHeaders(lines) {
+		if h.line > header {
+			end = h.line
+			break
+		}
+	}
+	indent := lines[header][:len(lines[header])-len(strings.TrimLeft(lines[header], " \t"))]
+
+	for i := header + 1; i < end; i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		rest, ok := strings.CutPrefix(trimmed, change.key)
+		if !ok || !strings.HasPrefix(strings.TrimSpace(rest), "=") {
+			continue
+		}
+		if change.value == nil {
+			return append(lines[:i:i], lines[i+1:]...)
+		}
+		lineIndent := lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " \t"))]
+		out := append([]string(nil), lines...)
+		out[i] = renderKey(lineIndent, change.key, change.value)
+		return out
+	}
+	if change.value == nil {
+		return lines
+	}
+	at := contentEnd(lines, header, end)
+	out := append([]string(nil), lines[:at]...)
+	out = append(out, renderKey(indent, change.key, change.value))
+	return append(out, lines[at:]...)
+}
+
+// insertBlock inserts block at index at, keeping a blank line on either side.
+func insertBlock(lines []string, at int, block []string) []string {
+	out := make([]string, 0, len(lines)+len(block)+2)
+	out = append(out, lines[:at]...)
+	if at > 0 && strings.TrimSpace(lines[at-1]) != "" {
+		out = append(out, "")
+	}
+	out = append(out, block...)
+	if at < len(lines) && strings.TrimSpace(lines[at]) != "" {
+		out = append(out, "")
+	}
+	return append(out, lines[at:]...)
+}
+
+type tableHeader struct {
+	line  int
+	name  string
+	array bool
+}
+
+// scanHeaders finds the table headers in a TOML document, skipping lines
+// inside multi-line strings.
+func scanHeaders(lines []string) []tableHeader {
+	var headers []tableHeader
+	inString := ""
+	for i, line := range lines {
+		if inString != "" {
+			if strings.Count(line, inString)%2 == 1 {
+				inString = ""
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			if h, ok := parseHeader(trimmed); ok {
+				h.line = i
+				headers = append(headers, h)
+			}
+			continue
+		}
+		for _, delim := range []string{`"""`, `'''`} {
+			if strings.Count(line, delim)%2 == 1 {
+				inString = delim
+				break
+			}
+		}
+	}
+	return headers
+}
+
+func parseHeader(line string) (tableHeader, bool) {
+	array := strings.HasPrefix(line, "[[")
+	open, closing := "[", "]"
+	if array {
+		open, closing = "[[", "]]"
+	}
+	end := strings.Index(line, closing)
+	if end < 0 {
+		return tableHeader{}, false
+	}
+	parts := strings.Split(line[len(open):end], ".")
+	for i, part := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(part), `"'`)
+	}
+	return tableHeader{name: strings.Join(parts, "."), array: array}, true
+}
+
+// renderFolder writes a folder in the layout of config.example.toml.
+func renderFolder(f config.Folder) []string {
+	lines := []string{"[[folder]]"}
+	lines = append(lines, renderKey("", "name", f.Name))
+	if f.Host != "" {
+		lines = append(lines, renderKey("", "host", f.Host))
+	}
+	lines = append(lines, renderKey("", "path", f.Path))
+	if f.EditorCommand != "" {
+		lines = append(lines, renderKey("", "editor_command", f.EditorCommand))
+	}
+	if f.TrustProjectSchedules {
+		lines = append(lines, renderKey("", "trust_project_schedules", true))
+	}
+	if f.EnvFile != "" {
+		lines = append(lines, renderKey("", "env_file", f.EnvFile))
+	}
+	if len(f.Env) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, renderEnv("  ", "folder.env", f.Env)...)
+	}
+	for _, agent := range f.Agents {
+		lines = append(lines, "")
+		lines = append(lines, renderAgent(agent)...)
+	}
+	for _, command := range f.Commands {
+		lines = append(lines, "")
+		lines = append(lines, renderCommand(command)...)
+	}
+	for _, task := range f.Tasks {
+		lines = append(lines, "")
+		lines = append(lines, renderTask(task)...)
+	}
+	return lines
+}
+
+func renderAgent(agent config.Agent) []string {
+	const indent = "  "
+	lines := []string{indent + "[[folder.agent]]"}
+	lines = append(lines, renderKey(indent, "name", agent.Name), renderKey(indent, "command", agent.Command))
+	if agent.ResumeCommand != "" {
+		lines = append(lines, renderKey(indent, "resume_command", agent.ResumeCommand))
+	}
+	if len(agent.NeedsInput) > 0 {
+		lines = append(lines, renderKey(indent, "needs_input", agent.NeedsInput))
+	}
+	if len(agent.Working) > 0 {
+		lines = append(lines, renderKey(indent, "working", agent.Working))
+	}
+	if len(agent.Finished) > 0 {
+		lines = append(lines, renderKey(indent, "finished", agent.Finished))
+	}
+	if agent.Spinner != "" {
+		lines = append(lines, renderKey(indent, "spinner", agent.Spinner))
+	}
+	if agent.IdleAfter != 0 {
+		lines = append(lines, renderKey(indent, "idle_after", agent.IdleAfter))
+	}
+	if agent.EnvFile != "" {
+		lines = append(lines, renderKey(indent, "env_file", agent.EnvFile))
+	}
+	if len(agent.Env) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, renderEnv(indent+"  ", "folder.agent.env", agent.Env)...)
+	}
+	return lines
+}
+
+func renderCommand(command config.Command) []string {
+	const indent = "  "
+	lines := []string{indent + "[[folder.command]]"}
+	lines = append(lines, renderKey(indent, "name", command.Name), renderKey(indent, "command", command.Command))
+	if len(command.DependsOn) > 0 {
+		lines = append(lines, renderKey(indent, "depends_on", command.DependsOn))
+	}
+	if command.Restart != "" {
+		lines = append(lines, renderKey(indent, "restart", command.Restart))
+	}
+	if command.RestartBackoff != 0 {
+		lines = append(lines, renderKey(indent, "restart_backoff", command.RestartBackoff))
+	}
+	if command.MaxRetries != 0 {
+		lines = append(lines, renderKey(indent, "max_retries", command.MaxRetries))
+	}
+	if len(command.StopKeys) > 0 {
+		lines = append(lines, renderKey(indent, "stop_keys", command.StopKeys))
+	}
+	if command.StopCommand != "" {
+		lines = append(lines, renderKey(indent, "stop_command", command.StopCommand))
+	}
+	if command.StopTimeout != 0 {
+		lines = append(lines, renderKey(indent, "stop_timeout", command.StopTimeout))
+	}
+	if command.EnvFile != "" {
+		lines = append(lines, renderKey(indent, "env_file", command.EnvFile))
+	}
+	if len(command.Env) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, renderEnv(indent+"  ", "folder.command.env", command.Env)...)
+	}
+	return lines
+}
+
+func renderTask(task config.Task) []string {
+	const indent = "  "
+	lines := []string{indent + "[[folder.task]]"}
+	lines = append(lines, renderKey(indent, "name", task.Name), renderKey(indent, "command", task.Command))
+	if task.Schedule != "" {
+		lines = append(lines, renderKey(indent, "schedule", task.Schedule))
+	}
+	if task.EnvFile != "" {
+		lines = append(lines, renderKey(indent, "env_file", task.EnvFile))
+	}
+	if len(task.Env) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, renderEnv(indent+"  ", "folder.task.env", task.Env)...)
+	}
+	return lines
+}
+
+func renderEnv(indent, table string, env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := []string{indent + "[" + table + "]"}
+	for _, key := range keys {
+		lines = append(lines, renderKey(indent, key, env[key]))
+	}
+	return lines
+}
+
+// renderKey formats one key/value line, leaving value encoding to the TOML
+// encoder so strings are escaped the way Load expects.
+func renderKey(indent, key string, value any) string {
+	var buf bytes.Buffer
+	// Strings, string lists, bools, ints and durations always encode.
+	_ = toml.NewEncoder(&buf).Encode(map[string]any{key: value})
+	return indent + strings.TrimSpace(buf.String())
+}
@@ -0,0 +1,541 @@
+package configfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+)
+
+const handWrittenConfig = `# My grove setup
+editor_command = "code ."
+
+[[folder]]
+name = "API"   # the backend
+path = "."
+
+  [folder.env]
+  RAILS_ENV = "development"
+
+  [[folder.command]]
+  name = "start"
+  command = "make start"
+
+# Frontend lives next door.
+[[folder]]
+name = "Web"
+path = "."
+editor_command = "zed ."
+
+# [[agent]]
+# name = "Amp"
+# command = "amp"
+`
+
+func writeHandWrittenConfig(t *testing.T) string {
+	t.Helper()
+	cfgPath := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(cfgPath, []byte(handWrittenConfig), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return cfgPath
+}
+
+func readConfigText(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	return string(b)
+}
+
+func TestAppendCommandKeepsCommentsAndLayout(t *testing.T) {
+	t.Parallel()
+
+	cfgPath := writeHandWrittenConfig(t)
+	command := config.Command{
+		Name:           "web",
+		Command:        `make "web"`,
+		DependsOn:      []string{"start"},
+		Restart:        config.RestartOnFailure,
+		RestartBackoff: 2 * time.Second,
+		StopKeys:       []string{"C-c"},
+		StopTimeout:    5 * time.Second,
+		Env:            map[string]string{"PORT": "3000"},
+	}
+	if err := AppendCommand(cfgPath, "api", command); err != nil {
+		t.Fatalf("AppendCommand() error = %v", err)
+	}
+
+	want := strings.Replace(handWrittenConfig, `  [[folder.command]]
+  name = "start"
+  command = "make start"
+`, `  [[folder.command]]
+  name = "start"
+  command = "make start"
+
+  [[folder.command]]
+  name = "web"
+  command = "make \"web\""
+  depends_on = ["start"]
+  restart = "on-failure"
+  restart_backoff = "2s"
+  stop_keys = ["C-c"]
+  stop_timeout = "5s"
+
+    [folder.command.env]
+    PORT = "3000"
+`, 1)
+	if got := readConfigText(t, cfgPath); got != want {
+		t.Fatalf("config after AppendCommand() =\n%s\nwant\n%s", got, want)
+	}
+
+	loaded, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := loaded.Folders[0].Commands; len(got) != 2 || got[1].Name != "web" || got[1].Env["PORT"] != "3000" || got[1].StopTimeout != 5*time.Second {
+		t.Fatalf("commands = %#v, want appended web command", got)
+	}
+	if len(loaded.Folders[1].Commands) != 0 {
+		t.Fatalf("Web commands = %#v, want none", loaded.Folders[1].Commands)
+	}
+}
+
+func TestAppendFolderAgentTargetsLastFolder(t *testing.T) {
+	t.Parallel()
+
+	cfgPath := writeHandWrittenConfig(t)
+	if err := AppendFolderAgent(cfgPath, "web", config.Agent{Name: "Codex", Command: "codex"}); err != nil {
+		t.Fatalf("AppendFolderAgent() error = %v", err)
+	}
+
+	want := strings.Replace(handWrittenConfig, `editor_command = "zed ."
+`, `editor_command = "zed ."
+
+  [[folder.agent]]
+  name = "Codex"
+  command = "codex"
+`, 1)
+	if got := readConfigText(t, cfgPath); got != want {
+		t.Fatalf("config after AppendFolderAgent() =\n%s\nwant\n%s", got, want)
+	}
+
+	if err := AppendFolderAgent(cfgPath, "web", config.Agent{Name: "codex", Command: "codex --other"}); err != nil {
+		t.Fatalf("AppendFolderAgent() duplicate error = %v", err)
+	}
+	if got := readConfigText(t, cfgPath); got != want {
+		t.Fatalf("config after duplicate AppendFolderAgent() =\n%s\nwant unchanged", got)
+	}
+}
+
+func TestAppendFolderAgentKeepsDetectionRules(t *testing.T) {
+	t.Parallel()
+
+	cfgPath := writeHandWrittenConfig(t)
+	agent := config.Agent{
+		Name:          "Claude",
+		Command:       "claude",
+		ResumeCommand: "claude --continue",
+		NeedsInput:    []string{`Do you want to \w+\?`},
+		Spinner:       "⠂⠐",
+		IdleAfter:     20 * time.Second,
+	}
+	if err := AppendFolderAgent(cfgPath, "web", agent); err != nil {
+		t.Fatalf("AppendFolderAgent() error = %v", err)
+	}
+
+	want := strings.Replace(handWrittenConfig, `editor_command = "zed ."
+`, `editor_command = "zed ."
+
+  [[folder.agent]]
+  name = "Claude"
+  command = "claude"
+  resume_command = "claude --continue"
+  needs_input = ["Do you want to \\w+\\?"]
+  spinner = "⠂⠐"
+  idle_after = "20s"
+`, 1)
+	if got := readConfigText(t, cfgPath); got != want {
+		t.Fatalf("config after AppendFolderAgent() =\n%s\nwant\n%s", got, want)
+	}
+
+	loaded, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	got := loaded.Folders[1].Agents[0]
+	if len(got.NeedsInput) != 1 || got.NeedsInput[0] != agent.NeedsInput[0] || got.Spinner != agent.Spinner || got.IdleAfter != agent.IdleAfter || got.ResumeCommand != agent.ResumeCommand {
+		t.Fatalf("agent = %#v, want the detection rules and resume command back", got)
+	}
+}
+
+func TestAppendFolderKeepsTemplateComments(t *testing.T) {
+	t.Parallel()
+
+	cfgPath := filepath.Join(t.TempDir(), "config.toml")
+	if err := EnsureTemplate(cfgPath); err != nil {
+		t.Fatalf("EnsureTemplate() error = %v", err)
+	}
+	template := readConfigText(t, cfgPath)
+
+	if err := AppendFolder(cfgPath, config.Folder{Name: "Main API", Path: "/tmp/main-api"}); err != nil {
+		t.Fatalf("AppendFolder() error = %v", err)
+	}
+
+	want := template + "\n[[folder]]\nname = \"Main API\"\npath = \"/tmp/main-api\"\n"
+	if got := readConfigText(t, cfgPath); got != want {
+		t.Fatalf("config after AppendFolder() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAppendCommandRejectsDuplicateWithoutWriting(t *testing.T) {
+	t.Parallel()
+
+	cfgPath := writeHandWrittenConfig(t)
+	err := AppendCommand(cfgPath, "api", config.Command{Name: "Start", Command: "make other"})
+	if err == nil || err.Error() != "command name already exists" {
+		t.Fatalf("AppendCommand() error = %v, want duplicate error", err)
+	}
+	if got := readConfigText(t, cfgPath); got != handWrittenConfig {
+		t.Fatalf("config = %q, want untouched", got)
+	}
+}
+
+func TestInsertIntoFolderSkipsMultilineStrings(t *testing.T) {
+	t.Parallel()
+
+	lines := splitLines(`[[folder]]
+name = "API"
+path = "."
+editor_command = """
+[[folder]]
+"""
+
+[[agent]]
+name = "Amp"
+command = "amp"`)
+
+	got, ok := insertIntoFolder(lines, 0, []string{"  [[folder.agent]]"})
+	if !ok {
+		t.Fatal("insertIntoFolder() ok = false, want true")
+	}
+	if got[7] != "  [[folder.agent]]" || got[9] != "[[agent]]" {
+		t.Fatalf("insertIntoFolder() = %q, want block before [[agent]]", got)
+	}
+	if _, ok := insertIntoFolder(lines, 1, nil); ok {
+		t.Fatal("insertIntoFolder() found a folder inside a multi-line string")
+	}
+}
+
+func TestAppendCommandReappliesAfterConcurrentChange(t *testing.T) {
+	cfgPath := writeHandWrittenConfig(t)
+	writes := 0
+	beforeConfigWrite = func() {
+		writes++
+		if writes > 1 {
+			return
+		}
+		// Another grove instance adds a folder between our read and write.
+		f, err := os.OpenFile(cfgPath, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString("\n[[folder]]\nname = \"Docs\"\npath = \".\"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { beforeConfigWrite = func() {} })
+
+	if err := AppendCommand(cfgPath, "api", config.Command{Name: "web", Command: "make web"}); err != nil {
+		t.Fatalf("AppendCommand() error = %v", err)
+	}
+	if writes != 2 {
+		t.Fatalf("write attempts = %d, want 2", writes)
+	}
+
+	loaded, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded.Folders) != 3 || loaded.Folders[2].Name != "Docs" {
+		t.Fatalf("folders = %#v, want concurrent Docs folder kept", loaded.Folders)
+	}
+	if got := loaded.Folders[0].Commands; len(got) != 2 || got[1].Name != "web" {
+		t.Fatalf("commands = %#v, want web re-applied", got)
+	}
+}
+
+func TestAppendCommandGivesUpWhenConfigKeepsChanging(t *testing.T) {
+	cfgPath := writeHandWrittenConfig(t)
+	writes := 0
+	beforeConfigWrite = func() {
+		writes++
+		content := fmt.Sprintf("%s\n# edit %d\n", handWrittenConfig, writes)
+		if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { beforeConfigWrite = func() {} })
+
+	err := AppendCommand(cfgPath, "api", config.Command{Name: "web", Command: "make web"})
+	if !errors.Is(err, ErrChanged) {
+		t.Fatalf("AppendCommand() error = %v, want ErrChanged", err)
+	}
+	if writes != editAttempts {
+		t.Fatalf("write attempts = %d, want %d", writes, editAttempts)
+	}
+	if got := readConfigText(t, cfgPath); strings.Contains(got, "make web") {
+		t.Fatalf("config = %q, want concurrent edit kept and command not written", got)
+	}
+}
+
+func TestEditFindsFolderAfterConcurrentInsertBeforeIt(t *testing.T) {
+	cfgPath := writeHandWrittenConfig(t)
+	writes := 0
+	beforeConfigWrite = func() {
+		writes++
+		if writes > 1 {
+			return
+		}
+		// Another grove instance adds a folder ahead of the one being edited.
+		content := "[[folder]]\nname = \"Docs\"\npath = \".\"\n\n" + handWrittenConfig
+		if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { beforeConfigWrite = func() {} })
+
+	if err := AppendCommand(cfgPath, "web", config.Command{Name: "dev", Command: "npm run dev"}); err != nil {
+		t.Fatalf("AppendCommand() error = %v", err)
+	}
+	if err := UpdateFolder(cfgPath, "web", config.Folder{Name: "Frontend", Path: "."}); err != nil {
+		t.Fatalf("UpdateFolder() error = %v", err)
+	}
+
+	loaded, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	var names []string
+	for _, folder := range loaded.Folders {
+		names = append(names, folder.Name)
+	}
+	if got := strings.Join(names, ","); got != "Docs,API,Frontend" {
+		t.Fatalf("folders = %s, want Docs,API,Frontend", got)
+	}
+	if got := loaded.Folders[2].Commands; len(got) != 1 || got[0].Name != "dev" {
+		t.Fatalf("Frontend commands = %#v, want dev", got)
+	}
+	if got := loaded.Folders[0].Commands; len(got) != 0 {
+		t.Fatalf("Docs commands = %#v, want none", got)
+	}
+
+	if err := RemoveFolder(cfgPath, "web"); err == nil {
+		t.Fatal("RemoveFolder() of a renamed folder error = nil, want it not found")
+	}
+}
+
+func TestUpdateFolderRewritesChangedKeysOnly(t *testing.T) {
+	t.Parallel()
+
+	cfgPath := writeHandWrittenConfig(t)
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := UpdateFolder(cfgPath, "web", config.Folder{Name: "Frontend", Path: cfg.Folders[1].Path}); err != nil {
+		t.Fatalf("UpdateFolder() error = %v", err)
+	}
+
+	want := strings.Replace(handWrittenConfig, `name = "Web"
+path = "."
+editor_command = "zed ."
+`, `name = "Frontend"
+path = "."
+`, 1)
+	if got := readConfigText(t, cfgPath); got != want {
+		t.Fatalf("config after UpdateFolder() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRemoveFolderKeepsNeighbouringComments(t *testing.T) {
+	t.Parallel()
+
+	cfgPath := writeHandWrittenConfig(t)
+	if err := RemoveFolder(cfgPath, "web"); err != nil {
+		t.Fatalf("RemoveFolder() error = %v", err)
+	}
+
+	want := strings.Replace(handWrittenConfig, `# Frontend lives next door.
+[[folder]]
+name = "Web"
+path = "."
+editor_command = "zed ."
+
+`, "", 1)
+	if got := readConfigText(t, cfgPath); got != want {
+		t.Fatalf("config after RemoveFolder() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUpdateAndRemoveCommandEditInPlace(t *testing.T) {
+	t.Parallel()
+
+	cfgPath := writeHandWrittenConfig(t)
+	if err := AppendCommand(cfgPath, "api", config.Command{Name: "web", Command: "make web", DependsOn: []string{"start"}}); err != nil {
+		t.Fatalf("AppendCommand() error = %v", err)
+	}
+	if err := UpdateCommand(cfgPath, "api", "start", config.Command{Name: "server", Command: "make server"}); err != nil {
+		t.Fatalf("UpdateCommand() error = %v", err)
+	}
+
+	want := strings.Replace(handWrittenConfig, `  [[folder.command]]
+  name = "start"
+  command = "make start"
+`, `  [[folder.command]]
+  name = "server"
+  command = "make server"
+
+  [[folder.command]]
+  name = "web"
+  command = "make web"
+  depends_on = ["server"]
+`, 1)
+	if got := readConfigText(t, cfgPath); got != want {
+		t.Fatalf("config after UpdateCommand() =\n%s\nwant\n%s", got, want)
+	}
+
+	if err := RemoveCommand(cfgPath, "api", "server"); err == nil || err.Error() != `command "server" is needed by "web"` {
+		t.Fatalf("RemoveCommand(server) error = %v, want needed by web", err)
+	}
+	if err := RemoveCommand(cfgPath, "api", "web"); err != nil {
+		t.Fatalf("RemoveCommand(web) error = %v", err)
+	}
+	want = strings.Replace(handWrittenConfig, `  name = "start"
+  command = "make start"
+`, `  name = "server"
+  command = "make server"
+`, 1)
+	if got := readConfigText(t, cfgPath); got != want {
+		t.Fatalf("config after RemoveCommand() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUpdateCommandRefusesToRewriteHandWrittenFile(t *testing.T) {
+	t.Parallel()
+
+	cfgPath := writeHandWrittenConfig(t)
+	err := UpdateCommand(cfgPath, "api", "start", config.Command{Name: "start", Command: "make start", Env: map[string]string{"PORT": "3000"}})
+	if !errors.Is(err, ErrNotEditable) {
+		t.Fatalf("UpdateCommand() error = %v, want ErrNotEditable", err)
+	}
+	if got := readConfigText(t, cfgPath); got != handWrittenConfig {
+		t.Fatalf("config after refused UpdateCommand() =\n%s\nwant it untouched", got)
+	}
+
+	// A file grove wrote itself has nothing to lose.
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := Save(cfgPath, cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := UpdateCommand(cfgPath, "api", "start", config.Command{Name: "start", Command: "make start", Env: map[string]string{"PORT": "3000"}}); err != nil {
+		t.Fatalf("UpdateCommand() on a written file error = %v", err)
+	}
+	if cfg, err = Load(cfgPath); err != nil || cfg.Folders[0].Commands[0].Env["PORT"] != "3000" {
+		t.Fatalf("Load() = %#v, %v, want PORT env on start", cfg.Folders[0].Commands, err)
+	}
+}
+
+func TestUpdateCommandLeavesProjectCommandsAlone(t *testing.T) {
+	t.Parallel()
+
+	tmp := t.TempDir()
+	repo := filepath.Join(tmp, "api")
+	if err := os.MkdirAll(repo, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	project := "[[command]]\nname = \"seed\"\ncommand = \"make seed\"\ndepends_on = [\"db\"]\n"
+	if err := os.WriteFile(filepath.Join(repo, config.ProjectFile), []byte(project), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfgPath := filepath.Join(tmp, "config.toml")
+	content := strings.Join([]string{
+		"[[folder]]",
+		"name = \"API\"",
+		"path = \"api\"",
+		"",
+		"  [[folder.command]]",
+		"  name = \"db\"",
+		"  command = \"make db\"",
+		"",
+		"  [[folder.command]]",
+		"  name = \"web\"",
+		"  command = \"make web\"",
+		"",
+	}, "\n")
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A file grove wrote could be rewritten whole, so only the project
+	// check stops the rename.
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := Save(cfgPath, cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	content = readConfigText(t, cfgPath)
+
+	err = UpdateCommand(cfgPath, "api", "db", config.Command{Name: "database", Command: "make db"})
+	if !errors.Is(err, ErrNotEditable) {
+		t.Fatalf("UpdateCommand() renaming a project dependency error = %v, want ErrNotEditable", err)
+	}
+	if got := readConfigText(t, cfgPath); got != content {
+		t.Fatalf("config after refused UpdateCommand() =\n%s\nwant it untouched", got)
+	}
+
+	if err := UpdateCommand(cfgPath, "api", "web", config.Command{Name: "web", Command: "make serve"}); err != nil {
+		t.Fatalf("UpdateCommand() error = %v", err)
+	}
+	want := strings.Replace(content, "make web", "make serve", 1)
+	if got := readConfigText(t, cfgPath); got != want {
+		t.Fatalf("config after UpdateCommand() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRemoveAgentDeletesItsTable(t *testing.T) {
+	t.Parallel()
+
+	cfgPath := writeHandWrittenConfig(t)
+	for _, name := range []string{"Codex", "Amp"} {
+		if err := AppendFolderAgent(cfgPath, "api", config.Agent{Name: name, Command: strings.ToLower(name)}); err != nil {
+			t.Fatalf("AppendFolderAgent(%s) error = %v", name, err)
+		}
+	}
+	if err := RemoveAgent(cfgPath, "api", "codex"); err != nil {
+		t.Fatalf("RemoveAgent() error = %v", err)
+	}
+
+	want := strings.Replace(handWrittenConfig, `  command = "make start"
+`, `  command = "make start"
+
+  [[folder.agent]]
+  name = "Amp"
+  command = "amp"
+`, 1)
+	if got := readConfigText(t, cfgPath); got != want {
+		t.Fatalf("config after RemoveAgent() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package configfile
+
+// lockConfig does nothing where flock is unavailable; the version check
+// still catches most concurrent writes.
+func lockConfig(resolvedPath string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,30 @@
+//go:build linux || darwin
+
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockConfig takes an exclusive lock on a file next to the config, which
+// other grove processes wait on before checking and replacing it. The
+// returned func releases the lock.
+func lockConfig(resolvedPath string) (func(), error) {
+	dir := filepath.Dir(resolvedPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create config directory %q: %w", dir, err)
+	}
+	lockPath := resolvedPath + ".lock"
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open config lock %q: %w", lockPath, err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("lock config %q: %w", resolvedPath, err)
+	}
+	return func() { _ = file.Close() }, nil
+}
@@ -0,0 +1,42 @@
+//go:build linux || darwin
+
+package configfile
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplaceConfigWaitsForLock(t *testing.T) {
+	t.Parallel()
+
+	cfgPath := filepath.Join(t.TempDir(), "config.toml")
+	unlock, err := lockConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("lockConfig() error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- replaceConfig(cfgPath, Version{}, []byte("editor_command = \"zed .\"\n"))
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("replaceConfig() = %v while another process holds the lock, want it to wait", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("replaceConfig() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("replaceConfig() still waiting after the lock was released")
+	}
+	if got := readConfigText(t, cfgPath); got != "editor_command = \"zed .\"\n" {
+		t.Fatalf("config = %q, want the write applied", got)
+	}
+}
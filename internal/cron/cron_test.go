@@ -0,0 +1,61 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	t.Parallel()
+
+	// A Wednesday.
+	from := time.Date(2026, time.March, 4, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{spec: "* * * * *", want: time.Date(2026, time.March, 4, 10, 18, 0, 0, time.UTC)},
+		{spec: "@hourly", want: time.Date(2026, time.March, 4, 11, 0, 0, 0, time.UTC)},
+		{spec: "*/15 * * * *", want: time.Date(2026, time.March, 4, 10, 30, 0, 0, time.UTC)},
+		{spec: "0 3 * * *", want: time.Date(2026, time.March, 5, 3, 0, 0, 0, time.UTC)},
+		{spec: "30 9 * * mon-fri", want: time.Date(2026, time.March, 5, 9, 30, 0, 0, time.UTC)},
+		{spec: "0 0 * * 7", want: time.Date(2026, time.March, 8, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 1 jan *", want: time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 12 15 * fri", want: time.Date(2026, time.March, 6, 12, 0, 0, 0, time.UTC)},
+		{spec: "5,45 10 * * *", want: time.Date(2026, time.March, 4, 10, 45, 0, 0, time.UTC)},
+		{spec: "0 0 30 2 *", want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Fatalf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRejectsBadSchedules(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"* * * *":      "must have 5 fields",
+		"60 * * * *":   "minute \"60\" must be between 0 and 59",
+		"* * * foo *":  "month \"foo\" must be between 1 and 12",
+		"*/0 * * * *":  "step \"0\" must be a positive number",
+		"* 5-2 * * *":  "hour range \"5-2\" runs backwards",
+		"@fortnightly": "must have 5 fields",
+	}
+	for spec, want := range tests {
+		if _, err := Parse(spec); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q) error = %v, want it to contain %q", spec, err, want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package logfile locates, writes, rotates and reads the output logs that
+// managed commands are piped into.
+package logfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+)
+
+const (
+	// MaxSize is the size past which a log is rotated, when its command
+	// starts and while it writes.
+	MaxSize = 10 << 20
+	// Keep is how many rotated logs (<command>.log.1 … .N) are kept.
+	Keep = 3
+)
+
+// SinkArg is the hidden grove subcommand that tmux pipes a command's output
+// into, so the log is rotated while the command runs.
+const SinkArg = "_log-sink"
+
+// Dir returns $XDG_STATE_HOME/grove/logs, falling back to
+// ~/.local/state/grove/logs.
+func Dir() string {
+	return filepath.Join(config.StateDir(), "logs")
+}
+
+// Path returns the log file for a managed command in a folder namespace.
+func Path(namespace, command string) string {
+	return filepath.Join(Dir(), namespace, config.Slug(command)+".log")
+}
+
+// Prepare creates the log directory and rotates the log if it has grown past
+// MaxSize, so a fresh run appends to a bounded file while earlier output stays
+// in the rotated copies.
+func Prepare(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create log dir: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() < MaxSize {
+		return nil
+	}
+	return rotate(path)
+}
+
+// rotate shifts the log and its rotated copies up by one, dropping the
+// oldest past Keep.
+func rotate(path string) error {
+	for i := Keep - 1; i >= 1; i-- {
+		older := path + "." + strconv.Itoa(i)
+		if _, err := os.Stat(older); err == nil {
+			if err := os.Rename(older, path+"."+strconv.Itoa(i+1)); err != nil {
+				return fmt.Errorf("rotate log: %w", err)
+			}
+		}
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		return fmt.Errorf("rotate log: %w", err)
+	}
+	return nil
+}
+
+// Writer appends to a log, rotating it whenever the next write would take
+// it past MaxSize.
+type Writer struct {
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+}
+
+// Open opens the log at path for appending, creating its directory.
+func Open(path string) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create log dir: %w", err)
+	}
+	w := &Writer{path: path, maxSize: MaxSize}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("open log: %w", err)
+	}
+	w.file, w.size = file, info.Size()
+	return nil
+}
+
+// Write appends p. Failing to rotate only lets the log grow; the output is
+// still written.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		w.file.Close()
+		_ = rotate(w.path)
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the log.
+func (w *Writer) Close() error {
+	return w.file.Close()
+}
+
+// Sink copies r into the log at path until r ends.
+func Sink(path string, r io.Reader) error {
+	w, err := Open(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return fmt.Errorf("write log: %w", err)
+	}
+	return w.Close()
+}
+
+// SinkArgs returns the command that runs Sink for path: this grove binary
+// with SinkArg.
+func SinkArgs(path string) []string {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "grove"
+	}
+	return []string{exe, SinkArg, path}
+}
+
+// Move renames a log, or a namespace's log directory, to follow a renamed
+// command or folder. A command still running keeps appending to the moved
+// file. Missing logs are not an error.
+func Move(from, to string) error {
+	if _, err := os.Stat(from); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return fmt.Errorf("create log dir: %w", err)
+	}
+	if err := os.Rename(from, to); err != nil {
+		return fmt.Errorf("move log: %w", err)
+	}
+	return nil
+}
+
+// ReadTail returns the lines in the last maxBytes of the log. Carriage-return
+// redraws keep only their final state, as a terminal would show them.
+func ReadTail(path string, maxBytes int64) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := int64(0)
+	if info.Size() > maxBytes {
+		offset = info.Size() - maxBytes
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		// Drop the partial line the window starts in.
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+
+	text := strings.TrimRight(string(data), "\r\n")
+	if text == "" {
+		return []string{}, nil
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if j := strings.LastIndexByte(line, '\r'); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = line
+	}
+	return lines, nil
+}
@@ -0,0 +1,138 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathUsesXDGStateHome(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+
+	got := Path("api", "Web Server")
+	want := filepath.Join(state, "grove", "logs", "api", "web-server.log")
+	if got != want {
+		t.Fatalf("Path() = %q, want %q", got, want)
+	}
+}
+
+func TestWriterRotatesWhileWriting(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "api", "web.log")
+	w, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	w.maxSize = 10
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write(%q) error = %v", line, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	for file, want := range map[string]string{path: "third\n", path + ".1": "second\n", path + ".2": "first\n"} {
+		if b, err := os.ReadFile(file); err != nil || string(b) != want {
+			t.Fatalf("%s = (%q, %v), want %q", filepath.Base(file), b, err, want)
+		}
+	}
+}
+
+func TestPrepareRotatesLargeLogs(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "api", "web.log")
+	if err := Prepare(path); err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		t.Fatalf("log dir stat = (%v, %v), want directory", info, err)
+	}
+
+	if err := os.WriteFile(path, []byte("small\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Prepare(path); err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Fatalf("small log rotated: stat .1 err = %v", err)
+	}
+
+	if err := os.WriteFile(path+".1", []byte("previous\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, MaxSize), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Prepare(path); err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("stat log after rotation err = %v, want not exist", err)
+	}
+	if info, err := os.Stat(path + ".1"); err != nil || info.Size() != MaxSize {
+		t.Fatalf("stat .1 = (%v, %v), want rotated log", info, err)
+	}
+	if b, err := os.ReadFile(path + ".2"); err != nil || string(b) != "previous\n" {
+		t.Fatalf(".2 = (%q, %v), want previous rotation", b, err)
+	}
+}
+
+func TestReadTail(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "web.log")
+	content := "first line\r\nsecond\r\nprogress 10%\rprogress 100%\r\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := ReadTail(path, 1<<20)
+	if err != nil {
+		t.Fatalf("ReadTail() error = %v", err)
+	}
+	if got := strings.Join(lines, "|"); got != "first line|second|progress 100%" {
+		t.Fatalf("ReadTail() = %q", got)
+	}
+
+	lines, err = ReadTail(path, 30)
+	if err != nil {
+		t.Fatalf("ReadTail() error = %v", err)
+	}
+	if got := strings.Join(lines, "|"); got != "progress 100%" {
+		t.Fatalf("ReadTail(30) = %q, want partial first line dropped", got)
+	}
+}
+
+func TestMoveFollowsRenamedCommand(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	from := filepath.Join(dir, "api", "web.log")
+	to := filepath.Join(dir, "backend", "server.log")
+	if err := Move(from, to); err != nil {
+		t.Fatalf("Move() missing log error = %v", err)
+	}
+
+	if err := Prepare(from); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(from, []byte("ready\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Move(from, to); err != nil {
+		t.Fatalf("Move() error = %v", err)
+	}
+	if b, err := os.ReadFile(to); err != nil || string(b) != "ready\n" {
+		t.Fatalf("moved log = (%q, %v), want original output", b, err)
+	}
+	if _, err := os.Stat(from); !os.IsNotExist(err) {
+		t.Fatalf("stat old log err = %v, want not exist", err)
+	}
+}
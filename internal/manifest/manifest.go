@@ -0,0 +1,73 @@
+// Package manifest records which managed sessions were running in each
+// folder, so they can be recreated after a reboot or after the tmux server
+// dies.
+package manifest
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/statefile"
+)
+
+// Kind is what sort of managed session an entry describes.
+type Kind string
+
+const (
+	KindAgent    Kind = "agent"
+	KindTerminal Kind = "terminal"
+	KindCommand  Kind = "command"
+)
+
+// Session is one running managed session.
+type Session struct {
+	Kind Kind `json:"kind"`
+	// Name is the agent's or command's session slug; terminals have none.
+	Name string `json:"name,omitempty"`
+	// Index numbers agent instances and terminals.
+	Index int `json:"index,omitempty"`
+	// Dir is the session's working directory.
+	Dir string `json:"dir"`
+	// Worktree is the git worktree an agent instance runs in, if any.
+	Worktree string `json:"worktree,omitempty"`
+}
+
+// Manifest lists the running managed sessions by folder namespace.
+type Manifest struct {
+	Folders map[string][]Session `json:"folders"`
+}
+
+// Len returns how many sessions the manifest lists.
+func (m Manifest) Len() int {
+	n := 0
+	for _, sessions := range m.Folders {
+		n += len(sessions)
+	}
+	return n
+}
+
+// Path returns the file the manifest is kept in.
+func Path() string {
+	return filepath.Join(config.StateDir(), "sessions.json")
+}
+
+// Load reads the manifest. A missing file lists no sessions.
+func Load(path string) (Manifest, error) {
+	m := Manifest{Folders: map[string][]Session{}}
+	if err := statefile.Load(path, &m); err != nil {
+		return Manifest{Folders: map[string][]Session{}}, fmt.Errorf("read session manifest: %w", err)
+	}
+	if m.Folders == nil {
+		m.Folders = map[string][]Session{}
+	}
+	return m, nil
+}
+
+// Save writes the manifest.
+func Save(path string, m Manifest) error {
+	if err := statefile.Save(path, m); err != nil {
+		return fmt.Errorf("write session manifest: %w", err)
+	}
+	return nil
+}
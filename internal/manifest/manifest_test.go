@@ -0,0 +1,33 @@
+package manifest
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state", "sessions.json")
+	if empty, err := Load(path); err != nil || empty.Len() != 0 || empty.Folders == nil {
+		t.Fatalf("Load() before saving = (%#v, %v), want an empty manifest", empty, err)
+	}
+	want := Manifest{Folders: map[string][]Session{
+		"api": {
+			{Kind: KindAgent, Name: "claude", Index: 2, Dir: "/tmp/api/.worktrees/agent-claude-2", Worktree: "/tmp/api/.worktrees/agent-claude-2"},
+			{Kind: KindTerminal, Index: 1, Dir: "/tmp/api/src"},
+			{Kind: KindCommand, Name: "web", Dir: "/tmp/api"},
+		},
+	}}
+	if err := Save(path, want); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) || got.Len() != 3 {
+		t.Fatalf("Load() = %#v, want %#v", got, want)
+	}
+}
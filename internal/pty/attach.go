@@ -0,0 +1,106 @@
+package pty
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/charmbracelet/x/term"
+
+	"github.com/SarthakJariwala/grove/internal/sockdir"
+)
+
+// detachKey (Ctrl-\) detaches the terminal from the session.
+const detachKey = 0x1c
+
+// Attach connects the terminal to a session until the session exits or the
+// user presses Ctrl-\.
+func Attach(socket, name string) error {
+	in, out := os.Stdin, os.Stdout
+	cols, rows, err := term.GetSize(out.Fd())
+	if err != nil {
+		cols, rows = defaultCols, defaultRows
+	}
+
+	conn, err := dial(socket)
+	if errors.Is(err, sockdir.ErrUnsafe) {
+		return fmt.Errorf("attach %s: %w", name, err)
+	}
+	if err != nil {
+		return fmt.Errorf("attach %s: %w", name, errNoDaemon)
+	}
+	defer conn.Close()
+	if err := writeMessage(conn, request{Op: opAttach, Name: name, Rows: rows, Cols: cols}); err != nil {
+		return fmt.Errorf("attach %s: %w", name, err)
+	}
+	r := bufio.NewReader(conn)
+	var resp response
+	if err := readMessage(r, &resp); err != nil {
+		return fmt.Errorf("attach %s: %w", name, err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("attach %s: %s", name, resp.Error)
+	}
+
+	state, err := term.MakeRaw(in.Fd())
+	if err != nil {
+		return fmt.Errorf("attach %s: %w", name, err)
+	}
+	defer term.Restore(in.Fd(), state)
+	// The session draws on the alternate screen so the shell's scrollback
+	// is left as it was.
+	_, _ = io.WriteString(out, "\x1b[?1049h")
+	defer io.WriteString(out, "\x1b[0m\x1b[?25h\x1b[?1049l")
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(out, r)
+		close(done)
+	}()
+	go forwardInput(in, conn)
+
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-resized:
+			if cols, rows, err := term.GetSize(out.Fd()); err == nil {
+				_ = writeFrame(conn, frameResize, resizePayload(rows, cols))
+			}
+		}
+	}
+}
+
+// forwardInput sends keyboard input to the session until the detach key,
+// then closes conn.
+func forwardInput(in io.Reader, conn net.Conn) {
+	defer conn.Close()
+	buf := make([]byte, 4096)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			input := buf[:n]
+			i := bytes.IndexByte(input, detachKey)
+			if i >= 0 {
+				input = input[:i]
+			}
+			if len(input) > 0 {
+				if err := writeFrame(conn, frameInput, input); err != nil {
+					return
+				}
+			}
+			if i >= 0 {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
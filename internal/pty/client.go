@@ -0,0 +1,265 @@
+// Package pty is grove's native session backend. A small daemon runs each
+// session on a pseudo-terminal and keeps a virtual screen of it, so grove
+// works without tmux.
+package pty
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SarthakJariwala/grove/internal/sockdir"
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// daemonStartTimeout bounds the wait for a freshly started daemon to listen.
+const daemonStartTimeout = 3 * time.Second
+
+var errNoDaemon = errors.New("no pty daemon running")
+
+// Client talks to the PTY daemon listening on a unix socket. It offers the
+// same session operations as tmux.Client and starts the daemon when a
+// session is created and none is running.
+type Client struct {
+	socket string
+	// startDaemon starts a daemon listening on socket.
+	startDaemon func() error
+}
+
+// NewClient returns a client for the daemon on socket.
+func NewClient(socket string) *Client {
+	c := &Client{socket: socket}
+	c.startDaemon = c.spawnDaemon
+	return c
+}
+
+// DefaultSocket returns the daemon's socket: under $XDG_RUNTIME_DIR, or a
+// per-user directory in the temp dir.
+func DefaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "grove", "pty.sock")
+	}
+	return filepath.Join(sockdir.Dir(), "pty.sock")
+}
+
+// dial connects to the daemon on socket, once its directory is known to
+// belong to this user alone.
+func dial(socket string) (net.Conn, error) {
+	if err := sockdir.Check(filepath.Dir(socket)); err != nil {
+		return nil, err
+	}
+	return net.Dial("unix", socket)
+}
+
+// executable returns the grove binary, which also runs the daemon and the
+// attach client.
+func executable() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return "grove"
+	}
+	return exe
+}
+
+func (c *Client) spawnDaemon() error {
+	cmd := exec.Command(executable(), DaemonArg, c.socket)
+	cmd.SysProcAttr = daemonAttr()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start pty daemon: %w", err)
+	}
+	go func() { _ = cmd.Wait() }()
+
+	deadline := time.Now().Add(daemonStartTimeout)
+	for time.Now().Before(deadline) {
+		if conn, err := dial(c.socket); err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return fmt.Errorf("start pty daemon: no answer on %s", c.socket)
+}
+
+// call sends one request. With start set, a daemon is started when none
+// answers, or when the running one is about to exit.
+func (c *Client) call(req request, start bool) (response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.roundTrip(req)
+		retry := errors.Is(err, errNoDaemon) || (err != nil && err.Error() == errShuttingDown.Error())
+		if !start || !retry || attempt > 0 {
+			return resp, err
+		}
+		if err := c.startDaemon(); err != nil {
+			return response{}, err
+		}
+	}
+}
+
+func (c *Client) roundTrip(req request) (response, error) {
+	conn, err := dial(c.socket)
+	if errors.Is(err, sockdir.ErrUnsafe) {
+		return response{}, err
+	}
+	if err != nil {
+		return response{}, errNoDaemon
+	}
+	defer conn.Close()
+	if err := writeMessage(conn, req); err != nil {
+		return response{}, fmt.Errorf("pty %s: %w", req.Op, err)
+	}
+	var resp response
+	if err := readMessage(bufio.NewReader(conn), &resp); err != nil {
+		return response{}, fmt.Errorf("pty %s: %w", req.Op, err)
+	}
+	if resp.Error != "" {
+		return response{}, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// do runs a request that acts on an existing session.
+func (c *Client) do(req request) error {
+	if _, err := c.call(req, false); err != nil {
+		return fmt.Errorf("pty %s: %w", req.Op, err)
+	}
+	return nil
+}
+
+func (c *Client) LoadSnapshot() (tmux.SessionSnapshot, error) {
+	resp, err := c.call(request{Op: opList}, false)
+	if err != nil && !errors.Is(err, errNoDaemon) {
+		return tmux.SessionSnapshot{}, fmt.Errorf("pty list: %w", err)
+	}
+	snapshot := tmux.SessionSnapshot{
+		Sessions:       append([]tmux.Session{}, resp.Sessions...),
+		SessionWindows: map[string][]int{},
+		ActiveWindows:  map[string]int{},
+		PaneDataFresh:  true,
+	}
+	// Every session has a single window.
+	for _, session := range snapshot.Sessions {
+		snapshot.SessionWindows[session.Name] = []int{0}
+		snapshot.ActiveWindows[session.Name] = 0
+	}
+	return snapshot, nil
+}
+
+func (c *Client) newSession(req request) error {
+	req.Op = opNew
+	if _, err := c.call(req, true); err != nil {
+		return fmt.Errorf("pty new-session: %w", err)
+	}
+	return nil
+}
+
+// NewSession starts a session running a login shell. env holds KEY=VALUE
+// pairs added to the session environment.
+func (c *Client) NewSession(name, cwd string, env []string) error {
+	return c.newSession(request{Name: name, Dir: cwd, Env: env})
+}
+
+func (c *Client) NewSessionWithCommand(name, cwd, command string, env []string) error {
+	return c.newSession(request{Name: name, Dir: cwd, Command: command, Env: env})
+}
+
+// NewCommandSession starts a session that stays listed with its exit status
+// once command exits. When logPath is set, output is appended to that file.
+func (c *Client) NewCommandSession(name, cwd, command string, env []string, logPath string) error {
+	return c.newSession(request{Name: name, Dir: cwd, Command: command, Env: env, Keep: true, LogPath: logPath})
+}
+
+func (c *Client) SendKeys(target, command string) error {
+	return c.do(request{Op: opSend, Name: sessionName(target), Data: command + "\r"})
+}
+
+// PressKeys sends keys to target by tmux key name, such as C-c or Escape,
+// without pressing Enter after them.
+func (c *Client) PressKeys(target string, keys []string) error {
+	var data strings.Builder
+	for _, key := range keys {
+		data.WriteString(keyInput(key))
+	}
+	return c.do(request{Op: opSend, Name: sessionName(target), Data: data.String()})
+}
+
+// namedKeys maps the tmux key names that are not control characters to the
+// input a terminal sends for them.
+var namedKeys = map[string]string{
+	"Enter":  "\r",
+	"Escape": "\x1b",
+	"Tab":    "\t",
+	"Space":  " ",
+	"BSpace": "\x7f",
+}
+
+// keyInput translates a tmux key name to terminal input. C- with a letter or
+// one of @[\]^_ is that control character; other names are sent as typed.
+func keyInput(key string) string {
+	if input, ok := namedKeys[key]; ok {
+		return input
+	}
+	if len(key) == 3 && strings.HasPrefix(key, "C-") {
+		switch c := key[2]; {
+		case c >= 'a' && c <= 'z':
+			return string(rune(c - 'a' + 1))
+		case c >= '@' && c <= '_':
+			return string(rune(c - '@'))
+		}
+	}
+	return key
+}
+
+func (c *Client) SetSessionOption(target, option, value string) error {
+	return c.do(request{Op: opOption, Name: sessionName(target), Option: option, Value: value})
+}
+
+func (c *Client) RenameSession(oldName, newName string) error {
+	return c.do(request{Op: opRename, Name: oldName, NewName: newName})
+}
+
+func (c *Client) KillSession(name string) error {
+	return c.do(request{Op: opKill, Name: name})
+}
+
+// CapturePane returns the visible screen of target's session, with SGR
+// sequences as tmux capture-pane -e prints them.
+func (c *Client) CapturePane(target string) (string, error) {
+	resp, err := c.call(request{Op: opCapture, Name: sessionName(target)}, false)
+	if err != nil {
+		return "", fmt.Errorf("pty capture: %w", err)
+	}
+	return resp.Screen, nil
+}
+
+// PeekPane is CapturePane: the daemon has no output to follow.
+func (c *Client) PeekPane(target string) (string, error) {
+	return c.CapturePane(target)
+}
+
+// AttachCommand returns the command that attaches the terminal to name:
+// grove's own attach client.
+func (c *Client) AttachCommand(name string) *exec.Cmd {
+	return exec.Command(executable(), AttachArg, c.socket, name)
+}
+
+// DetachKeys names the key the attach client detaches on.
+func (c *Client) DetachKeys(name string) string {
+	return `Ctrl-\`
+}
+
+// Close is a no-op; every request uses its own connection.
+func (c *Client) Close() error {
+	return nil
+}
+
+// sessionName strips a tmux-style window suffix from target.
+func sessionName(target string) string {
+	name, _, _ := strings.Cut(target, ":")
+	return name
+}
@@ -0,0 +1,222 @@
+package pty
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SarthakJariwala/grove/internal/sockdir"
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// startTestDaemon serves a daemon from the test process and returns a client
+// for it.
+func startTestDaemon(t *testing.T) *Client {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "grove", "pty.sock")
+	go func() { _ = Serve(socket) }()
+	c := NewClient(socket)
+	c.startDaemon = func() error {
+		deadline := time.Now().Add(daemonStartTimeout)
+		for time.Now().Before(deadline) {
+			if _, err := os.Stat(socket); err == nil {
+				return nil
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return errNoDaemon
+	}
+	return c
+}
+
+// waitFor polls the client's sessions until ok accepts one named name.
+func waitFor(t *testing.T, c *Client, name string, ok func(tmux.Session) bool) tmux.Session {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		snapshot, err := c.LoadSnapshot()
+		if err != nil {
+			t.Fatalf("LoadSnapshot() error = %v", err)
+		}
+		for _, session := range snapshot.Sessions {
+			if session.Name == name && ok(session) {
+				return session
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sessions = %+v, want %s in the expected state", snapshot.Sessions, name)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestClientRunsSessions(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	c := startTestDaemon(t)
+
+	if err := c.NewSession("api/term-1", t.TempDir(), []string{"GROVE_TEST=shell"}); err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+	if err := c.NewSession("api/term-1", t.TempDir(), nil); err == nil {
+		t.Fatal("NewSession() with a duplicate name error = nil")
+	}
+	if err := c.SendKeys("api/term-1:0", "echo value=$GROVE_TEST"); err != nil {
+		t.Fatalf("SendKeys() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		screen, err := c.CapturePane("api/term-1:0")
+		if err != nil {
+			t.Fatalf("CapturePane() error = %v", err)
+		}
+		if strings.Contains(screen, "value=shell") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("CapturePane() = %q, want the echoed variable", screen)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := c.SetSessionOption("api/term-1", tmux.WorktreeOption, "/tmp/wt"); err != nil {
+		t.Fatalf("SetSessionOption() error = %v", err)
+	}
+	if err := c.RenameSession("api/term-1", "api/term-2"); err != nil {
+		t.Fatalf("RenameSession() error = %v", err)
+	}
+	session := waitFor(t, c, "api/term-2", func(tmux.Session) bool { return true })
+	if session.Worktree != "/tmp/wt" || session.Dead {
+		t.Fatalf("session = %+v, want a live session with its worktree", session)
+	}
+
+	if err := c.KillSession("api/term-2"); err != nil {
+		t.Fatalf("KillSession() error = %v", err)
+	}
+	if err := c.KillSession("api/term-2"); err == nil || !strings.Contains(err.Error(), "can't find session") {
+		t.Fatalf("KillSession() of a killed session error = %v", err)
+	}
+}
+
+func TestClientKeepsExitedCommandSessions(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	c := startTestDaemon(t)
+	logPath := filepath.Join(t.TempDir(), "build.log")
+
+	if err := c.NewCommandSession("api/cmd-build", t.TempDir(), "echo building; exit 3", nil, logPath); err != nil {
+		t.Fatalf("NewCommandSession() error = %v", err)
+	}
+	session := waitFor(t, c, "api/cmd-build", func(s tmux.Session) bool { return s.Dead })
+	if session.ExitStatus != 3 {
+		t.Fatalf("ExitStatus = %d, want 3", session.ExitStatus)
+	}
+
+	screen, err := c.CapturePane("api/cmd-build")
+	if err != nil || !strings.Contains(screen, "building") {
+		t.Fatalf("CapturePane() = %q, %v, want the command output", screen, err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil || !strings.Contains(string(data), "building") {
+		t.Fatalf("log = %q, %v, want the command output", data, err)
+	}
+}
+
+func TestKeyInput(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"C-c":    "\x03",
+		"C-D":    "\x04",
+		"C-\\":   "\x1c",
+		"Escape": "\x1b",
+		"Enter":  "\r",
+		"q":      "q",
+		"C-":     "C-",
+	}
+	for key, want := range tests {
+		if got := keyInput(key); got != want {
+			t.Errorf("keyInput(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestClientWithoutDaemonListsNothing(t *testing.T) {
+	t.Parallel()
+
+	c := NewClient(filepath.Join(t.TempDir(), "grove", "pty.sock"))
+	snapshot, err := c.LoadSnapshot()
+	if err != nil || len(snapshot.Sessions) != 0 {
+		t.Fatalf("LoadSnapshot() = %+v, %v, want no sessions", snapshot, err)
+	}
+	if err := c.KillSession("api/term-1"); err == nil {
+		t.Fatal("KillSession() without a daemon error = nil")
+	}
+}
+
+func TestSocketDirMustBePrivate(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "pty.sock")
+	if err := Serve(socket); !errors.Is(err, sockdir.ErrUnsafe) {
+		t.Fatalf("Serve() error = %v, want sockdir.ErrUnsafe", err)
+	}
+	c := NewClient(socket)
+	c.startDaemon = func() error {
+		t.Fatal("started a daemon in an unsafe directory")
+		return nil
+	}
+	if _, err := c.LoadSnapshot(); !errors.Is(err, sockdir.ErrUnsafe) {
+		t.Fatalf("LoadSnapshot() error = %v, want sockdir.ErrUnsafe", err)
+	}
+}
+
+func TestStalledClientDoesNotBlockTheSession(t *testing.T) {
+	sess := &session{
+		screen:  newScreen(defaultRows, defaultCols),
+		clients: map[net.Conn]bool{},
+		options: map[string]string{},
+		dead:    true,
+	}
+	// Nothing reads from the other end, so writes to conn stall.
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	sess.clients[conn] = true
+
+	done := make(chan struct{})
+	go func() {
+		sess.output([]byte("hello"))
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	listed := make(chan struct{})
+	go func() {
+		sess.info("a")
+		close(listed)
+	}()
+	select {
+	case <-listed:
+	case <-done:
+		t.Fatal("output finished before the session could be listed")
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("listing the session waited on a stalled client")
+	}
+
+	<-done
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if len(sess.clients) != 0 {
+		t.Fatal("stalled client was not dropped")
+	}
+}
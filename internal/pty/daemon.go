@@ -0,0 +1,525 @@
+package pty
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SarthakJariwala/grove/internal/logfile"
+	"github.com/SarthakJariwala/grove/internal/sockdir"
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// New sessions start at tmux's default size until a client attaches.
+const (
+	defaultRows = 24
+	defaultCols = 80
+)
+
+// idleExit is how long the daemon waits without sessions before exiting.
+const idleExit = 5 * time.Second
+
+// killTimeout is how long a killed session's processes get to exit after
+// SIGHUP before they are killed outright.
+const killTimeout = 3 * time.Second
+
+var errShuttingDown = errors.New("pty daemon is shutting down")
+
+// server owns the pseudo-terminals of every session, so sessions outlive the
+// grove that started them, as they do with tmux.
+type server struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	idle     *time.Timer
+	closed   bool
+}
+
+// Serve runs the daemon on socket. It returns once it has had no sessions
+// for a few seconds.
+func Serve(socket string) error {
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return fmt.Errorf("pty daemon: %w", err)
+	}
+	if err := sockdir.Check(filepath.Dir(socket)); err != nil {
+		return fmt.Errorf("pty daemon: %w", err)
+	}
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return fmt.Errorf("pty daemon already running on %s", socket)
+	}
+	// Nothing answers on a socket left behind by a daemon that died.
+	_ = os.Remove(socket)
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("pty daemon: %w", err)
+	}
+
+	s := &server{ln: ln, sessions: map[string]*session{}}
+	s.idle = time.AfterFunc(idleExit, s.exitIfIdle)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.wg.Wait()
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("pty daemon: %w", err)
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *server) exitIfIdle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sessions) == 0 && !s.closed {
+		s.closed = true
+		s.ln.Close()
+	}
+}
+
+// removeLocked drops a session and starts the idle timer when it was the
+// last one. s.mu must be held.
+func (s *server) removeLocked(name string, sess *session) {
+	if s.sessions[name] != sess {
+		return
+	}
+	delete(s.sessions, name)
+	if len(s.sessions) == 0 {
+		s.idle.Reset(idleExit)
+	}
+}
+
+func (s *server) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	var req request
+	if err := readMessage(r, &req); err != nil {
+		conn.Close()
+		return
+	}
+	if req.Op == opAttach {
+		s.attach(conn, r, req)
+		return
+	}
+	defer conn.Close()
+	resp, err := s.serve(req)
+	if err != nil {
+		resp = response{Error: err.Error()}
+	}
+	_ = writeMessage(conn, resp)
+}
+
+func (s *server) serve(req request) (response, error) {
+	switch req.Op {
+	case opList:
+		return response{Sessions: s.list()}, nil
+	case opNew:
+		return response{}, s.create(req)
+	case opRename:
+		return response{}, s.rename(req.Name, req.NewName)
+	case opKill:
+		return response{}, s.kill(req.Name)
+	}
+
+	sess, err := s.lookup(req.Name)
+	if err != nil {
+		return response{}, err
+	}
+	switch req.Op {
+	case opSend:
+		return response{}, sess.send([]byte(req.Data))
+	case opOption:
+		sess.mu.Lock()
+		sess.options[req.Option] = req.Value
+		sess.mu.Unlock()
+		return response{}, nil
+	case opCapture:
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		return response{Screen: sess.screen.capture()}, nil
+	}
+	return response{}, fmt.Errorf("unknown request %q", req.Op)
+}
+
+func (s *server) lookup(name string) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[name]
+	if !ok {
+		return nil, fmt.Errorf("can't find session: %s", name)
+	}
+	return sess, nil
+}
+
+func (s *server) list() []tmux.Session {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.sessions))
+	for name := range s.sessions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sessions := make([]*session, len(names))
+	for i, name := range names {
+		sessions[i] = s.sessions[name]
+	}
+	s.mu.Unlock()
+
+	list := make([]tmux.Session, len(sessions))
+	for i, sess := range sessions {
+		list[i] = sess.info(names[i])
+	}
+	return list
+}
+
+func (s *server) create(req request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errShuttingDown
+	}
+	if _, exists := s.sessions[req.Name]; exists {
+		return fmt.Errorf("duplicate session: %s", req.Name)
+	}
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	cmd := exec.Command(shell)
+	command := shell
+	if fields := strings.Fields(req.Command); len(fields) == 0 {
+		// A login shell, as tmux starts.
+		cmd.Args[0] = "-" + filepath.Base(shell)
+	} else {
+		cmd.Args = append(cmd.Args, "-c", req.Command)
+		command = fields[0]
+	}
+	cmd.Dir = req.Dir
+	cmd.Env = append(sessionEnv(os.Environ()), "TERM=xterm-256color")
+	cmd.Env = append(cmd.Env, req.Env...)
+
+	var log *logfile.Writer
+	if req.LogPath != "" {
+		var err error
+		if log, err = logfile.Open(req.LogPath); err != nil {
+			return err
+		}
+	}
+	master, err := startProcess(cmd, defaultRows, defaultCols)
+	if err != nil {
+		if log != nil {
+			log.Close()
+		}
+		return fmt.Errorf("start session %s: %w", req.Name, err)
+	}
+
+	sess := &session{
+		command:  filepath.Base(command),
+		cmd:      cmd,
+		master:   master,
+		keep:     req.Keep,
+		log:      log,
+		exited:   make(chan struct{}),
+		screen:   newScreen(defaultRows, defaultCols),
+		clients:  map[net.Conn]bool{},
+		activity: time.Now().Unix(),
+		options:  map[string]string{},
+	}
+	s.sessions[req.Name] = sess
+	s.idle.Stop()
+	go s.run(sess)
+	return nil
+}
+
+// sessionEnv drops variables that would make programs in a session think
+// they run inside tmux.
+func sessionEnv(env []string) []string {
+	kept := make([]string, 0, len(env))
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "TMUX=") || strings.HasPrefix(kv, "TMUX_PANE=") || strings.HasPrefix(kv, "TERM=") {
+			continue
+		}
+		kept = append(kept, kv)
+	}
+	return kept
+}
+
+// run copies the session's output into its screen until the process exits.
+func (s *server) run(sess *session) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := sess.master.Read(buf)
+		if n > 0 {
+			sess.output(buf[:n])
+		}
+		if err != nil {
+			break
+		}
+	}
+	_ = sess.cmd.Wait()
+	status, sig := exitInfo(sess.cmd.ProcessState)
+	sess.master.Close()
+	close(sess.exited)
+
+	sess.mu.Lock()
+	sess.dead, sess.exitStatus, sess.exitSignal, sess.exitTime = true, status, sig, time.Now().Unix()
+	sess.closeClients()
+	if sess.log != nil {
+		sess.log.Close()
+		sess.log = nil
+	}
+	keep := sess.keep && !sess.killed
+	sess.mu.Unlock()
+
+	if !keep {
+		s.mu.Lock()
+		for current, candidate := range s.sessions {
+			if candidate == sess {
+				s.removeLocked(current, sess)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *server) rename(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[oldName]
+	if !ok {
+		return fmt.Errorf("can't find session: %s", oldName)
+	}
+	if _, exists := s.sessions[newName]; exists {
+		return fmt.Errorf("duplicate session: %s", newName)
+	}
+	delete(s.sessions, oldName)
+	s.sessions[newName] = sess
+	return nil
+}
+
+// kill removes a session and hangs up on its processes, killing them if
+// they are still running after killTimeout.
+func (s *server) kill(name string) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[name]
+	if ok {
+		s.removeLocked(name, sess)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("can't find session: %s", name)
+	}
+
+	sess.mu.Lock()
+	sess.killed = true
+	dead := sess.dead
+	sess.mu.Unlock()
+	if dead {
+		return nil
+	}
+	pid := sess.cmd.Process.Pid
+	signalGroup(pid, sigHangup)
+	go func() {
+		select {
+		case <-sess.exited:
+		case <-time.After(killTimeout):
+			signalGroup(pid, sigKill)
+		}
+	}()
+	return nil
+}
+
+// attach streams the session to conn until either side goes away.
+func (s *server) attach(conn net.Conn, r *bufio.Reader, req request) {
+	defer conn.Close()
+	sess, err := s.lookup(req.Name)
+	if err == nil && sess.isDead() {
+		err = fmt.Errorf("%s has exited", req.Name)
+	}
+	if err != nil {
+		_ = writeMessage(conn, response{Error: err.Error()})
+		return
+	}
+	if err := writeMessage(conn, response{}); err != nil {
+		return
+	}
+
+	sess.mu.Lock()
+	sess.resize(req.Rows, req.Cols)
+	if _, err := conn.Write([]byte(sess.screen.redraw())); err != nil {
+		sess.mu.Unlock()
+		return
+	}
+	sess.clients[conn] = true
+	sess.screen.bell = false
+	sess.mu.Unlock()
+
+	defer func() {
+		sess.mu.Lock()
+		delete(sess.clients, conn)
+		sess.mu.Unlock()
+	}()
+	for {
+		kind, payload, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		switch kind {
+		case frameInput:
+			if err := sess.send(payload); err != nil {
+				return
+			}
+		case frameResize:
+			rows, cols, err := parseResize(payload)
+			if err != nil {
+				return
+			}
+			sess.mu.Lock()
+			sess.resize(rows, cols)
+			sess.mu.Unlock()
+		}
+	}
+}
+
+type session struct {
+	// command names what the session was started with, for when the
+	// foreground process cannot be read.
+	command string
+	cmd     *exec.Cmd
+	master  *os.File
+	keep    bool
+	// exited is closed once the process has been reaped.
+	exited chan struct{}
+
+	mu       sync.Mutex
+	screen   *screen
+	log      *logfile.Writer
+	clients  map[net.Conn]bool
+	activity int64
+	options  map[string]string
+	killed   bool
+	dead     bool
+
+	exitStatus, exitSignal int
+	exitTime               int64
+}
+
+// output records what the process printed and forwards it to attached
+// clients. Terminal queries are answered here only when no client's
+// terminal is there to answer them. Clients are written to outside the
+// lock, so a slow one holds up only the output, not every other request
+// on the session.
+func (sess *session) output(p []byte) {
+	sess.mu.Lock()
+	_, _ = sess.screen.Write(p)
+	sess.activity = time.Now().Unix()
+	if sess.log != nil {
+		_, _ = sess.log.Write(p)
+	}
+	replies := sess.screen.replies
+	sess.screen.replies = nil
+	if len(sess.clients) == 0 {
+		sess.mu.Unlock()
+		if len(replies) > 0 {
+			_, _ = sess.master.Write(replies)
+		}
+		return
+	}
+	// Only a bell nobody saw is an alert.
+	sess.screen.bell = false
+	clients := make([]net.Conn, 0, len(sess.clients))
+	for conn := range sess.clients {
+		clients = append(clients, conn)
+	}
+	sess.mu.Unlock()
+
+	var failed []net.Conn
+	for _, conn := range clients {
+		_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+		if _, err := conn.Write(p); err != nil {
+			conn.Close()
+			failed = append(failed, conn)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	sess.mu.Lock()
+	for _, conn := range failed {
+		delete(sess.clients, conn)
+	}
+	sess.mu.Unlock()
+}
+
+func (sess *session) send(p []byte) error {
+	if sess.isDead() {
+		return errors.New("session has exited")
+	}
+	_, err := sess.master.Write(p)
+	return err
+}
+
+func (sess *session) isDead() bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.dead
+}
+
+// resize changes the size of the screen and the terminal. sess.mu must be
+// held.
+func (sess *session) resize(rows, cols int) {
+	if rows < 1 || cols < 1 || sess.dead {
+		return
+	}
+	sess.screen.resize(rows, cols)
+	_ = setSize(sess.master, rows, cols)
+}
+
+func (sess *session) closeClients() {
+	for conn := range sess.clients {
+		conn.Close()
+		delete(sess.clients, conn)
+	}
+}
+
+func (sess *session) info(name string) tmux.Session {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	info := tmux.Session{
+		Name:           name,
+		Windows:        1,
+		Attached:       len(sess.clients) > 0,
+		HasAlerts:      sess.screen.bell,
+		AlertsBell:     sess.screen.bell,
+		LastActivity:   sess.activity,
+		LastOutput:     sess.activity,
+		CurrentCommand: sess.command,
+		Worktree:       sess.options[tmux.WorktreeOption],
+		Dead:           sess.dead,
+		ExitStatus:     sess.exitStatus,
+		ExitSignal:     sess.exitSignal,
+		ExitTime:       sess.exitTime,
+	}
+	info.TitleGlyph, info.PaneTitle = tmux.SplitPaneTitle(sess.screen.title)
+	if !sess.dead {
+		if command, dir := foreground(sess.master); command != "" {
+			info.CurrentCommand, info.CurrentPath = command, dir
+		}
+	}
+	return info
+}
@@ -0,0 +1,121 @@
+package pty
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// Hidden grove subcommands that run the daemon and the attach client. main
+// dispatches them before parsing flags.
+const (
+	DaemonArg = "_pty-daemon"
+	AttachArg = "_pty-attach"
+)
+
+// A client sends one JSON request line and reads one JSON response line.
+// After a successful attach the connection carries the session's output to
+// the client and frames from the client to the session.
+type request struct {
+	Op      string   `json:"op"`
+	Name    string   `json:"name,omitempty"`
+	NewName string   `json:"new_name,omitempty"`
+	Dir     string   `json:"dir,omitempty"`
+	Command string   `json:"command,omitempty"`
+	Env     []string `json:"env,omitempty"`
+	// Keep leaves the session in place once its process exits.
+	Keep    bool   `json:"keep,omitempty"`
+	LogPath string `json:"log_path,omitempty"`
+	Data    string `json:"data,omitempty"`
+	Option  string `json:"option,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Rows    int    `json:"rows,omitempty"`
+	Cols    int    `json:"cols,omitempty"`
+}
+
+type response struct {
+	Error    string         `json:"error,omitempty"`
+	Sessions []tmux.Session `json:"sessions,omitempty"`
+	Screen   string         `json:"screen,omitempty"`
+}
+
+const (
+	opList    = "list"
+	opNew     = "new"
+	opSend    = "send"
+	opOption  = "option"
+	opRename  = "rename"
+	opKill    = "kill"
+	opCapture = "capture"
+	opAttach  = "attach"
+)
+
+func writeMessage(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func readMessage(r *bufio.Reader, v any) error {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(line, v)
+}
+
+// Frames sent by an attached client: a type byte, a big-endian length and
+// the payload.
+const (
+	frameInput  = 'i'
+	frameResize = 'r'
+)
+
+// maxFrame bounds a frame's payload; input arrives in small reads.
+const maxFrame = 1 << 20
+
+func writeFrame(w io.Writer, kind byte, payload []byte) error {
+	header := make([]byte, 5, 5+len(payload))
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrame {
+		return 0, nil, fmt.Errorf("pty frame of %d bytes", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+func resizePayload(rows, cols int) []byte {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint16(payload, uint16(rows))
+	binary.BigEndian.PutUint16(payload[2:], uint16(cols))
+	return payload
+}
+
+func parseResize(payload []byte) (rows, cols int, err error) {
+	if len(payload) != 4 {
+		return 0, 0, errors.New("malformed resize frame")
+	}
+	return int(binary.BigEndian.Uint16(payload)), int(binary.BigEndian.Uint16(payload[2:])), nil
+}
@@ -0,0 +1,60 @@
+package pty
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func openPTY() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open pty: %w", err)
+	}
+	var name [128]byte
+	err = ioctl(master, func(fd int) error {
+		if err := unix.IoctlSetInt(fd, unix.TIOCPTYGRANT, 0); err != nil {
+			return err
+		}
+		if err := unix.IoctlSetInt(fd, unix.TIOCPTYUNLK, 0); err != nil {
+			return err
+		}
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(unix.TIOCPTYGNAME), uintptr(unsafe.Pointer(&name[0]))); errno != 0 {
+			return errno
+		}
+		return nil
+	})
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("open pty: %w", err)
+	}
+	path := string(name[:bytes.IndexByte(name[:], 0)])
+	slave, err = os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("open pty: %w", err)
+	}
+	return master, slave, nil
+}
+
+// foreground returns the name of the terminal's foreground process. macOS
+// does not expose another process's working directory without cgo.
+func foreground(master *os.File) (command, dir string) {
+	pgid := foregroundGroup(master)
+	if pgid <= 0 {
+		return "", ""
+	}
+	proc, err := unix.SysctlKinfoProc("kern.proc.pid", pgid)
+	if err != nil {
+		return "", ""
+	}
+	comm := proc.Proc.P_comm[:]
+	if i := bytes.IndexByte(comm, 0); i >= 0 {
+		comm = comm[:i]
+	}
+	return string(comm), ""
+}
@@ -0,0 +1,50 @@
+package pty
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+func openPTY() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open pty: %w", err)
+	}
+	var n uint32
+	err = ioctl(master, func(fd int) error {
+		if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+			return err
+		}
+		n, err = unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+		return err
+	})
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("open pty: %w", err)
+	}
+	slave, err = os.OpenFile("/dev/pts/"+strconv.FormatUint(uint64(n), 10), os.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("open pty: %w", err)
+	}
+	return master, slave, nil
+}
+
+// foreground returns the name and working directory of the terminal's
+// foreground process.
+func foreground(master *os.File) (command, dir string) {
+	pgid := foregroundGroup(master)
+	if pgid <= 0 {
+		return "", ""
+	}
+	proc := "/proc/" + strconv.Itoa(pgid)
+	if comm, err := os.ReadFile(proc + "/comm"); err == nil {
+		command = strings.TrimSpace(string(comm))
+	}
+	dir, _ = os.Readlink(proc + "/cwd")
+	return command, dir
+}
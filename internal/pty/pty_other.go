@@ -0,0 +1,33 @@
+//go:build !linux && !darwin
+
+package pty
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var errUnsupported = errors.New("the pty backend is not supported on this platform")
+
+func startProcess(cmd *exec.Cmd, rows, cols int) (*os.File, error) {
+	return nil, errUnsupported
+}
+
+func setSize(master *os.File, rows, cols int) error { return errUnsupported }
+
+func foreground(master *os.File) (command, dir string) { return "", "" }
+
+func signalGroup(pid int, sig syscall.Signal) {}
+
+func exitInfo(state *os.ProcessState) (status, sig int) { return state.ExitCode(), 0 }
+
+func daemonAttr() *syscall.SysProcAttr { return nil }
+
+func notifyResize(ch chan<- os.Signal) {}
+
+const (
+	sigHangup = syscall.Signal(1)
+	sigKill   = syscall.Signal(9)
+)
@@ -0,0 +1,95 @@
+//go:build linux || darwin
+
+package pty
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// startProcess starts cmd as a session leader with a new pseudo-terminal as
+// its controlling terminal and returns the master side.
+func startProcess(cmd *exec.Cmd, rows, cols int) (*os.File, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	defer slave.Close()
+	if err := setSize(master, rows, cols); err != nil {
+		master.Close()
+		return nil, err
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return master, nil
+}
+
+// ioctl runs fn on f's descriptor without switching f to blocking mode, so
+// a pending Read still returns when f is closed.
+func ioctl(f *os.File, fn func(fd int) error) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := conn.Control(func(fd uintptr) { fnErr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+func setSize(master *os.File, rows, cols int) error {
+	return ioctl(master, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: uint16(rows), Col: uint16(cols)})
+	})
+}
+
+// foregroundGroup returns the process group in the terminal's foreground.
+func foregroundGroup(master *os.File) int {
+	pgid := 0
+	_ = ioctl(master, func(fd int) error {
+		var err error
+		pgid, err = unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+		return err
+	})
+	return pgid
+}
+
+// signalGroup signals the process group led by pid.
+func signalGroup(pid int, sig syscall.Signal) {
+	_ = syscall.Kill(-pid, sig)
+}
+
+// exitInfo returns the exit status, or the signal that killed the process.
+func exitInfo(state *os.ProcessState) (status, sig int) {
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return state.ExitCode(), 0
+	}
+	if ws.Signaled() {
+		return 0, int(ws.Signal())
+	}
+	return ws.ExitStatus(), 0
+}
+
+// daemonAttr detaches the daemon from grove's terminal and process group.
+func daemonAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+func notifyResize(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGWINCH)
+}
+
+const (
+	sigHangup = syscall.SIGHUP
+	sigKill   = syscall.SIGKILL
+)
@@ -0,0 +1,562 @@
+package pty
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mattn/go-runewidth"
+)
+
+// screen is a virtual terminal. It interprets a session's output the way a
+// terminal would, so the visible contents can be captured and redrawn for a
+// client that attaches later. It covers what shells and full-screen programs
+// commonly use: cursor movement, erasing, scroll regions, the alternate
+// screen and SGR attributes.
+type screen struct {
+	rows, cols int
+	grid       [][]cell
+	// primary holds the main screen while the alternate screen is shown.
+	primary [][]cell
+
+	row, col int
+	// wrapNext is set after printing in the last column; the next rune
+	// wraps to a new line.
+	wrapNext bool
+	// pen is what new cells are drawn with.
+	pen pen
+
+	savedRow, savedCol int
+	savedPen           pen
+
+	// top and bottom bound the scroll region.
+	top, bottom  int
+	cursorHidden bool
+
+	title string
+	// bell is set when the program rings the bell.
+	bell bool
+	// replies collects answers to terminal queries, for when no terminal is
+	// attached to give them.
+	replies []byte
+
+	parser *ansi.Parser
+}
+
+// cell is one column of the grid. r is 0 in the column covered by the right
+// half of a wide rune.
+type cell struct {
+	r   rune
+	pen pen
+}
+
+// pen is the graphic rendition set by SGR: the attributes that are on, as a
+// bit per SGR code, and the underline and colors, each kept as the
+// parameters that set it so capture replays them as the program sent them.
+type pen struct {
+	attrs           uint64
+	underline       string
+	fg, bg, ulColor string
+}
+
+// String returns the pen as SGR parameters, empty for the default pen.
+func (p pen) String() string {
+	var parts []string
+	for code := 1; code < 64; code++ {
+		if p.attrs&(1<<code) != 0 {
+			parts = append(parts, strconv.Itoa(code))
+		}
+	}
+	for _, part := range []string{p.underline, p.fg, p.bg, p.ulColor} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// set applies one SGR attribute, given by its code and its parameters as
+// written.
+func (p *pen) set(code int, attr string) {
+	switch {
+	case code == 0:
+		*p = pen{}
+	case code == 1, code == 2, code == 3, code >= 5 && code <= 9, code == 53:
+		p.attrs |= 1 << code
+	case code == 22:
+		p.attrs &^= 1<<1 | 1<<2
+	case code == 23:
+		p.attrs &^= 1 << 3
+	case code == 25:
+		p.attrs &^= 1<<5 | 1<<6
+	case code >= 27 && code <= 29:
+		p.attrs &^= 1 << (code - 20)
+	case code == 55:
+		p.attrs &^= 1 << 53
+	case code == 4:
+		// 4:0 turns the underline off; 4:n picks its style.
+		p.underline = attr
+		if attr == "4:0" {
+			p.underline = ""
+		}
+	case code == 24:
+		p.underline = ""
+	case code >= 30 && code <= 38, code >= 90 && code <= 97:
+		p.fg = attr
+	case code == 39:
+		p.fg = ""
+	case code >= 40 && code <= 48, code >= 100 && code <= 107:
+		p.bg = attr
+	case code == 49:
+		p.bg = ""
+	case code == 58:
+		p.ulColor = attr
+	case code == 59:
+		p.ulColor = ""
+	}
+}
+
+var blank = cell{r: ' '}
+
+func newScreen(rows, cols int) *screen {
+	s := &screen{parser: ansi.NewParser()}
+	s.parser.SetHandler(ansi.Handler{
+		Print:     s.print,
+		Execute:   s.execute,
+		HandleCsi: s.csi,
+		HandleEsc: s.esc,
+		HandleOsc: s.osc,
+	})
+	s.reset(rows, cols)
+	return s
+}
+
+func (s *screen) reset(rows, cols int) {
+	*s = screen{rows: rows, cols: cols, bottom: rows - 1, parser: s.parser}
+	s.grid = blankGrid(rows, cols)
+}
+
+func blankGrid(rows, cols int) [][]cell {
+	grid := make([][]cell, rows)
+	for i := range grid {
+		grid[i] = blankLine(cols)
+	}
+	return grid
+}
+
+func blankLine(cols int) []cell {
+	line := make([]cell, cols)
+	for i := range line {
+		line[i] = blank
+	}
+	return line
+}
+
+func (s *screen) Write(p []byte) (int, error) {
+	for _, b := range p {
+		s.parser.Advance(b)
+	}
+	return len(p), nil
+}
+
+// resize changes the screen size, keeping the lines around the cursor.
+func (s *screen) resize(rows, cols int) {
+	if rows < 1 || cols < 1 || (rows == s.rows && cols == s.cols) {
+		return
+	}
+	shift := 0
+	if s.row >= rows {
+		shift = s.row - rows + 1
+	}
+	fit := func(old [][]cell) [][]cell {
+		grid := blankGrid(rows, cols)
+		for r := 0; r < rows && r+shift < len(old); r++ {
+			copy(grid[r], old[r+shift])
+		}
+		return grid
+	}
+	s.grid = fit(s.grid)
+	if s.primary != nil {
+		s.primary = fit(s.primary)
+	}
+	s.rows, s.cols = rows, cols
+	s.row -= shift
+	s.col = min(s.col, cols-1)
+	s.top, s.bottom = 0, rows-1
+	s.wrapNext = false
+}
+
+// render returns the visible lines, with SGR sequences when styled is set.
+// Trailing blanks are dropped from each line, as tmux capture-pane does.
+func (s *screen) render(styled bool) []string {
+	lines := make([]string, s.rows)
+	for i, line := range s.grid {
+		end := len(line)
+		for end > 0 && line[end-1] == blank {
+			end--
+		}
+		var b strings.Builder
+		style := pen{}
+		for _, c := range line[:end] {
+			if c.r == 0 {
+				continue
+			}
+			if styled && c.pen != style {
+				b.WriteString("\x1b[0m")
+				if c.pen != (pen{}) {
+					b.WriteString("\x1b[" + c.pen.String() + "m")
+				}
+				style = c.pen
+			}
+			b.WriteRune(c.r)
+		}
+		if styled && style != (pen{}) {
+			b.WriteString("\x1b[0m")
+		}
+		lines[i] = b.String()
+	}
+	return lines
+}
+
+// capture returns the screen as capture-pane -p -e would print it.
+func (s *screen) capture() string {
+	return strings.Join(s.render(true), "\n") + "\n"
+}
+
+// redraw returns the output that paints the screen on a blank terminal and
+// puts the cursor back where the program left it.
+func (s *screen) redraw() string {
+	var b strings.Builder
+	if s.primary != nil {
+		b.WriteString("\x1b[?1049h")
+	}
+	b.WriteString("\x1b[0m\x1b[H\x1b[2J")
+	b.WriteString(strings.Join(s.render(true), "\r\n"))
+	fmt.Fprintf(&b, "\x1b[%d;%dH", s.row+1, s.col+1)
+	if s.pen != (pen{}) {
+		b.WriteString("\x1b[" + s.pen.String() + "m")
+	}
+	if s.cursorHidden {
+		b.WriteString("\x1b[?25l")
+	}
+	return b.String()
+}
+
+func (s *screen) print(r rune) {
+	width := runewidth.RuneWidth(r)
+	if width == 0 || width > s.cols {
+		return
+	}
+	if s.wrapNext || s.col+width > s.cols {
+		s.col = 0
+		s.lineFeed()
+	}
+	s.grid[s.row][s.col] = cell{r: r, pen: s.pen}
+	if width == 2 {
+		s.grid[s.row][s.col+1] = cell{pen: s.pen}
+	}
+	s.col += width
+	if s.col >= s.cols {
+		s.col = s.cols - 1
+		s.wrapNext = true
+	}
+}
+
+func (s *screen) execute(b byte) {
+	switch b {
+	case '\a':
+		s.bell = true
+	case '\b':
+		if s.col > 0 {
+			s.col--
+		}
+		s.wrapNext = false
+	case '\t':
+		s.col = min((s.col/8+1)*8, s.cols-1)
+	case '\n', '\v', '\f':
+		s.lineFeed()
+	case '\r':
+		s.col = 0
+		s.wrapNext = false
+	}
+}
+
+// lineFeed moves the cursor down, scrolling at the bottom of the scroll
+// region.
+func (s *screen) lineFeed() {
+	s.wrapNext = false
+	if s.row == s.bottom {
+		s.scrollUp(1)
+	} else if s.row < s.rows-1 {
+		s.row++
+	}
+}
+
+func (s *screen) reverseIndex() {
+	s.wrapNext = false
+	if s.row == s.top {
+		s.scrollDown(1)
+	} else if s.row > 0 {
+		s.row--
+	}
+}
+
+// scrollUp moves the lines of the scroll region up by n, adding blank lines
+// at the bottom.
+func (s *screen) scrollUp(n int) {
+	s.deleteLines(s.top, n)
+}
+
+// scrollDown moves the lines of the scroll region down by n, adding blank
+// lines at the top.
+func (s *screen) scrollDown(n int) {
+	s.insertLines(s.top, n)
+}
+
+func (s *screen) insertLines(at, n int) {
+	if at < s.top || at > s.bottom {
+		return
+	}
+	n = min(n, s.bottom-at+1)
+	copy(s.grid[at+n:s.bottom+1], s.grid[at:s.bottom+1-n])
+	for i := at; i < at+n; i++ {
+		s.grid[i] = blankLine(s.cols)
+	}
+}
+
+func (s *screen) deleteLines(at, n int) {
+	if at < s.top || at > s.bottom {
+		return
+	}
+	n = min(n, s.bottom-at+1)
+	copy(s.grid[at:s.bottom+1-n], s.grid[at+n:s.bottom+1])
+	for i := s.bottom + 1 - n; i <= s.bottom; i++ {
+		s.grid[i] = blankLine(s.cols)
+	}
+}
+
+func (s *screen) eraseCells(row, from, to int) {
+	line := s.grid[row]
+	for i := max(from, 0); i < min(to, len(line)); i++ {
+		line[i] = blank
+	}
+}
+
+func (s *screen) moveTo(row, col int) {
+	s.row = min(max(row, 0), s.rows-1)
+	s.col = min(max(col, 0), s.cols-1)
+	s.wrapNext = false
+}
+
+func (s *screen) saveCursor() {
+	s.savedRow, s.savedCol, s.savedPen = s.row, s.col, s.pen
+}
+
+func (s *screen) restoreCursor() {
+	s.moveTo(s.savedRow, s.savedCol)
+	s.pen = s.savedPen
+}
+
+func (s *screen) csi(cmd ansi.Cmd, params ansi.Params) {
+	// n returns the i-th parameter, treating a missing or zero value as def.
+	n := func(i, def int) int {
+		v, _, _ := params.Param(i, def)
+		if v == 0 {
+			return def
+		}
+		return v
+	}
+	if cmd.Prefix() == '?' {
+		if final := cmd.Final(); final == 'h' || final == 'l' {
+			params.ForEach(0, func(_, mode int, _ bool) {
+				s.setMode(mode, final == 'h')
+			})
+		}
+		return
+	}
+	if cmd.Prefix() != 0 || cmd.Intermediate() != 0 {
+		return
+	}
+
+	switch cmd.Final() {
+	case 'A':
+		s.moveTo(s.row-n(0, 1), s.col)
+	case 'B', 'e':
+		s.moveTo(s.row+n(0, 1), s.col)
+	case 'C', 'a':
+		s.moveTo(s.row, s.col+n(0, 1))
+	case 'D':
+		s.moveTo(s.row, s.col-n(0, 1))
+	case 'E':
+		s.moveTo(s.row+n(0, 1), 0)
+	case 'F':
+		s.moveTo(s.row-n(0, 1), 0)
+	case 'G', '`':
+		s.moveTo(s.row, n(0, 1)-1)
+	case 'd':
+		s.moveTo(n(0, 1)-1, s.col)
+	case 'H', 'f':
+		s.moveTo(n(0, 1)-1, n(1, 1)-1)
+	case 'J':
+		mode, _, _ := params.Param(0, 0)
+		switch mode {
+		case 0:
+			s.eraseCells(s.row, s.col, s.cols)
+			for r := s.row + 1; r < s.rows; r++ {
+				s.grid[r] = blankLine(s.cols)
+			}
+		case 1:
+			s.eraseCells(s.row, 0, s.col+1)
+			for r := 0; r < s.row; r++ {
+				s.grid[r] = blankLine(s.cols)
+			}
+		case 2, 3:
+			s.grid = blankGrid(s.rows, s.cols)
+		}
+	case 'K':
+		mode, _, _ := params.Param(0, 0)
+		switch mode {
+		case 0:
+			s.eraseCells(s.row, s.col, s.cols)
+		case 1:
+			s.eraseCells(s.row, 0, s.col+1)
+		case 2:
+			s.eraseCells(s.row, 0, s.cols)
+		}
+	case 'L':
+		s.insertLines(s.row, n(0, 1))
+	case 'M':
+		s.deleteLines(s.row, n(0, 1))
+	case '@':
+		line := s.grid[s.row]
+		count := min(n(0, 1), s.cols-s.col)
+		copy(line[s.col+count:], line[s.col:])
+		s.eraseCells(s.row, s.col, s.col+count)
+	case 'P':
+		line := s.grid[s.row]
+		count := min(n(0, 1), s.cols-s.col)
+		copy(line[s.col:], line[s.col+count:])
+		s.eraseCells(s.row, s.cols-count, s.cols)
+	case 'X':
+		s.eraseCells(s.row, s.col, s.col+n(0, 1))
+	case 'S':
+		s.scrollUp(n(0, 1))
+	case 'T':
+		s.scrollDown(n(0, 1))
+	case 'r':
+		top, bottom := n(0, 1)-1, n(1, s.rows)-1
+		if top < bottom && bottom < s.rows {
+			s.top, s.bottom = top, bottom
+			s.moveTo(0, 0)
+		}
+	case 's':
+		s.saveCursor()
+	case 'u':
+		s.restoreCursor()
+	case 'm':
+		s.sgr(params)
+	case 'n':
+		switch v, _, _ := params.Param(0, 0); v {
+		case 5:
+			s.replies = append(s.replies, "\x1b[0n"...)
+		case 6:
+			s.replies = fmt.Appendf(s.replies, "\x1b[%d;%dR", s.row+1, s.col+1)
+		}
+	case 'c':
+		s.replies = append(s.replies, "\x1b[?1;2c"...)
+	}
+}
+
+func (s *screen) setMode(mode int, on bool) {
+	switch mode {
+	case 25:
+		s.cursorHidden = !on
+	case 47, 1047, 1049:
+		if on == (s.primary != nil) {
+			return
+		}
+		if on {
+			if mode == 1049 {
+				s.saveCursor()
+			}
+			s.primary, s.grid = s.grid, blankGrid(s.rows, s.cols)
+			return
+		}
+		s.grid, s.primary = s.primary, nil
+		if mode == 1049 {
+			s.restoreCursor()
+		}
+	}
+}
+
+// sgr updates the pen. Each attribute replaces the one it conflicts with, so
+// the pen holds only what is in effect.
+func (s *screen) sgr(params ansi.Params) {
+	if len(params) == 0 {
+		s.pen = pen{}
+		return
+	}
+	for i := 0; i < len(params); i++ {
+		code := params[i].Param(0)
+		// Colon-separated sub-parameters form one attribute.
+		if params[i].HasMore() {
+			attr := strconv.Itoa(code)
+			for params[i].HasMore() && i+1 < len(params) {
+				i++
+				attr += ":" + strconv.Itoa(params[i].Param(0))
+			}
+			s.pen.set(code, attr)
+			continue
+		}
+		count := 1
+		switch code {
+		case 38, 48, 58:
+			// Extended colors: 5;index or 2;r;g;b.
+			if i+1 < len(params) {
+				switch params[i+1].Param(0) {
+				case 5:
+					count = 3
+				case 2:
+					count = 5
+				}
+			}
+		}
+		attr := make([]string, 0, count)
+		for j := i; j < i+count && j < len(params); j++ {
+			attr = append(attr, strconv.Itoa(params[j].Param(0)))
+		}
+		s.pen.set(code, strings.Join(attr, ";"))
+		i += count - 1
+	}
+}
+
+func (s *screen) esc(cmd ansi.Cmd) {
+	if cmd.Intermediate() != 0 {
+		return
+	}
+	switch cmd.Final() {
+	case '7':
+		s.saveCursor()
+	case '8':
+		s.restoreCursor()
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.col = 0
+		s.lineFeed()
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.reset(s.rows, s.cols)
+	}
+}
+
+func (s *screen) osc(cmd int, data []byte) {
+	if cmd != 0 && cmd != 2 {
+		return
+	}
+	if _, title, ok := strings.Cut(string(data), ";"); ok {
+		s.title = title
+	}
+}
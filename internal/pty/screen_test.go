@@ -0,0 +1,106 @@
+package pty
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScreenInterpretsOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{
+			name:   "lines and carriage returns",
+			output: "hello\r\nworld\rW",
+			want:   []string{"hello", "World", ""},
+		},
+		{
+			name:   "wraps at the last column",
+			output: "abcdefg",
+			want:   []string{"abcde", "fg", ""},
+		},
+		{
+			name:   "scrolls at the bottom",
+			output: "1\r\n2\r\n3\r\n4",
+			want:   []string{"2", "3", "4"},
+		},
+		{
+			name:   "cursor position and erase line",
+			output: "aaaaa\r\nbbbbb\x1b[1;3HX\x1b[K\x1b[2;2H\x1b[1K",
+			want:   []string{"aaX", "  bbb", ""},
+		},
+		{
+			name:   "erase display",
+			output: "aaaaa\r\nbbbbb\r\nccccc\x1b[2;3H\x1b[J",
+			want:   []string{"aaaaa", "bb", ""},
+		},
+		{
+			name:   "insert and delete characters",
+			output: "abcde\x1b[1;2H\x1b[2P\x1b[1;1H\x1b[@",
+			want:   []string{" ade", "", ""},
+		},
+		{
+			name:   "alternate screen restores the main one",
+			output: "main\x1b[?1049hfull screen\x1b[?1049lX",
+			want:   []string{"mainX", "", ""},
+		},
+		{
+			name:   "wide runes take two columns",
+			output: "日本語",
+			want:   []string{"日本", "語", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newScreen(3, 5)
+			s.Write([]byte(tt.output))
+			if got := s.render(false); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("screen = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreenPenReplacesConflictingAttributes(t *testing.T) {
+	t.Parallel()
+
+	s := newScreen(1, 20)
+	for i := 0; i < 100; i++ {
+		s.Write([]byte("\x1b[31m\x1b[1;4:3m\x1b[32;48;2;1;2;3m\x1b[22;24;49m"))
+	}
+	if got := s.pen.String(); got != "32" {
+		t.Fatalf("pen = %q, want only the last foreground in effect", got)
+	}
+	s.Write([]byte("\x1b[7;4m\x1b[38:5:9mx\x1b[27;39m"))
+	if got := s.pen.String(); got != "4" {
+		t.Fatalf("pen = %q, want the underline left on", got)
+	}
+	if want := "\x1b[0m\x1b[7;4;38:5:9mx\x1b[0m\n"; s.capture() != want {
+		t.Fatalf("capture() = %q, want %q", s.capture(), want)
+	}
+}
+
+func TestScreenCaptureKeepsAttributes(t *testing.T) {
+	t.Parallel()
+
+	s := newScreen(2, 20)
+	s.Write([]byte("\x1b]0;build\x07plain \x1b[1;31mred\x1b[38;5;0m black\x1b[0m done\a\x1b[6n"))
+
+	want := "plain \x1b[0m\x1b[1;31mred\x1b[0m\x1b[1;38;5;0m black\x1b[0m done\n\n"
+	if got := s.capture(); got != want {
+		t.Fatalf("capture() = %q, want %q", got, want)
+	}
+	if s.title != "build" || !s.bell {
+		t.Fatalf("title = %q, bell = %v, want the title and bell recorded", s.title, s.bell)
+	}
+	if string(s.replies) != "\x1b[1;20R" {
+		t.Fatalf("replies = %q, want a cursor position report", s.replies)
+	}
+}
@@ -0,0 +1,45 @@
+// Package seen remembers when each session was last looked at, so alerts
+// raised before then stay acknowledged across grove restarts.
+package seen
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/statefile"
+)
+
+// MaxAge is how long a session's entry is kept after it was last seen.
+const MaxAge = 30 * 24 * time.Hour
+
+// Path returns the file the last-seen times are kept in.
+func Path() string {
+	return filepath.Join(config.StateDir(), "seen.json")
+}
+
+// Load reads the last-seen times, in Unix seconds by session name. A missing
+// file holds no times.
+func Load(path string) (map[string]int64, error) {
+	times := map[string]int64{}
+	if err := statefile.Load(path, &times); err != nil {
+		return map[string]int64{}, fmt.Errorf("read seen state: %w", err)
+	}
+	return times, nil
+}
+
+// Save writes the last-seen times, dropping those older than MaxAge.
+func Save(path string, times map[string]int64, now time.Time) error {
+	kept := make(map[string]int64, len(times))
+	cutoff := now.Add(-MaxAge).Unix()
+	for name, at := range times {
+		if at >= cutoff {
+			kept[name] = at
+		}
+	}
+	if err := statefile.Save(path, kept); err != nil {
+		return fmt.Errorf("write seen state: %w", err)
+	}
+	return nil
+}
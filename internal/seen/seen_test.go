@@ -0,0 +1,28 @@
+package seen
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveDropsOldEntries(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state", "seen.json")
+	now := time.Unix(1700000000, 0)
+	err := Save(path, map[string]int64{
+		"api/term-1": now.Unix(),
+		"api/term-2": now.Add(-MaxAge - time.Hour).Unix(),
+	}, now)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	times, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(times) != 1 || times["api/term-1"] != now.Unix() {
+		t.Fatalf("Load() = %v, want only the recent entry", times)
+	}
+}
@@ -0,0 +1,19 @@
+// Package sockdir locates the private directory grove keeps its sockets in:
+// the pty daemon's, and the shared ssh connections to remote hosts.
+package sockdir
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrUnsafe reports a socket directory that other users could use to stand
+// in for grove's sockets or reach what is behind them.
+var ErrUnsafe = errors.New("unsafe socket directory")
+
+// Dir returns the current user's socket directory under the system temp dir.
+func Dir() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("grove-%d", os.Getuid()))
+}
@@ -0,0 +1,6 @@
+//go:build !linux && !darwin
+
+package sockdir
+
+// Check accepts any directory where ownership and modes cannot be checked.
+func Check(dir string) error { return nil }
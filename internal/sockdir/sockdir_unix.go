@@ -0,0 +1,32 @@
+//go:build linux || darwin
+
+package sockdir
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Check refuses a socket directory that is not owned by this user or that
+// other users can enter, as tmux does. A directory that does not exist yet
+// is left for the caller to create.
+func Check(dir string) error {
+	info, err := os.Lstat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w %s: not a directory", ErrUnsafe, dir)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%w %s: owned by uid %d, not %d", ErrUnsafe, dir, st.Uid, os.Getuid())
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return fmt.Errorf("%w %s: mode %#o, want 0700", ErrUnsafe, dir, perm)
+	}
+	return nil
+}
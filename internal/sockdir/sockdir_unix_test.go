@@ -0,0 +1,43 @@
+//go:build linux || darwin
+
+package sockdir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	if err := Check(missing); err != nil {
+		t.Fatalf("Check(missing) error = %v, want nil", err)
+	}
+
+	private := filepath.Join(dir, "private")
+	if err := os.Mkdir(private, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(private); err != nil {
+		t.Fatalf("Check(0700) error = %v, want nil", err)
+	}
+
+	if err := os.Chmod(private, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(private); !errors.Is(err, ErrUnsafe) {
+		t.Fatalf("Check(0755) error = %v, want ErrUnsafe", err)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(file); !errors.Is(err, ErrUnsafe) {
+		t.Fatalf("Check(file) error = %v, want ErrUnsafe", err)
+	}
+}
@@ -0,0 +1,54 @@
+package statefile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadLeavesValueWhenFileIsMissing(t *testing.T) {
+	t.Parallel()
+
+	v := map[string]int{"kept": 1}
+	if err := Load(filepath.Join(t.TempDir(), "missing.json"), &v); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(v, map[string]int{"kept": 1}) {
+		t.Fatalf("value = %v, want it untouched", v)
+	}
+}
+
+func TestSaveCreatesDirAndLeavesNoTempFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "state")
+	path := filepath.Join(dir, "things.json")
+	for _, want := range []map[string]int{{"a": 1}, {"b": 2}} {
+		if err := Save(path, want); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		var got map[string]int
+		if err := Load(path, &got); err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("Load() = (%v, %v), want %v", got, err, want)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("state dir holds %v (%v), want only things.json", entries, err)
+	}
+}
+
+func TestLoadReportsCorruptFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]int
+	if err := Load(path, &v); err == nil || !strings.Contains(err.Error(), path) {
+		t.Fatalf("Load() error = %v, want one naming the file", err)
+	}
+}
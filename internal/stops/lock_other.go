@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package stops
+
+// lock does nothing where flock is unavailable, leaving concurrent stops to
+// race.
+func lock(path string) (func(), error) {
+	return func() {}, nil
+}
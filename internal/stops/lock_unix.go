@@ -0,0 +1,30 @@
+//go:build linux || darwin
+
+package stops
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lock takes an exclusive lock on a file next to the stops, which other
+// grove processes wait on before reading and rewriting them. The returned
+// func releases the lock.
+func lock(path string) (func(), error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create state directory %q: %w", dir, err)
+	}
+	lockPath := path + ".lock"
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open command stops lock %q: %w", lockPath, err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("lock command stops %q: %w", path, err)
+	}
+	return func() { _ = file.Close() }, nil
+}
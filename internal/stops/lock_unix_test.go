@@ -0,0 +1,42 @@
+//go:build linux || darwin
+
+package stops
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMarkWaitsForLock(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "stops.json")
+	unlock, err := lock(path)
+	if err != nil {
+		t.Fatalf("lock() error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Mark(path, "api/cmd-web", time.Unix(1700000000, 0))
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Mark() = %v while another process holds the lock, want it to wait", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Mark() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Mark() still waiting after the lock was released")
+	}
+	if stopped, err := Load(path); err != nil || stopped["api/cmd-web"] != 1700000000 {
+		t.Fatalf("Load() = %v, %v, want the stop recorded", stopped, err)
+	}
+}
@@ -0,0 +1,70 @@
+// Package stops records the commands that were stopped on purpose, from
+// grove or the grove CLI, so every running grove can tell a stop from a crash
+// and leave the command stopped instead of restarting it.
+package stops
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/statefile"
+)
+
+// Path returns the file the stops are kept in.
+func Path() string {
+	return filepath.Join(config.StateDir(), "stops.json")
+}
+
+// Load reads when each command was stopped, in Unix seconds by session name.
+// A missing file holds no stops.
+func Load(path string) (map[string]int64, error) {
+	stopped := map[string]int64{}
+	if err := statefile.Load(path, &stopped); err != nil {
+		return map[string]int64{}, fmt.Errorf("read command stops: %w", err)
+	}
+	return stopped, nil
+}
+
+// Mark records that the command in session name is being stopped. The file
+// is locked while it is rewritten, so a stop made by another grove process
+// at the same time is not lost.
+func Mark(path, name string, now time.Time) error {
+	unlock, err := lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	stopped, err := Load(path)
+	if err != nil {
+		return err
+	}
+	stopped[name] = now.Unix()
+	return save(path, stopped)
+}
+
+// Clear forgets a stop once the command is started again.
+func Clear(path, name string) error {
+	unlock, err := lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	stopped, err := Load(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := stopped[name]; !ok {
+		return nil
+	}
+	delete(stopped, name)
+	return save(path, stopped)
+}
+
+func save(path string, stopped map[string]int64) error {
+	if err := statefile.Save(path, stopped); err != nil {
+		return fmt.Errorf("write command stops: %w", err)
+	}
+	return nil
+}
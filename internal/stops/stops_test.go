@@ -0,0 +1,32 @@
+package stops
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMarkAndClear(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state", "stops.json")
+	now := time.Unix(1700000000, 0)
+	for _, name := range []string{"api/cmd-web", "api/cmd-worker"} {
+		if err := Mark(path, name, now); err != nil {
+			t.Fatalf("Mark(%s) error = %v", name, err)
+		}
+	}
+	if err := Clear(path, "api/cmd-web"); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	if err := Clear(path, "api/cmd-missing"); err != nil {
+		t.Fatalf("Clear() of an unknown stop error = %v", err)
+	}
+	stopped, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(stopped) != 1 || stopped["api/cmd-worker"] != now.Unix() {
+		t.Fatalf("Load() = %v, want only api/cmd-worker", stopped)
+	}
+}
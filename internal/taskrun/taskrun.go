@@ -0,0 +1,69 @@
+// Package taskrun records the last run of each task, so its exit code and
+// duration are still shown after its session is gone or grove restarts.
+package taskrun
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/statefile"
+)
+
+// Run is one run of a task.
+type Run struct {
+	Started time.Time `json:"started"`
+	// Finished is zero while the task runs. It is when the server says the
+	// task exited, or when grove saw it exit on servers that cannot say.
+	Finished   time.Time `json:"finished"`
+	ExitStatus int       `json:"exit_status"`
+	ExitSignal int       `json:"exit_signal,omitempty"`
+	// Stopped is set when the session went away before the task exited.
+	Stopped bool `json:"stopped,omitempty"`
+	// Skipped counts the runs that came due while this one was still going,
+	// and so were not started.
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// Running reports whether the run has started and not finished.
+func (r Run) Running() bool {
+	return !r.Started.IsZero() && r.Finished.IsZero()
+}
+
+// Failed reports whether a finished run exited non-zero, was killed by a
+// signal or was stopped.
+func (r Run) Failed() bool {
+	return r.Stopped || r.ExitSignal != 0 || r.ExitStatus != 0
+}
+
+// Duration returns how long the run took, or has taken so far at now.
+func (r Run) Duration(now time.Time) time.Duration {
+	if r.Finished.IsZero() {
+		return now.Sub(r.Started)
+	}
+	return r.Finished.Sub(r.Started)
+}
+
+// Path returns the file the task runs are kept in.
+func Path() string {
+	return filepath.Join(config.StateDir(), "tasks.json")
+}
+
+// Load reads the last run of each task by session name. A missing file holds
+// no runs.
+func Load(path string) (map[string]Run, error) {
+	runs := map[string]Run{}
+	if err := statefile.Load(path, &runs); err != nil {
+		return map[string]Run{}, fmt.Errorf("read task runs: %w", err)
+	}
+	return runs, nil
+}
+
+// Save writes the task runs.
+func Save(path string, runs map[string]Run) error {
+	if err := statefile.Save(path, runs); err != nil {
+		return fmt.Errorf("write task runs: %w", err)
+	}
+	return nil
+}
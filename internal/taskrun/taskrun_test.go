@@ -0,0 +1,37 @@
+package taskrun
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunsRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2026, time.March, 4, 3, 0, 0, 0, time.UTC)
+	want := map[string]Run{
+		"api/task-audit": {Started: started, Finished: started.Add(42 * time.Second), ExitStatus: 1},
+		"api/task-lint":  {Started: started},
+	}
+	path := filepath.Join(t.TempDir(), "state", "tasks.json")
+	if err := Save(path, want); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Load() = %#v, want %#v", got, want)
+	}
+
+	audit := got["api/task-audit"]
+	if audit.Running() || !audit.Failed() || audit.Duration(time.Now()) != 42*time.Second {
+		t.Fatalf("audit run = %#v, want a failed 42s run", audit)
+	}
+	if lint := got["api/task-lint"]; !lint.Running() || lint.Duration(started.Add(time.Minute)) != time.Minute {
+		t.Fatalf("lint run = %#v, want a run in progress for a minute", lint)
+	}
+}
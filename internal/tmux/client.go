@@ -3,11 +3,20 @@ package tmux
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/SarthakJariwala/grove/internal/logfile"
+	"github.com/SarthakJariwala/grove/internal/sockdir"
 )
 
 type Session struct {
@@ -19,9 +28,23 @@ type Session struct {
 	AlertsActivity bool
 	AlertsSilence  bool
 	LastActivity   int64
+	// LastOutput is when any window last printed output, in Unix seconds.
+	// LastActivity only moves when a client types.
+	LastOutput     int64
 	CurrentCommand string
 	PaneTitle      string
-	CurrentPath    string
+	// TitleGlyph is the status glyph stripped from the front of the pane
+	// title, such as an agent's spinner.
+	TitleGlyph  string
+	CurrentPath string
+	Worktree    string
+	// Dead is set when the active pane's process has exited and the pane was
+	// kept by remain-on-exit. ExitStatus and ExitSignal describe how it ended,
+	// and ExitTime is when, in Unix seconds, or 0 when the server cannot say.
+	Dead       bool
+	ExitStatus int
+	ExitSignal int
+	ExitTime   int64
 }
 
 type PaneInfo struct {
@@ -35,6 +58,12 @@ type PaneInfo struct {
 	BellFlag     bool
 	SilenceFlag  bool
 	CurrentPath  string
+	Dead         bool
+	DeadStatus   int
+	DeadSignal   int
+	DeadTime     int64
+	// WindowActivity is when the window last printed output.
+	WindowActivity int64
 }
 
 type SessionSnapshot struct {
@@ -44,12 +73,214 @@ type SessionSnapshot struct {
 	PaneDataFresh  bool
 }
 
-type Client struct{}
+// WorktreeOption is the session user option that records the git worktree
+// an agent session was started in.
+const WorktreeOption = "@grove_worktree"
+
+// Client runs tmux commands. After Watch connects a control-mode client,
+// LoadSnapshot and CapturePane are answered over that connection instead of
+// forking tmux for every call.
+type Client struct {
+	// host is the ssh destination of a remote tmux server; empty means
+	// tmux runs locally.
+	host string
+	// shareSSH is set when ssh may keep a shared connection to host in the
+	// socket directory, which must be this user's alone.
+	shareSSH bool
+	// server holds the -L or -S flag that selects the tmux server; empty
+	// means the default server.
+	server []string
+
+	mu      sync.Mutex
+	control *control
+}
 
 var execCommand = exec.Command
 
-func NewClient() *Client {
-	return &Client{}
+// NewClient returns a client for the tmux server at socket: a path (-S) when
+// it contains a slash, otherwise a socket name (-L). An empty socket selects
+// the default server.
+func NewClient(socket string) *Client {
+	c := &Client{}
+	switch {
+	case socket == "":
+	case strings.Contains(socket, "/"):
+		c.server = []string{"-S", socket}
+	default:
+		c.server = []string{"-L", socket}
+	}
+	return c
+}
+
+// NewRemoteClient returns a client for a tmux server on host, reached with
+// ssh. socket selects the server as for NewClient.
+func NewRemoteClient(host, socket string) *Client {
+	c := NewClient(socket)
+	c.host = host
+	// ssh does not create the directory of its control sockets. Without a
+	// private one each call connects on its own.
+	dir := sockdir.Dir()
+	c.shareSSH = os.MkdirAll(dir, 0o700) == nil && sockdir.Check(dir) == nil
+	return c
+}
+
+// sshOptions bound how long a call waits on a host that cannot be reached
+// or has stopped answering and, when it is safe, share one connection per
+// host between calls so each skips the handshake.
+func (c *Client) sshOptions() []string {
+	options := []string{
+		"-o", "ConnectTimeout=5",
+		"-o", "ServerAliveInterval=10",
+	}
+	if c.shareSSH {
+		options = append(options,
+			"-o", "ControlMaster=auto",
+			"-o", "ControlPath="+filepath.Join(sockdir.Dir(), "ssh-%C"),
+			"-o", "ControlPersist=60",
+		)
+	}
+	return options
+}
+
+// tmux builds a tmux invocation against the client's server. On a remote
+// host ssh runs it without prompting, since grove owns the terminal.
+func (c *Client) tmux(args ...string) *exec.Cmd {
+	args = append(append([]string(nil), c.server...), args...)
+	if c.host == "" {
+		return execCommand("tmux", args...)
+	}
+	sshArgs := append(c.sshOptions(), "-o", "BatchMode=yes", c.host, remoteCommand(args))
+	return execCommand("ssh", sshArgs...)
+}
+
+// remoteCommand quotes a tmux invocation for the remote shell ssh runs it in.
+func remoteCommand(args []string) string {
+	quoted := make([]string, 0, len(args)+1)
+	quoted = append(quoted, "tmux")
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Watch connects a tmux control-mode client so changes are pushed through
+// NextChange. The client attaches to grove's own session, created as
+// needed, and learns of changes elsewhere through subscriptions.
+func (c *Client) Watch() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.control != nil && c.control.alive() {
+		return nil
+	}
+
+	cmd := c.tmux("-C", "new-session", "-A", "-f", "ignore-size", "-s", controlSession)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("tmux control mode: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("tmux control mode: %w", err)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("tmux control mode: %w", err)
+	}
+
+	ctl := newControl(stdout, stdin, stdin.Close)
+	exited := make(chan struct{})
+	go func() {
+		<-ctl.done
+		stdin.Close()
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	err = nil
+	select {
+	case <-ctl.ready:
+		err = subscribe(ctl)
+	case <-ctl.done:
+		err = errControlClosed
+	}
+	if err != nil {
+		ctl.close()
+		<-exited
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("tmux control mode: %w (%s)", err, msg)
+		}
+		return fmt.Errorf("tmux control mode: %w", err)
+	}
+	c.control = ctl
+	return nil
+}
+
+// subscribe sets up grove's session and the subscriptions that stand in for
+// the notifications tmux only sends about the attached session.
+func subscribe(ctl *control) error {
+	for _, args := range [][]string{
+		{"set-option", "-t", controlSession, "destroy-unattached", "on"},
+		{"refresh-client", "-B", paneSubscription},
+		{"refresh-client", "-B", outputSubscription},
+	} {
+		if _, err := ctl.command(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NextChange blocks until the control-mode client reports changes. It returns
+// false when no connection is open or the connection closed, after which
+// callers fall back to polling.
+func (c *Client) NextChange() (Changes, bool) {
+	c.mu.Lock()
+	ctl := c.control
+	c.mu.Unlock()
+	if ctl == nil {
+		return Changes{}, false
+	}
+	return ctl.next()
+}
+
+// Close disconnects the control-mode client, if any, and for a remote host
+// ends the shared ssh connection rather than leaving it to linger.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	ctl := c.control
+	c.control = nil
+	c.mu.Unlock()
+	var err error
+	if ctl != nil {
+		err = ctl.close()
+	}
+	if c.host != "" && c.shareSSH {
+		// Fails harmlessly when no shared connection was opened.
+		args := append(c.sshOptions(), "-O", "exit", c.host)
+		_ = execCommand("ssh", args...).Run()
+	}
+	return err
+}
+
+func (c *Client) liveControl() *control {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.control == nil || !c.control.alive() {
+		return nil
+	}
+	return c.control
+}
+
+// query runs a read-only tmux command, over the control connection when one
+// is open.
+func (c *Client) query(args ...string) ([]byte, error) {
+	if ctl := c.liveControl(); ctl != nil {
+		out, err := ctl.command(args...)
+		if !errors.Is(err, errControlClosed) {
+			return out, err
+		}
+	}
+	return c.tmux(args...).CombinedOutput()
 }
 
 func (c *Client) LoadSnapshot() (SessionSnapshot, error) {
@@ -72,9 +303,8 @@ func (c *Client) LoadSnapshot() (SessionSnapshot, error) {
 }
 
 func (c *Client) ListSessions() ([]Session, error) {
-	cmd := execCommand("tmux", "list-sessions", "-F",
-		"#{session_name}:#{session_windows}:#{?session_attached,attached,detached}:#{session_alerts}:#{session_activity}")
-	out, err := cmd.CombinedOutput()
+	out, err := c.query("list-sessions", "-F",
+		"#{session_name}:#{session_windows}:#{session_attached}:#{session_alerts}:#{session_activity}:#{"+WorktreeOption+"}")
 	if err != nil {
 		if bytes.Contains(out, []byte("no server running")) ||
 			bytes.Contains(out, []byte("error connecting to")) {
@@ -91,8 +321,8 @@ func (c *Client) ListSessions() ([]Session, error) {
 			continue
 		}
 
-		parts := strings.SplitN(line, ":", 5)
-		if len(parts) < 3 {
+		parts := strings.SplitN(line, ":", 6)
+		if len(parts) < 3 || parts[0] == controlSession {
 			continue
 		}
 
@@ -100,11 +330,12 @@ func (c *Client) ListSessions() ([]Session, error) {
 		if err != nil {
 			windows = 0
 		}
+		clients, _ := strconv.Atoi(parts[2])
 
 		s := Session{
 			Name:     parts[0],
 			Windows:  windows,
-			Attached: parts[2] == "attached",
+			Attached: clients > 0,
 		}
 
 		if len(parts) >= 4 {
@@ -119,6 +350,9 @@ func (c *Client) ListSessions() ([]Session, error) {
 				s.LastActivity = ts
 			}
 		}
+		if len(parts) >= 6 {
+			s.Worktree = parts[5]
+		}
 
 		sessions = append(sessions, s)
 	}
@@ -131,9 +365,8 @@ func (c *Client) ListSessions() ([]Session, error) {
 }
 
 func (c *Client) ListPanes() ([]PaneInfo, error) {
-	cmd := execCommand("tmux", "list-panes", "-a", "-F",
-		"#{session_name}\t#{window_index}\t#{pane_current_command}\t#{?pane_active,1,0}\t#{?window_active,1,0}\t#{window_activity_flag}\t#{window_bell_flag}\t#{window_silence_flag}\t#{pane_title}\t#{pane_current_path}")
-	out, err := cmd.CombinedOutput()
+	out, err := c.query("list-panes", "-a", "-F",
+		"#{session_name}\t#{window_index}\t#{pane_current_command}\t#{?pane_active,1,0}\t#{?window_active,1,0}\t#{window_activity_flag}\t#{window_bell_flag}\t#{window_silence_flag}\t#{pane_title}\t#{pane_current_path}\t#{?pane_dead,1,0}\t#{pane_dead_status}\t#{pane_dead_signal}\t#{window_activity}\t#{pane_dead_time}")
 	if err != nil {
 		if bytes.Contains(out, []byte("no server running")) ||
 			bytes.Contains(out, []byte("no current")) {
@@ -150,8 +383,8 @@ func (c *Client) ListPanes() ([]PaneInfo, error) {
 			continue
 		}
 
-		parts := strings.SplitN(line, "\t", 10)
-		if len(parts) < 5 {
+		parts := strings.SplitN(line, "\t", 15)
+		if len(parts) < 5 || parts[0] == controlSession {
 			continue
 		}
 
@@ -178,6 +411,21 @@ func (c *Client) ListPanes() ([]PaneInfo, error) {
 		if len(parts) >= 10 {
 			p.CurrentPath = parts[9]
 		}
+		if len(parts) >= 11 {
+			p.Dead = parts[10] == "1"
+		}
+		if len(parts) >= 12 {
+			p.DeadStatus, _ = strconv.Atoi(parts[11])
+		}
+		if len(parts) >= 13 {
+			p.DeadSignal, _ = strconv.Atoi(parts[12])
+		}
+		if len(parts) >= 14 {
+			p.WindowActivity, _ = strconv.ParseInt(parts[13], 10, 64)
+		}
+		if len(parts) >= 15 {
+			p.DeadTime, _ = strconv.ParseInt(parts[14], 10, 64)
+		}
 		panes = append(panes, p)
 	}
 
@@ -191,10 +439,16 @@ func (c *Client) ListPanes() ([]PaneInfo, error) {
 type ActivePaneState struct {
 	Command      string
 	PaneTitle    string
+	TitleGlyph   string
+	LastOutput   int64
 	CurrentPath  string
 	BellFlag     bool
 	ActivityFlag bool
 	SilenceFlag  bool
+	Dead         bool
+	DeadStatus   int
+	DeadSignal   int
+	DeadTime     int64
 }
 
 func AssembleSessionSnapshot(sessions []Session, panes []PaneInfo) SessionSnapshot {
@@ -210,7 +464,13 @@ func AssembleSessionSnapshot(sessions []Session, panes []PaneInfo) SessionSnapsh
 		if st, ok := states[snapshot.Sessions[i].Name]; ok {
 			snapshot.Sessions[i].CurrentCommand = st.Command
 			snapshot.Sessions[i].PaneTitle = st.PaneTitle
+			snapshot.Sessions[i].TitleGlyph = st.TitleGlyph
+			snapshot.Sessions[i].LastOutput = st.LastOutput
 			snapshot.Sessions[i].CurrentPath = st.CurrentPath
+			snapshot.Sessions[i].Dead = st.Dead
+			snapshot.Sessions[i].ExitStatus = st.DeadStatus
+			snapshot.Sessions[i].ExitSignal = st.DeadSignal
+			snapshot.Sessions[i].ExitTime = st.DeadTime
 			if st.BellFlag {
 				snapshot.Sessions[i].AlertsBell = true
 			}
@@ -234,8 +494,16 @@ func ActivePaneStates(panes []PaneInfo) map[string]ActivePaneState {
 		// Active window+pane provides command, title, and path
 		if p.WindowActive && p.PaneActive {
 			state.Command = p.Command
-			state.PaneTitle = stripTitleBranding(strings.TrimSpace(p.PaneTitle))
+			state.TitleGlyph, state.PaneTitle = SplitPaneTitle(strings.TrimSpace(p.PaneTitle))
 			state.CurrentPath = p.CurrentPath
+			state.Dead = p.Dead
+			state.DeadStatus = p.DeadStatus
+			state.DeadSignal = p.DeadSignal
+			state.DeadTime = p.DeadTime
+		}
+
+		if p.WindowActivity > state.LastOutput {
+			state.LastOutput = p.WindowActivity
 		}
 
 		// Aggregate alert flags across all windows in the session
@@ -294,19 +562,25 @@ func ActiveWindowIndexes(panes []PaneInfo) map[string]int {
 	return result
 }
 
-// stripTitleBranding removes common app-branding prefixes from pane titles
-// (e.g. Claude Code's ✳ prefix) for cleaner display.
-func stripTitleBranding(title string) string {
-	for _, prefix := range []string{"✳ ", "* "} {
-		if strings.HasPrefix(title, prefix) {
-			return strings.TrimPrefix(title, prefix)
-		}
+// SplitPaneTitle separates the status glyph apps put in front of their pane
+// title (e.g. Claude Code's ✳, or a braille spinner while it works) from the
+// rest of the title, which is cleaner to display.
+func SplitPaneTitle(title string) (glyph, rest string) {
+	r, size := utf8.DecodeRuneInString(title)
+	if size == 0 || !strings.HasPrefix(title[size:], " ") {
+		return "", title
+	}
+	if r != '*' && r != '·' && !unicode.IsSymbol(r) {
+		return "", title
 	}
-	return title
+	return string(r), strings.TrimLeft(title[size:], " ")
 }
 
-func (c *Client) NewSession(name, cwd string) error {
-	cmd := execCommand("tmux", "new-session", "-d", "-s", name, "-c", cwd)
+// NewSession starts a detached shell session. env holds KEY=VALUE pairs that
+// are set in the session environment.
+func (c *Client) NewSession(name, cwd string, env []string) error {
+	args := append([]string{"new-session", "-d", "-s", name, "-c", cwd}, envArgs(env)...)
+	cmd := c.tmux(args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("tmux new-session: %w (%s)", err, strings.TrimSpace(string(out)))
@@ -314,13 +588,34 @@ func (c *Client) NewSession(name, cwd string) error {
 	return nil
 }
 
-func (c *Client) NewSessionWithCommand(name, cwd, command string) error {
-	args := []string{"new-session", "-d", "-s", name, "-c", cwd}
+func (c *Client) NewSessionWithCommand(name, cwd, command string, env []string) error {
+	args := append([]string{"new-session", "-d", "-s", name, "-c", cwd}, envArgs(env)...)
 	if strings.TrimSpace(command) != "" {
 		args = append(args, command)
 	}
 
-	cmd := execCommand("tmux", args...)
+	cmd := c.tmux(args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("tmux new-session: %w (%s)", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// NewCommandSession starts a detached session running command and keeps its
+// pane once the command exits, so the exit status stays readable through
+// ListPanes. When logPath is set, pane output is piped into grove's log
+// sink, which appends it to that file and rotates it as it grows.
+// Everything happens in one tmux invocation so a command that exits
+// immediately still leaves its pane and its output behind.
+func (c *Client) NewCommandSession(name, cwd, command string, env []string, logPath string) error {
+	args := append([]string{"new-session", "-d", "-s", name, "-c", cwd}, envArgs(env)...)
+	args = append(args, command, ";", "set-option", "-t", name, "remain-on-exit", "on")
+	if logPath != "" {
+		args = append(args, ";", "pipe-pane", "-t", name, shellCommand(logfile.SinkArgs(logPath)))
+	}
+
+	cmd := c.tmux(args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("tmux new-session: %w (%s)", err, strings.TrimSpace(string(out)))
@@ -328,8 +623,28 @@ func (c *Client) NewSessionWithCommand(name, cwd, command string) error {
 	return nil
 }
 
+func shellCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func envArgs(env []string) []string {
+	args := make([]string, 0, len(env)*2)
+	for _, kv := range env {
+		args = append(args, "-e", kv)
+	}
+	return args
+}
+
 func (c *Client) SendKeys(target, command string) error {
-	cmd := execCommand("tmux", "send-keys", "-t", target, command, "C-m")
+	cmd := c.tmux("send-keys", "-t", target, command, "C-m")
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("tmux send-keys: %w (%s)", err, strings.TrimSpace(string(out)))
@@ -337,8 +652,28 @@ func (c *Client) SendKeys(target, command string) error {
 	return nil
 }
 
+// PressKeys sends keys to target by tmux key name, such as C-c or Escape,
+// without pressing Enter after them.
+func (c *Client) PressKeys(target string, keys []string) error {
+	args := append([]string{"send-keys", "-t", target}, keys...)
+	out, err := c.tmux(args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("tmux send-keys: %w (%s)", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (c *Client) SetSessionOption(target, option, value string) error {
+	cmd := c.tmux("set-option", "-t", target, option, value)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("tmux set-option: %w (%s)", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 func (c *Client) RenameSession(oldName, newName string) error {
-	cmd := execCommand("tmux", "rename-session", "-t", oldName, newName)
+	cmd := c.tmux("rename-session", "-t", oldName, newName)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("tmux rename-session: %w (%s)", err, strings.TrimSpace(string(out)))
@@ -347,7 +682,7 @@ func (c *Client) RenameSession(oldName, newName string) error {
 }
 
 func (c *Client) KillSession(name string) error {
-	cmd := execCommand("tmux", "kill-session", "-t", name)
+	cmd := c.tmux("kill-session", "-t", name)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("tmux kill-session: %w (%s)", err, strings.TrimSpace(string(out)))
@@ -355,15 +690,54 @@ func (c *Client) KillSession(name string) error {
 	return nil
 }
 
+// CapturePane returns the visible contents of target. With a control-mode
+// connection open, NextChange then reports output from target's session.
 func (c *Client) CapturePane(target string) (string, error) {
-	cmd := execCommand("tmux", "capture-pane", "-e", "-t", target, "-p")
-	out, err := cmd.CombinedOutput()
+	if ctl := c.liveControl(); ctl != nil {
+		session, _, _ := strings.Cut(target, ":")
+		ctl.follow(session)
+	}
+	return c.PeekPane(target)
+}
+
+// PeekPane returns the visible contents of target like CapturePane, without
+// following its session's output.
+func (c *Client) PeekPane(target string) (string, error) {
+	out, err := c.query("capture-pane", "-e", "-t", target, "-p")
 	if err != nil {
 		return "", fmt.Errorf("tmux capture-pane: %w (%s)", err, strings.TrimSpace(string(out)))
 	}
 	return string(out), nil
 }
 
+// AttachCommand returns the command that attaches the terminal to name. On a
+// dedicated server it also works from inside another tmux, which tmux would
+// otherwise refuse as nesting. Remote sessions attach through ssh -t.
 func (c *Client) AttachCommand(name string) *exec.Cmd {
-	return execCommand("tmux", "attach", "-t", name)
+	if c.host != "" {
+		args := append(append([]string(nil), c.server...), "attach", "-t", name)
+		sshArgs := append(c.sshOptions(), "-t", c.host, remoteCommand(args))
+		return execCommand("ssh", sshArgs...)
+	}
+	cmd := c.tmux("attach", "-t", name)
+	if len(c.server) > 0 {
+		cmd.Env = withoutEnv(os.Environ(), "TMUX")
+	}
+	return cmd
+}
+
+// DetachKeys names the keys that detach an attached terminal: tmux's
+// default binding.
+func (c *Client) DetachKeys(name string) string {
+	return "Ctrl-b d"
+}
+
+func withoutEnv(env []string, key string) []string {
+	kept := make([]string, 0, len(env))
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, key+"=") {
+			kept = append(kept, kv)
+		}
+	}
+	return kept
 }
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/SarthakJariwala/grove/internal/logfile"
+	"github.com/SarthakJariwala/grove/internal/sockdir"
 )
 
 func TestListSessionsParsesOutput(t *testing.T) {
@@ -35,6 +39,12 @@ func TestListSessionsParsesOutput(t *testing.T) {
 	if first.LastActivity != 1710000000 {
 		t.Fatalf("first.LastActivity = %d, want %d", first.LastActivity, int64(1710000000))
 	}
+	if first.Worktree != "/tmp/api/.grove/worktrees/agent-codex-1" {
+		t.Fatalf("first.Worktree = %q, want worktree path", first.Worktree)
+	}
+	if sessions[1].Worktree != "" {
+		t.Fatalf("second.Worktree = %q, want empty", sessions[1].Worktree)
+	}
 }
 
 func TestListSessionsNoServerRunningReturnsEmpty(t *testing.T) {
@@ -76,9 +86,12 @@ func TestListPanesParsesOutput(t *testing.T) {
 	if p.SessionName != "api/one" || p.WindowIndex != 0 || p.Command != "go" {
 		t.Fatalf("pane parsed incorrectly: %#v", p)
 	}
-	if !p.PaneActive || !p.WindowActive || !p.ActivityFlag || !p.BellFlag || p.SilenceFlag {
+	if !p.PaneActive || !p.WindowActive || !p.ActivityFlag || !p.BellFlag || p.SilenceFlag || p.Dead || p.WindowActivity != 1700000000 {
 		t.Fatalf("pane flags parsed incorrectly: %#v", p)
 	}
+	if dead := panes[1]; !dead.Dead || dead.DeadStatus != 3 || dead.DeadSignal != 0 || dead.DeadTime != 1700000042 {
+		t.Fatalf("dead pane parsed incorrectly: %#v", dead)
+	}
 }
 
 func TestListPanesNoServerRunningReturnsEmpty(t *testing.T) {
@@ -108,7 +121,7 @@ func TestMutatingCommandsIncludeTmuxOutputOnError(t *testing.T) {
 	defer restore()
 
 	client := &Client{}
-	err := client.NewSession("api/one", "/tmp")
+	err := client.NewSession("api/one", "/tmp", nil)
 	if err == nil {
 		t.Fatalf("NewSession() error = nil, want non-nil")
 	}
@@ -127,22 +140,128 @@ func TestNewSessionWithCommandIncludesStartupCommand(t *testing.T) {
 	defer restore()
 
 	client := &Client{}
-	if err := client.NewSessionWithCommand("api/cmd-start", "/tmp/api", "make start"); err != nil {
+	if err := client.NewSessionWithCommand("api/cmd-start", "/tmp/api", "make start", []string{"PORT=3000", "RAILS_ENV=development"}); err != nil {
 		t.Fatalf("NewSessionWithCommand() error = %v", err)
 	}
 
-	want := []string{"new-session", "-d", "-s", "api/cmd-start", "-c", "/tmp/api", "make start"}
+	want := []string{"new-session", "-d", "-s", "api/cmd-start", "-c", "/tmp/api", "-e", "PORT=3000", "-e", "RAILS_ENV=development", "make start"}
+	if got := fmt.Sprint(gotArgs); got != fmt.Sprint(want) {
+		t.Fatalf("tmux args = %v, want %v", gotArgs, want)
+	}
+}
+
+func TestNewCommandSessionKeepsPaneAndPipesLog(t *testing.T) {
+	var gotArgs []string
+	restore := stubExecCommand(t, func(name string, args ...string) *exec.Cmd {
+		_ = name
+		gotArgs = append([]string(nil), args...)
+		return helperCommand(t, "mutate_ok")
+	})
+	defer restore()
+
+	client := &Client{}
+	if err := client.NewCommandSession("api/cmd-start", "/tmp/api", "make start", []string{"PORT=3000"}, "/tmp/logs/it's.log"); err != nil {
+		t.Fatalf("NewCommandSession() error = %v", err)
+	}
+
+	want := []string{
+		"new-session", "-d", "-s", "api/cmd-start", "-c", "/tmp/api", "-e", "PORT=3000", "make start",
+		";", "set-option", "-t", "api/cmd-start", "remain-on-exit", "on",
+		";", "pipe-pane", "-t", "api/cmd-start", shellQuote(logfile.SinkArgs("")[0]) + ` '_log-sink' '/tmp/logs/it'\''s.log'`,
+	}
+	if got := fmt.Sprint(gotArgs); got != fmt.Sprint(want) {
+		t.Fatalf("tmux args = %v, want %v", gotArgs, want)
+	}
+}
+
+func TestSetSessionOptionArgs(t *testing.T) {
+	var gotArgs []string
+	restore := stubExecCommand(t, func(name string, args ...string) *exec.Cmd {
+		_ = name
+		gotArgs = append([]string(nil), args...)
+		return helperCommand(t, "mutate_ok")
+	})
+	defer restore()
+
+	client := &Client{}
+	if err := client.SetSessionOption("api/agent-codex-1", WorktreeOption, "/tmp/api/wt"); err != nil {
+		t.Fatalf("SetSessionOption() error = %v", err)
+	}
+
+	want := []string{"set-option", "-t", "api/agent-codex-1", "@grove_worktree", "/tmp/api/wt"}
 	if got := fmt.Sprint(gotArgs); got != fmt.Sprint(want) {
 		t.Fatalf("tmux args = %v, want %v", gotArgs, want)
 	}
 }
 
+func TestPressKeysArgs(t *testing.T) {
+	var gotArgs []string
+	restore := stubExecCommand(t, func(name string, args ...string) *exec.Cmd {
+		_ = name
+		gotArgs = append([]string(nil), args...)
+		return helperCommand(t, "mutate_ok")
+	})
+	defer restore()
+
+	client := &Client{}
+	if err := client.PressKeys("api/cmd-web", []string{"C-c", "C-c"}); err != nil {
+		t.Fatalf("PressKeys() error = %v", err)
+	}
+
+	want := []string{"send-keys", "-t", "api/cmd-web", "C-c", "C-c"}
+	if got := fmt.Sprint(gotArgs); got != fmt.Sprint(want) {
+		t.Fatalf("tmux args = %v, want %v", gotArgs, want)
+	}
+}
+
+func TestClientSelectsTmuxServer(t *testing.T) {
+	var gotArgs [][]string
+	restore := stubExecCommand(t, func(name string, args ...string) *exec.Cmd {
+		_ = name
+		gotArgs = append(gotArgs, append([]string(nil), args...))
+		return helperCommand(t, "mutate_ok")
+	})
+	defer restore()
+	t.Setenv("TMUX", "/tmp/tmux-0/default,1,0")
+
+	tests := []struct {
+		socket string
+		server []string
+	}{
+		{socket: ""},
+		{socket: "grove", server: []string{"-L", "grove"}},
+		{socket: "/tmp/grove.sock", server: []string{"-S", "/tmp/grove.sock"}},
+	}
+	for _, tt := range tests {
+		gotArgs = nil
+		client := NewClient(tt.socket)
+		if err := client.KillSession("api/one"); err != nil {
+			t.Fatalf("KillSession() error = %v", err)
+		}
+		attach := client.AttachCommand("api/one")
+
+		want := append(append([]string(nil), tt.server...), "kill-session", "-t", "api/one")
+		if got := fmt.Sprint(gotArgs[0]); got != fmt.Sprint(want) {
+			t.Fatalf("tmux args for socket %q = %v, want %v", tt.socket, gotArgs[0], want)
+		}
+		want = append(append([]string(nil), tt.server...), "attach", "-t", "api/one")
+		if got := fmt.Sprint(gotArgs[1]); got != fmt.Sprint(want) {
+			t.Fatalf("attach args for socket %q = %v, want %v", tt.socket, gotArgs[1], want)
+		}
+		// A dedicated server is attached to even from inside another tmux.
+		keepsTMUX := attach.Env == nil || strings.Contains(strings.Join(attach.Env, "\n"), "TMUX=")
+		if keepsTMUX != (tt.socket == "") {
+			t.Fatalf("attach env for socket %q keeps TMUX = %v", tt.socket, keepsTMUX)
+		}
+	}
+}
+
 func TestActivePaneStates(t *testing.T) {
 	t.Parallel()
 
 	panes := []PaneInfo{
-		{SessionName: "api/one", ActivityFlag: true},
-		{SessionName: "api/one", WindowActive: true, PaneActive: true, Command: "go", PaneTitle: "* Claude", CurrentPath: "/tmp/api", BellFlag: true},
+		{SessionName: "api/one", ActivityFlag: true, WindowActivity: 1700000020},
+		{SessionName: "api/one", WindowActive: true, PaneActive: true, Command: "go", PaneTitle: "* Claude", CurrentPath: "/tmp/api", BellFlag: true, WindowActivity: 1700000010},
 		{SessionName: "web/two", WindowActive: false, PaneActive: false, SilenceFlag: true},
 	}
 
@@ -152,14 +271,40 @@ func TestActivePaneStates(t *testing.T) {
 	}
 
 	st := states["api/one"]
-	if st.Command != "go" || st.PaneTitle != "Claude" || st.CurrentPath != "/tmp/api" {
+	if st.Command != "go" || st.PaneTitle != "Claude" || st.TitleGlyph != "*" || st.CurrentPath != "/tmp/api" {
 		t.Fatalf("active pane metadata incorrect: %#v", st)
 	}
+	if st.LastOutput != 1700000020 {
+		t.Fatalf("LastOutput = %d, want the latest window activity", st.LastOutput)
+	}
 	if !st.BellFlag || !st.ActivityFlag {
 		t.Fatalf("active pane flags incorrect: %#v", st)
 	}
 }
 
+func TestSplitPaneTitle(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		title     string
+		wantGlyph string
+		wantRest  string
+	}{
+		{title: "✳ Fix tests", wantGlyph: "✳", wantRest: "Fix tests"},
+		{title: "⠙ Fix tests", wantGlyph: "⠙", wantRest: "Fix tests"},
+		{title: "* Claude", wantGlyph: "*", wantRest: "Claude"},
+		{title: "amp - Refactor", wantRest: "amp - Refactor"},
+		{title: "✳Fix", wantRest: "✳Fix"},
+		{title: "", wantRest: ""},
+	}
+	for _, tt := range tests {
+		glyph, rest := SplitPaneTitle(tt.title)
+		if glyph != tt.wantGlyph || rest != tt.wantRest {
+			t.Fatalf("SplitPaneTitle(%q) = %q, %q, want %q, %q", tt.title, glyph, rest, tt.wantGlyph, tt.wantRest)
+		}
+	}
+}
+
 func TestSessionWindowIndexes(t *testing.T) {
 	t.Parallel()
 
@@ -213,6 +358,9 @@ func TestAssembleSessionSnapshot(t *testing.T) {
 			CurrentPath:  "/tmp/api",
 			ActivityFlag: true,
 			BellFlag:     true,
+			Dead:         true,
+			DeadStatus:   1,
+			DeadTime:     1700000042,
 		}},
 	)
 
@@ -228,6 +376,9 @@ func TestAssembleSessionSnapshot(t *testing.T) {
 	if got := snapshot.Sessions[0]; got.CurrentCommand != "go" || got.PaneTitle != "Claude" || got.CurrentPath != "/tmp/api" || !got.AlertsBell || !got.AlertsActivity {
 		t.Fatalf("session = %#v, want merged pane metadata", got)
 	}
+	if got := snapshot.Sessions[0]; !got.Dead || got.ExitStatus != 1 || got.ExitTime != 1700000042 {
+		t.Fatalf("session = %#v, want dead pane exit status", got)
+	}
 }
 
 func TestLoadSnapshotReturnsSessionsWhenListPanesFails(t *testing.T) {
@@ -288,13 +439,13 @@ func TestHelperProcess(t *testing.T) {
 
 	switch args[i+1] {
 	case "session_ok":
-		fmt.Fprint(os.Stdout, "api/one:3:attached:!#:1710000000\nweb/two:1:detached::1700000000\n")
+		fmt.Fprint(os.Stdout, "api/one:3:1:!#:1710000000:/tmp/api/.grove/worktrees/agent-codex-1\nweb/two:1:0::1700000000\n")
 		os.Exit(0)
 	case "session_no_server":
 		fmt.Fprint(os.Stderr, "no server running on /tmp/tmux.sock\n")
 		os.Exit(1)
 	case "panes_ok":
-		fmt.Fprint(os.Stdout, "api/one\t0\tgo\t1\t1\t1\t1\t0\t* Claude\t/tmp/api\nweb/two\t1\tzsh\t0\t0\t0\t0\t1\tmy-host\t/tmp/web\n")
+		fmt.Fprint(os.Stdout, "api/one\t0\tgo\t1\t1\t1\t1\t0\t* Claude\t/tmp/api\t0\t\t\t1700000000\nweb/two\t1\tzsh\t0\t0\t0\t0\t1\tmy-host\t/tmp/web\t1\t3\t\t1700000000\t1700000042\n")
 		os.Exit(0)
 	case "panes_no_server":
 		fmt.Fprint(os.Stderr, "no current client\n")
@@ -309,3 +460,43 @@ func TestHelperProcess(t *testing.T) {
 		os.Exit(2)
 	}
 }
+
+func TestRemoteClientRunsTmuxOverSSH(t *testing.T) {
+	var got []string
+	restore := stubExecCommand(t, func(name string, args ...string) *exec.Cmd {
+		got = append(got, name+" "+strings.Join(args, " "))
+		return helperCommand(t, "mutate_ok")
+	})
+	defer restore()
+
+	t.Setenv("TMPDIR", t.TempDir())
+	client := NewRemoteClient("devbox", "grove")
+	if err := client.SendKeys("api/one", "echo 'hi'"); err != nil {
+		t.Fatalf("SendKeys() error = %v", err)
+	}
+	client.AttachCommand("api/one")
+
+	ssh := "ssh -o ConnectTimeout=5 -o ServerAliveInterval=10 -o ControlMaster=auto -o ControlPath=" + filepath.Join(sockdir.Dir(), "ssh-%C") + " -o ControlPersist=60"
+	want := []string{
+		ssh + ` -o BatchMode=yes devbox tmux '-L' 'grove' 'send-keys' '-t' 'api/one' 'echo '\''hi'\''' 'C-m'`,
+		ssh + ` -t devbox tmux '-L' 'grove' 'attach' '-t' 'api/one'`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestRemoteClientSharesNoConnectionInAnUnsafeDir(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	if err := os.Mkdir(sockdir.Dir(), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(sockdir.Dir(), 0o777); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewRemoteClient("devbox", "grove")
+	if got := strings.Join(client.sshOptions(), " "); strings.Contains(got, "Control") {
+		t.Fatalf("sshOptions() = %q, want no shared connection", got)
+	}
+}
@@ -0,0 +1,256 @@
+package tmux
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Changes summarizes the control-mode notifications received since the last
+// call to Client.NextChange.
+type Changes struct {
+	// Sessions is set when sessions, windows or the pane metadata grove
+	// shows may have changed.
+	Sessions bool
+	// Output is set when a pane in the followed session printed output.
+	Output bool
+}
+
+// controlSession is the session the control client attaches to. It is grove's
+// own, so the client never counts as attached to a user's session or clears
+// its alerts, and it goes away once no grove is attached.
+const controlSession = "_grove"
+
+// Subscriptions and %output only cover the session the client is attached
+// to, so grove's subscriptions loop over every session in their format.
+// tmux checks them once a second.
+const (
+	// paneSubscription reports changes to the pane metadata that ListPanes
+	// reads, so those changes arrive without polling.
+	paneSubscription = "grove::#{S:#{W:#{P:#{session_name}|#{pane_current_command}|#{pane_title}|#{pane_current_path}|#{pane_dead}|#{window_activity_flag}|#{window_bell_flag}|#{window_silence_flag};}}}"
+	// outputSubscription lists when each session's windows last printed,
+	// as "name.time time :name.time :". Session names cannot hold ':' or
+	// '.', and a comma would split the loop's format.
+	outputSubscriptionName = "grove-output"
+	outputSubscription     = outputSubscriptionName + "::#{S:#{session_name}.#{W:#{window_activity} }:}"
+)
+
+var errControlClosed = errors.New("tmux control connection closed")
+
+// control is a tmux control-mode (tmux -C) connection. Commands written to it
+// are answered in %begin/%end blocks in the order they were sent; every other
+// line is a notification.
+type control struct {
+	w       io.Writer
+	closeFn func() error
+
+	// cmdMu serializes commands so each reply matches the oldest pending
+	// command.
+	cmdMu   sync.Mutex
+	replies chan controlReply
+
+	mu sync.Mutex
+	// following is the session whose output is reported as Changes.Output,
+	// and followedActivity its entry in the last output notification.
+	following        string
+	followedActivity string
+	attached         bool
+	pending          Changes
+	notify           chan struct{}
+	// ready is closed once tmux reports the attached session; commands sent
+	// earlier can fail with "no current client".
+	ready chan struct{}
+	done  chan struct{}
+}
+
+type controlReply struct {
+	out []byte
+	err error
+}
+
+func newControl(r io.Reader, w io.Writer, closeFn func() error) *control {
+	c := &control{
+		w:       w,
+		closeFn: closeFn,
+		replies: make(chan controlReply),
+		notify:  make(chan struct{}, 1),
+		ready:   make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go c.read(r)
+	return c
+}
+
+func (c *control) read(r io.Reader) {
+	defer close(c.done)
+	br := bufio.NewReader(r)
+	var (
+		inBlock bool
+		ours    bool
+		out     []byte
+	)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		if inBlock {
+			if !strings.HasPrefix(line, "%end ") && !strings.HasPrefix(line, "%error ") {
+				out = append(out, line...)
+				out = append(out, '\n')
+				continue
+			}
+			inBlock = false
+			if !ours {
+				continue
+			}
+			reply := controlReply{out: out}
+			if strings.HasPrefix(line, "%error ") {
+				reply.err = errors.New(strings.TrimSpace(string(out)))
+			}
+			select {
+			case c.replies <- reply:
+			case <-c.done:
+			}
+			continue
+		}
+
+		name, args, _ := strings.Cut(line, " ")
+		switch name {
+		case "%begin":
+			// The last field is 1 for commands this client sent; the block
+			// tmux prints on attach is not a reply to anything.
+			fields := strings.Fields(args)
+			inBlock, ours, out = true, len(fields) == 3 && fields[2] == "1", nil
+		case "%exit":
+			return
+		case "%session-changed":
+			c.mu.Lock()
+			if !c.attached {
+				c.attached = true
+				close(c.ready)
+			}
+			c.mu.Unlock()
+			c.record(Changes{Sessions: true})
+		case "%subscription-changed":
+			c.subscriptionChanged(args)
+		case "%sessions-changed", "%session-renamed", "%session-window-changed",
+			"%window-add", "%window-close", "%window-renamed",
+			"%unlinked-window-add", "%unlinked-window-close", "%unlinked-window-renamed",
+			"%layout-change", "%window-pane-changed", "%pane-mode-changed":
+			c.record(Changes{Sessions: true})
+		}
+	}
+}
+
+// subscriptionChanged handles
+// "%subscription-changed name $session @window index %pane ... : value".
+// Output is reported when the followed session's windows printed since the
+// last notification.
+func (c *control) subscriptionChanged(args string) {
+	name, _, _ := strings.Cut(args, " ")
+	if name != outputSubscriptionName {
+		c.record(Changes{Sessions: true})
+		return
+	}
+	_, value, _ := strings.Cut(args, " : ")
+	c.mu.Lock()
+	printed := false
+	for _, entry := range strings.Split(value, ":") {
+		session, activity, _ := strings.Cut(entry, ".")
+		if session == c.following && c.following != "" {
+			printed = activity != c.followedActivity
+			c.followedActivity = activity
+			break
+		}
+	}
+	c.mu.Unlock()
+	if printed {
+		c.record(Changes{Output: true})
+	}
+}
+
+func (c *control) record(changes Changes) {
+	c.mu.Lock()
+	c.pending.Sessions = c.pending.Sessions || changes.Sessions
+	c.pending.Output = c.pending.Output || changes.Output
+	c.mu.Unlock()
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// next blocks until there are changes to report. It returns false once the
+// connection has closed.
+func (c *control) next() (Changes, bool) {
+	for {
+		select {
+		case <-c.notify:
+		case <-c.done:
+			return Changes{}, false
+		}
+		c.mu.Lock()
+		changes := c.pending
+		c.pending = Changes{}
+		c.mu.Unlock()
+		// A signal can outlive the changes an earlier call already took.
+		if changes != (Changes{}) {
+			return changes, true
+		}
+	}
+}
+
+// follow reports output from session from now on, in place of the session
+// followed before.
+func (c *control) follow(session string) {
+	c.mu.Lock()
+	if session != c.following {
+		c.following, c.followedActivity = session, ""
+	}
+	c.mu.Unlock()
+}
+
+func (c *control) alive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// command runs a tmux command over the connection and returns its output.
+func (c *control) command(args ...string) ([]byte, error) {
+	c.cmdMu.Lock()
+	defer c.cmdMu.Unlock()
+
+	if _, err := fmt.Fprintln(c.w, controlCommandLine(args)); err != nil {
+		return nil, err
+	}
+	select {
+	case reply := <-c.replies:
+		return reply.out, reply.err
+	case <-c.done:
+		return nil, errControlClosed
+	}
+}
+
+func (c *control) close() error {
+	return c.closeFn()
+}
+
+// controlCommandLine quotes args for tmux's command parser. Inside single
+// quotes nothing but the closing quote is special.
+func controlCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
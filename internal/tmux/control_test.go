@@ -0,0 +1,77 @@
+package tmux
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestControlMatchesRepliesAndCoalescesNotifications(t *testing.T) {
+	t.Parallel()
+
+	serverR, clientW := io.Pipe()
+	clientR, serverW := io.Pipe()
+	ctl := newControl(clientR, clientW, clientW.Close)
+
+	go func() {
+		io.WriteString(serverW, "%begin 1 10 0\n%end 1 10 0\n%session-changed $9 _grove\n")
+		commands := bufio.NewScanner(serverR)
+		commands.Scan()
+		fmt.Fprintf(serverW, "%%subscription-changed grove-output $9 - - - : _grove.1700000000 :web/two.1700000000 :\n%%begin 2 11 1\nline for %s\n%%end 2 11 1\n", commands.Text())
+		commands.Scan()
+		io.WriteString(serverW, "%sessions-changed\n%subscription-changed grove-output $9 - - - : _grove.1700000000 :api/one.1700000001 :web/two.1700000000 :\n%begin 3 12 1\nunknown command: nope\n%error 3 12 1\n")
+		commands.Scan()
+		io.WriteString(serverW, "%exit\n")
+		serverW.Close()
+	}()
+
+	<-ctl.ready
+	ctl.follow("api/one")
+	out, err := ctl.command("display-message", "-p", "it's")
+	if err != nil || string(out) != `line for 'display-message' '-p' 'it'\''s'`+"\n" {
+		t.Fatalf("command() = %q, %v", out, err)
+	}
+	if _, err := ctl.command("nope"); err == nil || err.Error() != "unknown command: nope" {
+		t.Fatalf("command(nope) error = %v, want tmux error", err)
+	}
+
+	changes, ok := ctl.next()
+	if !ok || !changes.Sessions || !changes.Output {
+		t.Fatalf("next() = %+v, %v, want sessions and output coalesced", changes, ok)
+	}
+
+	if _, err := ctl.command("kill-server"); err != errControlClosed {
+		t.Fatalf("command() after exit error = %v, want errControlClosed", err)
+	}
+	if _, ok := ctl.next(); ok {
+		t.Fatal("next() ok = true after exit")
+	}
+}
+
+func TestControlReportsOutputOfTheFollowedSessionOnly(t *testing.T) {
+	t.Parallel()
+
+	ctl := &control{notify: make(chan struct{}, 1)}
+	ctl.follow("api/one")
+	for _, tc := range []struct {
+		value string
+		want  bool
+	}{
+		{"api/one.100 :web/two.100 :", true},
+		{"api/one.100 :web/two.101 :", false},
+		{"api/one.100 102 :web/two.101 :", true},
+		{"web/two.103 :", false},
+	} {
+		ctl.subscriptionChanged(outputSubscriptionName + " $9 - - - : " + tc.value)
+		if ctl.pending.Output != tc.want {
+			t.Fatalf("output after %q = %v, want %v", tc.value, ctl.pending.Output, tc.want)
+		}
+		ctl.pending = Changes{}
+	}
+
+	ctl.subscriptionChanged("grove $9 - - - : zsh|;")
+	if !ctl.pending.Sessions || ctl.pending.Output {
+		t.Fatalf("pending = %+v after a pane change, want sessions only", ctl.pending)
+	}
+}
@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"strings"
@@ -10,10 +12,19 @@ import (
 
 	"github.com/SarthakJariwala/grove/internal/config"
 	"github.com/SarthakJariwala/grove/internal/configfile"
+	"github.com/SarthakJariwala/grove/internal/logfile"
+	"github.com/SarthakJariwala/grove/internal/stops"
 	"github.com/SarthakJariwala/grove/internal/tmux"
 )
 
 func (m Model) loadSessionsCmd() tea.Cmd {
+	// Agents are classified off the update loop, so they are judged against
+	// a copy of the seen times.
+	seen := make(map[string]int64, len(m.seen))
+	for name, at := range m.seen {
+		seen[name] = at
+	}
+	m.seen = seen
 	return func() tea.Msg {
 		if len(m.cfg.Folders) == 0 {
 			return sessionsLoadedMsg{
@@ -24,33 +35,59 @@ func (m Model) loadSessionsCmd() tea.Cmd {
 			}
 		}
 
+		// A snapshot can come back alongside an error when only some hosts
+		// answered.
 		snapshot, err := m.client.LoadSnapshot()
-		if err != nil {
+		if err != nil && snapshot.Sessions == nil {
 			return sessionsLoadedMsg{err: err}
 		}
 
-		grouped := map[int][]tmux.Session{}
-		for _, session := range snapshot.Sessions {
-			for idx, folder := range m.cfg.Folders {
-				prefix := folder.Namespace + "/"
-				if strings.HasPrefix(session.Name, prefix) {
-					grouped[idx] = append(grouped[idx], session)
-					break
-				}
-			}
-		}
-
+		sessions := groupSessions(m.cfg.Folders, snapshot.Sessions)
+		capture := snapshot.PaneDataFresh && time.Since(m.agentScreensAt) >= agentCaptureInterval
+		states, screens := m.detectAgentStates(sessions, capture)
 		return sessionsLoadedMsg{
-			sessions:       grouped,
+			sessions:       sessions,
+			agentStates:    states,
+			agentScreens:   screens,
 			sessionWindows: snapshot.SessionWindows,
 			activeWindows:  snapshot.ActiveWindows,
 			panesFresh:     snapshot.PaneDataFresh,
+			err:            err,
+		}
+	}
+}
+
+// pollSessionsCmd loads sessions for a refresh tick, marking the result so
+// the next tick knows whether it is still loading.
+func (m Model) pollSessionsCmd() tea.Cmd {
+	load := m.loadSessionsCmd()
+	return func() tea.Msg {
+		msg := load()
+		if loaded, ok := msg.(sessionsLoadedMsg); ok {
+			loaded.poll = true
+			return loaded
 		}
+		return msg
 	}
 }
 
-func tickCmd() tea.Cmd {
-	return tea.Tick(refreshInterval, func(t time.Time) tea.Msg {
+// groupSessions assigns sessions to the folders whose namespace they live in.
+func groupSessions(folders []config.Folder, sessions []tmux.Session) map[int][]tmux.Session {
+	grouped := map[int][]tmux.Session{}
+	for _, session := range sessions {
+		for idx, folder := range folders {
+			prefix := folder.Namespace + "/"
+			if strings.HasPrefix(session.Name, prefix) {
+				grouped[idx] = append(grouped[idx], session)
+				break
+			}
+		}
+	}
+	return grouped
+}
+
+func tickCmd(interval time.Duration) tea.Cmd {
+	return tea.Tick(interval, func(t time.Time) tea.Msg {
 		return t
 	})
 }
@@ -66,7 +103,11 @@ func (m Model) newSessionCmd(folder config.Folder, leaf string) tea.Cmd {
 	fullName := folder.Namespace + "/" + leaf
 
 	return func() tea.Msg {
-		if err := m.client.NewSession(fullName, folder.Path); err != nil {
+		env, err := folder.TerminalEnv()
+		if err != nil {
+			return actionResultMsg{err: err}
+		}
+		if err := m.client.NewSession(fullName, folder.Path, env); err != nil {
 			return actionResultMsg{err: err}
 		}
 		return actionResultMsg{status: "created " + fullName, attachTarget: fullName}
@@ -77,50 +118,144 @@ func (m Model) newTerminalCmd(folderIndex int, folder config.Folder) tea.Cmd {
 	index := nextTerminalIndex(folder, m.sessions[folderIndex])
 	name := terminalSessionName(folder, index)
 	return func() tea.Msg {
-		if err := m.client.NewSession(name, folder.Path); err != nil {
+		env, err := folder.TerminalEnv()
+		if err != nil {
+			return actionResultMsg{err: err}
+		}
+		if err := m.client.NewSession(name, folder.Path, env); err != nil {
 			return actionResultMsg{err: err}
 		}
 		return actionResultMsg{status: "created " + name, attachTarget: name}
 	}
 }
 
-func (m Model) newAgentCmd(folderIndex int, folder config.Folder, agent config.Agent, persist bool) tea.Cmd {
+// newAgentCmd launches an agent instance. When branch is set, the instance
+// gets its own git worktree on that branch instead of sharing folder.Path.
+func (m Model) newAgentCmd(folderIndex int, folder config.Folder, agent config.Agent, persist bool, branch string) tea.Cmd {
 	index := nextAgentIndex(folder, agent.Name, m.sessions[folderIndex])
 	name := agentSessionName(folder, agent.Name, index)
+	worktreePath := agentWorktreePath(folder, agent.Name, index)
 	return func() tea.Msg {
 		if persist {
-			if err := configfile.Save(m.cfgPath, m.cfg); err != nil {
+			if err := configfile.AppendFolderAgent(m.cfgPath, folder.Namespace, agent); err != nil {
 				return actionResultMsg{err: err}
 			}
 		}
-		if err := m.client.NewSessionWithCommand(name, folder.Path, agent.Command); err != nil {
+		env, err := folder.AgentEnv(agent)
+		if err != nil {
+			return actionResultMsg{err: err}
+		}
+		if branch == "" {
+			if err := m.client.NewSessionWithCommand(name, folder.Path, agent.Command, env); err != nil {
+				return actionResultMsg{err: err}
+			}
+			return actionResultMsg{status: "created " + name, attachTarget: name}
+		}
+
+		newBranch := !m.worktrees.BranchExists(folder.Path, branch)
+		if err := m.worktrees.Add(folder.Path, worktreePath, branch); err != nil {
+			return actionResultMsg{err: err}
+		}
+		created := false
+		err = m.client.NewSessionWithCommand(name, worktreePath, agent.Command, env)
+		if err == nil {
+			created = true
+			err = m.client.SetSessionOption(name, tmux.WorktreeOption, worktreePath)
+		}
+		if err != nil {
+			m.discardAgentWorktree(folder, name, worktreePath, branch, created, newBranch)
 			return actionResultMsg{err: err}
 		}
 		return actionResultMsg{status: "created " + name, attachTarget: name}
 	}
 }
 
-func (m Model) startCommandCmd(folder config.Folder, row treeRow) tea.Cmd {
+// discardAgentWorktree undoes an agent launch that failed after its worktree
+// was added, so nothing is left behind that no session tracks. The branch
+// goes too when the launch created it. Cleanup is best effort; the launch
+// error is what gets reported.
+func (m Model) discardAgentWorktree(folder config.Folder, name, worktreePath, branch string, sessionCreated, newBranch bool) {
+	if sessionCreated {
+		_ = m.client.KillSession(name)
+	}
+	if err := m.worktrees.Remove(folder.Path, worktreePath); err != nil {
+		return
+	}
+	if newBranch {
+		_ = m.worktrees.DeleteBranch(folder.Path, branch)
+	}
+}
+
+// startCommandsCmd starts commands in the given order. Callers pass
+// dependencies before the commands that need them.
+func (m Model) startCommandsCmd(folder config.Folder, commands []config.Command, status string) tea.Cmd {
 	return func() tea.Msg {
-		if err := m.client.NewSessionWithCommand(row.sessionName, folder.Path, row.commandText); err != nil {
-			return actionResultMsg{err: err}
+		for _, command := range commands {
+			if err := m.launchCommand(folder, command); err != nil {
+				return actionResultMsg{err: err}
+			}
 		}
-		return actionResultMsg{status: "started " + row.displayName}
+		return actionResultMsg{status: status}
 	}
 }
 
-func (m Model) restartCommandCmd(folder config.Folder, row treeRow) tea.Cmd {
+// restartCommandCmd restarts the row's command after starting any of its
+// stopped dependencies. The running command is stopped gracefully first.
+func (m *Model) restartCommandCmd(folder config.Folder, row treeRow, deps []config.Command) tea.Cmd {
+	command, _ := commandForRow(folder, row)
+	m.markStopping(row, command)
+	model := *m
 	return func() tea.Msg {
-		if err := m.client.KillSession(row.sessionName); err != nil {
-			return actionResultMsg{err: err}
+		done := func(result actionResultMsg) tea.Msg {
+			return commandsStoppedMsg{sessions: []string{row.sessionName}, result: result}
 		}
-		if err := m.client.NewSessionWithCommand(row.sessionName, folder.Path, row.commandText); err != nil {
-			return actionResultMsg{err: err}
+		for _, dep := range deps {
+			if err := model.launchCommand(folder, dep); err != nil {
+				return done(actionResultMsg{err: err})
+			}
+		}
+		if _, err := model.stopCommand(row); err != nil {
+			return done(actionResultMsg{err: err})
 		}
-		return actionResultMsg{status: "restarted " + row.displayName}
+		if err := model.newCommandSession(folder, command); err != nil {
+			return done(actionResultMsg{err: err})
+		}
+		return done(actionResultMsg{status: "restarted " + row.displayName})
 	}
 }
 
+// launchCommand starts a managed command, first clearing the session left
+// behind by a previous run that has exited.
+func (m Model) launchCommand(folder config.Folder, command config.Command) error {
+	name := commandSessionName(folder, command.Name)
+	if m.sessionExists(name) {
+		if err := m.client.KillSession(name); err != nil {
+			return err
+		}
+	}
+	return m.newCommandSession(folder, command)
+}
+
+// newCommandSession creates the command's session with its output appended
+// to the command's log file. Logs are only kept for local folders. Starting
+// the command clears its recorded stop, so it is supervised again.
+func (m Model) newCommandSession(folder config.Folder, command config.Command) error {
+	env, err := folder.CommandEnv(command)
+	if err != nil {
+		return err
+	}
+	logPath := ""
+	if folder.Host == "" {
+		logPath = logfile.Path(folder.Namespace, command.Name)
+		if err := logfile.Prepare(logPath); err != nil {
+			return err
+		}
+	}
+	name := commandSessionName(folder, command.Name)
+	_ = stops.Clear(m.stopsPath, name)
+	return m.client.NewCommandSession(name, folder.Path, command.Command, env, logPath)
+}
+
 func (m Model) renameSessionCmd(oldName, newName string) tea.Cmd {
 	return func() tea.Msg {
 		if err := m.client.RenameSession(oldName, newName); err != nil {
@@ -132,6 +267,7 @@ func (m Model) renameSessionCmd(oldName, newName string) tea.Cmd {
 
 func (m Model) killSessionCmd(name string) tea.Cmd {
 	return func() tea.Msg {
+		m.forgetSavedSessions([]string{name})
 		if err := m.client.KillSession(name); err != nil {
 			return actionResultMsg{err: err}
 		}
@@ -139,6 +275,54 @@ func (m Model) killSessionCmd(name string) tea.Cmd {
 	}
 }
 
+// killSessionsCmd kills the rows' sessions, and with removeWorktrees also
+// the worktrees they run in. It carries on past failures and reports them
+// together.
+func (m *Model) killSessionsCmd(targets []treeRow, removeWorktrees bool) tea.Cmd {
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.sessionName)
+	}
+	m.forgetSessions(names)
+	model := *m
+	return func() tea.Msg {
+		model.forgetSavedSessions(names)
+		var failed []string
+		removed := 0
+		for _, target := range targets {
+			if err := model.client.KillSession(target.sessionName); err != nil {
+				failed = append(failed, err.Error())
+				continue
+			}
+			if !removeWorktrees || target.worktreePath == "" {
+				continue
+			}
+			if err := model.worktrees.Remove(model.cfg.Folders[target.folderIndex].Path, target.worktreePath); err != nil {
+				failed = append(failed, err.Error())
+				continue
+			}
+			removed++
+		}
+		if len(failed) > 0 {
+			if len(targets) == 1 {
+				return actionResultMsg{err: errors.New(failed[0])}
+			}
+			return actionResultMsg{err: fmt.Errorf("%d of %d failed: %s", len(failed), len(targets), strings.Join(failed, "; "))}
+		}
+		status := "killed " + targets[0].sessionName
+		if len(targets) > 1 {
+			status = fmt.Sprintf("killed %d sessions", len(targets))
+		}
+		switch {
+		case removed == 1 && len(targets) == 1:
+			status += " and removed its worktree"
+		case removed > 0:
+			status += fmt.Sprintf(" and removed %d worktree%s", removed, pluralSuffix(removed))
+		}
+		return actionResultMsg{status: status}
+	}
+}
+
 func (m Model) addFolderCmd(f config.Folder) tea.Cmd {
 	cfgPath := m.cfgPath
 	return func() tea.Msg {
@@ -150,17 +334,13 @@ func (m Model) addFolderCmd(f config.Folder) tea.Cmd {
 }
 
 func (m Model) addCommandCmd(folderIndex int, command config.Command) tea.Cmd {
-	cfg := m.cfg
-	cfg.Folders = append([]config.Folder(nil), cfg.Folders...)
 	cfgPath := m.cfgPath
+	namespace := m.cfg.Folders[folderIndex].Namespace
 	return func() tea.Msg {
-		if err := config.AppendCommand(&cfg, folderIndex, command); err != nil {
-			return commandAddedMsg{err: err}
-		}
-		if err := configfile.Save(cfgPath, cfg); err != nil {
+		if err := configfile.AppendCommand(cfgPath, namespace, command); err != nil {
 			return commandAddedMsg{err: err}
 		}
-		return commandAddedMsg{folderIndex: folderIndex, command: command}
+		return commandAddedMsg{namespace: namespace, command: command}
 	}
 }
 
@@ -0,0 +1,197 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// agentState is what an agent instance appears to be doing. The zero value
+// means grove has not looked yet.
+type agentState int
+
+const (
+	agentUnknown agentState = iota
+	agentWorking
+	agentNeedsInput
+	agentIdle
+	agentFinished
+)
+
+func (s agentState) String() string {
+	switch s {
+	case agentWorking:
+		return "working"
+	case agentNeedsInput:
+		return "needs input"
+	case agentIdle:
+		return "idle"
+	case agentFinished:
+		return "finished"
+	default:
+		return ""
+	}
+}
+
+// agentStatus is an agent's detected state and what gave it away.
+type agentStatus struct {
+	state  agentState
+	reason string
+}
+
+// maxMatchWidth bounds how much matched pane text a reason quotes.
+const maxMatchWidth = 40
+
+// agentPatterns caches compiled detection patterns. Config validation has
+// already rejected the ones that do not compile.
+var agentPatterns sync.Map
+
+func matchAgentPattern(patterns []string, text string) (string, bool) {
+	for _, pattern := range patterns {
+		cached, ok := agentPatterns.Load(pattern)
+		if !ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				continue
+			}
+			cached, _ = agentPatterns.LoadOrStore(pattern, re)
+		}
+		if match := cached.(*regexp.Regexp).FindString(text); match != "" {
+			return truncateRight(strings.TrimSpace(match), maxMatchWidth), true
+		}
+	}
+	return "", false
+}
+
+// agentHasPatterns reports whether classifying agent needs its pane text.
+func agentHasPatterns(agent config.Agent) bool {
+	return len(agent.NeedsInput) > 0 || len(agent.Working) > 0 || len(agent.Finished) > 0
+}
+
+// isSpinnerGlyph reports whether a pane-title glyph means the agent is busy:
+// one of the agent's spinner glyphs, or any braille pattern by default.
+func isSpinnerGlyph(agent config.Agent, glyph string) bool {
+	if glyph == "" {
+		return false
+	}
+	if agent.Spinner != "" {
+		return strings.Contains(agent.Spinner, glyph)
+	}
+	r := []rune(glyph)[0]
+	return r > 0x2800 && r <= 0x28ff
+}
+
+// classifyAgent judges an agent instance from its session and visible pane
+// text. Explicit evidence wins over the bell, and the bell over silence:
+// a prompt on screen, then a working sign, then a finished sign, then the
+// bell, and otherwise how long the agent has been quiet.
+func classifyAgent(agent config.Agent, session tmux.Session, screen string, now time.Time) agentStatus {
+	if session.Dead {
+		return agentStatus{state: agentFinished, reason: fmt.Sprintf("exited (code %d)", session.ExitStatus)}
+	}
+	screen = ansi.Strip(screen)
+	if match, ok := matchAgentPattern(agent.NeedsInput, screen); ok {
+		return agentStatus{state: agentNeedsInput, reason: "prompt: " + match}
+	}
+	if match, ok := matchAgentPattern(agent.Working, screen); ok {
+		return agentStatus{state: agentWorking, reason: "screen: " + match}
+	}
+	if isSpinnerGlyph(agent, session.TitleGlyph) {
+		return agentStatus{state: agentWorking, reason: "spinner " + session.TitleGlyph + " in title"}
+	}
+	if match, ok := matchAgentPattern(agent.Finished, screen); ok {
+		return agentStatus{state: agentFinished, reason: "screen: " + match}
+	}
+	if session.AlertsBell {
+		return agentStatus{state: agentNeedsInput, reason: "rang the bell"}
+	}
+
+	last := session.LastOutput
+	if last == 0 {
+		last = session.LastActivity
+	}
+	if last == 0 {
+		return agentStatus{state: agentIdle, reason: "no output yet"}
+	}
+	quiet := now.Sub(time.Unix(last, 0))
+	reason := "last output " + formatDuration(quiet)
+	if quiet < agent.IdleTimeout() {
+		return agentStatus{state: agentWorking, reason: reason}
+	}
+	return agentStatus{state: agentIdle, reason: reason}
+}
+
+// agentForSession returns the agent an instance was started from: the
+// folder's agent with the session's slug, or else the global one.
+func agentForSession(cfg config.Config, folder config.Folder, slug string) (config.Agent, bool) {
+	for _, choice := range buildAgentChoices(cfg, folder) {
+		if !choice.IsNew && sanitizeLeaf(choice.Agent.Name) == slug {
+			return choice.Agent, true
+		}
+	}
+	return config.Agent{}, false
+}
+
+// agentCaptureInterval is how often the panes of agents with patterns are
+// captured. Snapshots in between judge them by the screens captured last.
+const agentCaptureInterval = 2 * time.Second
+
+// detectAgentStates classifies every agent instance in sessions. With capture
+// set it captures the panes of agents that have patterns to match and returns
+// those screens; otherwise it matches the screens captured last. Panes are
+// left alone when part of the snapshot is stale, as a host that did not
+// answer would only time out again. Sessions seen since they last printed
+// are judged without their alerts. Instances of agents that are no longer
+// configured are judged by the default rules.
+func (m Model) detectAgentStates(sessions map[int][]tmux.Session, capture bool) (map[string]agentStatus, map[string]string) {
+	states := map[string]agentStatus{}
+	var screens map[string]string
+	if capture {
+		screens = map[string]string{}
+	}
+	now := time.Now()
+	for folderIndex, folderSessions := range sessions {
+		folder := m.cfg.Folders[folderIndex]
+		for _, session := range folderSessions {
+			id, ok := parseManagedSession(folder.Namespace, session.Name)
+			if !ok || id.kind != managedAgent {
+				continue
+			}
+			agent, _ := agentForSession(m.cfg, folder, id.slug)
+			if !m.unread(session) {
+				// A bell already seen is not a request for input.
+				clearAlerts(&session)
+			}
+			screen := m.agentScreens[session.Name]
+			if capture && agentHasPatterns(agent) && !session.Dead {
+				// A pane that cannot be captured is judged without its text.
+				screen, _ = m.client.PeekPane(session.Name)
+				screens[session.Name] = screen
+			}
+			states[session.Name] = classifyAgent(agent, session, screen, now)
+		}
+	}
+	return states, screens
+}
+
+// withAgentStates fills in the detected state of the agent rows.
+func (m Model) withAgentStates(rows []treeRow) []treeRow {
+	for i := range rows {
+		if rows[i].typeOf == rowAgentInstance {
+			rows[i].agent = m.agentStates[rows[i].sessionName]
+		}
+	}
+	return rows
+}
+
+// agentStateKey names a state in grove status --json.
+func agentStateKey(state agentState) string {
+	return strings.ReplaceAll(state.String(), " ", "_")
+}
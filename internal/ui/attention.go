@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// attentionItem is a session that waits on the user.
+type attentionItem struct {
+	sessionName string
+	// since is when the session went quiet, in Unix seconds; 0 when unknown.
+	since int64
+}
+
+// needsAttention reports whether a session waits on the user: it rang the
+// bell, tmux flagged it as silent, or it is an agent that needs input, and
+// it has not been seen since.
+func (m Model) needsAttention(session tmux.Session) bool {
+	if session.AlertsBell || session.AlertsSilence {
+		return true
+	}
+	return m.agentStates[session.Name].state == agentNeedsInput && m.unread(session)
+}
+
+// attentionQueue lists the sessions in every folder that wait on the user,
+// the one waiting longest first.
+func (m Model) attentionQueue() []attentionItem {
+	queue := []attentionItem{}
+	for folderIndex := range m.cfg.Folders {
+		for _, session := range m.sessions[folderIndex] {
+			if !m.needsAttention(session) {
+				continue
+			}
+			queue = append(queue, attentionItem{sessionName: session.Name, since: lastOutput(session)})
+		}
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		a, b := queue[i].since, queue[j].since
+		if (a == 0) != (b == 0) {
+			return b == 0
+		}
+		if a != b {
+			return a < b
+		}
+		return queue[i].sessionName < queue[j].sessionName
+	})
+	return queue
+}
+
+// jumpToAttention selects the next waiting session after the selected one,
+// clearing the filter when it hides that session.
+func (m *Model) jumpToAttention() tea.Cmd {
+	queue := m.attentionQueue()
+	if len(queue) == 0 {
+		return m.setStatus("no sessions are waiting")
+	}
+	next := 0
+	if row, ok := m.selectedRow(); ok {
+		for i, item := range queue {
+			if item.sessionName == row.sessionName {
+				next = (i + 1) % len(queue)
+				break
+			}
+		}
+	}
+	target := treeRow{typeOf: rowAgentInstance, sessionName: queue[next].sessionName}
+	index, ok := findMatchingRowIndex(m.rows, target)
+	if !ok && m.filterQuery != "" {
+		m.filterQuery = ""
+		m.rebuildRows()
+		index, ok = findMatchingRowIndex(m.rows, target)
+	}
+	if !ok {
+		return nil
+	}
+	m.setSelected(index)
+	return m.syncSelectionPreview(true, true)
+}
+
+// attentionCounter is the footer's count of waiting sessions.
+func (m Model) attentionCounter() string {
+	count := len(m.attentionQueue())
+	if count == 0 {
+		return ""
+	}
+	return m.styles.alertIndicator.Render(fmt.Sprintf("▲ %d waiting", count))
+}
@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sendTarget is a set of sessions the run-command prompt sends to.
+type sendTarget struct {
+	label    string
+	sessions []string
+}
+
+// sendTargets lists what c can send to from the current selection: the
+// marked rows, the selected session, every agent in the selected folder, and
+// every session the filter shows. Only running agents and terminals are
+// included, and the first target is the default.
+func (m Model) sendTargets() []sendTarget {
+	var targets []sendTarget
+	if len(m.marked) > 0 {
+		if sessions := m.markedSessionTargets(); len(sessions) > 0 {
+			targets = append(targets, sendTarget{label: countSessions(len(sessions), "marked session"), sessions: sessions})
+		}
+	}
+	row, hasRow := m.selectedRow()
+	if hasRow && m.sendable(row) {
+		targets = append(targets, sendTarget{label: row.sessionName, sessions: []string{row.sessionName}})
+	}
+	if folder, ok := m.selectedFolder(); ok {
+		var agents []string
+		for _, candidate := range buildAgentRows(row.folderIndex, folder, m.sessions[row.folderIndex]) {
+			if m.sendable(candidate) {
+				agents = append(agents, candidate.sessionName)
+			}
+		}
+		if len(agents) > 0 {
+			targets = append(targets, sendTarget{label: countSessions(len(agents), "agent") + " in " + folder.Name, sessions: agents})
+		}
+	}
+	if m.filterQuery != "" {
+		var matched []string
+		for _, candidate := range m.rows {
+			if m.sendable(candidate) {
+				matched = append(matched, candidate.sessionName)
+			}
+		}
+		if len(matched) > 0 {
+			targets = append(targets, sendTarget{label: fmt.Sprintf("%s matching %q", countSessions(len(matched), "session"), m.filterQuery), sessions: matched})
+		}
+	}
+	return targets
+}
+
+// sendable reports whether a row is a running agent or terminal.
+func (m Model) sendable(row treeRow) bool {
+	if row.typeOf != rowAgentInstance && row.typeOf != rowTerminalInstance {
+		return false
+	}
+	return sessionRunningIn(m.sessions[row.folderIndex], row.sessionName)
+}
+
+func countSessions(n int, noun string) string {
+	return fmt.Sprintf("%d %s%s", n, noun, pluralSuffix(n))
+}
+
+// openSendPrompt opens the run-command prompt on the default target.
+func (m *Model) openSendPrompt() tea.Cmd {
+	targets := m.sendTargets()
+	if len(targets) == 0 {
+		m.errMsg = "select an agent or terminal to run command"
+		return nil
+	}
+	m.sendTargetList = targets
+	m.sendTargetIndex = 0
+	m.openPrompt(promptRunCommand, "", "command to run")
+	return textinput.Blink
+}
+
+func (m *Model) cycleSendTarget() {
+	if len(m.sendTargetList) > 0 {
+		m.sendTargetIndex = (m.sendTargetIndex + 1) % len(m.sendTargetList)
+	}
+}
+
+func (m Model) sendTarget() (sendTarget, bool) {
+	if m.sendTargetIndex < 0 || m.sendTargetIndex >= len(m.sendTargetList) {
+		return sendTarget{}, false
+	}
+	return m.sendTargetList[m.sendTargetIndex], true
+}
+
+// sendCommandsCmd sends a command to every session in names, carrying on
+// past those that fail. Sessions in gone stopped running while the prompt
+// was open, and are reported with the failures.
+func (m Model) sendCommandsCmd(names, gone []string, command string) tea.Cmd {
+	return func() tea.Msg {
+		var failed []string
+		for _, name := range gone {
+			failed = append(failed, name+": no longer running")
+		}
+		for _, name := range names {
+			if err := m.client.SendKeys(name, command); err != nil {
+				failed = append(failed, name+": "+err.Error())
+			}
+		}
+		total := len(names) + len(gone)
+		if len(failed) > 0 {
+			return actionResultMsg{err: fmt.Errorf("sent command to %d of %d sessions; %s", total-len(failed), total, strings.Join(failed, "; "))}
+		}
+		return actionResultMsg{status: "sent command to " + countSessions(total, "session")}
+	}
+}
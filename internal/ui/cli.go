@@ -0,0 +1,440 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/manifest"
+	"github.com/SarthakJariwala/grove/internal/taskrun"
+)
+
+// CommandUsage lists the subcommands RunCommand accepts.
+const CommandUsage = `Commands:
+  ls [folder]                  list sessions, commands and tasks
+  status [--json]              summarize each folder, or print the full tree as JSON
+  start <folder>[/<command>]   start a command or run a task, or start all of a folder's commands
+  stop <folder>[/<target>]     stop a command or session, or all of a folder's commands
+  restart <folder>/<command>   restart a command
+  new-agent <folder> <agent>   launch an agent instance
+  attach <folder>/<target>     attach to a running session
+  restore                      recreate the sessions that were running before a reboot
+  send <folder>/<target> <text>
+                               send a line of input to a running session
+
+A target is a command or task name, or a session such as agent-claude-1 or term-2.`
+
+// ErrUnknownCommand is returned by RunCommand for a subcommand it does not
+// know.
+var ErrUnknownCommand = errors.New("unknown command")
+
+// RunCommand runs one of grove's subcommands without starting the UI. Progress
+// is written to stdout.
+func RunCommand(cfg config.Config, cfgPath string, client SessionManager, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing command", ErrUnknownCommand)
+	}
+	m := NewModel(cfg, cfgPath, client)
+	defer m.Close()
+	if msg, ok := m.loadSessionsCmd()().(sessionsLoadedMsg); ok {
+		if msg.err != nil {
+			if msg.sessions == nil {
+				return msg.err
+			}
+			// Sessions on the hosts that answered are still listed.
+			fmt.Fprintf(os.Stderr, "grove: %v\n", msg.err)
+		}
+		m.sessions = msg.sessions
+		m.agentStates = msg.agentStates
+	}
+	if runs, err := taskrun.Load(m.taskRunsPath); err == nil {
+		m.taskRuns = runs
+	}
+
+	name, args := args[0], args[1:]
+	switch name {
+	case "ls":
+		if len(args) > 1 {
+			return usageError("ls [folder]")
+		}
+		return m.cliList(args, stdout)
+	case "status":
+		asJSON := false
+		for _, arg := range args {
+			if arg != "--json" && arg != "-json" {
+				return usageError("status [--json]")
+			}
+			asJSON = true
+		}
+		return m.cliStatus(asJSON, stdout)
+	case "start":
+		if len(args) != 1 {
+			return usageError("start <folder>[/<command>]")
+		}
+		return m.cliStart(args[0], stdout)
+	case "stop":
+		if len(args) != 1 {
+			return usageError("stop <folder>[/<target>]")
+		}
+		return m.cliStop(args[0], stdout)
+	case "restart":
+		if len(args) != 1 {
+			return usageError("restart <folder>/<command>")
+		}
+		return m.cliRestart(args[0], stdout)
+	case "new-agent":
+		if len(args) != 2 {
+			return usageError("new-agent <folder> <agent>")
+		}
+		return m.cliNewAgent(args[0], args[1], stdout)
+	case "attach":
+		if len(args) != 1 {
+			return usageError("attach <folder>/<target>")
+		}
+		return m.cliAttach(args[0])
+	case "restore":
+		if len(args) != 0 {
+			return usageError("restore")
+		}
+		return m.cliRestore(stdout)
+	case "send":
+		if len(args) < 2 {
+			return usageError("send <folder>/<target> <text>")
+		}
+		return m.cliSend(args[0], strings.Join(args[1:], " "), stdout)
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownCommand, name)
+	}
+}
+
+func usageError(usage string) error {
+	return fmt.Errorf("usage: grove %s", usage)
+}
+
+// cliTarget is what a "<folder>/<name>" argument refers to. command or task
+// is set when name is one of the folder's commands or tasks; otherwise
+// session names an existing session in the folder.
+type cliTarget struct {
+	folderIndex int
+	folder      config.Folder
+	command     *config.Command
+	task        *config.Task
+	session     string
+}
+
+func (m Model) findFolder(name string) (int, error) {
+	for i, folder := range m.cfg.Folders {
+		if strings.EqualFold(folder.Name, name) || folder.Namespace == config.Slug(name) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("no folder %q", name)
+}
+
+// resolveTarget looks up a target. leaf is empty when the argument only names
+// a folder.
+func (m Model) resolveTarget(arg string) (cliTarget, string, error) {
+	folderName, leaf := arg, ""
+	if i := strings.LastIndex(arg, "/"); i >= 0 {
+		folderName, leaf = arg[:i], arg[i+1:]
+	}
+	folderIndex, err := m.findFolder(folderName)
+	if err != nil {
+		return cliTarget{}, "", err
+	}
+	target := cliTarget{folderIndex: folderIndex, folder: m.cfg.Folders[folderIndex]}
+	if leaf == "" {
+		return target, "", nil
+	}
+	for _, command := range target.folder.Commands {
+		if commandSessionName(target.folder, command.Name) == commandSessionName(target.folder, leaf) {
+			target.command = &command
+			target.session = commandSessionName(target.folder, command.Name)
+			return target, leaf, nil
+		}
+	}
+	for _, task := range target.folder.Tasks {
+		if taskSessionName(target.folder, task.Name) == taskSessionName(target.folder, leaf) {
+			target.task = &task
+			target.session = taskSessionName(target.folder, task.Name)
+			return target, leaf, nil
+		}
+	}
+	session := target.folder.Namespace + "/" + leaf
+	if !m.sessionExists(session) {
+		return cliTarget{}, "", fmt.Errorf("no command, task or running session %q in folder %s", leaf, target.folder.Name)
+	}
+	target.session = session
+	return target, leaf, nil
+}
+
+// resolveSession resolves a target that must name a single session.
+func (m Model) resolveSession(arg string) (cliTarget, error) {
+	target, leaf, err := m.resolveTarget(arg)
+	if err != nil {
+		return cliTarget{}, err
+	}
+	if leaf == "" {
+		return cliTarget{}, fmt.Errorf("%s names a folder; add /<command> or /<session>", arg)
+	}
+	return target, nil
+}
+
+func (m Model) resolveCommand(arg string) (cliTarget, error) {
+	target, err := m.resolveSession(arg)
+	if err != nil {
+		return cliTarget{}, err
+	}
+	if target.command == nil {
+		return cliTarget{}, fmt.Errorf("%s is not a command", arg)
+	}
+	return target, nil
+}
+
+// runAction runs an action's command synchronously and prints its status.
+func runAction(cmd tea.Cmd, stdout io.Writer) error {
+	var msg actionResultMsg
+	switch result := cmd().(type) {
+	case actionResultMsg:
+		msg = result
+	case commandsStoppedMsg:
+		msg = result.result
+	}
+	if msg.err != nil {
+		return msg.err
+	}
+	fmt.Fprintln(stdout, msg.status)
+	return nil
+}
+
+// cliList prints one line per session and configured command: its session
+// name, kind and status.
+func (m Model) cliList(args []string, stdout io.Writer) error {
+	folderIndexes := make([]int, 0, len(m.cfg.Folders))
+	if len(args) == 1 {
+		folderIndex, err := m.findFolder(args[0])
+		if err != nil {
+			return err
+		}
+		folderIndexes = append(folderIndexes, folderIndex)
+	} else {
+		for i := range m.cfg.Folders {
+			folderIndexes = append(folderIndexes, i)
+		}
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, folderIndex := range folderIndexes {
+		folder := m.cfg.Folders[folderIndex]
+		for _, session := range m.sessions[folderIndex] {
+			id, _ := parseManagedSession(folder.Namespace, session.Name)
+			switch id.kind {
+			case managedAgent:
+				fmt.Fprintf(w, "%s\tagent\trunning\n", session.Name)
+			case managedTerminal:
+				fmt.Fprintf(w, "%s\tterminal\trunning\n", session.Name)
+			case managedUnknown:
+				fmt.Fprintf(w, "%s\tsession\trunning\n", session.Name)
+			}
+		}
+		for _, command := range folder.Commands {
+			name := commandSessionName(folder, command.Name)
+			row := treeRow{status: "stopped"}
+			for _, session := range m.sessions[folderIndex] {
+				if session.Name == name {
+					row = treeRow{status: commandSessionStatus(session), exitStatus: session.ExitStatus, exitSignal: session.ExitSignal}
+				}
+			}
+			fmt.Fprintf(w, "%s\tcommand\t%s\n", name, commandStatusLabel(row))
+		}
+		for _, row := range m.withTaskRuns(buildTaskRows(folderIndex, folder, m.sessionsByName())) {
+			fmt.Fprintf(w, "%s\ttask\t%s\n", row.sessionName, taskRunLabel(row, time.Now()))
+		}
+	}
+	return w.Flush()
+}
+
+func (m Model) cliStart(arg string, stdout io.Writer) error {
+	target, leaf, err := m.resolveTarget(arg)
+	if err != nil {
+		return err
+	}
+	if target.task != nil {
+		return m.cliRunTask(target, stdout)
+	}
+	var order []config.Command
+	if leaf == "" {
+		order, err = config.FolderStartOrder(target.folder)
+	} else if target.command == nil {
+		return fmt.Errorf("%s is not a command", arg)
+	} else {
+		order, err = config.CommandStartOrder(target.folder, target.command.Name)
+	}
+	if err != nil {
+		return err
+	}
+	pending := m.stoppedCommands(target.folderIndex, order)
+	if len(pending) == 0 {
+		fmt.Fprintln(stdout, "already running")
+		return nil
+	}
+	return runAction(m.startCommandsCmd(target.folder, pending, "started "+commandNames(pending)), stdout)
+}
+
+func (m Model) cliStop(arg string, stdout io.Writer) error {
+	target, leaf, err := m.resolveTarget(arg)
+	if err != nil {
+		return err
+	}
+	if leaf != "" {
+		if !m.sessionExists(target.session) {
+			return fmt.Errorf("%s is not running", arg)
+		}
+		if target.command != nil {
+			return runAction(m.stopCommandsCmd([]treeRow{cliCommandRow(target.folderIndex, *target.command, target.session)}), stdout)
+		}
+		return runAction(m.killSessionCmd(target.session), stdout)
+	}
+
+	// Stop dependents before the commands they need.
+	order, err := config.FolderStartOrder(target.folder)
+	if err != nil {
+		return err
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		name := commandSessionName(target.folder, order[i].Name)
+		if !m.sessionExists(name) {
+			continue
+		}
+		if err := runAction(m.stopCommandsCmd([]treeRow{cliCommandRow(target.folderIndex, order[i], name)}), stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m Model) cliRestart(arg string, stdout io.Writer) error {
+	target, err := m.resolveCommand(arg)
+	if err != nil {
+		return err
+	}
+	order, err := config.CommandStartOrder(target.folder, target.command.Name)
+	if err != nil {
+		return err
+	}
+	if !m.commandRunning(target.folderIndex, target.folder, *target.command) {
+		pending := m.stoppedCommands(target.folderIndex, order)
+		return runAction(m.startCommandsCmd(target.folder, pending, "started "+commandNames(pending)), stdout)
+	}
+	deps := m.stoppedCommands(target.folderIndex, order[:len(order)-1])
+	row := cliCommandRow(target.folderIndex, *target.command, target.session)
+	return runAction(m.restartCommandCmd(target.folder, row, deps), stdout)
+}
+
+// cliRunTask runs a task and records the run, so the UI shows it as started
+// now rather than when it first notices the session.
+func (m Model) cliRunTask(target cliTarget, stdout io.Writer) error {
+	if m.taskRunning(target.folderIndex, target.folder, *target.task) {
+		fmt.Fprintln(stdout, "already running")
+		return nil
+	}
+	started := m.runTaskCmd(target.folder, *target.task, "started "+target.task.Name)().(taskStartedMsg)
+	if started.err != nil {
+		return started.err
+	}
+	if started.skipped {
+		fmt.Fprintln(stdout, "already running")
+		return nil
+	}
+	runs, err := taskrun.Load(m.taskRunsPath)
+	if err != nil {
+		return err
+	}
+	mergeTaskRuns(runs, map[string]taskrun.Run{started.session: {Started: started.started}})
+	if err := taskrun.Save(m.taskRunsPath, runs); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, started.status)
+	return nil
+}
+
+func cliCommandRow(folderIndex int, command config.Command, session string) treeRow {
+	return treeRow{typeOf: rowCommand, folderIndex: folderIndex, sessionName: session, displayName: command.Name}
+}
+
+// cliNewAgent launches one of the folder's agents or a global agent template.
+// Unlike the agent picker it never saves a template into the folder.
+func (m Model) cliNewAgent(folderName, agentName string, stdout io.Writer) error {
+	folderIndex, err := m.findFolder(folderName)
+	if err != nil {
+		return err
+	}
+	folder := m.cfg.Folders[folderIndex]
+	for _, choice := range buildAgentChoices(m.cfg, folder) {
+		if choice.IsNew || sanitizeLeaf(choice.Agent.Name) != sanitizeLeaf(agentName) {
+			continue
+		}
+		return runAction(m.newAgentCmd(folderIndex, folder, choice.Agent, false, ""), stdout)
+	}
+	return fmt.Errorf("no agent %q for folder %s", agentName, folder.Name)
+}
+
+// cliRestore recreates the sessions in the manifest that are not running,
+// reporting each one and carrying on past failures.
+func (m Model) cliRestore(stdout io.Writer) error {
+	saved, err := manifest.Load(m.manifestPath)
+	if err != nil {
+		return err
+	}
+	items := m.missingSessions(saved)
+	if len(items) == 0 {
+		fmt.Fprintln(stdout, "nothing to restore")
+		return nil
+	}
+	failed := 0
+	for _, item := range items {
+		if err := m.restoreSession(item); err != nil {
+			fmt.Fprintf(os.Stderr, "grove: %v\n", err)
+			failed++
+			continue
+		}
+		fmt.Fprintln(stdout, "restored "+item.session)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sessions could not be restored", failed, len(items))
+	}
+	return nil
+}
+
+func (m Model) cliAttach(arg string) error {
+	target, err := m.resolveSession(arg)
+	if err != nil {
+		return err
+	}
+	if !m.sessionExists(target.session) {
+		return fmt.Errorf("%s is not running", arg)
+	}
+	cmd := m.client.AttachCommand(target.session)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	markSeenFile(m.seenPath, target.session)
+	return err
+}
+
+func (m Model) cliSend(arg, text string, stdout io.Writer) error {
+	target, err := m.resolveSession(arg)
+	if err != nil {
+		return err
+	}
+	if !sessionRunningIn(m.sessions[target.folderIndex], target.session) {
+		return fmt.Errorf("%s is not running", arg)
+	}
+	return runAction(m.sendCommandCmd(target.session, text), stdout)
+}
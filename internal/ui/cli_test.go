@@ -0,0 +1,297 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/manifest"
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// runningSessionManager reports a fixed set of live sessions.
+type runningSessionManager struct {
+	trackingSessionManager
+	sessions []tmux.Session
+}
+
+func (f *runningSessionManager) LoadSnapshot() (tmux.SessionSnapshot, error) {
+	return tmux.SessionSnapshot{Sessions: f.sessions, PaneDataFresh: true}, nil
+}
+
+// PressKeys ends the target's process, as C-c would.
+func (f *runningSessionManager) PressKeys(target string, keys []string) error {
+	for i := range f.sessions {
+		if f.sessions[i].Name == target {
+			f.sessions[i].Dead = true
+		}
+	}
+	return f.trackingSessionManager.PressKeys(target, keys)
+}
+
+func cliTestConfig() config.Config {
+	return config.Config{
+		Agents: []config.Agent{{Name: "claude", Command: "claude"}},
+		Folders: []config.Folder{{
+			Name:      "API",
+			Path:      "/tmp/api",
+			Namespace: "api",
+			Commands: []config.Command{
+				{Name: "db", Command: "make db"},
+				{Name: "web", Command: "make web", DependsOn: []string{"db"}},
+			},
+		}},
+	}
+}
+
+func TestRunCommandListsSessionsAndCommands(t *testing.T) {
+	t.Parallel()
+
+	client := &runningSessionManager{sessions: []tmux.Session{
+		{Name: "api/agent-claude-1"},
+		{Name: "api/term-2"},
+		{Name: "api/cmd-db", Dead: true, ExitStatus: 3},
+	}}
+	var out bytes.Buffer
+	if err := RunCommand(cliTestConfig(), "config.toml", client, []string{"ls"}, &out); err != nil {
+		t.Fatalf("RunCommand(ls) error = %v", err)
+	}
+
+	want := "api/agent-claude-1  agent     running\n" +
+		"api/term-2          terminal  running\n" +
+		"api/cmd-db          command   exited (code 3)\n" +
+		"api/cmd-web         command   stopped\n"
+	if out.String() != want {
+		t.Fatalf("ls output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestRunCommandStatusJSON(t *testing.T) {
+	t.Parallel()
+
+	client := &runningSessionManager{sessions: []tmux.Session{
+		{Name: "api/agent-claude-1", Attached: true, Windows: 1, AlertsBell: true, HasAlerts: true, PaneTitle: "Fixing tests", LastActivity: 1700000000},
+		{Name: "api/term-1", Windows: 2, CurrentCommand: "zsh"},
+		{Name: "api/cmd-db", Dead: true, ExitStatus: 1},
+	}}
+	var out bytes.Buffer
+	if err := RunCommand(cliTestConfig(), "config.toml", client, []string{"status", "--json"}, &out); err != nil {
+		t.Fatalf("RunCommand(status --json) error = %v", err)
+	}
+
+	var got Status
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("status output is not JSON: %v\n%s", err, out.String())
+	}
+	if len(got.Folders) != 1 {
+		t.Fatalf("folders = %d, want 1", len(got.Folders))
+	}
+	folder := got.Folders[0]
+	if folder.Name != "API" || folder.Namespace != "api" || folder.Path != "/tmp/api" {
+		t.Fatalf("folder = %+v", folder)
+	}
+
+	agent := folder.Agents[0]
+	if agent.Name != "Claude #1" || agent.Status != "attached" || !agent.Running || agent.PaneTitle != "Fixing tests" || agent.LastActivity != 1700000000 || strings.Join(agent.Alerts, ",") != "bell" {
+		t.Fatalf("agent = %+v", agent)
+	}
+	if terminal := folder.Terminals[0]; terminal.Session != "api/term-1" || terminal.Windows != 2 || terminal.CurrentCommand != "zsh" {
+		t.Fatalf("terminal = %+v", terminal)
+	}
+
+	db, web := folder.Commands[0], folder.Commands[1]
+	if db.Status != "exited" || db.Running || db.ExitStatus == nil || *db.ExitStatus != 1 || db.Command != "make db" || db.Source != "config" {
+		t.Fatalf("db = %+v", db)
+	}
+	if web.Status != "stopped" || web.Running || web.ExitStatus != nil {
+		t.Fatalf("web = %+v", web)
+	}
+	if !strings.Contains(out.String(), `"exit_status": 1`) || strings.Contains(out.String(), `"exit_signal"`) {
+		t.Fatalf("status output = %s, want only the exit status of exited commands", out.String())
+	}
+}
+
+func TestRunCommandActsOnTargets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		sessions []tmux.Session
+		args     []string
+		want     string
+		check    func(*runningSessionManager) bool
+	}{
+		{
+			name: "start with dependencies",
+			args: []string{"start", "API/web"},
+			want: "started db, web\n",
+			check: func(c *runningSessionManager) bool {
+				return strings.Join(c.launched, ",") == "api/cmd-db,api/cmd-web"
+			},
+		},
+		{
+			name:     "start folder skips running commands",
+			sessions: []tmux.Session{{Name: "api/cmd-db"}},
+			args:     []string{"start", "api"},
+			want:     "started web\n",
+			check: func(c *runningSessionManager) bool {
+				return strings.Join(c.launched, ",") == "api/cmd-web"
+			},
+		},
+		{
+			name:     "restart running command",
+			sessions: []tmux.Session{{Name: "api/cmd-db"}, {Name: "api/cmd-web"}},
+			args:     []string{"restart", "api/web"},
+			want:     "restarted web\n",
+			check: func(c *runningSessionManager) bool {
+				return strings.Join(c.killed, ",") == "api/cmd-web" && strings.Join(c.launched, ",") == "api/cmd-web"
+			},
+		},
+		{
+			name:     "stop folder stops dependents first",
+			sessions: []tmux.Session{{Name: "api/cmd-db"}, {Name: "api/cmd-web"}, {Name: "api/term-1"}},
+			args:     []string{"stop", "api"},
+			want:     "stopped web\nstopped db\n",
+			check: func(c *runningSessionManager) bool {
+				return strings.Join(c.pressed, ",") == "api/cmd-web C-c,api/cmd-db C-c" && strings.Join(c.killed, ",") == "api/cmd-web,api/cmd-db"
+			},
+		},
+		{
+			name:     "stop session",
+			sessions: []tmux.Session{{Name: "api/term-1"}},
+			args:     []string{"stop", "api/term-1"},
+			want:     "killed api/term-1\n",
+			check: func(c *runningSessionManager) bool {
+				return strings.Join(c.killed, ",") == "api/term-1"
+			},
+		},
+		{
+			name:     "new agent from global template",
+			sessions: []tmux.Session{{Name: "api/agent-claude-1"}},
+			args:     []string{"new-agent", "api", "Claude"},
+			want:     "created api/agent-claude-2\n",
+			check: func(c *runningSessionManager) bool {
+				return strings.Join(c.launched, ",") == "api/agent-claude-2"
+			},
+		},
+		{
+			name:     "send joins text",
+			sessions: []tmux.Session{{Name: "api/agent-claude-1"}},
+			args:     []string{"send", "api/agent-claude-1", "run", "the", "tests"},
+			want:     "sent command to api/agent-claude-1\n",
+			check: func(c *runningSessionManager) bool {
+				return strings.Join(c.sentCmds, ",") == "run the tests"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &runningSessionManager{sessions: tt.sessions}
+			var out bytes.Buffer
+			if err := RunCommand(cliTestConfig(), "config.toml", client, tt.args, &out); err != nil {
+				t.Fatalf("RunCommand(%v) error = %v", tt.args, err)
+			}
+			if out.String() != tt.want {
+				t.Fatalf("RunCommand(%v) output = %q, want %q", tt.args, out.String(), tt.want)
+			}
+			if !tt.check(client) {
+				t.Fatalf("RunCommand(%v) client = %+v", tt.args, client.trackingSessionManager)
+			}
+		})
+	}
+}
+
+func TestRunCommandRestoresMissingSessions(t *testing.T) {
+	t.Parallel()
+
+	// Only this test writes the manifest under the shared state dir.
+	err := manifest.Save(manifest.Path(), manifest.Manifest{Folders: map[string][]manifest.Session{
+		"api": {
+			{Kind: manifest.KindCommand, Name: "web", Dir: "/tmp/api"},
+			{Kind: manifest.KindCommand, Name: "db", Dir: "/tmp/api"},
+			{Kind: manifest.KindAgent, Name: "codex", Index: 1, Dir: "/tmp/api"},
+			{Kind: manifest.KindTerminal, Index: 1, Dir: "/tmp/api"},
+			{Kind: manifest.KindTerminal, Index: 3, Dir: "/nonexistent/src"},
+		},
+		"gone": {{Kind: manifest.KindTerminal, Index: 1, Dir: "/tmp/gone"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &runningSessionManager{sessions: []tmux.Session{{Name: "api/term-1"}}}
+	var out bytes.Buffer
+	err = RunCommand(cliTestConfig(), "config.toml", client, []string{"restore"}, &out)
+	if err == nil || err.Error() != "1 of 4 sessions could not be restored" {
+		t.Fatalf("RunCommand(restore) error = %v, want the unknown agent reported", err)
+	}
+	want := "restored api/term-3\nrestored api/cmd-db\nrestored api/cmd-web\n"
+	if out.String() != want {
+		t.Fatalf("restore output = %q, want %q", out.String(), want)
+	}
+	if got := strings.Join(client.dirs, ","); got != "/tmp/api,/tmp/api,/tmp/api" {
+		t.Fatalf("dirs = %s, want the folder path for a directory that is gone", got)
+	}
+}
+
+func TestRunCommandRejectsBadTargets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"start", "web/api"}, want: `no folder "web"`},
+		{args: []string{"start", "api/term-1"}, want: `no command, task or running session "term-1" in folder API`},
+		{args: []string{"restart", "api"}, want: "api names a folder; add /<command> or /<session>"},
+		{args: []string{"stop", "api/web"}, want: "api/web is not running"},
+		{args: []string{"send", "api/term-1"}, want: "usage: grove send <folder>/<target> <text>"},
+		{args: []string{"status", "--yaml"}, want: "usage: grove status [--json]"},
+		{args: []string{"new-agent", "api", "codex"}, want: `no agent "codex" for folder API`},
+		{args: []string{"restore", "api"}, want: "usage: grove restore"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := RunCommand(cliTestConfig(), "config.toml", &runningSessionManager{}, tt.args, &out)
+		if err == nil || err.Error() != tt.want {
+			t.Fatalf("RunCommand(%v) error = %v, want %q", tt.args, err, tt.want)
+		}
+	}
+
+	err := RunCommand(cliTestConfig(), "config.toml", &runningSessionManager{}, []string{"bogus"}, &bytes.Buffer{})
+	if !errors.Is(err, ErrUnknownCommand) {
+		t.Fatalf("RunCommand(bogus) error = %v, want ErrUnknownCommand", err)
+	}
+}
+
+func TestRunCommandListsAndRunsTasks(t *testing.T) {
+	t.Parallel()
+
+	cfg := cliTestConfig()
+	cfg.Folders[0].Commands = nil
+	cfg.Folders[0].Tasks = []config.Task{{Name: "audit", Command: "npm audit"}, {Name: "tests", Command: "make test"}}
+	client := &runningSessionManager{sessions: []tmux.Session{{Name: "api/task-tests", Dead: true, ExitStatus: 1}}}
+	var out bytes.Buffer
+	if err := RunCommand(cfg, "config.toml", client, []string{"ls"}, &out); err != nil {
+		t.Fatalf("RunCommand(ls) error = %v", err)
+	}
+	want := "api/task-audit  task  never run\n" +
+		"api/task-tests  task  exited (code 1)\n"
+	if out.String() != want {
+		t.Fatalf("ls output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := RunCommand(cfg, "config.toml", client, []string{"start", "api/audit"}, &out); err != nil {
+		t.Fatalf("RunCommand(start) error = %v", err)
+	}
+	if out.String() != "started audit\n" || strings.Join(client.launched, ",") != "api/task-audit" {
+		t.Fatalf("start printed %q and launched %v, want the audit task run", out.String(), client.launched)
+	}
+}
@@ -0,0 +1,258 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/configfile"
+	"github.com/SarthakJariwala/grove/internal/logfile"
+)
+
+// editSelected opens the edit prompts for the selected folder or command.
+func (m *Model) editSelected() tea.Cmd {
+	row, ok := m.selectedRow()
+	switch {
+	case ok && row.typeOf == rowFolder:
+		folder := m.cfg.Folders[row.folderIndex]
+		m.promptFolderIndex = row.folderIndex
+		m.promptStep = 0
+		m.pendingFolder = folder
+		m.openPrompt(promptEditFolder, folder.Name, "folder name")
+		return textinput.Blink
+	case ok && row.typeOf == rowCommand:
+		if row.fromProject {
+			m.errMsg = row.displayName + " is defined in " + config.ProjectFile
+			return nil
+		}
+		command, _ := commandForRow(m.cfg.Folders[row.folderIndex], row)
+		m.promptFolderIndex = row.folderIndex
+		m.editingName = command.Name
+		m.pendingCommand = command
+		m.openPrompt(promptEditCommandName, command.Name, "dev command name")
+		return textinput.Blink
+	default:
+		m.errMsg = "select a folder or command to edit"
+		return nil
+	}
+}
+
+// confirmRemoveSelected asks before removing the selected folder or command
+// from the config.
+func (m *Model) confirmRemoveSelected() {
+	row, ok := m.selectedRow()
+	switch {
+	case ok && row.typeOf == rowFolder:
+		if live := len(m.sessions[row.folderIndex]); live > 0 {
+			m.errMsg = fmt.Sprintf("stop the folder's %d session%s before removing it", live, pluralSuffix(live))
+			return
+		}
+		m.confirmRemoval = removal{kind: removalFolder, folderIndex: row.folderIndex, name: row.displayName}
+	case ok && row.typeOf == rowCommand:
+		if row.fromProject {
+			m.errMsg = row.displayName + " is defined in " + config.ProjectFile
+			return
+		}
+		m.confirmRemoval = removal{kind: removalCommand, folderIndex: row.folderIndex, name: row.displayName}
+	default:
+		m.errMsg = "select a folder or command to remove"
+		return
+	}
+	m.statusMsg = ""
+	m.errMsg = ""
+}
+
+func (m Model) removalPrompt() string {
+	switch m.confirmRemoval.kind {
+	case removalFolder:
+		return "remove folder " + m.confirmRemoval.name + " from config?"
+	case removalCommand:
+		text := "remove command " + m.confirmRemoval.name
+		if m.sessionExists(commandSessionName(m.cfg.Folders[m.confirmRemoval.folderIndex], m.confirmRemoval.name)) {
+			text += " and stop it"
+		}
+		return text + "?"
+	case removalAgent:
+		return "remove agent " + m.confirmRemoval.name + " from folder?"
+	default:
+		return ""
+	}
+}
+
+func (m Model) updateRemoveConfirm(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch strings.ToLower(key.String()) {
+	case "y", "enter":
+		target := m.confirmRemoval
+		m.confirmRemoval = removal{}
+		if target.folderIndex < 0 || target.folderIndex >= len(m.cfg.Folders) {
+			m.errMsg = "select a folder"
+			return m, nil
+		}
+		switch target.kind {
+		case removalFolder:
+			return m, m.removeFolderCmd(target.folderIndex)
+		case removalCommand:
+			return m, m.removeCommandCmd(target.folderIndex, target.name)
+		case removalAgent:
+			return m, m.removeAgentCmd(target.folderIndex, target.name)
+		}
+		return m, nil
+	case "n", "esc":
+		m.confirmRemoval = removal{}
+		return m, m.setStatus("remove cancelled")
+	default:
+		return m, nil
+	}
+}
+
+// savedConfigMsg reloads the config after an edit so the model picks up
+// exactly what was written.
+func (m Model) savedConfigMsg(status string, renamed map[string]string) tea.Msg {
+	cfg, version, err := configfile.LoadVersion(m.cfgPath)
+	if err != nil {
+		return configEditedMsg{err: err}
+	}
+	return configEditedMsg{status: status, cfg: cfg, version: version, renamed: renamed}
+}
+
+// updateFolderCmd saves a folder's new settings. A rename moves its live
+// sessions to the new namespace before the config is saved, and back if
+// saving fails, then moves its command logs.
+func (m Model) updateFolderCmd(folderIndex int, updated config.Folder) tea.Cmd {
+	folder := m.cfg.Folders[folderIndex]
+	sessions := m.sessions[folderIndex]
+	return func() tea.Msg {
+		namespace := config.Slug(updated.Name)
+		renamed := map[string]string{}
+		if namespace != folder.Namespace {
+			for _, session := range sessions {
+				newName := namespace + strings.TrimPrefix(session.Name, folder.Namespace)
+				if err := m.client.RenameSession(session.Name, newName); err != nil {
+					m.undoRenames(renamed)
+					return configEditedMsg{err: err}
+				}
+				renamed[session.Name] = newName
+			}
+		}
+		if err := configfile.UpdateFolder(m.cfgPath, folder.Namespace, updated); err != nil {
+			m.undoRenames(renamed)
+			return configEditedMsg{err: err}
+		}
+		if namespace != folder.Namespace {
+			if err := logfile.Move(filepath.Join(logfile.Dir(), folder.Namespace), filepath.Join(logfile.Dir(), namespace)); err != nil {
+				return configEditedMsg{err: err}
+			}
+		}
+		return m.savedConfigMsg("updated folder "+strings.TrimSpace(updated.Name), renamed)
+	}
+}
+
+// updateCommandCmd saves a command's new name and command line. A renamed
+// command that is running keeps its session and log under the new name; the
+// session is renamed before the config is saved, and back if saving fails.
+func (m Model) updateCommandCmd(folderIndex int, name string, updated config.Command) tea.Cmd {
+	folder := m.cfg.Folders[folderIndex]
+	oldSession := commandSessionName(folder, name)
+	running := m.sessionExists(oldSession)
+	return func() tea.Msg {
+		rename := config.Slug(updated.Name) != config.Slug(name)
+		renamed := map[string]string{}
+		if rename && running {
+			newSession := commandSessionName(folder, updated.Name)
+			if err := m.client.RenameSession(oldSession, newSession); err != nil {
+				return configEditedMsg{err: err}
+			}
+			renamed[oldSession] = newSession
+		}
+		if err := configfile.UpdateCommand(m.cfgPath, folder.Namespace, name, updated); err != nil {
+			m.undoRenames(renamed)
+			return configEditedMsg{err: err}
+		}
+		if rename {
+			if err := logfile.Move(logfile.Path(folder.Namespace, name), logfile.Path(folder.Namespace, updated.Name)); err != nil {
+				return configEditedMsg{err: err}
+			}
+		}
+		return m.savedConfigMsg("updated command "+strings.TrimSpace(updated.Name), renamed)
+	}
+}
+
+// undoRenames puts renamed sessions back under their old names after the
+// edit they were part of failed. It is best effort: a session that cannot be
+// renamed back is shown under its new name.
+func (m Model) undoRenames(renamed map[string]string) {
+	for oldName, newName := range renamed {
+		_ = m.client.RenameSession(newName, oldName)
+	}
+}
+
+func (m Model) removeFolderCmd(folderIndex int) tea.Cmd {
+	folder := m.cfg.Folders[folderIndex]
+	name := folder.Name
+	return func() tea.Msg {
+		if err := configfile.RemoveFolder(m.cfgPath, folder.Namespace); err != nil {
+			return configEditedMsg{err: err}
+		}
+		return m.savedConfigMsg("removed folder "+name, nil)
+	}
+}
+
+// removeCommandCmd removes a command from the config and stops its session,
+// which grove would otherwise no longer show.
+func (m Model) removeCommandCmd(folderIndex int, name string) tea.Cmd {
+	folder := m.cfg.Folders[folderIndex]
+	session := commandSessionName(folder, name)
+	running := m.sessionExists(session)
+	return func() tea.Msg {
+		if err := configfile.RemoveCommand(m.cfgPath, folder.Namespace, name); err != nil {
+			return configEditedMsg{err: err}
+		}
+		if running {
+			if err := m.client.KillSession(session); err != nil {
+				return configEditedMsg{err: err}
+			}
+		}
+		return m.savedConfigMsg("removed command "+name, nil)
+	}
+}
+
+func (m Model) removeAgentCmd(folderIndex int, name string) tea.Cmd {
+	namespace := m.cfg.Folders[folderIndex].Namespace
+	return func() tea.Msg {
+		if err := configfile.RemoveAgent(m.cfgPath, namespace, name); err != nil {
+			return configEditedMsg{err: err}
+		}
+		return m.savedConfigMsg("removed agent "+name, nil)
+	}
+}
+
+func (m Model) handleConfigEdited(msg configEditedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.errMsg = msg.err.Error()
+		return m, m.loadSessionsCmd()
+	}
+	for oldName, newName := range msg.renamed {
+		if state, ok := m.restarts[oldName]; ok {
+			m.restarts[newName] = state
+			delete(m.restarts, oldName)
+		}
+		if at, ok := m.seen[oldName]; ok {
+			m.seen[newName] = at
+			delete(m.seen, oldName)
+			m.seenDirty = true
+		}
+	}
+	m.cfgVersion = msg.version
+	m.cfgErr = ""
+	m.applyConfig(msg.cfg)
+	return m, tea.Batch(m.setStatus(msg.status), m.loadSessionsCmd())
+}
@@ -0,0 +1,325 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// hostSessions routes session calls to the tmux server that owns each
+// session: the local one, or the remote host of the folder whose namespace
+// the session name starts with. Snapshots from every server are merged into
+// one tree.
+type hostSessions struct {
+	local     SessionManager
+	newRemote func(host string) SessionManager
+
+	mu      sync.Mutex
+	remotes map[string]SessionManager
+	// hosts maps the namespaces of remote folders to their hosts.
+	hosts map[string]string
+	// last keeps each server's last snapshot, keyed by host ("" for the
+	// local server), so an unreachable host keeps its rows.
+	last    map[string]tmux.SessionSnapshot
+	pumping map[string]bool
+
+	changes chan tmux.Changes
+	stopped chan struct{}
+}
+
+func newHostSessions(local SessionManager, newRemote func(host string) SessionManager) *hostSessions {
+	return &hostSessions{
+		local:     local,
+		newRemote: newRemote,
+		remotes:   map[string]SessionManager{},
+		hosts:     map[string]string{},
+		last:      map[string]tmux.SessionSnapshot{},
+		pumping:   map[string]bool{},
+		changes:   make(chan tmux.Changes),
+		stopped:   make(chan struct{}, 1),
+	}
+}
+
+// setFolders records which namespaces live on which hosts, disconnecting
+// from hosts no folder uses any more.
+func (h *hostSessions) setFolders(folders []config.Folder) {
+	h.mu.Lock()
+	h.hosts = map[string]string{}
+	for _, folder := range folders {
+		if folder.Host == "" {
+			continue
+		}
+		h.hosts[folder.Namespace] = folder.Host
+		if _, ok := h.remotes[folder.Host]; !ok {
+			h.remotes[folder.Host] = h.newRemote(folder.Host)
+		}
+	}
+	var gone []SessionManager
+	for host, remote := range h.remotes {
+		if !h.inUse(host) {
+			gone = append(gone, remote)
+			delete(h.remotes, host)
+			delete(h.last, host)
+		}
+	}
+	h.mu.Unlock()
+	for _, remote := range gone {
+		closeSessions(remote)
+	}
+}
+
+func (h *hostSessions) inUse(host string) bool {
+	for _, used := range h.hosts {
+		if used == host {
+			return true
+		}
+	}
+	return false
+}
+
+// Close disconnects from every remote host. The local server's client is
+// left to whoever created it.
+func (h *hostSessions) Close() error {
+	h.mu.Lock()
+	remotes := h.remotes
+	h.remotes = map[string]SessionManager{}
+	h.mu.Unlock()
+	var errs []error
+	for _, remote := range remotes {
+		errs = append(errs, closeSessions(remote))
+	}
+	return errors.Join(errs...)
+}
+
+// closeSessions closes a session client that holds connections open.
+func closeSessions(client SessionManager) error {
+	if closer, ok := client.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// hostOf returns the host of the server that owns name.
+func (h *hostSessions) hostOf(name string) string {
+	namespace, _, _ := strings.Cut(name, "/")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.hosts[namespace]
+}
+
+func (h *hostSessions) member(host string) SessionManager {
+	if host == "" {
+		return h.local
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.remotes[host]
+}
+
+func (h *hostSessions) route(name string) SessionManager {
+	return h.member(h.hostOf(name))
+}
+
+// members returns the servers in use, the local one first.
+func (h *hostSessions) members() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hosts := []string{""}
+	seen := map[string]bool{}
+	for _, host := range h.hosts {
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts[1:])
+	return hosts
+}
+
+// LoadSnapshot merges the snapshots of every server, queried together so a
+// slow host does not hold up the others. A server that cannot be reached
+// contributes its last snapshot, marked stale, and its error is returned
+// alongside the merged result.
+func (h *hostSessions) LoadSnapshot() (tmux.SessionSnapshot, error) {
+	hosts := h.members()
+	snapshots := make([]tmux.SessionSnapshot, len(hosts))
+	errs := make([]error, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			member := h.member(host)
+			if member == nil {
+				// The host left the config since members was read.
+				return
+			}
+			snapshots[i], errs[i] = member.LoadSnapshot()
+		}()
+	}
+	wg.Wait()
+
+	merged := tmux.SessionSnapshot{
+		SessionWindows: map[string][]int{},
+		ActiveWindows:  map[string]int{},
+		PaneDataFresh:  true,
+	}
+	for i, host := range hosts {
+		snapshot, err := snapshots[i], errs[i]
+		h.mu.Lock()
+		if err != nil {
+			if host != "" {
+				errs[i] = fmt.Errorf("%s: %w", host, err)
+			}
+			cached, ok := h.last[host]
+			h.mu.Unlock()
+			if !ok {
+				continue
+			}
+			snapshot = cached
+			snapshot.PaneDataFresh = false
+		} else {
+			h.last[host] = snapshot
+			h.mu.Unlock()
+		}
+
+		if merged.Sessions == nil {
+			merged.Sessions = []tmux.Session{}
+		}
+		for _, session := range snapshot.Sessions {
+			if h.hostOf(session.Name) != host {
+				continue
+			}
+			merged.Sessions = append(merged.Sessions, session)
+			if windows, ok := snapshot.SessionWindows[session.Name]; ok {
+				merged.SessionWindows[session.Name] = windows
+			}
+			if window, ok := snapshot.ActiveWindows[session.Name]; ok {
+				merged.ActiveWindows[session.Name] = window
+			}
+		}
+		merged.PaneDataFresh = merged.PaneDataFresh && snapshot.PaneDataFresh
+	}
+	return merged, errors.Join(errs...)
+}
+
+func (h *hostSessions) NewSession(name, cwd string, env []string) error {
+	return h.route(name).NewSession(name, cwd, env)
+}
+
+func (h *hostSessions) NewSessionWithCommand(name, cwd, command string, env []string) error {
+	return h.route(name).NewSessionWithCommand(name, cwd, command, env)
+}
+
+func (h *hostSessions) NewCommandSession(name, cwd, command string, env []string, logPath string) error {
+	return h.route(name).NewCommandSession(name, cwd, command, env, logPath)
+}
+
+func (h *hostSessions) SendKeys(target, command string) error {
+	return h.route(target).SendKeys(target, command)
+}
+
+func (h *hostSessions) PressKeys(target string, keys []string) error {
+	return h.route(target).PressKeys(target, keys)
+}
+
+func (h *hostSessions) SetSessionOption(target, option, value string) error {
+	return h.route(target).SetSessionOption(target, option, value)
+}
+
+// RenameSession renames within the server that owns oldName; sessions do not
+// move between hosts.
+func (h *hostSessions) RenameSession(oldName, newName string) error {
+	return h.route(oldName).RenameSession(oldName, newName)
+}
+
+func (h *hostSessions) KillSession(name string) error {
+	return h.route(name).KillSession(name)
+}
+
+func (h *hostSessions) CapturePane(target string) (string, error) {
+	return h.route(target).CapturePane(target)
+}
+
+func (h *hostSessions) PeekPane(target string) (string, error) {
+	return h.route(target).PeekPane(target)
+}
+
+func (h *hostSessions) AttachCommand(name string) *exec.Cmd {
+	return h.route(name).AttachCommand(name)
+}
+
+func (h *hostSessions) DetachKeys(name string) string {
+	return h.route(name).DetachKeys(name)
+}
+
+// Watch starts following every server that can push changes. It fails only
+// when none can; servers that are not followed are still polled.
+func (h *hostSessions) Watch() error {
+	var errs []error
+	watching := false
+	for _, host := range h.members() {
+		watcher, ok := h.member(host).(changeWatcher)
+		if !ok {
+			continue
+		}
+		if err := watcher.Watch(); err != nil {
+			if host != "" {
+				err = fmt.Errorf("%s: %w", host, err)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		watching = true
+		h.mu.Lock()
+		start := !h.pumping[host]
+		h.pumping[host] = true
+		h.mu.Unlock()
+		if start {
+			go h.pump(host, watcher)
+		}
+	}
+	if !watching {
+		if len(errs) == 0 {
+			return errors.New("no tmux server can be watched")
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// pump forwards one server's changes until its connection closes.
+func (h *hostSessions) pump(host string, watcher changeWatcher) {
+	for {
+		changes, ok := watcher.NextChange()
+		if !ok {
+			break
+		}
+		h.changes <- changes
+	}
+	h.mu.Lock()
+	h.pumping[host] = false
+	h.mu.Unlock()
+	select {
+	case h.stopped <- struct{}{}:
+	default:
+	}
+}
+
+// NextChange returns the next change from any followed server. It returns
+// false when one of them disconnects, so the model polls and calls Watch
+// again to reconnect it.
+func (h *hostSessions) NextChange() (tmux.Changes, bool) {
+	select {
+	case changes := <-h.changes:
+		return changes, true
+	case <-h.stopped:
+		return tmux.Changes{}, false
+	}
+}
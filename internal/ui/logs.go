@@ -0,0 +1,245 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/SarthakJariwala/grove/internal/logfile"
+)
+
+const logRefreshInterval = 500 * time.Millisecond
+
+// logTailBytes bounds how much of a log the viewer loads.
+const logTailBytes = 1 << 20
+
+type logLoadedMsg struct {
+	path  string
+	lines []string
+	err   error
+	seq   int
+}
+
+type logTickMsg struct{}
+
+func logTickCmd() tea.Cmd {
+	return tea.Tick(logRefreshInterval, func(time.Time) tea.Msg {
+		return logTickMsg{}
+	})
+}
+
+func (m *Model) openLogs(row treeRow) tea.Cmd {
+	folder := m.cfg.Folders[row.folderIndex]
+	m.clearSelectionPreview()
+	m.detailMode = detailLogs
+	m.logSession = row.sessionName
+	m.logPath = logfile.Path(folder.Namespace, row.displayName)
+	if row.typeOf == rowTask {
+		m.logPath = taskLogPath(folder, row.displayName)
+	}
+	m.logLines = nil
+	m.logErr = nil
+	m.logTop = 0
+	m.logFollow = true
+	m.logSearch = ""
+	m.logSeq++
+	return tea.Batch(m.loadLogCmd(), logTickCmd())
+}
+
+func (m *Model) exitLogs() {
+	m.detailMode = detailNormal
+	m.logSession = ""
+	m.logPath = ""
+	m.logLines = nil
+	m.logErr = nil
+	m.logSearch = ""
+}
+
+func (m Model) loadLogCmd() tea.Cmd {
+	path, seq := m.logPath, m.logSeq
+	return func() tea.Msg {
+		lines, err := logfile.ReadTail(path, logTailBytes)
+		return logLoadedMsg{path: path, lines: lines, err: err, seq: seq}
+	}
+}
+
+func (m *Model) applyLogLoad(msg logLoadedMsg) {
+	if msg.err != nil {
+		m.logLines = nil
+		m.logErr = msg.err
+		return
+	}
+	m.logErr = nil
+	m.logLines = msg.lines
+	m.clampLogTop()
+}
+
+func (m Model) updateLogs(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q":
+		m.exitLogs()
+		return m, m.syncSelectionPreview(true, true)
+	case "up", "k":
+		m.scrollLogs(-1)
+	case "down", "j":
+		m.scrollLogs(1)
+	case "pgup", "ctrl+b":
+		m.scrollLogs(-m.logPageSize())
+	case "pgdown", "ctrl+f":
+		m.scrollLogs(m.logPageSize())
+	case "g", "home":
+		m.logFollow = false
+		m.logTop = 0
+	case "G", "end":
+		m.logFollow = true
+		m.clampLogTop()
+		return m, m.loadLogCmd()
+	case "f":
+		m.logFollow = !m.logFollow
+		if m.logFollow {
+			return m, m.loadLogCmd()
+		}
+		m.clampLogTop()
+	case "r":
+		return m, m.loadLogCmd()
+	case "/":
+		m.openPrompt(promptLogSearch, m.logSearch, "search log")
+		return m, textinput.Blink
+	case "n":
+		return m, m.jumpToLogMatch(m.logTop+1, 1)
+	case "N":
+		return m, m.jumpToLogMatch(m.logTop-1, -1)
+	}
+	return m, nil
+}
+
+func (m *Model) scrollLogs(delta int) {
+	m.logFollow = false
+	m.logTop += delta
+	m.clampLogTop()
+}
+
+// clampLogTop keeps the first visible line within range. While following,
+// the view is pinned to the end of the log.
+func (m *Model) clampLogTop() {
+	maxTop := len(m.logLines) - m.logPageSize()
+	if maxTop < 0 {
+		maxTop = 0
+	}
+	if m.logFollow || m.logTop > maxTop {
+		m.logTop = maxTop
+	}
+	if m.logTop < 0 {
+		m.logTop = 0
+	}
+}
+
+func (m Model) logPageSize() int {
+	// Pane border plus the title and position lines.
+	h := m.contentHeight() - 4
+	if h < 1 {
+		h = 1
+	}
+	return h
+}
+
+// jumpToLogMatch scrolls to the next line matching the search, starting at
+// from and moving in dir, wrapping around the ends of the log.
+func (m *Model) jumpToLogMatch(from, dir int) tea.Cmd {
+	if m.logSearch == "" || len(m.logLines) == 0 {
+		return nil
+	}
+	n := len(m.logLines)
+	for i := 0; i < n; i++ {
+		index := ((from+dir*i)%n + n) % n
+		if logLineMatches(m.logLines[index], m.logSearch) {
+			m.logFollow = false
+			m.logTop = index
+			m.clampLogTop()
+			return nil
+		}
+	}
+	return m.setStatus("no matches for " + m.logSearch)
+}
+
+func logLineMatches(line, query string) bool {
+	return query != "" && strings.Contains(strings.ToLower(stripANSI(line)), strings.ToLower(query))
+}
+
+func (m Model) logMatchCount() int {
+	count := 0
+	for _, line := range m.logLines {
+		if logLineMatches(line, m.logSearch) {
+			count++
+		}
+	}
+	return count
+}
+
+func (m Model) renderLogPane(innerH, maxWidth, paneWidth int, dim bool) string {
+	title := m.styles.paneTitle.Render("Logs")
+	if m.logSession != "" {
+		title += " " + m.styles.detailMeta.Render(truncateRight(m.logSession, maxWidth-6))
+	}
+
+	if m.logErr != nil {
+		msg := m.styles.footerErr.Render("error: " + m.logErr.Error())
+		if os.IsNotExist(m.logErr) {
+			msg = m.styles.emptyHint.Render("no output logged yet")
+		}
+		padded := padToHeight(title+"\n\n"+msg, innerH)
+		return m.styledPane(padded, paneWidth, innerH, dim)
+	}
+
+	bodyH := innerH - 2
+	if bodyH < 1 {
+		bodyH = 1
+	}
+	top := m.logTop
+	if m.logFollow || top > len(m.logLines)-bodyH {
+		top = len(m.logLines) - bodyH
+	}
+	if top < 0 {
+		top = 0
+	}
+	end := top + bodyH
+	if end > len(m.logLines) {
+		end = len(m.logLines)
+	}
+
+	position := fmt.Sprintf("%d–%d of %d", top+1, end, len(m.logLines))
+	if len(m.logLines) == 0 {
+		position = "empty"
+	}
+	if m.logFollow {
+		position += " · following"
+	}
+	if m.logSearch != "" {
+		count := m.logMatchCount()
+		position += fmt.Sprintf(" · /%s %d match%s", m.logSearch, count, pluralSuffixES(count))
+	}
+
+	lines := []string{title, m.styles.detailMeta.Render(truncateRight(position, maxWidth))}
+	for _, line := range m.logLines[top:end] {
+		if logLineMatches(line, m.logSearch) {
+			lines = append(lines, m.styles.footerWarn.Render(truncateRight(stripANSI(line), maxWidth)))
+			continue
+		}
+		lines = append(lines, ansi.Truncate(sanitizeANSI(line), maxWidth, ""))
+	}
+	return m.styledPane(padToHeight(strings.Join(lines, "\n"), innerH), paneWidth, innerH, dim)
+}
+
+func pluralSuffixES(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "es"
+}
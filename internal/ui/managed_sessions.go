@@ -7,6 +7,7 @@ import (
 
 	"github.com/SarthakJariwala/grove/internal/config"
 	"github.com/SarthakJariwala/grove/internal/tmux"
+	"github.com/SarthakJariwala/grove/internal/worktree"
 )
 
 type managedSessionKind int
@@ -16,6 +17,7 @@ const (
 	managedAgent
 	managedTerminal
 	managedCommand
+	managedTask
 )
 
 type managedSessionID struct {
@@ -25,7 +27,19 @@ type managedSessionID struct {
 }
 
 func agentSessionName(folder config.Folder, slug string, index int) string {
-	return fmt.Sprintf("%s/agent-%s-%d", folder.Namespace, sanitizeLeaf(slug), index)
+	return folder.Namespace + "/" + agentSessionLeaf(slug, index)
+}
+
+func agentSessionLeaf(slug string, index int) string {
+	return fmt.Sprintf("agent-%s-%d", sanitizeLeaf(slug), index)
+}
+
+func agentWorktreePath(folder config.Folder, slug string, index int) string {
+	return worktree.Path(folder.Path, agentSessionLeaf(slug, index))
+}
+
+func agentWorktreeBranch(slug string, index int) string {
+	return fmt.Sprintf("grove/%s-%d", sanitizeLeaf(slug), index)
 }
 
 func terminalSessionName(folder config.Folder, index int) string {
@@ -36,6 +50,10 @@ func commandSessionName(folder config.Folder, slug string) string {
 	return fmt.Sprintf("%s/cmd-%s", folder.Namespace, sanitizeLeaf(slug))
 }
 
+func taskSessionName(folder config.Folder, slug string) string {
+	return fmt.Sprintf("%s/task-%s", folder.Namespace, sanitizeLeaf(slug))
+}
+
 func parseManagedSession(namespace, fullName string) (managedSessionID, bool) {
 	prefix := namespace + "/"
 	if !strings.HasPrefix(fullName, prefix) {
@@ -56,6 +74,13 @@ func parseManagedSession(namespace, fullName string) (managedSessionID, bool) {
 		}
 		return managedSessionID{kind: managedCommand, slug: slug}, true
 	}
+	if strings.HasPrefix(leaf, "task-") {
+		slug := strings.TrimPrefix(leaf, "task-")
+		if slug == "" || sanitizeLeaf(slug) != slug {
+			return managedSessionID{}, false
+		}
+		return managedSessionID{kind: managedTask, slug: slug}, true
+	}
 	if strings.HasPrefix(leaf, "term-") {
 		rawIndex := strings.TrimPrefix(leaf, "term-")
 		if rawIndex == "" || strings.Contains(rawIndex, "-") || strings.HasPrefix(rawIndex, "+") {
@@ -0,0 +1,272 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+)
+
+// Marked rows are kept by session name, so a mark follows its session across
+// refreshes and filters. While any row is marked, K, x, s, R and c act on
+// every marked row instead of the selected one.
+
+func markable(row treeRow) bool {
+	switch row.typeOf {
+	case rowAgentInstance, rowTerminalInstance, rowCommand:
+		return true
+	default:
+		return false
+	}
+}
+
+// toggleMark marks or unmarks the selected row and moves to the next one.
+func (m *Model) toggleMark() tea.Cmd {
+	row, ok := m.selectedRow()
+	if !ok || !markable(row) {
+		m.errMsg = "select a session or command to mark"
+		return nil
+	}
+	if m.marked[row.sessionName] {
+		delete(m.marked, row.sessionName)
+	} else {
+		m.marked[row.sessionName] = true
+	}
+	m.errMsg = ""
+	if m.setSelected(m.selected + 1) {
+		return m.syncSelectionPreview(true, true)
+	}
+	return nil
+}
+
+// markFolder marks every session and command in the selected folder, or
+// clears them when they are all marked already.
+func (m *Model) markFolder() tea.Cmd {
+	folder, ok := m.selectedFolder()
+	if !ok {
+		m.errMsg = "select a folder or one of its sections"
+		return nil
+	}
+	folderIndex := m.rows[m.selected].folderIndex
+	var names []string
+	all := true
+	for _, row := range m.rows {
+		if row.folderIndex == folderIndex && markable(row) {
+			names = append(names, row.sessionName)
+			all = all && m.marked[row.sessionName]
+		}
+	}
+	if len(names) == 0 {
+		m.errMsg = folder.Name + " has nothing to mark"
+		return nil
+	}
+	for _, name := range names {
+		if all {
+			delete(m.marked, name)
+		} else {
+			m.marked[name] = true
+		}
+	}
+	if all {
+		return m.setStatus("unmarked " + folder.Name)
+	}
+	return m.setStatus(fmt.Sprintf("marked %d in %s", len(names), folder.Name))
+}
+
+func (m *Model) clearMarks() {
+	for name := range m.marked {
+		delete(m.marked, name)
+	}
+}
+
+// pruneMarks drops the marks of rows that are gone from the tree, such as
+// killed sessions.
+func (m *Model) pruneMarks(rows []treeRow) {
+	if len(m.marked) == 0 {
+		return
+	}
+	present := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		if markable(row) {
+			present[row.sessionName] = true
+		}
+	}
+	for name := range m.marked {
+		if !present[name] {
+			delete(m.marked, name)
+		}
+	}
+}
+
+// markedRows returns the marked rows in tree order, including those the
+// filter hides.
+func (m Model) markedRows() []treeRow {
+	var marked []treeRow
+	for _, row := range m.treeRows() {
+		if markable(row) && m.marked[row.sessionName] {
+			marked = append(marked, row)
+		}
+	}
+	return marked
+}
+
+// confirmKillMarked asks to kill the marked agents and terminals.
+func (m *Model) confirmKillMarked() {
+	var targets []treeRow
+	for _, row := range m.markedRows() {
+		if row.typeOf == rowAgentInstance || row.typeOf == rowTerminalInstance {
+			targets = append(targets, row)
+		}
+	}
+	if len(targets) == 0 {
+		m.errMsg = "no marked agents or terminals to kill"
+		return
+	}
+	m.confirmKillTargets = targets
+	m.statusMsg = ""
+	m.errMsg = ""
+}
+
+func (m Model) isKillTarget(row treeRow) bool {
+	for _, target := range m.confirmKillTargets {
+		if target.sessionName == row.sessionName {
+			return true
+		}
+	}
+	return false
+}
+
+// killTargetsHaveWorktrees reports whether any session awaiting a kill
+// confirmation runs in its own worktree.
+func (m Model) killTargetsHaveWorktrees() bool {
+	for _, target := range m.confirmKillTargets {
+		if target.worktreePath != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (m Model) killConfirmPrompt() string {
+	names := make([]string, 0, len(m.confirmKillTargets))
+	for _, target := range m.confirmKillTargets {
+		names = append(names, target.sessionName)
+	}
+	return "kill " + strings.Join(names, ", ") + "?"
+}
+
+// stopMarked stops the marked commands that are running or have exited.
+func (m *Model) stopMarked() tea.Cmd {
+	var targets []treeRow
+	for _, row := range m.markedRows() {
+		if row.typeOf == rowCommand && row.status != "stopped" && !m.isStopping(row.sessionName) {
+			m.markCommandStopped(row.sessionName)
+			targets = append(targets, row)
+		}
+	}
+	if len(targets) == 0 {
+		m.errMsg = "no marked commands to stop"
+		return nil
+	}
+	return m.stopCommandsCmd(targets)
+}
+
+// startMarked starts the marked commands that are not running, with their
+// dependencies, one folder after another.
+func (m *Model) startMarked() tea.Cmd {
+	var cmds []tea.Cmd
+	pending := map[int][]config.Command{}
+	var folderOrder []int
+	queued := map[string]bool{}
+	for _, row := range m.markedRows() {
+		if row.typeOf != rowCommand || row.status == "running" {
+			continue
+		}
+		folder := m.cfg.Folders[row.folderIndex]
+		order, err := config.CommandStartOrder(folder, row.displayName)
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		for _, command := range m.stoppedCommands(row.folderIndex, order) {
+			name := commandSessionName(folder, command.Name)
+			if queued[name] {
+				continue
+			}
+			queued[name] = true
+			if _, ok := pending[row.folderIndex]; !ok {
+				folderOrder = append(folderOrder, row.folderIndex)
+			}
+			pending[row.folderIndex] = append(pending[row.folderIndex], command)
+		}
+	}
+	if len(folderOrder) == 0 {
+		return m.setStatus("marked commands already running")
+	}
+	for _, folderIndex := range folderOrder {
+		folder := m.cfg.Folders[folderIndex]
+		m.resetRestarts(folder, pending[folderIndex])
+		cmds = append(cmds, m.startCommandsCmd(folder, pending[folderIndex], "started "+commandNames(pending[folderIndex])))
+	}
+	return tea.Sequence(cmds...)
+}
+
+// restartMarked restarts the marked running commands and starts the others,
+// starting each stopped dependency once.
+func (m *Model) restartMarked() tea.Cmd {
+	var cmds []tea.Cmd
+	started := map[string]bool{}
+	notStarted := func(folder config.Folder, commands []config.Command) []config.Command {
+		var fresh []config.Command
+		for _, command := range commands {
+			name := commandSessionName(folder, command.Name)
+			if !started[name] {
+				started[name] = true
+				fresh = append(fresh, command)
+			}
+		}
+		return fresh
+	}
+	for _, row := range m.markedRows() {
+		if row.typeOf != rowCommand || m.isStopping(row.sessionName) {
+			continue
+		}
+		folder := m.cfg.Folders[row.folderIndex]
+		order, err := config.CommandStartOrder(folder, row.displayName)
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		if row.status != "running" {
+			pending := notStarted(folder, m.stoppedCommands(row.folderIndex, order))
+			if len(pending) == 0 {
+				continue
+			}
+			m.resetRestarts(folder, pending)
+			cmds = append(cmds, m.startCommandsCmd(folder, pending, "started "+commandNames(pending)))
+			continue
+		}
+		deps := notStarted(folder, m.stoppedCommands(row.folderIndex, order[:len(order)-1]))
+		m.resetRestarts(folder, append(deps, order[len(order)-1]))
+		cmds = append(cmds, m.restartCommandCmd(folder, row, deps))
+	}
+	if len(cmds) == 0 {
+		m.errMsg = "no marked commands to restart"
+		return nil
+	}
+	return tea.Sequence(cmds...)
+}
+
+// markedSessionTargets returns the marked agents and terminals that are
+// running, for sending a command to.
+func (m Model) markedSessionTargets() []string {
+	var targets []string
+	for _, row := range m.markedRows() {
+		if (row.typeOf == rowAgentInstance || row.typeOf == rowTerminalInstance) && m.sessionExists(row.sessionName) {
+			targets = append(targets, row.sessionName)
+		}
+	}
+	return targets
+}
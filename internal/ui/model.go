@@ -11,7 +11,13 @@ import (
 	"github.com/charmbracelet/x/ansi"
 
 	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/configfile"
+	"github.com/SarthakJariwala/grove/internal/manifest"
+	"github.com/SarthakJariwala/grove/internal/seen"
+	"github.com/SarthakJariwala/grove/internal/stops"
+	"github.com/SarthakJariwala/grove/internal/taskrun"
 	"github.com/SarthakJariwala/grove/internal/tmux"
+	"github.com/SarthakJariwala/grove/internal/worktree"
 )
 
 const refreshInterval = 500 * time.Millisecond
@@ -26,6 +32,7 @@ const (
 	rowAgentInstance
 	rowTerminalInstance
 	rowCommand
+	rowTask
 )
 
 type treeRow struct {
@@ -46,6 +53,14 @@ type treeRow struct {
 	paneTitle      string
 	currentPath    string
 	lastActivity   int64
+	worktreePath   string
+	exitStatus     int
+	exitSignal     int
+	fromProject    bool
+	// agent is the detected state of an agent instance.
+	agent agentStatus
+	// lastRun is the last recorded run of a task.
+	lastRun taskrun.Run
 }
 
 type overlayMode int
@@ -75,13 +90,35 @@ const (
 	promptAddAgentCommand
 	promptAddCommandName
 	promptAddCommandCommand
+	promptAgentWorktreeBranch
+	promptLogSearch
+	promptEditFolder
+	promptEditCommandName
+	promptEditCommandCommand
 )
 
+type removalKind int
+
+const (
+	removalNone removalKind = iota
+	removalFolder
+	removalCommand
+	removalAgent
+)
+
+// removal is a config entry waiting for the user to confirm its removal.
+type removal struct {
+	kind        removalKind
+	folderIndex int
+	name        string
+}
+
 type detailMode int
 
 const (
 	detailNormal detailMode = iota
 	detailPreview
+	detailLogs
 )
 
 // ── Color palette (forest/grove theme) ──────────────────────────────
@@ -114,10 +151,16 @@ const (
 )
 
 type Model struct {
-	cfg     config.Config
-	cfgPath string
-	client  sessionManager
-	styles  styleSet
+	cfg        config.Config
+	cfgPath    string
+	cfgVersion configfile.Version
+	// projectsVersion covers the folders' project files, which are merged
+	// into cfg and watched with it.
+	projectsVersion configfile.Version
+	cfgErr          string
+	client          SessionManager
+	worktrees       worktreeManager
+	styles          styleSet
 
 	width  int
 	height int
@@ -127,12 +170,48 @@ type Model struct {
 	sessions       map[int][]tmux.Session
 	sessionWindows map[string][]int
 	activeWindows  map[string]int
-	statusMsg      string
-	statusSeq      int
-	errMsg         string
+	agentStates    map[string]agentStatus
+	// agentScreens are the agent panes captured at agentScreensAt, matched
+	// by the snapshots until the next capture.
+	agentScreens   map[string]string
+	agentScreensAt time.Time
+	restarts       map[string]commandRestartState
+	// stopsPath records the commands stopped on purpose by any grove.
+	stopsPath    string
+	stopping     map[string]time.Time
+	watching     bool
+	watchAttempt time.Time
+	// polling is set while a refresh tick's snapshot is still loading, so
+	// slow hosts do not pile up polls behind it.
+	polling   bool
+	statusMsg string
+	statusSeq int
+	errMsg    string
+
+	// seen holds when each session was last looked at, in Unix seconds.
+	seen       map[string]int64
+	seenPath   string
+	seenLoaded bool
+	seenDirty  bool
+
+	// manifest is the last saved list of running managed sessions.
+	manifest       manifest.Manifest
+	manifestPath   string
+	manifestLoaded bool
+	restoreChecked bool
+	confirmRestore []restoreItem
+
+	// taskRuns holds the last run of each task by session name.
+	taskRuns       map[string]taskrun.Run
+	taskRunsPath   string
+	taskRunsLoaded bool
+	// taskCheckedAt is when scheduled tasks were last checked for being due.
+	taskCheckedAt time.Time
 
 	filterQuery        string
-	confirmKillTarget  string
+	marked             map[string]bool
+	confirmKillTargets []treeRow
+	confirmRemoval     removal
 	detailScroll       int
 	overlayMode        overlayMode
 	overlayIndex       int
@@ -149,14 +228,26 @@ type Model struct {
 	previewZoomed   bool
 	previewInFlight bool
 
+	logSession string
+	logPath    string
+	logLines   []string
+	logErr     error
+	logTop     int
+	logFollow  bool
+	logSearch  string
+	logSeq     int
+
 	prompt            textinput.Model
 	promptMode        promptMode
-	promptTarget      string
+	sendTargetList    []sendTarget
+	sendTargetIndex   int
 	promptFolderIndex int
 	promptStep        int
 	pendingFolder     config.Folder
 	pendingAgent      config.Agent
+	pendingPersist    bool
 	pendingCommand    config.Command
+	editingName       string
 }
 
 type styleSet struct {
@@ -295,10 +386,15 @@ func defaultStyles() styleSet {
 
 type sessionsLoadedMsg struct {
 	sessions       map[int][]tmux.Session
+	agentStates    map[string]agentStatus
 	sessionWindows map[string][]int
 	activeWindows  map[string]int
 	panesFresh     bool
-	err            error
+	// agentScreens is set when the agents' panes were captured.
+	agentScreens map[string]string
+	// poll marks the snapshot a refresh tick asked for.
+	poll bool
+	err  error
 }
 
 type actionResultMsg struct {
@@ -308,7 +404,8 @@ type actionResultMsg struct {
 }
 
 type attachedMsg struct {
-	err error
+	session string
+	err     error
 }
 
 type clearStatusMsg struct {
@@ -320,10 +417,20 @@ type folderAddedMsg struct {
 	err    error
 }
 
+// configEditedMsg carries the config as saved by an edit or removal.
+// renamed maps old session names to new ones.
+type configEditedMsg struct {
+	status  string
+	cfg     config.Config
+	version configfile.Version
+	renamed map[string]string
+	err     error
+}
+
 type commandAddedMsg struct {
-	folderIndex int
-	command     config.Command
-	err         error
+	namespace string
+	command   config.Command
+	err       error
 }
 
 type paneCapturedMsg struct {
@@ -335,30 +442,63 @@ type paneCapturedMsg struct {
 
 type previewTickMsg struct{}
 
-func NewModel(cfg config.Config, cfgPath string, client sessionManager) Model {
+// Close disconnects from the remote hosts the model reached. client, as
+// passed to NewModel, is left to the caller to close.
+func (m Model) Close() error {
+	if hosts, ok := m.client.(*hostSessions); ok {
+		return hosts.Close()
+	}
+	return nil
+}
+
+func NewModel(cfg config.Config, cfgPath string, client SessionManager) Model {
 	t := textinput.New()
 	t.CharLimit = 512
 	t.Prompt = ""
 
+	// Remote servers are chosen by socket name only; a socket path names a
+	// file on this machine.
+	socket := cfg.TmuxSocket
+	if strings.Contains(socket, "/") {
+		socket = ""
+	}
+	sessions := newHostSessions(client, func(host string) SessionManager {
+		return tmux.NewRemoteClient(host, socket)
+	})
+	sessions.setFolders(cfg.Folders)
+
 	m := Model{
-		cfg:     cfg,
-		cfgPath: cfgPath,
-		client:  client,
-		styles:  defaultStyles(),
+		cfg:       cfg,
+		cfgPath:   cfgPath,
+		client:    sessions,
+		worktrees: worktree.NewClient(),
+		styles:    defaultStyles(),
 
 		sessions:          map[int][]tmux.Session{},
 		sessionWindows:    map[string][]int{},
 		activeWindows:     map[string]int{},
+		restarts:          map[string]commandRestartState{},
+		stopsPath:         stops.Path(),
+		stopping:          map[string]time.Time{},
+		marked:            map[string]bool{},
+		seen:              map[string]int64{},
+		seenPath:          seen.Path(),
+		manifestPath:      manifest.Path(),
+		taskRuns:          map[string]taskrun.Run{},
+		taskRunsPath:      taskrun.Path(),
+		taskCheckedAt:     time.Now(),
 		previewWindow:     -1,
 		promptFolderIndex: -1,
 		prompt:            t,
 	}
+	m.cfgVersion, _ = configfile.Stat(cfgPath)
+	m.projectsVersion = configfile.StatProjects(cfg)
 	m.rebuildRows()
 	return m
 }
 
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(m.loadSessionsCmd(), tickCmd())
+	return tea.Batch(m.loadSessionsCmd(), loadSeenCmd(m.seenPath), loadManifestCmd(m.manifestPath), loadTaskRunsCmd(m.taskRunsPath), tickCmd(refreshInterval), taskTickCmd(), configTickCmd(), m.watchCmd())
 }
 
 func (m Model) selectedRow() (treeRow, bool) {
@@ -370,6 +510,12 @@ func (m Model) selectedRow() (treeRow, bool) {
 
 // ── Tree Pane ───────────────────────────────────────────────────────
 
+// treeRows builds every row of the tree, including those the filter hides,
+// with the agent states and task runs grove tracks.
+func (m Model) treeRows() []treeRow {
+	return m.withTaskRuns(m.withAgentStates(buildTreeRows(m.cfg, m.sessions, m.sessionsByName())))
+}
+
 func (m *Model) rebuildRows() {
 	selectedRow, hadSelection := treeRow{}, false
 	if m.selected >= 0 && m.selected < len(m.rows) {
@@ -377,14 +523,8 @@ func (m *Model) rebuildRows() {
 		hadSelection = true
 	}
 
-	sessionByName := make(map[string]tmux.Session)
-	for _, folderSessions := range m.sessions {
-		for _, session := range folderSessions {
-			sessionByName[session.Name] = session
-		}
-	}
-
-	rows := buildTreeRows(m.cfg, m.sessions, sessionByName)
+	rows := m.treeRows()
+	m.pruneMarks(rows)
 	m.rows = filterTreeRows(rows, m.cfg, m.filterQuery)
 	if hadSelection {
 		if nextSelected, ok := findMatchingRowIndex(m.rows, selectedRow); ok {
@@ -400,6 +540,16 @@ func (m *Model) rebuildRows() {
 	m.detailScroll = 0
 }
 
+func (m Model) sessionsByName() map[string]tmux.Session {
+	sessionByName := make(map[string]tmux.Session)
+	for _, folderSessions := range m.sessions {
+		for _, session := range folderSessions {
+			sessionByName[session.Name] = session
+		}
+	}
+	return sessionByName
+}
+
 func filterTreeRows(rows []treeRow, cfg config.Config, query string) []treeRow {
 	query = strings.ToLower(strings.TrimSpace(query))
 	if query == "" {
@@ -481,6 +631,15 @@ func findMatchingRowIndex(rows []treeRow, selected treeRow) (int, bool) {
 	return 0, false
 }
 
+func (m Model) rowBySessionName(name string) (treeRow, bool) {
+	for _, row := range m.rows {
+		if row.sessionName != "" && row.sessionName == name {
+			return row, true
+		}
+	}
+	return treeRow{}, false
+}
+
 func (m Model) selectedSessionRow() (treeRow, bool) {
 	if len(m.rows) == 0 || m.selected < 0 || m.selected >= len(m.rows) {
 		return treeRow{}, false
@@ -489,7 +648,7 @@ func (m Model) selectedSessionRow() (treeRow, bool) {
 	switch row.typeOf {
 	case rowAgentInstance, rowTerminalInstance:
 		return row, true
-	case rowCommand:
+	case rowCommand, rowTask:
 		if row.status == "running" {
 			return row, true
 		}
@@ -498,7 +657,7 @@ func (m Model) selectedSessionRow() (treeRow, bool) {
 }
 
 func (m Model) shouldAutoPreview() bool {
-	if m.detailMode == detailPreview {
+	if m.detailMode != detailNormal {
 		return false
 	}
 	_, ok := m.selectedSessionRow()
@@ -527,6 +686,40 @@ func (m Model) selectedCommandRow() (treeRow, bool) {
 	return row, true
 }
 
+func (m Model) selectedTaskRow() (treeRow, bool) {
+	if len(m.rows) == 0 || m.selected < 0 || m.selected >= len(m.rows) {
+		return treeRow{}, false
+	}
+	row := m.rows[m.selected]
+	if row.typeOf != rowTask {
+		return treeRow{}, false
+	}
+	return row, true
+}
+
+func (m Model) commandRunning(folderIndex int, folder config.Folder, command config.Command) bool {
+	return sessionRunningIn(m.sessions[folderIndex], commandSessionName(folder, command.Name))
+}
+
+func (m Model) stoppedCommands(folderIndex int, commands []config.Command) []config.Command {
+	folder := m.cfg.Folders[folderIndex]
+	stopped := make([]config.Command, 0, len(commands))
+	for _, command := range commands {
+		if !m.commandRunning(folderIndex, folder, command) {
+			stopped = append(stopped, command)
+		}
+	}
+	return stopped
+}
+
+func commandNames(commands []config.Command) string {
+	names := make([]string, 0, len(commands))
+	for _, command := range commands {
+		names = append(names, command.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
 func (m Model) selectedFolder() (config.Folder, bool) {
 	if len(m.rows) == 0 || m.selected < 0 || m.selected >= len(m.rows) {
 		return config.Folder{}, false
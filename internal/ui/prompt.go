@@ -14,18 +14,14 @@ import (
 
 func (m *Model) openPrompt(mode promptMode, initial, placeholder string) {
 	if mode != promptRunCommand {
-		m.promptTarget = ""
+		m.sendTargetList = nil
 	}
 	m.promptMode = mode
 	m.prompt.SetValue(initial)
 	m.prompt.Placeholder = placeholder
 	m.prompt.Focus()
 	m.errMsg = ""
-	if mode == promptRunCommand {
-		m.statusMsg = "run command in selected session"
-	} else {
-		m.statusMsg = ""
-	}
+	m.statusMsg = ""
 }
 
 func (m Model) updatePrompt(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -34,14 +30,20 @@ func (m Model) updatePrompt(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "esc":
 			m.prompt.Blur()
 			m.promptMode = promptNone
-			m.promptTarget = ""
+			m.sendTargetList = nil
 			m.promptFolderIndex = -1
 			m.pendingAgent = config.Agent{}
+			m.pendingPersist = false
 			m.pendingCommand = config.Command{}
+			m.editingName = ""
 			m.statusMsg = ""
 			return m, nil
 		case "tab":
-			if m.promptMode == promptAddFolder && m.promptStep == 1 {
+			if m.promptMode == promptRunCommand {
+				m.cycleSendTarget()
+				return m, nil
+			}
+			if (m.promptMode == promptAddFolder || m.promptMode == promptEditFolder) && m.promptStep == 1 {
 				m.completePathInput()
 				return m, nil
 			}
@@ -51,10 +53,12 @@ func (m Model) updatePrompt(msg tea.Msg) (tea.Model, tea.Cmd) {
 			closePrompt := func() {
 				m.prompt.Blur()
 				m.promptMode = promptNone
-				m.promptTarget = ""
+				m.sendTargetList = nil
 				m.promptFolderIndex = -1
 				m.pendingAgent = config.Agent{}
+				m.pendingPersist = false
 				m.pendingCommand = config.Command{}
+				m.editingName = ""
 			}
 
 			switch mode {
@@ -84,7 +88,8 @@ func (m Model) updatePrompt(msg tea.Msg) (tea.Model, tea.Cmd) {
 				closePrompt()
 				return m, m.renameSessionCmd(row.sessionName, folder.Namespace+"/"+sanitizeLeaf(value))
 			case promptRunCommand:
-				if m.promptTarget == "" {
+				target, ok := m.sendTarget()
+				if !ok {
 					m.errMsg = "select a session"
 					return m, nil
 				}
@@ -92,13 +97,26 @@ func (m Model) updatePrompt(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.errMsg = "command cannot be empty"
 					return m, nil
 				}
-				if !m.sessionExists(m.promptTarget) {
+				var live, gone []string
+				for _, name := range target.sessions {
+					if m.sessionExists(name) {
+						live = append(live, name)
+					} else {
+						gone = append(gone, name)
+					}
+				}
+				if len(live) == 0 {
 					m.errMsg = "selected session is no longer running"
+					if len(target.sessions) > 1 {
+						m.errMsg = "none of the " + target.label + " are still running"
+					}
 					return m, nil
 				}
-				target := m.promptTarget
 				closePrompt()
-				return m, m.sendCommandCmd(target, value)
+				if len(target.sessions) == 1 {
+					return m, m.sendCommandCmd(live[0], value)
+				}
+				return m, m.sendCommandsCmd(live, gone, value)
 			case promptAddFolder:
 				switch m.promptStep {
 				case 0:
@@ -158,7 +176,27 @@ func (m Model) updatePrompt(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				folder := m.cfg.Folders[folderIndex]
 				closePrompt()
-				return m, m.newAgentCmd(folderIndex, folder, agent, true)
+				return m, m.newAgentCmd(folderIndex, folder, agent, true, "")
+			case promptAgentWorktreeBranch:
+				if value == "" {
+					m.errMsg = "branch name is required"
+					return m, nil
+				}
+				folderIndex := m.promptFolderIndex
+				if folderIndex < 0 || folderIndex >= len(m.cfg.Folders) {
+					m.errMsg = "select a folder"
+					return m, nil
+				}
+				agent, persist := m.pendingAgent, m.pendingPersist
+				if persist {
+					if err := config.AppendFolderAgent(&m.cfg, folderIndex, agent); err != nil {
+						m.errMsg = err.Error()
+						return m, nil
+					}
+				}
+				folder := m.cfg.Folders[folderIndex]
+				closePrompt()
+				return m, m.newAgentCmd(folderIndex, folder, agent, persist, value)
 			case promptAddCommandName:
 				if value == "" {
 					m.errMsg = "command name is required"
@@ -193,6 +231,71 @@ func (m Model) updatePrompt(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				closePrompt()
 				return m, m.addCommandCmd(folderIndex, command)
+			case promptEditFolder:
+				folderIndex := m.promptFolderIndex
+				if folderIndex < 0 || folderIndex >= len(m.cfg.Folders) {
+					m.errMsg = "select a folder"
+					return m, nil
+				}
+				switch m.promptStep {
+				case 0:
+					others := append(append([]config.Folder(nil), m.cfg.Folders[:folderIndex]...), m.cfg.Folders[folderIndex+1:]...)
+					if _, err := config.PrepareFolderName(value, others); err != nil {
+						m.errMsg = err.Error()
+						return m, nil
+					}
+					m.pendingFolder.Name = value
+					m.promptStep = 1
+					m.openPrompt(promptEditFolder, m.pendingFolder.Path, "folder path")
+					return m, textinput.Blink
+				case 1:
+					path, err := config.PrepareFolderPath(value)
+					if err != nil {
+						m.errMsg = err.Error()
+						return m, nil
+					}
+					m.pendingFolder.Path = path
+					m.promptStep = 2
+					m.openPrompt(promptEditFolder, m.pendingFolder.EditorCommand, "editor command (optional, e.g. code .)")
+					return m, textinput.Blink
+				case 2:
+					m.pendingFolder.EditorCommand = value
+					folder := m.pendingFolder
+					closePrompt()
+					return m, m.updateFolderCmd(folderIndex, folder)
+				}
+			case promptEditCommandName:
+				if value == "" {
+					m.errMsg = "command name is required"
+					return m, nil
+				}
+				folderIndex := m.promptFolderIndex
+				if folderIndex < 0 || folderIndex >= len(m.cfg.Folders) {
+					m.errMsg = "select a folder"
+					return m, nil
+				}
+				if config.Slug(value) != config.Slug(m.editingName) && config.CommandNameExists(m.cfg.Folders[folderIndex], value) {
+					m.errMsg = "command name already exists"
+					return m, nil
+				}
+				m.pendingCommand.Name = value
+				m.openPrompt(promptEditCommandCommand, m.pendingCommand.Command, "dev command to run")
+				return m, textinput.Blink
+			case promptEditCommandCommand:
+				if value == "" {
+					m.errMsg = "command is required"
+					return m, nil
+				}
+				folderIndex := m.promptFolderIndex
+				if folderIndex < 0 || folderIndex >= len(m.cfg.Folders) {
+					m.errMsg = "select a folder"
+					return m, nil
+				}
+				command := m.pendingCommand
+				command.Command = value
+				name := m.editingName
+				closePrompt()
+				return m, m.updateCommandCmd(folderIndex, name, command)
 			case promptFilter:
 				closePrompt()
 				m.filterQuery = value
@@ -204,6 +307,10 @@ func (m Model) updatePrompt(msg tea.Msg) (tea.Model, tea.Cmd) {
 					clearCmd = m.setStatus("filter set: " + value)
 				}
 				return m, tea.Batch(clearCmd, m.syncSelectionPreview(true, true))
+			case promptLogSearch:
+				closePrompt()
+				m.logSearch = value
+				return m, m.jumpToLogMatch(m.logTop, 1)
 			}
 		}
 	}
@@ -276,6 +383,9 @@ func (m Model) promptTitle() string {
 	case promptRenameSession:
 		return "rename:"
 	case promptRunCommand:
+		if target, ok := m.sendTarget(); ok {
+			return "command → " + target.label + ":"
+		}
 		return "command:"
 	case promptFilter:
 		return "filter:"
@@ -289,6 +399,16 @@ func (m Model) promptTitle() string {
 		return "dev command name:"
 	case promptAddCommandCommand:
 		return "dev command:"
+	case promptAgentWorktreeBranch:
+		return "worktree branch:"
+	case promptLogSearch:
+		return "search log:"
+	case promptEditFolder:
+		return fmt.Sprintf("edit folder (%d/3):", m.promptStep+1)
+	case promptEditCommandName:
+		return "edit command name:"
+	case promptEditCommandCommand:
+		return "edit command:"
 	default:
 		return ""
 	}
@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"reflect"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/configfile"
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+const configWatchInterval = time.Second
+
+type configTickMsg struct{}
+
+// configCheckedMsg reports the current versions of the config file and the
+// project files merged into it. cfg is only set when either changed and the
+// config was loaded again.
+type configCheckedMsg struct {
+	version  configfile.Version
+	projects configfile.Version
+	cfg      config.Config
+	loaded   bool
+	err      error
+}
+
+func configTickCmd() tea.Cmd {
+	return tea.Tick(configWatchInterval, func(time.Time) tea.Msg {
+		return configTickMsg{}
+	})
+}
+
+// checkConfigCmd reloads the config when it, or a folder's project file,
+// changed on disk since the versions the model last saw.
+func (m Model) checkConfigCmd() tea.Cmd {
+	path, known, knownProjects, cfg := m.cfgPath, m.cfgVersion, m.projectsVersion, m.cfg
+	return func() tea.Msg {
+		version, err := configfile.Stat(path)
+		if err != nil {
+			return configCheckedMsg{projects: knownProjects, err: err}
+		}
+		projects := configfile.StatProjects(cfg)
+		if version == known && projects == knownProjects {
+			return configCheckedMsg{version: version, projects: projects}
+		}
+		cfg, loaded, err := configfile.LoadVersion(path)
+		if err != nil {
+			return configCheckedMsg{version: version, projects: projects, err: err}
+		}
+		return configCheckedMsg{version: loaded, projects: configfile.StatProjects(cfg), cfg: cfg, loaded: true}
+	}
+}
+
+func (m Model) handleConfigTick() (tea.Model, tea.Cmd) {
+	// Folder indexes held by an open prompt or overlay would go stale, so
+	// the reload waits until it closes.
+	if m.promptMode != promptNone || m.overlayMode != overlayNone || m.confirmKillTargets != nil || m.confirmRemoval.kind != removalNone || m.confirmRestore != nil {
+		return m, configTickCmd()
+	}
+	return m, m.checkConfigCmd()
+}
+
+func (m Model) handleConfigChecked(msg configCheckedMsg) (tea.Model, tea.Cmd) {
+	m.cfgVersion, m.projectsVersion = msg.version, msg.projects
+	if msg.err != nil {
+		m.cfgErr = msg.err.Error()
+		return m, configTickCmd()
+	}
+	if !msg.loaded {
+		return m, configTickCmd()
+	}
+	m.cfgErr = ""
+	// grove's own saves change the file too; those need no reload.
+	if reflect.DeepEqual(msg.cfg, m.cfg) {
+		return m, configTickCmd()
+	}
+	status := "reloaded config"
+	// The session client is connected to one server for grove's lifetime.
+	switch {
+	case msg.cfg.Backend != m.cfg.Backend:
+		status = "reloaded config; restart grove to switch backend"
+	case msg.cfg.TmuxSocket != m.cfg.TmuxSocket:
+		status = "reloaded config; restart grove to switch tmux_socket"
+	}
+	m.applyConfig(msg.cfg)
+	return m, tea.Batch(configTickCmd(), m.setStatus(status), m.loadSessionsCmd())
+}
+
+// applyConfig swaps in a reloaded config, regrouping the known sessions under
+// the new folders and keeping the selection on the same row.
+func (m *Model) applyConfig(cfg config.Config) {
+	selectedRow, hadSelection := m.selectedRow()
+	if hadSelection && selectedRow.typeOf == rowFolder {
+		selectedRow.folderIndex = folderIndexByNamespace(cfg, m.cfg.Folders[selectedRow.folderIndex].Namespace)
+	}
+
+	sessions := make([]tmux.Session, 0)
+	for _, folderSessions := range m.sessions {
+		sessions = append(sessions, folderSessions...)
+	}
+	m.cfg = cfg
+	if hosts, ok := m.client.(*hostSessions); ok {
+		hosts.setFolders(cfg.Folders)
+	}
+	m.sessions = groupSessions(cfg.Folders, sessions)
+	m.rebuildRows()
+	if !hadSelection {
+		return
+	}
+	if index, ok := findMatchingRowIndex(m.rows, selectedRow); ok {
+		m.setSelected(index)
+	}
+}
+
+func folderIndexByNamespace(cfg config.Config, namespace string) int {
+	for i, folder := range cfg.Folders {
+		if folder.Namespace == namespace {
+			return i
+		}
+	}
+	return -1
+}
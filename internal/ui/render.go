@@ -8,6 +8,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/x/ansi"
 
+	"github.com/SarthakJariwala/grove/internal/config"
 	"github.com/SarthakJariwala/grove/internal/tmux"
 )
 
@@ -104,35 +105,52 @@ func (m Model) renderHeader() string {
 
 func (m Model) renderFooter() string {
 	if m.overlayMode == overlayAgentPicker {
-		return m.styles.promptLabel.Render("agent picker") + m.styles.promptHint.Render("  ↑/↓ select · enter confirm · esc cancel")
+		return m.styles.promptLabel.Render("agent picker") + m.styles.promptHint.Render("  ↑/↓ select · enter confirm · w worktree · D remove · esc cancel")
 	}
 
 	// Prompt mode: show prompt input
 	if m.promptMode != promptNone {
 		label := m.styles.promptLabel.Render(m.promptTitle() + " ")
 		enterHint := "enter confirm"
-		if m.promptMode == promptAddFolder && m.promptStep < 2 {
+		if (m.promptMode == promptAddFolder || m.promptMode == promptEditFolder) && m.promptStep < 2 || m.promptMode == promptEditCommandName {
 			enterHint = "enter next"
 		}
 		extra := ""
-		if m.promptMode == promptAddFolder && m.promptStep == 1 {
+		if (m.promptMode == promptAddFolder || m.promptMode == promptEditFolder) && m.promptStep == 1 {
 			extra = " · tab complete"
 		}
+		if m.promptMode == promptRunCommand && len(m.sendTargetList) > 1 {
+			extra = " · tab change target"
+		}
 		hint := m.styles.promptHint.Render("  " + enterHint + " · esc cancel" + extra)
 		return label + m.prompt.View() + hint
 	}
 
 	// Kill confirmation mode
-	if m.confirmKillTarget != "" {
-		warn := m.styles.footerWarn.Render("kill " + m.confirmKillTarget + "?")
-		hint := m.styles.helpDesc.Render("  y/enter confirm · n cancel")
-		return warn + hint
+	if m.confirmKillTargets != nil {
+		warn := m.styles.footerWarn.Render(m.killConfirmPrompt())
+		hintText := "  y/enter confirm · n cancel"
+		if m.killTargetsHaveWorktrees() {
+			hintText = "  y/enter confirm · w also remove worktree" + pluralSuffix(len(m.confirmKillTargets)) + " · n cancel"
+		}
+		return warn + m.styles.helpDesc.Render(hintText)
+	}
+
+	if m.confirmRemoval.kind != removalNone {
+		return m.styles.footerWarn.Render(m.removalPrompt()) + m.styles.helpDesc.Render("  y/enter confirm · n cancel")
+	}
+
+	if m.confirmRestore != nil {
+		return m.styles.footerWarn.Render(m.restorePrompt()) + m.styles.helpDesc.Render("  y/enter restore · n discard")
 	}
 
 	// Status message takes precedence
 	if m.errMsg != "" {
 		return m.styles.footerErr.Render("error: " + m.errMsg)
 	}
+	if m.cfgErr != "" {
+		return m.styles.footerErr.Render("config error: " + m.cfgErr)
+	}
 	if m.statusMsg != "" {
 		return m.styles.footerOK.Render(m.statusMsg)
 	}
@@ -163,9 +181,36 @@ func (m Model) renderHelpBar() string {
 			{"esc", "back"},
 			{"q", "back"},
 		}
+	} else if m.detailMode == detailLogs {
+		followHint := "follow"
+		if m.logFollow {
+			followHint = "unfollow"
+		}
+		bindings = []binding{
+			{"↑/↓", "scroll"},
+			{"/", "search"},
+			{"n/N", "next/prev"},
+			{"f", followHint},
+			{"g/G", "top/end"},
+			{"esc", "back"},
+		}
+	} else if len(m.marked) > 0 {
+		bindings = []binding{
+			{"space", "mark"},
+			{"ctrl+a", "mark folder"},
+			{"K", "kill"},
+			{"s", "start"},
+			{"x", "stop"},
+			{"R", "restart"},
+			{"c", "send cmd"},
+			{"esc", "clear marks"},
+			{"q", "quit"},
+		}
 	} else if hasSelectedRow && selectedRow.typeOf == rowCommand {
-		bindings = []binding{{"e", "editor"}, {"d", "dev command"}}
-		if selectedRow.status == "running" {
+		bindings = []binding{{"e", "editor"}, {"d", "dev command"}, {"l", "logs"}, {"E", "edit"}, {"D", "remove"}}
+		if m.isStopping(selectedRow.sessionName) {
+			bindings = append(bindings, binding{"⏎", "attach"}, binding{"v", "preview"})
+		} else if selectedRow.status == "running" {
 			bindings = append(bindings,
 				binding{"⏎", "attach"},
 				binding{"v", "preview"},
@@ -177,6 +222,31 @@ func (m Model) renderHelpBar() string {
 				binding{"s", "start"},
 				binding{"R", "restart"},
 			)
+			if selectedRow.status != "stopped" {
+				bindings = append(bindings, binding{"x", "clear"})
+			}
+		}
+		if m.filterQuery != "" {
+			bindings = append(bindings, binding{"esc", "clear filter"})
+		}
+		bindings = append(bindings,
+			binding{"/", "filter"},
+			binding{"r", "refresh"},
+			binding{"q", "quit"},
+		)
+	} else if hasSelectedRow && selectedRow.typeOf == rowTask {
+		bindings = []binding{{"e", "editor"}, {"l", "logs"}}
+		switch selectedRow.status {
+		case "running":
+			bindings = append(bindings,
+				binding{"⏎", "attach"},
+				binding{"v", "preview"},
+				binding{"x", "stop"},
+			)
+		case "idle":
+			bindings = append(bindings, binding{"s", "run"})
+		default:
+			bindings = append(bindings, binding{"s", "run"}, binding{"x", "clear"})
 		}
 		if m.filterQuery != "" {
 			bindings = append(bindings, binding{"esc", "clear filter"})
@@ -196,6 +266,7 @@ func (m Model) renderHelpBar() string {
 			{"d", "dev command"},
 			{"K", "kill"},
 			{"c", "send cmd"},
+			{"space", "mark"},
 			{"A", "add folder"},
 		}
 		if m.filterQuery != "" {
@@ -211,10 +282,18 @@ func (m Model) renderHelpBar() string {
 			{"n", "new terminal"},
 			{"a", "agent"},
 			{"d", "dev command"},
+		}
+		if folder, ok := m.selectedFolder(); ok && len(folder.Commands) > 0 {
+			bindings = append(bindings, binding{"S", "start all"})
+		}
+		bindings = append(bindings, []binding{
 			{"e", "editor"},
 			{"A", "add folder"},
-			{"↑/↓", "navigate"},
+		}...)
+		if hasSelectedRow && selectedRow.typeOf == rowFolder {
+			bindings = append(bindings, binding{"E", "edit"}, binding{"D", "remove"})
 		}
+		bindings = append(bindings, binding{"↑/↓", "navigate"})
 		if m.filterQuery != "" {
 			bindings = append(bindings, binding{"esc", "clear filter"})
 		}
@@ -225,7 +304,18 @@ func (m Model) renderHelpBar() string {
 		}...)
 	}
 
-	parts := make([]string, 0, len(bindings))
+	counter := m.attentionCounter()
+	if counter != "" && m.detailMode == detailNormal {
+		bindings = append([]binding{{"tab", "next waiting"}}, bindings...)
+	}
+
+	parts := make([]string, 0, len(bindings)+2)
+	if counter != "" {
+		parts = append(parts, counter)
+	}
+	if len(m.marked) > 0 && m.detailMode == detailNormal {
+		parts = append(parts, m.styles.selAccent.Render(fmt.Sprintf("%s %d marked", markGlyph, len(m.marked))))
+	}
 	lb := m.styles.helpBracket.Render("[")
 	rb := m.styles.helpBracket.Render("]")
 	for _, b := range bindings {
@@ -254,6 +344,9 @@ func (m Model) renderAgentPickerOverlay() string {
 		if choice.Persist {
 			label += "  " + m.styles.detailMeta.Render("save to folder")
 		}
+		if choice.Agent.Source == config.SourceProject {
+			label += "  " + m.styles.detailMeta.Render(config.ProjectFile)
+		}
 		if choice.IsNew {
 			label = m.styles.infoValue.Render(choice.Label)
 		}
@@ -279,6 +372,9 @@ func (m Model) folderStatus(folderIndex int) folderVisualStatus {
 		if session.HasAlerts || session.AlertsBell || session.AlertsActivity || session.AlertsSilence {
 			return folderStatusAttention
 		}
+		if m.agentStates[session.Name].state == agentNeedsInput {
+			return folderStatusAttention
+		}
 	}
 	return folderStatusActive
 }
@@ -335,7 +431,7 @@ func (m Model) renderTreePane(innerH, maxWidth, paneWidth int, dim bool) string
 	actualEnd := end
 	for i := start; i < end; i++ {
 		row := m.rows[i]
-		isKillTarget := m.confirmKillTarget != "" && row.sessionName == m.confirmKillTarget
+		isKillTarget := m.isKillTarget(row)
 
 		visualLines++
 		if visualLines > effectiveBodyH {
@@ -376,21 +472,44 @@ func (m Model) renderTreePane(innerH, maxWidth, paneWidth int, dim bool) string
 
 const treeChildIndent = "    "
 
+// markGlyph sits in the indent of a marked row.
+const markGlyph = "•"
+
+func (m Model) childIndent(row treeRow) string {
+	if m.marked[row.sessionName] {
+		return "  " + markGlyph + " "
+	}
+	return treeChildIndent
+}
+
+func (m Model) styledChildIndent(row treeRow) string {
+	if m.marked[row.sessionName] {
+		return "  " + m.styles.selAccent.Render(markGlyph) + " "
+	}
+	return treeChildIndent
+}
+
 func commandTreeIcon(row treeRow) string {
-	if row.status == "running" {
+	switch {
+	case row.status == "running":
 		return "▶"
+	case commandFailed(row):
+		return "✗"
+	default:
+		return "■"
 	}
-	return "■"
 }
 
 func sessionIndicatorGlyph(row treeRow) string {
 	switch row.typeOf {
 	case rowAgentInstance:
-		return "◆"
+		return agentTreeIcon(row)
 	case rowTerminalInstance:
 		return terminalTreeIcon(row)
 	case rowCommand:
 		return commandTreeIcon(row)
+	case rowTask:
+		return taskTreeIcon(row)
 	default:
 		return ""
 	}
@@ -399,13 +518,24 @@ func sessionIndicatorGlyph(row treeRow) string {
 func (m Model) sessionIndicatorStyle(row treeRow) lipgloss.Style {
 	switch row.typeOf {
 	case rowAgentInstance:
-		return m.styles.childIconActive
+		return m.agentTreeIconStyle(row)
 	case rowTerminalInstance:
 		return m.terminalTreeIconStyle(row)
 	case rowCommand:
 		if row.status == "running" {
 			return m.styles.childIconActive
 		}
+		if commandFailed(row) {
+			return m.styles.alertIndicator
+		}
+		return m.styles.childIconDim
+	case rowTask:
+		if row.status == "running" {
+			return m.styles.childIconActive
+		}
+		if taskFailed(row) {
+			return m.styles.alertIndicator
+		}
 		return m.styles.childIconDim
 	default:
 		return m.styles.childIconDim
@@ -435,16 +565,93 @@ func (m Model) terminalTreeIconStyle(row treeRow) lipgloss.Style {
 	return m.styles.childIconDim
 }
 
+// agentTreeIcon shows an agent's detected state, so a blocked agent stands
+// out without selecting it.
+func agentTreeIcon(row treeRow) string {
+	switch row.agent.state {
+	case agentNeedsInput:
+		return "▲"
+	case agentIdle:
+		return "◇"
+	case agentFinished:
+		return "✓"
+	default:
+		return "◆"
+	}
+}
+
+func (m Model) agentTreeIconStyle(row treeRow) lipgloss.Style {
+	switch row.agent.state {
+	case agentNeedsInput:
+		return m.styles.alertIndicator
+	case agentIdle, agentFinished:
+		return m.styles.childIconDim
+	default:
+		return m.styles.childIconActive
+	}
+}
+
+// agentTreeBadge names an agent's state and marks agents that run in their
+// own worktree so instances sharing the folder checkout are easy to tell
+// apart.
+func agentTreeBadge(row treeRow) string {
+	label := row.agent.state.String()
+	if label == "" {
+		label = "active"
+	}
+	if row.worktreePath != "" {
+		return "⎇ " + label
+	}
+	return label
+}
+
+func (m Model) agentBadgeStyle(row treeRow) lipgloss.Style {
+	switch row.agent.state {
+	case agentNeedsInput:
+		return m.styles.alertIndicator
+	case agentIdle, agentFinished:
+		return m.styles.commandDim
+	default:
+		return m.styles.badgeActive
+	}
+}
+
+// commandTreeBadge marks commands defined in the folder's .grove.toml rather
+// than the user config.
+func commandTreeBadge(row treeRow) string {
+	if row.fromProject {
+		return "repo"
+	}
+	return ""
+}
+
+func (m Model) taskBadgeStyle(row treeRow) lipgloss.Style {
+	switch {
+	case row.status == "running":
+		return m.styles.badgeActive
+	case taskFailed(row):
+		return m.styles.alertIndicator
+	default:
+		return m.styles.commandDim
+	}
+}
+
 func (m Model) treeLineText(row treeRow, maxWidth int) string {
 	switch row.typeOf {
 	case rowFolder:
-		return truncateRight(fmt.Sprintf("%s ● %s", m.folderCaret(row.folderIndex), row.displayName), maxWidth)
+		line := fmt.Sprintf("%s ● %s", m.folderCaret(row.folderIndex), row.displayName)
+		if host := m.cfg.Folders[row.folderIndex].Host; host != "" {
+			line += " @" + host
+		}
+		return truncateRight(line, maxWidth)
 	case rowAgentInstance:
-		return treeJustify(treeChildIndent+sessionIndicatorGlyph(row)+" "+row.displayName, "active", maxWidth)
+		return treeJustify(m.childIndent(row)+sessionIndicatorGlyph(row)+" "+row.displayName, agentTreeBadge(row), maxWidth)
 	case rowTerminalInstance:
-		return treeJustify(treeChildIndent+sessionIndicatorGlyph(row)+" "+row.displayName, "", maxWidth)
+		return treeJustify(m.childIndent(row)+sessionIndicatorGlyph(row)+" "+row.displayName, "", maxWidth)
 	case rowCommand:
-		return treeJustify(treeChildIndent+sessionIndicatorGlyph(row)+" "+row.displayName, "", maxWidth)
+		return treeJustify(m.childIndent(row)+sessionIndicatorGlyph(row)+" "+row.displayName, commandTreeBadge(row), maxWidth)
+	case rowTask:
+		return treeJustify(m.childIndent(row)+sessionIndicatorGlyph(row)+" "+row.displayName, taskRunLabel(row, time.Now()), maxWidth)
 	default:
 		return ""
 	}
@@ -483,16 +690,21 @@ func (m Model) treeLineStyled(row treeRow, plain string, maxWidth int) string {
 			dot = m.styles.folderDotActive.Render("●")
 		}
 
-		return m.styles.detailMeta.Render(m.folderCaret(row.folderIndex)) + " " + dot + " " + nameStyle.Render(row.displayName)
+		line := m.styles.detailMeta.Render(m.folderCaret(row.folderIndex)) + " " + dot + " " + nameStyle.Render(row.displayName)
+		if host := m.cfg.Folders[row.folderIndex].Host; host != "" {
+			line += m.styles.detailMeta.Render(" @" + host)
+		}
+		return line
 	case rowAgentInstance:
 		name := m.styles.rowSession.Render(row.displayName)
 		if selected, ok := m.selectedRow(); ok && selected.sessionName == row.sessionName {
 			name = m.styles.rowSelectedText.Render(row.displayName)
 		}
-		left := treeChildIndent + m.sessionIndicator(row) + " " + name
-		right := m.styles.badgeActive.Render("active")
-		leftPlain := treeChildIndent + sessionIndicatorGlyph(row) + " " + row.displayName
-		gap := maxWidth - lipgloss.Width(leftPlain) - lipgloss.Width("active")
+		left := m.styledChildIndent(row) + m.sessionIndicator(row) + " " + name
+		badge := agentTreeBadge(row)
+		right := m.agentBadgeStyle(row).Render(badge)
+		leftPlain := m.childIndent(row) + sessionIndicatorGlyph(row) + " " + row.displayName
+		gap := maxWidth - lipgloss.Width(leftPlain) - lipgloss.Width(badge)
 		if gap < 1 {
 			gap = 1
 		}
@@ -502,13 +714,36 @@ func (m Model) treeLineStyled(row treeRow, plain string, maxWidth int) string {
 		if selected, ok := m.selectedRow(); ok && selected.sessionName == row.sessionName {
 			name = m.styles.rowSelectedText.Render(row.displayName)
 		}
-		return treeChildIndent + m.sessionIndicator(row) + " " + name
+		return m.styledChildIndent(row) + m.sessionIndicator(row) + " " + name
 	case rowCommand:
 		name := m.styles.rowSession.Render(row.displayName)
 		if selected, ok := m.selectedRow(); ok && selected.sessionName == row.sessionName {
 			name = m.styles.rowSelectedText.Render(row.displayName)
 		}
-		return treeChildIndent + m.sessionIndicator(row) + " " + name
+		left := m.styledChildIndent(row) + m.sessionIndicator(row) + " " + name
+		badge := commandTreeBadge(row)
+		if badge == "" {
+			return left
+		}
+		leftPlain := m.childIndent(row) + sessionIndicatorGlyph(row) + " " + row.displayName
+		gap := maxWidth - lipgloss.Width(leftPlain) - lipgloss.Width(badge)
+		if gap < 1 {
+			gap = 1
+		}
+		return left + strings.Repeat(" ", gap) + m.styles.commandDim.Render(badge)
+	case rowTask:
+		name := m.styles.rowSession.Render(row.displayName)
+		if selected, ok := m.selectedRow(); ok && selected.sessionName == row.sessionName {
+			name = m.styles.rowSelectedText.Render(row.displayName)
+		}
+		left := m.styledChildIndent(row) + m.sessionIndicator(row) + " " + name
+		badge := taskRunLabel(row, time.Now())
+		leftPlain := m.childIndent(row) + sessionIndicatorGlyph(row) + " " + row.displayName
+		gap := maxWidth - lipgloss.Width(leftPlain) - lipgloss.Width(badge)
+		if gap < 1 {
+			gap = 1
+		}
+		return left + strings.Repeat(" ", gap) + m.taskBadgeStyle(row).Render(badge)
 	default:
 		return plain
 	}
@@ -543,6 +778,9 @@ func (m Model) renderDetailPane(innerH, maxWidth, paneWidth int, dim bool) strin
 
 	row := m.rows[m.selected]
 
+	if m.detailMode == detailLogs {
+		return m.renderLogPane(innerH, maxWidth, paneWidth, dim)
+	}
 	if m.detailMode == detailPreview || m.shouldAutoPreview() {
 		return m.renderPreviewPane(innerH, maxWidth, paneWidth, dim)
 	}
@@ -556,6 +794,8 @@ func (m Model) detailLinesForRow(row treeRow, maxWidth int) []string {
 		return m.folderDetailLines(row, maxWidth)
 	case rowCommand:
 		return m.commandDetailLines(row, maxWidth)
+	case rowTask:
+		return m.taskDetailLines(row, maxWidth)
 	case rowAgentInstance, rowTerminalInstance:
 		return m.instanceDetailLines(row, maxWidth)
 	default:
@@ -581,6 +821,11 @@ func (m Model) folderDetailLines(row treeRow, maxWidth int) []string {
 		}
 	}
 
+	location := folder.Path
+	if folder.Host != "" {
+		location = folder.Host + ":" + folder.Path
+	}
+
 	const lw = 13
 	lines := []string{
 		m.styles.detailName.Render(folder.Name),
@@ -588,7 +833,7 @@ func (m Model) folderDetailLines(row treeRow, maxWidth int) []string {
 		m.dividerLine(maxWidth),
 		"",
 		m.styles.detailSectionHeader.Render("PATH"),
-		m.styles.infoValue.Render(truncateMiddle(folder.Path, maxWidth)),
+		m.styles.infoValue.Render(truncateMiddle(location, maxWidth)),
 		"",
 		m.dividerLine(maxWidth),
 		"",
@@ -605,9 +850,22 @@ func (m Model) folderDetailLines(row treeRow, maxWidth int) []string {
 	} else {
 		lines = append(lines, m.kvPad("Commands", lw, m.styles.infoValue.Render("0 configured")))
 	}
+	if len(folder.Tasks) > 0 {
+		taskSummary := fmt.Sprintf("%d configured", len(folder.Tasks))
+		running := 0
+		for _, task := range folder.Tasks {
+			if m.taskRunning(row.folderIndex, folder, task) {
+				running++
+			}
+		}
+		if running > 0 {
+			taskSummary += fmt.Sprintf(", %d running", running)
+		}
+		lines = append(lines, m.kvPad("Tasks", lw, m.styles.infoValue.Render(taskSummary)))
+	}
 
 	// SESSIONS mini-table: list all sessions in this folder
-	agentRows := buildAgentRows(row.folderIndex, folder, sessions)
+	agentRows := m.withAgentStates(buildAgentRows(row.folderIndex, folder, sessions))
 	termRows := buildTerminalRows(row.folderIndex, folder, sessions)
 	cmdRows := buildCommandRows(row.folderIndex, folder, sessionByName)
 	allRows := make([]treeRow, 0, len(agentRows)+len(termRows)+len(cmdRows))
@@ -628,9 +886,21 @@ func (m Model) folderDetailLines(row treeRow, maxWidth int) []string {
 
 func (m Model) commandDetailLines(row treeRow, maxWidth int) []string {
 	const lw = 13
+	restart := m.restarts[row.sessionName]
 	statusText := m.styles.chipMuted.Render("stopped")
-	if row.status == "running" {
+	switch {
+	case m.isStopping(row.sessionName):
+		statusText = m.styles.chipWarn.Render("stopping… · kill in " + formatRestartDelay(time.Until(m.stopping[row.sessionName])))
+	case row.status == "running":
 		statusText = m.styles.chipPrimary.Render("running")
+	case !restart.nextRestart.IsZero():
+		statusText = m.styles.chipWarn.Render("restarting in " + formatRestartDelay(time.Until(restart.nextRestart)))
+	case restart.gaveUp:
+		statusText = m.styles.chipWarn.Render(commandStatusLabel(row) + " · gave up restarting")
+	case commandFailed(row):
+		statusText = m.styles.chipWarn.Render(commandStatusLabel(row))
+	case row.status == "exited":
+		statusText = m.styles.chipMuted.Render(commandStatusLabel(row))
 	}
 
 	lines := []string{
@@ -641,6 +911,19 @@ func (m Model) commandDetailLines(row treeRow, maxWidth int) []string {
 		m.styles.detailSectionHeader.Render("COMMAND"),
 		m.kvPad("Command", lw, m.styles.infoValue.Render(truncateRight(row.commandText, maxWidth-lw))),
 		m.kvPad("Session", lw, m.styles.detailMeta.Render(truncateRight(row.sessionName, maxWidth-lw))),
+		m.kvPad("Defined in", lw, m.styles.detailMeta.Render(commandSourceLabel(row))),
+	}
+	if command, ok := commandForRow(m.cfg.Folders[row.folderIndex], row); ok && command.Restart != "" && command.Restart != config.RestartNo {
+		policy := command.Restart
+		if command.MaxRetries > 0 {
+			policy += fmt.Sprintf(" (max %d)", command.MaxRetries)
+		}
+		lines = append(lines, "", m.dividerLine(maxWidth), "", m.styles.detailSectionHeader.Render("RESTART"))
+		lines = append(lines, m.kvPad("Policy", lw, m.styles.infoValue.Render(policy)))
+		lines = append(lines, m.kvPad("Restarts", lw, m.styles.infoValue.Render(fmt.Sprintf("%d", restart.restarts))))
+		if !restart.lastExit.IsZero() {
+			lines = append(lines, m.kvPad("Last exit", lw, m.styles.infoValue.Render(formatDuration(time.Since(restart.lastExit)))))
+		}
 	}
 
 	if row.status != "running" {
@@ -652,6 +935,63 @@ func (m Model) commandDetailLines(row treeRow, maxWidth int) []string {
 	return lines
 }
 
+// taskDetailLines shows a task's schedule and its last run, which stays on
+// record after the session is cleared.
+func (m Model) taskDetailLines(row treeRow, maxWidth int) []string {
+	const lw = 13
+	now := time.Now()
+	statusText := m.styles.chipMuted.Render(taskRunLabel(row, now))
+	switch {
+	case row.status == "running":
+		statusText = m.styles.chipPrimary.Render(taskRunLabel(row, now))
+	case taskFailed(row):
+		statusText = m.styles.chipWarn.Render(taskRunLabel(row, now))
+	}
+
+	task, _ := taskForRow(m.cfg.Folders[row.folderIndex], row)
+	schedule := "manual"
+	switch {
+	case task.Schedule != "":
+		schedule = task.Schedule
+	case task.IgnoredSchedule != "":
+		schedule = task.IgnoredSchedule + " (not trusted)"
+	}
+	lines := []string{
+		m.styles.detailName.Render(truncateRight(row.displayName, maxWidth)),
+		statusText,
+		m.dividerLine(maxWidth),
+		"",
+		m.styles.detailSectionHeader.Render("TASK"),
+		m.kvPad("Command", lw, m.styles.infoValue.Render(truncateRight(row.commandText, maxWidth-lw))),
+		m.kvPad("Schedule", lw, m.styles.infoValue.Render(truncateRight(schedule, maxWidth-lw))),
+	}
+	if next := task.NextRun(now); !next.IsZero() {
+		lines = append(lines, m.kvPad("Next run", lw, m.styles.infoValue.Render(next.Format("Mon Jan 2 15:04"))))
+	}
+	lines = append(lines,
+		m.kvPad("Session", lw, m.styles.detailMeta.Render(truncateRight(row.sessionName, maxWidth-lw))),
+		m.kvPad("Defined in", lw, m.styles.detailMeta.Render(commandSourceLabel(row))),
+	)
+
+	if run := row.lastRun; !run.Started.IsZero() {
+		result := "running"
+		switch {
+		case run.Running():
+		case run.Stopped:
+			result = "stopped"
+		case run.ExitSignal != 0:
+			result = fmt.Sprintf("signal %d", run.ExitSignal)
+		default:
+			result = fmt.Sprintf("code %d", run.ExitStatus)
+		}
+		lines = append(lines, "", m.dividerLine(maxWidth), "", m.styles.detailSectionHeader.Render("LAST RUN"))
+		lines = append(lines, m.kvPad("Started", lw, m.styles.infoValue.Render(formatDuration(now.Sub(run.Started)))))
+		lines = append(lines, m.kvPad("Duration", lw, m.styles.infoValue.Render(formatRestartDelay(run.Duration(now)))))
+		lines = append(lines, m.kvPad("Exit", lw, m.styles.infoValue.Render(result)))
+	}
+	return lines
+}
+
 func (m Model) instanceDetailLines(row treeRow, maxWidth int) []string {
 	metaText := m.sessionIndicator(row)
 	windowLabel := "windows"
@@ -670,6 +1010,10 @@ func (m Model) instanceDetailLines(row treeRow, maxWidth int) []string {
 		m.styles.detailSectionHeader.Render("STATUS"),
 	}
 	lines = append(lines, m.instanceDetailBodyLines(row, maxWidth)...)
+	if row.worktreePath != "" {
+		lines = append(lines, "", m.dividerLine(maxWidth), "", m.styles.detailSectionHeader.Render("WORKTREE"))
+		lines = append(lines, m.styles.infoValue.Render(truncateMiddle(row.worktreePath, maxWidth)))
+	}
 	return lines
 }
 
@@ -677,6 +1021,17 @@ func (m Model) instanceDetailBodyLines(row treeRow, maxWidth int) []string {
 	const lw = 13
 	lines := make([]string, 0, 8)
 
+	if row.agent.state != agentUnknown {
+		state := m.styles.infoValue.Render(row.agent.state.String())
+		if row.agent.state == agentNeedsInput {
+			state = m.styles.chipWarn.Render(row.agent.state.String())
+		}
+		if row.agent.reason != "" {
+			state += m.styles.detailMeta.Render(" · " + truncateRight(row.agent.reason, maxWidth-lw-lipgloss.Width(row.agent.state.String())-3))
+		}
+		lines = append(lines, m.kvPad("State", lw, state))
+	}
+
 	running := strings.TrimSpace(row.currentCommand)
 	if running == "" || isShellCommand(running) {
 		lines = append(lines, m.kvPad("Running", lw, m.styles.detailMeta.Render("shell idle")))
@@ -823,13 +1178,28 @@ func (m Model) renderPreviewPane(innerH, maxWidth, paneWidth int, dim bool) stri
 		return m.styledPane(padded, paneWidth, innerH, dim)
 	}
 
+	header := []string{title}
+	if row, ok := m.rowBySessionName(sessionName); ok {
+		if row.worktreePath != "" {
+			header = append(header, m.styles.detailMeta.Render("⎇ "+truncateMiddle(row.worktreePath, maxWidth-2)))
+		}
+		if restart := m.restarts[sessionName]; restart.restarts > 0 {
+			meta := fmt.Sprintf("↻ restarted %d×", restart.restarts)
+			if !restart.lastExit.IsZero() {
+				meta += " · last exit " + formatDuration(time.Since(restart.lastExit))
+			}
+			header = append(header, m.styles.detailMeta.Render(truncateRight(meta, maxWidth)))
+		}
+	}
+	header = append(header, "")
+
 	content := sanitizeANSI(m.previewContent)
 	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
 	lines = truncateLines(lines, maxWidth)
-	if maxLines := innerH - 2; len(lines) > maxLines {
+	if maxLines := innerH - len(header); len(lines) > maxLines {
 		lines = lines[len(lines)-maxLines:]
 	}
-	contentLines := append([]string{title, ""}, lines...)
+	contentLines := append(header, lines...)
 	return m.renderDetailLines(contentLines, innerH, paneWidth, dim)
 }
 
@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/stops"
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// commandRestartState tracks automatic restarts of one managed command,
+// keyed by its session name.
+type commandRestartState struct {
+	restarts    int
+	lastExit    time.Time
+	nextRestart time.Time
+	gaveUp      bool
+	// stopped is set when the command is stopped from grove or the grove
+	// CLI, so the exit is not treated as a crash.
+	stopped bool
+	seq     int
+}
+
+type commandRestartMsg struct {
+	sessionName string
+	seq         int
+}
+
+// superviseCommands compares the previous session snapshot with the current
+// one and schedules relaunches for commands whose restart policy asks for it.
+// Commands stopped on purpose, here or by another grove, are left stopped.
+func (m *Model) superviseCommands(prev map[int][]tmux.Session) tea.Cmd {
+	now := time.Now()
+	cmds := make([]tea.Cmd, 0)
+	var stopped map[string]int64
+	for folderIndex, folder := range m.cfg.Folders {
+		for _, command := range folder.Commands {
+			if command.Restart == "" || command.Restart == config.RestartNo {
+				continue
+			}
+			name := commandSessionName(folder, command.Name)
+			if !sessionRunningIn(prev[folderIndex], name) || sessionRunningIn(m.sessions[folderIndex], name) {
+				continue
+			}
+			session, exists := findSession(m.sessions[folderIndex], name)
+
+			state := m.restarts[name]
+			if state.stopped || m.isStopping(name) {
+				continue
+			}
+			if stopped == nil {
+				stopped, _ = stops.Load(m.stopsPath)
+			}
+			if _, ok := stopped[name]; ok {
+				state.stopped = true
+				m.restarts[name] = state
+				continue
+			}
+			state.lastExit = now
+			// A session that vanished was killed outside grove, which counts
+			// as a failure just like a non-zero exit or a signal.
+			failed := !exists || session.ExitSignal != 0 || session.ExitStatus != 0
+			if !command.ShouldRestart(state.restarts, failed) {
+				if failed || command.Restart == config.RestartAlways {
+					state.gaveUp = true
+					m.errMsg = fmt.Sprintf("%s exited; gave up after %d restart%s", command.Name, state.restarts, pluralSuffix(state.restarts))
+				}
+				m.restarts[name] = state
+				continue
+			}
+
+			delay := command.RestartDelay(state.restarts)
+			state.seq++
+			state.nextRestart = now.Add(delay)
+			m.restarts[name] = state
+			cmds = append(cmds, restartAfterCmd(name, state.seq, delay))
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+func restartAfterCmd(name string, seq int, delay time.Duration) tea.Cmd {
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return commandRestartMsg{sessionName: name, seq: seq}
+	})
+}
+
+// relaunchCommand handles a due restart. Stale ticks, commands stopped in the
+// meantime and commands that are already running again are ignored.
+func (m *Model) relaunchCommand(msg commandRestartMsg) tea.Cmd {
+	state, ok := m.restarts[msg.sessionName]
+	if !ok || state.seq != msg.seq || state.stopped {
+		return nil
+	}
+	state.nextRestart = time.Time{}
+
+	for folderIndex, folder := range m.cfg.Folders {
+		for _, command := range folder.Commands {
+			if commandSessionName(folder, command.Name) != msg.sessionName {
+				continue
+			}
+			if m.commandRunning(folderIndex, folder, command) {
+				m.restarts[msg.sessionName] = state
+				return nil
+			}
+			state.restarts++
+			m.restarts[msg.sessionName] = state
+			status := fmt.Sprintf("restarted %s (%d)", command.Name, state.restarts)
+			return m.startCommandsCmd(folder, []config.Command{command}, status)
+		}
+	}
+
+	// The command was removed from the config.
+	delete(m.restarts, msg.sessionName)
+	return nil
+}
+
+// resetRestarts clears restart tracking for commands started by hand.
+func (m *Model) resetRestarts(folder config.Folder, commands []config.Command) {
+	for _, command := range commands {
+		delete(m.restarts, commandSessionName(folder, command.Name))
+	}
+}
+
+// markCommandStopped keeps a command stopped from grove from being restarted
+// and cancels any pending restart.
+func (m *Model) markCommandStopped(sessionName string) {
+	state := m.restarts[sessionName]
+	state.stopped = true
+	state.nextRestart = time.Time{}
+	state.seq++
+	m.restarts[sessionName] = state
+}
+
+func formatRestartDelay(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return d.Round(time.Second).String()
+}
+
+func sessionRunningIn(sessions []tmux.Session, name string) bool {
+	session, ok := findSession(sessions, name)
+	return ok && commandSessionRunning(session)
+}
+
+func findSession(sessions []tmux.Session, name string) (tmux.Session, bool) {
+	for _, session := range sessions {
+		if session.Name == name {
+			return session, true
+		}
+	}
+	return tmux.Session{}, false
+}
@@ -0,0 +1,335 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/manifest"
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// The running managed sessions are written to a manifest as they change.
+// When grove starts and some of them are gone, as after a reboot, it offers
+// to recreate them under their old names. Sessions grove kills or stops on
+// purpose are dropped from the manifest as that happens, so only sessions
+// that vanish on their own are offered.
+
+type manifestLoadedMsg struct {
+	manifest manifest.Manifest
+	err      error
+}
+
+type manifestSavedMsg struct {
+	err error
+}
+
+// restoreItem is a session from the manifest that is not running.
+type restoreItem struct {
+	folderIndex int
+	session     string
+	entry       manifest.Session
+}
+
+func loadManifestCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		saved, err := manifest.Load(path)
+		return manifestLoadedMsg{manifest: saved, err: err}
+	}
+}
+
+func writeManifestCmd(path string, saved manifest.Manifest) tea.Cmd {
+	return func() tea.Msg {
+		return manifestSavedMsg{err: manifest.Save(path, saved)}
+	}
+}
+
+func (m Model) handleManifestLoaded(msg manifestLoadedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.errMsg = msg.err.Error()
+	}
+	m.manifest = msg.manifest
+	m.manifestLoaded = true
+	// The restore offer is made against a snapshot taken after loading.
+	return m, m.loadSessionsCmd()
+}
+
+// trackSessions follows a new snapshot: the first one after the manifest is
+// loaded decides whether to offer a restore, and later ones are saved. An
+// incomplete snapshot is skipped, as it would drop the sessions of hosts
+// that did not answer; a complete one with no sessions means they were all
+// ended, and is saved like any other.
+func (m *Model) trackSessions(complete bool) tea.Cmd {
+	if !m.manifestLoaded || !complete || m.confirmRestore != nil {
+		return nil
+	}
+	if !m.restoreChecked {
+		m.restoreChecked = true
+		if missing := m.missingSessions(m.manifest); len(missing) > 0 {
+			m.confirmRestore = missing
+			m.statusMsg = ""
+			m.errMsg = ""
+			return nil
+		}
+	}
+	current := m.sessionManifest()
+	if reflect.DeepEqual(current, m.manifest) {
+		return nil
+	}
+	m.manifest = current
+	return writeManifestCmd(m.manifestPath, current)
+}
+
+// forgetSessions drops sessions grove is about to kill or stop from the
+// manifest it last saved, so losing them does not count as the server dying.
+func (m *Model) forgetSessions(names []string) {
+	if m.manifestLoaded {
+		m.manifest = m.withoutSessions(m.manifest, names)
+	}
+}
+
+// forgetSavedSessions drops the sessions from the manifest file, for the
+// next start and for any other grove. Failing to do so only leaves them to
+// be offered for restore.
+func (m Model) forgetSavedSessions(names []string) {
+	saved, err := manifest.Load(m.manifestPath)
+	if err != nil {
+		return
+	}
+	if kept := m.withoutSessions(saved, names); kept.Len() != saved.Len() {
+		_ = manifest.Save(m.manifestPath, kept)
+	}
+}
+
+// withoutSessions returns saved without the entries of the named sessions.
+// Entries of folders that are no longer configured are kept.
+func (m Model) withoutSessions(saved manifest.Manifest, names []string) manifest.Manifest {
+	drop := make(map[string]bool, len(names))
+	for _, name := range names {
+		drop[name] = true
+	}
+	kept := manifest.Manifest{Folders: map[string][]manifest.Session{}}
+	for namespace, entries := range saved.Folders {
+		folderIndex := folderIndexByNamespace(m.cfg, namespace)
+		for _, entry := range entries {
+			if folderIndex >= 0 && drop[restoredSessionName(m.cfg.Folders[folderIndex], entry)] {
+				continue
+			}
+			kept.Folders[namespace] = append(kept.Folders[namespace], entry)
+		}
+	}
+	return kept
+}
+
+// sessionManifest lists the managed sessions that are running. Sessions
+// whose process has exited are left out. Agents and commands are recorded
+// in the directory they were started in, as a cd inside them says nothing
+// about where they belong; terminals keep the directory they were left in
+// while it still exists.
+func (m Model) sessionManifest() manifest.Manifest {
+	saved := manifest.Manifest{Folders: map[string][]manifest.Session{}}
+	for folderIndex, folder := range m.cfg.Folders {
+		for _, session := range m.sessions[folderIndex] {
+			id, ok := parseManagedSession(folder.Namespace, session.Name)
+			// Tasks run to completion, so they are not restarted.
+			if !ok || session.Dead || id.kind == managedTask {
+				continue
+			}
+			entry := manifest.Session{Dir: folder.Path}
+			switch id.kind {
+			case managedAgent:
+				entry.Kind, entry.Name, entry.Index = manifest.KindAgent, id.slug, id.index
+				if session.Worktree != "" {
+					entry.Dir, entry.Worktree = session.Worktree, session.Worktree
+				}
+			case managedTerminal:
+				entry.Kind, entry.Index = manifest.KindTerminal, id.index
+				if terminalDirExists(folder, session.CurrentPath) {
+					entry.Dir = session.CurrentPath
+				}
+			case managedCommand:
+				entry.Kind, entry.Name = manifest.KindCommand, id.slug
+			}
+			saved.Folders[folder.Namespace] = append(saved.Folders[folder.Namespace], entry)
+		}
+	}
+	return saved
+}
+
+// terminalDirExists reports whether a terminal's directory is still there.
+// Directories on remote hosts cannot be checked and are taken as they are.
+func terminalDirExists(folder config.Folder, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	if folder.Host != "" {
+		return true
+	}
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
+
+// missingSessions lists the sessions in saved that are not running, in
+// config order. A folder's commands come after its other sessions, in the
+// order their dependencies start.
+func (m Model) missingSessions(saved manifest.Manifest) []restoreItem {
+	var items []restoreItem
+	for folderIndex, folder := range m.cfg.Folders {
+		var commands []restoreItem
+		for _, entry := range saved.Folders[folder.Namespace] {
+			name := restoredSessionName(folder, entry)
+			if name == "" || m.sessionExists(name) {
+				continue
+			}
+			item := restoreItem{folderIndex: folderIndex, session: name, entry: entry}
+			if entry.Kind == manifest.KindCommand {
+				commands = append(commands, item)
+			} else {
+				items = append(items, item)
+			}
+		}
+		order, err := config.FolderStartOrder(folder)
+		if err != nil {
+			items = append(items, commands...)
+			continue
+		}
+		for _, command := range order {
+			for _, item := range commands {
+				if item.session == commandSessionName(folder, command.Name) {
+					items = append(items, item)
+				}
+			}
+		}
+	}
+	return items
+}
+
+func restoredSessionName(folder config.Folder, entry manifest.Session) string {
+	switch entry.Kind {
+	case manifest.KindAgent:
+		return agentSessionName(folder, entry.Name, entry.Index)
+	case manifest.KindTerminal:
+		return terminalSessionName(folder, entry.Index)
+	case manifest.KindCommand:
+		return commandSessionName(folder, entry.Name)
+	default:
+		return ""
+	}
+}
+
+// restoreSession recreates a session from the manifest. Agents run their
+// resume_command when they have one, and commands start as configured.
+func (m Model) restoreSession(item restoreItem) error {
+	folder := m.cfg.Folders[item.folderIndex]
+	dir := item.entry.Dir
+	if item.entry.Worktree != "" {
+		dir = item.entry.Worktree
+	}
+	if folder.Host == "" {
+		if _, err := os.Stat(dir); err != nil {
+			if item.entry.Worktree != "" {
+				return fmt.Errorf("restore %s: worktree %s is gone", item.session, item.entry.Worktree)
+			}
+			dir = folder.Path
+		}
+	}
+
+	switch item.entry.Kind {
+	case manifest.KindAgent:
+		agent, ok := agentForSession(m.cfg, folder, item.entry.Name)
+		if !ok {
+			return fmt.Errorf("restore %s: agent %s is no longer configured", item.session, item.entry.Name)
+		}
+		env, err := folder.AgentEnv(agent)
+		if err != nil {
+			return err
+		}
+		command := agent.Command
+		if agent.ResumeCommand != "" {
+			command = agent.ResumeCommand
+		}
+		if err := m.client.NewSessionWithCommand(item.session, dir, command, env); err != nil {
+			return err
+		}
+		if item.entry.Worktree != "" {
+			return m.client.SetSessionOption(item.session, tmux.WorktreeOption, item.entry.Worktree)
+		}
+		return nil
+	case manifest.KindTerminal:
+		env, err := folder.TerminalEnv()
+		if err != nil {
+			return err
+		}
+		return m.client.NewSession(item.session, dir, env)
+	default:
+		command, ok := restoredCommand(folder, item)
+		if !ok {
+			return fmt.Errorf("restore %s: command %s is no longer configured", item.session, item.entry.Name)
+		}
+		return m.launchCommand(folder, command)
+	}
+}
+
+func restoredCommand(folder config.Folder, item restoreItem) (config.Command, bool) {
+	for _, command := range folder.Commands {
+		if commandSessionName(folder, command.Name) == item.session {
+			return command, true
+		}
+	}
+	return config.Command{}, false
+}
+
+// restoreSessionsCmd recreates the sessions, carrying on past those that
+// fail.
+func (m *Model) restoreSessionsCmd(items []restoreItem) tea.Cmd {
+	for _, item := range items {
+		folder := m.cfg.Folders[item.folderIndex]
+		if command, ok := restoredCommand(folder, item); ok {
+			m.resetRestarts(folder, []config.Command{command})
+		}
+	}
+	model := *m
+	return func() tea.Msg {
+		var failed []string
+		for _, item := range items {
+			if err := model.restoreSession(item); err != nil {
+				failed = append(failed, err.Error())
+			}
+		}
+		if len(failed) > 0 {
+			return actionResultMsg{err: fmt.Errorf("restored %d of %d sessions: %s", len(items)-len(failed), len(items), strings.Join(failed, "; "))}
+		}
+		return actionResultMsg{status: fmt.Sprintf("restored %d session%s", len(items), pluralSuffix(len(items)))}
+	}
+}
+
+func (m Model) restorePrompt() string {
+	n := len(m.confirmRestore)
+	return fmt.Sprintf("restore %d session%s from last time?", n, pluralSuffix(n))
+}
+
+func (m Model) updateRestoreConfirm(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch strings.ToLower(key.String()) {
+	case "y", "enter":
+		items := m.confirmRestore
+		m.confirmRestore = nil
+		return m, m.restoreSessionsCmd(items)
+	case "n", "esc":
+		m.confirmRestore = nil
+		m.manifest = m.sessionManifest()
+		return m, tea.Batch(writeManifestCmd(m.manifestPath, m.manifest), m.setStatus("restore skipped"))
+	case "ctrl+c":
+		return m, tea.Quit
+	default:
+		return m, nil
+	}
+}
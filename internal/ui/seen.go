@@ -0,0 +1,165 @@
+package ui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/SarthakJariwala/grove/internal/seen"
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// Sessions are marked seen when their pane is shown in the details pane or
+// they are attached to. Alerts count as unread only while the session has
+// printed something since.
+
+type seenLoadedMsg struct {
+	times map[string]int64
+	err   error
+}
+
+// seenSavedMsg carries the times that were saved, including those another
+// grove process recorded, such as grove attach.
+type seenSavedMsg struct {
+	times map[string]int64
+	err   error
+}
+
+func loadSeenCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		times, err := seen.Load(path)
+		return seenLoadedMsg{times: times, err: err}
+	}
+}
+
+// saveSeenCmd persists the last-seen times. Nothing is written before the
+// saved times are loaded, so they are never overwritten by a partial set, and
+// each save merges with the file so a newer time written by another grove
+// process is kept.
+func (m *Model) saveSeenCmd() tea.Cmd {
+	if !m.seenLoaded || !m.seenDirty {
+		return nil
+	}
+	m.seenDirty = false
+	path := m.seenPath
+	times := make(map[string]int64, len(m.seen))
+	for name, at := range m.seen {
+		times[name] = at
+	}
+	return func() tea.Msg {
+		onDisk, err := seen.Load(path)
+		if err != nil {
+			return seenSavedMsg{err: err}
+		}
+		mergeSeen(times, onDisk)
+		return seenSavedMsg{times: times, err: seen.Save(path, times, time.Now())}
+	}
+}
+
+// mergeSeen keeps the later time of each session in into.
+func mergeSeen(into, from map[string]int64) {
+	for name, at := range from {
+		if at > into[name] {
+			into[name] = at
+		}
+	}
+}
+
+func (m Model) handleSeenLoaded(msg seenLoadedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.errMsg = msg.err.Error()
+	}
+	// Sessions seen while the file was loading keep their newer times.
+	mergeSeen(m.seen, msg.times)
+	m.seenLoaded = true
+	m.acknowledgeSessions(m.sessions)
+	m.rebuildRows()
+	// Agents are judged again now that their bells can be acknowledged.
+	return m, m.loadSessionsCmd()
+}
+
+// lastOutput is when the session last printed, falling back to its last
+// activity where output is not tracked.
+func lastOutput(session tmux.Session) int64 {
+	if session.LastOutput != 0 {
+		return session.LastOutput
+	}
+	return session.LastActivity
+}
+
+// unread reports whether the session has printed since it was last seen.
+func (m Model) unread(session tmux.Session) bool {
+	at, ok := m.seen[session.Name]
+	return !ok || lastOutput(session) > at
+}
+
+// acknowledgeSessions clears the alert flags of sessions with nothing new
+// since they were seen.
+func (m Model) acknowledgeSessions(sessions map[int][]tmux.Session) {
+	for _, folderSessions := range sessions {
+		for i := range folderSessions {
+			if !m.unread(folderSessions[i]) {
+				clearAlerts(&folderSessions[i])
+			}
+		}
+	}
+}
+
+func clearAlerts(session *tmux.Session) {
+	session.HasAlerts = false
+	session.AlertsBell = false
+	session.AlertsActivity = false
+	session.AlertsSilence = false
+}
+
+// markSeen records that the user has just looked at a session and clears its
+// alerts in place, leaving the selection and scroll position alone.
+func (m *Model) markSeen(name string) {
+	if name == "" {
+		return
+	}
+	m.seen[name] = time.Now().Unix()
+	m.seenDirty = true
+	for _, folderSessions := range m.sessions {
+		for i := range folderSessions {
+			if folderSessions[i].Name == name {
+				clearAlerts(&folderSessions[i])
+			}
+		}
+	}
+	for i := range m.rows {
+		if m.rows[i].sessionName == name {
+			m.rows[i].hasAlerts = false
+			m.rows[i].alertsBell = false
+			m.rows[i].alertsActivity = false
+			m.rows[i].alertsSilence = false
+		}
+	}
+}
+
+// attachCmd hands the terminal to a session, which counts as seeing it both
+// before and after.
+func (m *Model) attachCmd(name string) tea.Cmd {
+	m.markSeen(name)
+	return tea.ExecProcess(m.client.AttachCommand(name), func(err error) tea.Msg {
+		return attachedMsg{session: name, err: err}
+	})
+}
+
+// attachStatus says which session the terminal went to and how the backend
+// gives it back.
+func (m Model) attachStatus(name string) string {
+	return "attached to " + name + " (detach with " + m.client.DetachKeys(name) + ")"
+}
+
+// markSeenFile records in the saved last-seen times that a session was just
+// attached to. Failing to do so only leaves its old alerts unread.
+func markSeenFile(path, name string) {
+	times, err := seen.Load(path)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	times[name] = now.Unix()
+	_ = seen.Save(path, times, now)
+}
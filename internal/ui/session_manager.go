@@ -6,13 +6,28 @@ import (
 	"github.com/SarthakJariwala/grove/internal/tmux"
 )
 
-type sessionManager interface {
+// SessionManager is what grove needs from a session backend: tmux.Client, or
+// pty.Client for the native PTY daemon.
+type SessionManager interface {
 	LoadSnapshot() (tmux.SessionSnapshot, error)
-	NewSession(name, cwd string) error
-	NewSessionWithCommand(name, cwd, command string) error
+	NewSession(name, cwd string, env []string) error
+	NewSessionWithCommand(name, cwd, command string, env []string) error
+	NewCommandSession(name, cwd, command string, env []string, logPath string) error
 	SendKeys(target, command string) error
+	PressKeys(target string, keys []string) error
+	SetSessionOption(target, option, value string) error
 	RenameSession(oldName, newName string) error
 	KillSession(name string) error
 	CapturePane(target string) (string, error)
+	PeekPane(target string) (string, error)
 	AttachCommand(name string) *exec.Cmd
+	DetachKeys(name string) string
+}
+
+type worktreeManager interface {
+	IsRepo(dir string) bool
+	Add(repo, path, branch string) error
+	Remove(repo, path string) error
+	BranchExists(repo, branch string) bool
+	DeleteBranch(repo, branch string) error
 }
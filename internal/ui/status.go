@@ -0,0 +1,182 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+)
+
+// Status is the machine-readable form of the tree grove shows: every folder
+// with its agent, terminal, command and task sections.
+type Status struct {
+	Folders []FolderStatus `json:"folders"`
+}
+
+type FolderStatus struct {
+	Name      string          `json:"name"`
+	Namespace string          `json:"namespace"`
+	Path      string          `json:"path"`
+	Agents    []SessionStatus `json:"agents"`
+	Terminals []SessionStatus `json:"terminals"`
+	Commands  []SessionStatus `json:"commands"`
+	Tasks     []SessionStatus `json:"tasks"`
+}
+
+// SessionStatus describes one tree row. Status is "attached" or "detached" for
+// agents and terminals, and "running", "stopped", "exited" or "crashed" for
+// commands, whose Source is "config" or "project". A task is "idle" until it
+// first runs, and then reports its session like a command. LastActivity is
+// in Unix seconds. State is an agent's detected state: "working",
+// "needs_input", "idle" or "finished".
+type SessionStatus struct {
+	Name           string   `json:"name"`
+	Session        string   `json:"session"`
+	Status         string   `json:"status"`
+	Running        bool     `json:"running"`
+	Attached       bool     `json:"attached"`
+	Windows        int      `json:"windows"`
+	Command        string   `json:"command,omitempty"`
+	Source         string   `json:"source,omitempty"`
+	ExitStatus     *int     `json:"exit_status,omitempty"`
+	ExitSignal     *int     `json:"exit_signal,omitempty"`
+	Alerts         []string `json:"alerts,omitempty"`
+	CurrentCommand string   `json:"current_command,omitempty"`
+	PaneTitle      string   `json:"pane_title,omitempty"`
+	CurrentPath    string   `json:"current_path,omitempty"`
+	Worktree       string   `json:"worktree,omitempty"`
+	LastActivity   int64    `json:"last_activity,omitempty"`
+	State          string   `json:"state,omitempty"`
+	Schedule       string   `json:"schedule,omitempty"`
+	LastRun        *TaskRun `json:"last_run,omitempty"`
+}
+
+// TaskRun is a task's last recorded run. Times are in Unix seconds, and
+// Finished is 0 while the task runs.
+type TaskRun struct {
+	Started    int64 `json:"started"`
+	Finished   int64 `json:"finished"`
+	Duration   int64 `json:"duration_seconds"`
+	ExitStatus int   `json:"exit_status"`
+	ExitSignal int   `json:"exit_signal,omitempty"`
+	Stopped    bool  `json:"stopped,omitempty"`
+}
+
+// status builds the Status for the model's current rows.
+func (m Model) status() Status {
+	status := Status{Folders: make([]FolderStatus, 0, len(m.cfg.Folders))}
+	for _, row := range m.treeRows() {
+		if row.typeOf == rowFolder {
+			folder := m.cfg.Folders[row.folderIndex]
+			status.Folders = append(status.Folders, FolderStatus{
+				Name:      folder.Name,
+				Namespace: folder.Namespace,
+				Path:      folder.Path,
+				Agents:    []SessionStatus{},
+				Terminals: []SessionStatus{},
+				Commands:  []SessionStatus{},
+				Tasks:     []SessionStatus{},
+			})
+			continue
+		}
+		folder := &status.Folders[len(status.Folders)-1]
+		switch row.section {
+		case sectionAgents:
+			folder.Agents = append(folder.Agents, sessionStatus(row))
+		case sectionTerminals:
+			folder.Terminals = append(folder.Terminals, sessionStatus(row))
+		case sectionCommands:
+			folder.Commands = append(folder.Commands, sessionStatus(row))
+		case sectionTasks:
+			task := sessionStatus(row)
+			if configured, ok := taskForRow(m.cfg.Folders[row.folderIndex], row); ok {
+				task.Schedule = configured.Schedule
+			}
+			if run := row.lastRun; !run.Started.IsZero() {
+				task.LastRun = &TaskRun{
+					Started:    run.Started.Unix(),
+					Duration:   int64(run.Duration(time.Now()).Seconds()),
+					ExitStatus: run.ExitStatus,
+					ExitSignal: run.ExitSignal,
+					Stopped:    run.Stopped,
+				}
+				if !run.Finished.IsZero() {
+					task.LastRun.Finished = run.Finished.Unix()
+				}
+			}
+			folder.Tasks = append(folder.Tasks, task)
+		}
+	}
+	return status
+}
+
+func sessionStatus(row treeRow) SessionStatus {
+	s := SessionStatus{
+		Name:           row.displayName,
+		Session:        row.sessionName,
+		Status:         row.status,
+		Running:        row.status != "stopped" && row.status != "exited" && row.status != "crashed" && row.status != "idle",
+		Attached:       row.attached,
+		Windows:        row.windows,
+		CurrentCommand: row.currentCommand,
+		PaneTitle:      row.paneTitle,
+		CurrentPath:    row.currentPath,
+		Worktree:       row.worktreePath,
+		LastActivity:   row.lastActivity,
+	}
+	if row.agent.state != agentUnknown {
+		s.State = agentStateKey(row.agent.state)
+	}
+	if row.typeOf == rowCommand || row.typeOf == rowTask {
+		s.Command = row.commandText
+		s.Source = "config"
+		if row.fromProject {
+			s.Source = config.SourceProject
+		}
+	}
+	switch row.status {
+	case "exited":
+		s.ExitStatus = &row.exitStatus
+	case "crashed":
+		s.ExitSignal = &row.exitSignal
+	}
+	if row.alertsBell {
+		s.Alerts = append(s.Alerts, "bell")
+	}
+	if row.alertsActivity {
+		s.Alerts = append(s.Alerts, "activity")
+	}
+	if row.alertsSilence {
+		s.Alerts = append(s.Alerts, "silence")
+	}
+	return s
+}
+
+// cliStatus prints the status as JSON, or one summary line per folder.
+func (m Model) cliStatus(asJSON bool, stdout io.Writer) error {
+	status := m.status()
+	if asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, folder := range status.Folders {
+		running := 0
+		for _, command := range folder.Commands {
+			if command.Running {
+				running++
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d agent%s\t%d terminal%s\t%d/%d commands running\n",
+			folder.Name,
+			len(folder.Agents), pluralSuffix(len(folder.Agents)),
+			len(folder.Terminals), pluralSuffix(len(folder.Terminals)),
+			running, len(folder.Commands))
+	}
+	return w.Flush()
+}
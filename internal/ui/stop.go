@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/stops"
+)
+
+// Commands are stopped gracefully: grove sends the command's stop keys and
+// stop_command, waits for its process to exit, and only then kills the
+// session. A command that is still running after its stop timeout is killed
+// anyway. Each stop is recorded in a state file first, so a grove running
+// elsewhere does not restart the command.
+
+const stopPollInterval = 200 * time.Millisecond
+
+// commandsStoppedMsg ends the stopping state of sessions once the stop or
+// restart that waited on them has finished.
+type commandsStoppedMsg struct {
+	sessions []string
+	result   actionResultMsg
+}
+
+func (m Model) handleCommandsStopped(msg commandsStoppedMsg) (tea.Model, tea.Cmd) {
+	for _, name := range msg.sessions {
+		delete(m.stopping, name)
+	}
+	return m.Update(msg.result)
+}
+
+// markStopping shows the row as stopping until the kill deadline of its
+// command's stop timeout.
+func (m *Model) markStopping(row treeRow, command config.Command) {
+	m.stopping[row.sessionName] = time.Now().Add(command.StopWait())
+}
+
+func (m Model) isStopping(name string) bool {
+	_, ok := m.stopping[name]
+	return ok
+}
+
+// stopCommandsCmd stops the rows' commands one after another, carrying on
+// past failures and reporting them together.
+func (m *Model) stopCommandsCmd(rows []treeRow) tea.Cmd {
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if command, ok := commandForRow(m.cfg.Folders[row.folderIndex], row); ok {
+			m.markStopping(row, command)
+		}
+		names = append(names, row.sessionName)
+	}
+	m.forgetSessions(names)
+	model := *m
+	return func() tea.Msg {
+		model.forgetSavedSessions(names)
+		var failed, killed []string
+		for _, row := range rows {
+			exited, err := model.stopCommand(row)
+			if err != nil {
+				failed = append(failed, err.Error())
+				continue
+			}
+			if !exited {
+				killed = append(killed, row.displayName)
+			}
+		}
+		result := actionResultMsg{}
+		switch {
+		case len(failed) == 1 && len(rows) == 1:
+			result.err = errors.New(failed[0])
+		case len(failed) > 0:
+			result.err = fmt.Errorf("%d of %d failed: %s", len(failed), len(rows), strings.Join(failed, "; "))
+		case len(rows) == 1 && len(killed) == 1:
+			result.status = "killed " + rows[0].displayName + " after it did not stop in time"
+		case len(rows) == 1:
+			result.status = "stopped " + rows[0].displayName
+		case len(killed) > 0:
+			result.status = fmt.Sprintf("stopped %d commands; killed %s after they did not stop in time", len(rows), strings.Join(killed, ", "))
+		default:
+			result.status = fmt.Sprintf("stopped %d commands", len(rows))
+		}
+		return commandsStoppedMsg{sessions: names, result: result}
+	}
+}
+
+// stopCommand asks the row's command to exit if it is running, waits for it
+// to, and kills its session. It reports whether the process exited by itself.
+func (m Model) stopCommand(row treeRow) (bool, error) {
+	folder := m.cfg.Folders[row.folderIndex]
+	// Failing to record the stop only risks a restart; the stop goes ahead.
+	_ = stops.Mark(m.stopsPath, row.sessionName, time.Now())
+	exited := true
+	if command, ok := commandForRow(folder, row); ok && m.commandRunning(row.folderIndex, folder, command) {
+		m.askToStop(row.sessionName, command)
+		exited = m.waitForExit(row.sessionName, command.StopWait())
+	}
+	if err := m.client.KillSession(row.sessionName); err != nil {
+		return exited, err
+	}
+	return exited, nil
+}
+
+// askToStop sends the command's stop keys, then its stop_command. Failures
+// are ignored, as the session is killed either way.
+func (m Model) askToStop(name string, command config.Command) {
+	if keys := command.StopKeySequence(); len(keys) > 0 {
+		_ = m.client.PressKeys(name, keys)
+	}
+	if command.StopCommand != "" {
+		_ = m.client.SendKeys(name, command.StopCommand)
+	}
+}
+
+// waitForExit polls until the session's process has exited or the session is
+// gone, and reports false if that did not happen within timeout.
+func (m Model) waitForExit(name string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if snapshot, err := m.client.LoadSnapshot(); err == nil {
+			session, ok := findSession(snapshot.Sessions, name)
+			if !ok || session.Dead {
+				return true
+			}
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
@@ -0,0 +1,341 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/SarthakJariwala/grove/internal/config"
+	"github.com/SarthakJariwala/grove/internal/logfile"
+	"github.com/SarthakJariwala/grove/internal/taskrun"
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// Tasks run to completion, unlike commands. While grove is open it starts a
+// scheduled task each time its schedule comes due, skipping a run that would
+// overlap one still going. Schedules from a repo's project file only run in
+// folders that set trust_project_schedules. Runs are recorded from the
+// session snapshots: a task whose session has died finished with its exit
+// status, and one whose session went away before that was stopped.
+
+type taskRunsLoadedMsg struct {
+	runs map[string]taskrun.Run
+	err  error
+}
+
+// taskRunsSavedMsg carries the runs that were saved, including those another
+// grove process recorded, such as grove run.
+type taskRunsSavedMsg struct {
+	runs map[string]taskrun.Run
+	err  error
+}
+
+type taskTickMsg time.Time
+
+// taskStartedMsg reports a task launched by runTaskCmd, or skipped because
+// its last run was still going.
+type taskStartedMsg struct {
+	session string
+	started time.Time
+	skipped bool
+	status  string
+	err     error
+}
+
+// errTaskRunning is returned by launchTask when the task's last run is still
+// going.
+var errTaskRunning = errors.New("its last run is still going")
+
+func loadTaskRunsCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		runs, err := taskrun.Load(path)
+		return taskRunsLoadedMsg{runs: runs, err: err}
+	}
+}
+
+// taskTickCmd fires at the start of every minute, the resolution of a
+// schedule.
+func taskTickCmd() tea.Cmd {
+	return tea.Every(time.Minute, func(t time.Time) tea.Msg {
+		return taskTickMsg(t)
+	})
+}
+
+// saveTaskRunsCmd writes the task runs once the saved ones have been loaded,
+// so a run recorded during startup does not replace the others, and each save
+// merges with the file so a newer run written by another grove process is
+// kept.
+func (m Model) saveTaskRunsCmd() tea.Cmd {
+	if !m.taskRunsLoaded {
+		return nil
+	}
+	path := m.taskRunsPath
+	runs := make(map[string]taskrun.Run, len(m.taskRuns))
+	for name, run := range m.taskRuns {
+		runs[name] = run
+	}
+	return func() tea.Msg {
+		onDisk, err := taskrun.Load(path)
+		if err != nil {
+			return taskRunsSavedMsg{err: err}
+		}
+		mergeTaskRuns(runs, onDisk)
+		return taskRunsSavedMsg{runs: runs, err: taskrun.Save(path, runs)}
+	}
+}
+
+// mergeTaskRuns keeps the newer run of each task in into.
+func mergeTaskRuns(into, from map[string]taskrun.Run) {
+	for name, run := range from {
+		if current, ok := into[name]; !ok || newerRun(run, current) {
+			into[name] = run
+		}
+	}
+}
+
+// newerRun reports whether a is a later run than b, or a later record of the
+// same run.
+func newerRun(a, b taskrun.Run) bool {
+	if !a.Started.Equal(b.Started) {
+		return a.Started.After(b.Started)
+	}
+	if a.Finished.IsZero() != b.Finished.IsZero() {
+		return !a.Finished.IsZero()
+	}
+	return a.Skipped > b.Skipped
+}
+
+func (m Model) handleTaskRunsLoaded(msg taskRunsLoadedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.errMsg = msg.err.Error()
+	}
+	// Runs recorded while the file was loading are newer than its own.
+	for name, run := range msg.runs {
+		if _, ok := m.taskRuns[name]; !ok {
+			m.taskRuns[name] = run
+		}
+	}
+	m.taskRunsLoaded = true
+	m.rebuildRows()
+	return m, m.loadSessionsCmd()
+}
+
+func (m Model) handleTaskStarted(msg taskStartedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.errMsg = msg.err.Error()
+		return m, m.loadSessionsCmd()
+	}
+	if msg.skipped {
+		run := m.taskRuns[msg.session]
+		run.Skipped++
+		m.taskRuns[msg.session] = run
+		return m, tea.Batch(m.saveTaskRunsCmd(), m.setStatus(msg.status), m.loadSessionsCmd())
+	}
+	m.taskRuns[msg.session] = taskrun.Run{Started: msg.started}
+	m.rebuildRows()
+	return m, tea.Batch(m.saveTaskRunsCmd(), m.setStatus(msg.status), m.loadSessionsCmd())
+}
+
+// trackTaskRuns records the runs that started or finished in a complete
+// snapshot. A finished run takes the exit time the server reports, so its
+// duration holds even when grove was closed as it ended. A task found
+// running without a run, as one started from the CLI, is recorded as
+// starting now.
+func (m *Model) trackTaskRuns(now time.Time) tea.Cmd {
+	if !m.taskRunsLoaded {
+		return nil
+	}
+	changed := false
+	for folderIndex, folder := range m.cfg.Folders {
+		for _, task := range folder.Tasks {
+			name := taskSessionName(folder, task.Name)
+			run := m.taskRuns[name]
+			session, ok := findSession(m.sessions[folderIndex], name)
+			switch {
+			case ok && !session.Dead:
+				if run.Running() {
+					continue
+				}
+				run = taskrun.Run{Started: now}
+			case !run.Running():
+				continue
+			case ok:
+				run.Finished, run.ExitStatus, run.ExitSignal = exitTime(session, run, now), session.ExitStatus, session.ExitSignal
+			default:
+				run.Finished, run.Stopped = now, true
+			}
+			m.taskRuns[name] = run
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return m.saveTaskRunsCmd()
+}
+
+// exitTime is when the session's process exited, as reported by the server.
+// Without that it is now, when grove noticed.
+func exitTime(session tmux.Session, run taskrun.Run, now time.Time) time.Time {
+	if session.ExitTime == 0 {
+		return now
+	}
+	exited := time.Unix(session.ExitTime, 0)
+	if exited.Before(run.Started) {
+		return run.Started
+	}
+	return exited
+}
+
+// runDueTasks starts the scheduled tasks that came due since the last check.
+func (m *Model) runDueTasks(now time.Time) tea.Cmd {
+	checked := m.taskCheckedAt
+	m.taskCheckedAt = now
+	var cmds []tea.Cmd
+	for folderIndex, folder := range m.cfg.Folders {
+		for _, task := range folder.Tasks {
+			if task.Source == config.SourceProject && !folder.TrustProjectSchedules {
+				continue
+			}
+			due := task.NextRun(checked)
+			if due.IsZero() || due.After(now) || m.taskRunning(folderIndex, folder, task) {
+				continue
+			}
+			cmds = append(cmds, m.runTaskCmd(folder, task, "started scheduled task "+task.Name))
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m Model) taskRunning(folderIndex int, folder config.Folder, task config.Task) bool {
+	return sessionRunningIn(m.sessions[folderIndex], taskSessionName(folder, task.Name))
+}
+
+func (m Model) runTaskCmd(folder config.Folder, task config.Task, status string) tea.Cmd {
+	name := taskSessionName(folder, task.Name)
+	return func() tea.Msg {
+		started := time.Now()
+		err := m.launchTask(folder, task)
+		if errors.Is(err, errTaskRunning) {
+			return taskStartedMsg{session: name, skipped: true, status: fmt.Sprintf("skipped %s: %v", task.Name, err)}
+		}
+		if err != nil {
+			return taskStartedMsg{session: name, err: err}
+		}
+		return taskStartedMsg{session: name, started: started, status: status}
+	}
+}
+
+// launchTask starts the task in a new session, first clearing the one its
+// last run left behind. The server is asked again right before, as the last
+// snapshot may be a minute old, and a run still going is never killed: it
+// returns errTaskRunning instead. Like commands, its output is logged for
+// local folders.
+func (m Model) launchTask(folder config.Folder, task config.Task) error {
+	name := taskSessionName(folder, task.Name)
+	snapshot, err := m.client.LoadSnapshot()
+	if err != nil && snapshot.Sessions == nil {
+		return err
+	}
+	if session, ok := findSession(snapshot.Sessions, name); ok {
+		if !session.Dead {
+			return errTaskRunning
+		}
+		if err := m.client.KillSession(name); err != nil {
+			return err
+		}
+	}
+	env, err := folder.TaskEnv(task)
+	if err != nil {
+		return err
+	}
+	logPath := ""
+	if folder.Host == "" {
+		logPath = taskLogPath(folder, task.Name)
+		if err := logfile.Prepare(logPath); err != nil {
+			return err
+		}
+	}
+	return m.client.NewCommandSession(name, folder.Path, task.Command, env, logPath)
+}
+
+func taskLogPath(folder config.Folder, name string) string {
+	return logfile.Path(folder.Namespace, "task-"+name)
+}
+
+// stopTaskCmd kills a running task, or clears the session of a finished one.
+func (m Model) stopTaskCmd(row treeRow) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.client.KillSession(row.sessionName); err != nil {
+			return actionResultMsg{err: err}
+		}
+		if row.status == "running" {
+			return actionResultMsg{status: "stopped " + row.displayName}
+		}
+		return actionResultMsg{status: "cleared " + row.displayName}
+	}
+}
+
+func (m Model) withTaskRuns(rows []treeRow) []treeRow {
+	for i := range rows {
+		if rows[i].typeOf == rowTask {
+			rows[i].lastRun = m.taskRuns[rows[i].sessionName]
+		}
+	}
+	return rows
+}
+
+// taskFailed reports whether the task's last run failed. A finished session
+// without a recorded run is judged as a command's.
+func taskFailed(row treeRow) bool {
+	if !row.lastRun.Finished.IsZero() && row.status != "running" {
+		return row.lastRun.Failed()
+	}
+	return commandFailed(row)
+}
+
+// taskRunLabel describes the task's current or last run, as "running 12s",
+// "exit 0 in 42s" or "never run".
+func taskRunLabel(row treeRow, now time.Time) string {
+	run := row.lastRun
+	switch {
+	case row.status == "running" && run.Running():
+		label := "running " + formatRestartDelay(run.Duration(now))
+		if run.Skipped > 0 {
+			label += fmt.Sprintf(", %d run%s skipped", run.Skipped, pluralSuffix(run.Skipped))
+		}
+		return label
+	case row.status == "running":
+		return "running"
+	case !run.Finished.IsZero():
+		took := formatRestartDelay(run.Duration(now))
+		switch {
+		case run.Stopped:
+			return "stopped after " + took
+		case run.ExitSignal != 0:
+			return fmt.Sprintf("signal %d after %s", run.ExitSignal, took)
+		default:
+			return fmt.Sprintf("exit %d in %s", run.ExitStatus, took)
+		}
+	case row.status == "idle":
+		return "never run"
+	default:
+		return commandStatusLabel(row)
+	}
+}
+
+// taskTreeIcon shows a running task, and whether the last run succeeded.
+func taskTreeIcon(row treeRow) string {
+	switch {
+	case row.status == "running":
+		return "▶"
+	case row.status == "idle" && row.lastRun.Started.IsZero():
+		return "○"
+	case taskFailed(row):
+		return "✗"
+	default:
+		return "✓"
+	}
+}
@@ -17,6 +17,7 @@ const (
 	sectionAgents
 	sectionTerminals
 	sectionCommands
+	sectionTasks
 )
 
 func buildTreeRows(cfg config.Config, sessions map[int][]tmux.Session, sessionByName map[string]tmux.Session) []treeRow {
@@ -26,6 +27,7 @@ func buildTreeRows(cfg config.Config, sessions map[int][]tmux.Session, sessionBy
 		rows = append(rows, buildAgentRows(folderIndex, folder, sessions[folderIndex])...)
 		rows = append(rows, buildTerminalRows(folderIndex, folder, sessions[folderIndex])...)
 		rows = append(rows, buildCommandRows(folderIndex, folder, sessionByName)...)
+		rows = append(rows, buildTaskRows(folderIndex, folder, sessionByName)...)
 	}
 	return rows
 }
@@ -41,9 +43,7 @@ func buildCommandRows(folderIndex int, folder config.Folder, sessionByName map[s
 		if ok {
 			attached = session.Attached
 			windows = session.Windows
-			if commandSessionRunning(session) {
-				status = "running"
-			}
+			status = commandSessionStatus(session)
 		}
 		rows = append(rows, treeRow{
 			typeOf:         rowCommand,
@@ -63,6 +63,43 @@ func buildCommandRows(folderIndex int, folder config.Folder, sessionByName map[s
 			alertsBell:     session.AlertsBell,
 			alertsActivity: session.AlertsActivity,
 			alertsSilence:  session.AlertsSilence,
+			exitStatus:     session.ExitStatus,
+			exitSignal:     session.ExitSignal,
+			fromProject:    command.Source == config.SourceProject,
+		})
+	}
+	return rows
+}
+
+// buildTaskRows lists the folder's tasks. A task is "idle" without a
+// session; once it has run, its session stays until the next run so the
+// output can be read, and its status is that of a command.
+func buildTaskRows(folderIndex int, folder config.Folder, sessionByName map[string]tmux.Session) []treeRow {
+	rows := make([]treeRow, 0, len(folder.Tasks))
+	for _, task := range folder.Tasks {
+		sessionName := taskSessionName(folder, task.Name)
+		session, ok := sessionByName[sessionName]
+		status := "idle"
+		if ok {
+			status = commandSessionStatus(session)
+		}
+		rows = append(rows, treeRow{
+			typeOf:         rowTask,
+			section:        sectionTasks,
+			folderIndex:    folderIndex,
+			sessionName:    sessionName,
+			displayName:    task.Name,
+			commandText:    task.Command,
+			status:         status,
+			attached:       session.Attached,
+			windows:        session.Windows,
+			currentCommand: session.CurrentCommand,
+			paneTitle:      session.PaneTitle,
+			currentPath:    session.CurrentPath,
+			lastActivity:   session.LastActivity,
+			exitStatus:     session.ExitStatus,
+			exitSignal:     session.ExitSignal,
+			fromProject:    task.Source == config.SourceProject,
 		})
 	}
 	return rows
@@ -85,7 +122,7 @@ func buildTerminalRows(folderIndex int, folder config.Folder, sessions []tmux.Se
 	rows := make([]treeRow, 0)
 	for _, session := range sessions {
 		id, ok := parseManagedSession(folder.Namespace, session.Name)
-		if ok && id.kind == managedCommand {
+		if ok && (id.kind == managedCommand || id.kind == managedTask) {
 			continue
 		}
 		if ok && id.kind == managedAgent {
@@ -131,12 +168,68 @@ func sessionTreeRow(folderIndex int, section sectionKind, typeOf rowType, sessio
 		paneTitle:      session.PaneTitle,
 		currentPath:    session.CurrentPath,
 		lastActivity:   session.LastActivity,
+		worktreePath:   session.Worktree,
+	}
+}
+
+func commandForRow(folder config.Folder, row treeRow) (config.Command, bool) {
+	for _, command := range folder.Commands {
+		if commandSessionName(folder, command.Name) == row.sessionName {
+			return command, true
+		}
+	}
+	return config.Command{}, false
+}
+
+func taskForRow(folder config.Folder, row treeRow) (config.Task, bool) {
+	for _, task := range folder.Tasks {
+		if taskSessionName(folder, task.Name) == row.sessionName {
+			return task, true
+		}
+	}
+	return config.Task{}, false
+}
+
+// commandSessionStatus reports a command session as "running", "exited"
+// (the pane is dead with an exit status) or "crashed" (killed by a signal).
+func commandSessionStatus(session tmux.Session) string {
+	switch {
+	case !session.Dead:
+		return "running"
+	case session.ExitSignal != 0:
+		return "crashed"
+	default:
+		return "exited"
 	}
 }
 
 func commandSessionRunning(session tmux.Session) bool {
-	command := strings.TrimSpace(session.CurrentCommand)
-	return command != "" && !isShellCommand(command)
+	return !session.Dead
+}
+
+// commandSourceLabel names the file a command row is defined in.
+func commandSourceLabel(row treeRow) string {
+	if row.fromProject {
+		return config.ProjectFile
+	}
+	return "user config"
+}
+
+// commandStatusLabel describes a command row's status for display.
+func commandStatusLabel(row treeRow) string {
+	switch row.status {
+	case "exited":
+		return fmt.Sprintf("exited (code %d)", row.exitStatus)
+	case "crashed":
+		return fmt.Sprintf("crashed (signal %d)", row.exitSignal)
+	default:
+		return row.status
+	}
+}
+
+// commandFailed reports whether a command row ended unsuccessfully.
+func commandFailed(row treeRow) bool {
+	return row.status == "crashed" || (row.status == "exited" && row.exitStatus != 0)
 }
 
 func titleSlug(slug string) string {
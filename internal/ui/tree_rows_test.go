@@ -83,7 +83,9 @@ func TestBuildTreeRowsKeepsEmptyFoldersVisible(t *testing.T) {
 	}
 }
 
-func TestCommandRowUsesShellIdleAsStoppedState(t *testing.T) {
+func TestCommandRowStatusFollowsPaneExit(t *testing.T) {
+	t.Parallel()
+
 	cfg := config.Config{Folders: []config.Folder{{
 		Name:      "API",
 		Path:      "/tmp/api",
@@ -91,13 +93,37 @@ func TestCommandRowUsesShellIdleAsStoppedState(t *testing.T) {
 		Commands:  []config.Command{{Name: "start", Command: "make start"}},
 	}}}
 
-	rows := buildTreeRows(
-		cfg,
-		map[int][]tmux.Session{0: {{Name: "api/cmd-start", CurrentCommand: "zsh"}}},
-		map[string]tmux.Session{"api/cmd-start": {Name: "api/cmd-start", CurrentCommand: "zsh"}},
-	)
+	tests := []struct {
+		name      string
+		session   *tmux.Session
+		wantLabel string
+		wantFail  bool
+	}{
+		{name: "no session", wantLabel: "stopped"},
+		{name: "shell command still running", session: &tmux.Session{Name: "api/cmd-start", CurrentCommand: "zsh"}, wantLabel: "running"},
+		{name: "clean exit", session: &tmux.Session{Name: "api/cmd-start", Dead: true}, wantLabel: "exited (code 0)"},
+		{name: "failed exit", session: &tmux.Session{Name: "api/cmd-start", Dead: true, ExitStatus: 2}, wantLabel: "exited (code 2)", wantFail: true},
+		{name: "signal", session: &tmux.Session{Name: "api/cmd-start", Dead: true, ExitSignal: 11}, wantLabel: "crashed (signal 11)", wantFail: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			sessions := map[int][]tmux.Session{}
+			byName := map[string]tmux.Session{}
+			if tt.session != nil {
+				sessions[0] = []tmux.Session{*tt.session}
+				byName[tt.session.Name] = *tt.session
+			}
 
-	if rows[1].status != "stopped" {
-		t.Fatalf("command row status = %q, want stopped", rows[1].status)
+			rows := buildTreeRows(cfg, sessions, byName)
+			if got := commandStatusLabel(rows[1]); got != tt.wantLabel {
+				t.Fatalf("commandStatusLabel() = %q, want %q", got, tt.wantLabel)
+			}
+			if got := commandFailed(rows[1]); got != tt.wantFail {
+				t.Fatalf("commandFailed() = %v, want %v", got, tt.wantFail)
+			}
+		})
 	}
 }
@@ -20,21 +20,57 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case sessionsLoadedMsg:
-		if msg.err != nil {
+		if msg.poll {
+			m.polling = false
+		}
+		if msg.err != nil && msg.sessions == nil {
 			m.errMsg = msg.err.Error()
 			return m, nil
 		}
+		prev := m.sessions
 		m.sessions = msg.sessions
+		m.agentStates = msg.agentStates
+		if msg.agentScreens != nil {
+			m.agentScreens, m.agentScreensAt = msg.agentScreens, time.Now()
+		}
+		m.acknowledgeSessions(m.sessions)
 		if msg.panesFresh {
 			m.sessionWindows = msg.sessionWindows
 			m.activeWindows = msg.activeWindows
 		}
+		var taskCmd tea.Cmd
+		if msg.err == nil && msg.panesFresh {
+			taskCmd = m.trackTaskRuns(time.Now())
+		}
 		m.rebuildRows()
 		m.errMsg = ""
+		if msg.err != nil {
+			m.errMsg = msg.err.Error()
+		}
+		var restartCmd tea.Cmd
+		if msg.panesFresh {
+			restartCmd = m.superviseCommands(prev)
+		}
+		manifestCmd := m.trackSessions(msg.err == nil)
 		if m.detailMode == detailPreview {
-			return m, m.reconcilePreviewAfterLoad()
+			return m, tea.Batch(restartCmd, manifestCmd, taskCmd, m.reconcilePreviewAfterLoad())
 		}
-		return m, m.syncSelectionPreview(true, false)
+		return m, tea.Batch(restartCmd, manifestCmd, taskCmd, m.syncSelectionPreview(true, false))
+
+	case commandRestartMsg:
+		return m, m.relaunchCommand(msg)
+
+	case configTickMsg:
+		return m.handleConfigTick()
+
+	case configCheckedMsg:
+		return m.handleConfigChecked(msg)
+
+	case configEditedMsg:
+		return m.handleConfigEdited(msg)
+
+	case commandsStoppedMsg:
+		return m.handleCommandsStopped(msg)
 
 	case actionResultMsg:
 		if msg.err != nil {
@@ -46,14 +82,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Batch(
 				clearCmd,
 				m.loadSessionsCmd(),
-				tea.ExecProcess(m.client.AttachCommand(msg.attachTarget), func(err error) tea.Msg {
-					return attachedMsg{err: err}
-				}),
+				m.attachCmd(msg.attachTarget),
 			)
 		}
 		return m, tea.Batch(clearCmd, m.loadSessionsCmd())
 
 	case attachedMsg:
+		m.markSeen(msg.session)
 		if msg.err != nil {
 			m.errMsg = msg.err.Error()
 			return m, m.loadSessionsCmd()
@@ -73,6 +108,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			m.previewErr = nil
 			m.previewContent = msg.content
+			m.markSeen(m.previewSession)
 		}
 		return m, nil
 
@@ -80,7 +116,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.detailMode != detailPreview {
 			return m, nil
 		}
-		if m.previewInFlight {
+		// Captures follow pushed output while tmux is watched.
+		if m.previewInFlight || m.watching {
 			return m, previewTickCmd()
 		}
 		return m, tea.Batch(m.beginPreviewCapture(false), previewTickCmd())
@@ -90,7 +127,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.errMsg = msg.err.Error()
 			return m, nil
 		}
-		m.cfg.Folders = append(m.cfg.Folders, msg.folder)
+		// A config reload may already have picked up the saved folder.
+		if folderIndexByNamespace(m.cfg, msg.folder.Namespace) < 0 {
+			m.cfg.Folders = append(m.cfg.Folders, msg.folder)
+		}
 		m.rebuildRows()
 		clearCmd := m.setStatus("added folder: " + msg.folder.Name)
 		return m, tea.Batch(clearCmd, m.loadSessionsCmd())
@@ -100,16 +140,19 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.errMsg = msg.err.Error()
 			return m, nil
 		}
-		if msg.folderIndex < 0 || msg.folderIndex >= len(m.cfg.Folders) {
+		folderIndex := folderIndexByNamespace(m.cfg, msg.namespace)
+		if folderIndex < 0 {
 			m.errMsg = "select a folder"
 			return m, nil
 		}
-		m.cfg.Folders[msg.folderIndex].Commands = append(m.cfg.Folders[msg.folderIndex].Commands, msg.command)
-		folder := m.cfg.Folders[msg.folderIndex]
+		if !config.CommandNameExists(m.cfg.Folders[folderIndex], msg.command.Name) {
+			m.cfg.Folders[folderIndex].Commands = append(m.cfg.Folders[folderIndex].Commands, msg.command)
+		}
+		folder := m.cfg.Folders[folderIndex]
 		m.rebuildRows()
 		target := treeRow{
 			typeOf:      rowCommand,
-			folderIndex: msg.folderIndex,
+			folderIndex: folderIndex,
 			sessionName: commandSessionName(folder, msg.command.Name),
 		}
 		if index, ok := findMatchingRowIndex(m.rows, target); ok {
@@ -117,6 +160,60 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, m.setStatus("added dev command: " + msg.command.Name)
 
+	case logLoadedMsg:
+		if msg.seq != m.logSeq || m.detailMode != detailLogs {
+			return m, nil
+		}
+		m.applyLogLoad(msg)
+		return m, nil
+
+	case logTickMsg:
+		if m.detailMode != detailLogs {
+			return m, nil
+		}
+		if m.logFollow {
+			return m, tea.Batch(m.loadLogCmd(), logTickCmd())
+		}
+		return m, logTickCmd()
+
+	case seenLoadedMsg:
+		return m.handleSeenLoaded(msg)
+
+	case seenSavedMsg:
+		if msg.err != nil {
+			m.errMsg = msg.err.Error()
+			return m, nil
+		}
+		mergeSeen(m.seen, msg.times)
+		return m, nil
+
+	case manifestLoadedMsg:
+		return m.handleManifestLoaded(msg)
+
+	case manifestSavedMsg:
+		if msg.err != nil {
+			m.errMsg = msg.err.Error()
+		}
+		return m, nil
+
+	case taskRunsLoadedMsg:
+		return m.handleTaskRunsLoaded(msg)
+
+	case taskRunsSavedMsg:
+		if msg.err != nil {
+			m.errMsg = msg.err.Error()
+			return m, nil
+		}
+		mergeTaskRuns(m.taskRuns, msg.runs)
+		m.rebuildRows()
+		return m, nil
+
+	case taskTickMsg:
+		return m, tea.Batch(m.runDueTasks(time.Time(msg)), taskTickCmd())
+
+	case taskStartedMsg:
+		return m.handleTaskStarted(msg)
+
 	case clearStatusMsg:
 		if msg.seq == m.statusSeq {
 			m.statusMsg = ""
@@ -124,7 +221,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case time.Time:
-		return m, tea.Batch(tickCmd(), m.loadSessionsCmd())
+		return m.handleRefreshTick()
+
+	case watchStartedMsg:
+		return m.handleWatchStarted(msg)
+
+	case tmuxChangedMsg:
+		return m.handleTmuxChanged(msg)
 	}
 
 	if m.promptMode != promptNone {
@@ -133,19 +236,35 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if m.overlayMode != overlayNone {
 		return m.updateOverlay(msg)
 	}
-	if m.confirmKillTarget != "" {
+	if m.confirmKillTargets != nil {
 		return m.updateKillConfirm(msg)
 	}
+	if m.confirmRemoval.kind != removalNone {
+		return m.updateRemoveConfirm(msg)
+	}
+	if m.confirmRestore != nil {
+		return m.updateRestoreConfirm(msg)
+	}
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		if m.detailMode == detailPreview {
+		switch m.detailMode {
+		case detailPreview:
 			return m.updatePreview(msg)
+		case detailLogs:
+			return m.updateLogs(msg)
 		}
 		switch msg.String() {
 		case "ctrl+c", "q":
+			if save := m.saveSeenCmd(); save != nil {
+				return m, tea.Sequence(save, tea.Quit)
+			}
 			return m, tea.Quit
 		case "esc":
+			if len(m.marked) > 0 {
+				m.clearMarks()
+				return m, m.setStatus("marks cleared")
+			}
 			if m.filterQuery != "" {
 				m.filterQuery = ""
 				m.rebuildRows()
@@ -165,6 +284,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		case "r":
 			return m, m.loadSessionsCmd()
+		case "tab":
+			return m, m.jumpToAttention()
+		case " ":
+			return m, m.toggleMark()
+		case "ctrl+a":
+			return m, m.markFolder()
 		case "/":
 			m.openPrompt(promptFilter, m.filterQuery, "filter folders and sessions")
 			return m, textinput.Blink
@@ -202,47 +327,112 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.openPrompt(promptAddCommandName, "", "dev command name")
 			return m, textinput.Blink
 		case "s":
+			if len(m.marked) > 0 {
+				return m, m.startMarked()
+			}
+			if row, ok := m.selectedTaskRow(); ok {
+				if row.status == "running" {
+					return m, nil
+				}
+				task, _ := taskForRow(m.cfg.Folders[row.folderIndex], row)
+				return m, m.runTaskCmd(m.cfg.Folders[row.folderIndex], task, "started "+task.Name)
+			}
 			row, ok := m.selectedCommandRow()
 			if !ok || row.status == "running" {
 				return m, nil
 			}
-			folder := m.cfg.Folders[row.folderIndex]
-			return m, m.startCommandCmd(folder, row)
+			return m, m.startCommandWithDeps(row)
+		case "S":
+			folder, ok := m.selectedFolder()
+			if !ok {
+				m.errMsg = "select a folder or one of its sections"
+				return m, nil
+			}
+			folderIndex := m.rows[m.selected].folderIndex
+			order, err := config.FolderStartOrder(folder)
+			if err != nil {
+				m.errMsg = err.Error()
+				return m, nil
+			}
+			pending := m.stoppedCommands(folderIndex, order)
+			if len(pending) == 0 {
+				return m, m.setStatus("all commands already running")
+			}
+			m.resetRestarts(folder, pending)
+			return m, m.startCommandsCmd(folder, pending, "started "+commandNames(pending))
 		case "x":
+			if len(m.marked) > 0 {
+				cmd := m.stopMarked()
+				return m, cmd
+			}
+			if row, ok := m.selectedTaskRow(); ok {
+				if row.status == "idle" {
+					return m, nil
+				}
+				return m, m.stopTaskCmd(row)
+			}
 			row, ok := m.selectedCommandRow()
-			if !ok || row.status != "running" {
+			if !ok || row.status == "stopped" || m.isStopping(row.sessionName) {
 				return m, nil
 			}
-			return m, m.killSessionCmd(row.sessionName)
+			m.markCommandStopped(row.sessionName)
+			cmd := m.stopCommandsCmd([]treeRow{row})
+			return m, cmd
 		case "R":
+			if len(m.marked) > 0 {
+				return m, m.restartMarked()
+			}
 			row, ok := m.selectedCommandRow()
-			if !ok {
+			if !ok || m.isStopping(row.sessionName) {
 				return m, nil
 			}
+			if row.status != "running" {
+				return m, m.startCommandWithDeps(row)
+			}
 			folder := m.cfg.Folders[row.folderIndex]
-			if row.status == "running" {
-				return m, m.restartCommandCmd(folder, row)
+			order, err := config.CommandStartOrder(folder, row.displayName)
+			if err != nil {
+				m.errMsg = err.Error()
+				return m, nil
 			}
-			return m, m.startCommandCmd(folder, row)
-		case "c":
-			row, ok := m.selectedRow()
-			if !ok || (row.typeOf != rowAgentInstance && row.typeOf != rowTerminalInstance) {
-				m.errMsg = "select an agent or terminal to run command"
+			deps := m.stoppedCommands(row.folderIndex, order[:len(order)-1])
+			m.resetRestarts(folder, append(deps, order[len(order)-1]))
+			return m, m.restartCommandCmd(folder, row, deps)
+		case "l":
+			row, ok := m.selectedCommandRow()
+			if !ok {
+				row, ok = m.selectedTaskRow()
+			}
+			if !ok {
+				m.errMsg = "select a command or task to view its log"
 				return m, nil
 			}
-			m.promptTarget = row.sessionName
-			m.openPrompt(promptRunCommand, "", "command to run")
-			return m, textinput.Blink
+			if m.cfg.Folders[row.folderIndex].Host != "" {
+				m.errMsg = "logs are not kept for commands on remote hosts"
+				return m, nil
+			}
+			return m, m.openLogs(row)
+		case "c":
+			return m, m.openSendPrompt()
 		case "K":
+			if len(m.marked) > 0 {
+				m.confirmKillMarked()
+				return m, nil
+			}
 			row, ok := m.selectedKillableSessionRow()
 			if !ok {
 				m.errMsg = "select an agent or terminal to kill"
 				return m, nil
 			}
-			m.confirmKillTarget = row.sessionName
+			m.confirmKillTargets = []treeRow{row}
 			m.statusMsg = ""
 			m.errMsg = ""
 			return m, nil
+		case "E":
+			return m, m.editSelected()
+		case "D":
+			m.confirmRemoveSelected()
+			return m, nil
 		case "A":
 			m.promptStep = 0
 			m.pendingFolder = config.Folder{}
@@ -267,9 +457,18 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.errMsg = "no editor configured; set editor_command in config or $EDITOR"
 				return m, nil
 			}
+			if folder.Host != "" {
+				m.errMsg = "folder is on " + folder.Host + "; attach to a session to edit there"
+				return m, nil
+			}
 			dir := folder.Path
-			if row, ok := m.selectedSessionRow(); ok && row.currentPath != "" {
-				dir = row.currentPath
+			if row, ok := m.selectedSessionRow(); ok {
+				switch {
+				case row.worktreePath != "":
+					dir = row.worktreePath
+				case row.currentPath != "":
+					dir = row.currentPath
+				}
 			}
 			return m, m.openEditorInDir(cmd, dir)
 		case "enter":
@@ -278,17 +477,29 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.errMsg = "select a running session"
 				return m, nil
 			}
-			m.statusMsg = "attached to " + row.sessionName + " (detach with Ctrl-b d)"
+			m.statusMsg = m.attachStatus(row.sessionName)
 			m.errMsg = ""
-			return m, tea.ExecProcess(m.client.AttachCommand(row.sessionName), func(err error) tea.Msg {
-				return attachedMsg{err: err}
-			})
+			return m, m.attachCmd(row.sessionName)
 		}
 	}
 
 	return m, nil
 }
 
+// startCommandWithDeps starts the row's command together with any of its
+// dependencies that are not running yet.
+func (m *Model) startCommandWithDeps(row treeRow) tea.Cmd {
+	folder := m.cfg.Folders[row.folderIndex]
+	order, err := config.CommandStartOrder(folder, row.displayName)
+	if err != nil {
+		m.errMsg = err.Error()
+		return nil
+	}
+	pending := m.stoppedCommands(row.folderIndex, order)
+	m.resetRestarts(folder, pending)
+	return m.startCommandsCmd(folder, pending, "started "+commandNames(pending))
+}
+
 // ── View ────────────────────────────────────────────────────────────
 
 func (m *Model) openAgentPicker(folder config.Folder) {
@@ -371,7 +582,44 @@ func (m Model) updateOverlay(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		folder := m.cfg.Folders[folderIndex]
 		m.closeOverlay()
-		return m, m.newAgentCmd(folderIndex, folder, choice.Agent, choice.Persist)
+		return m, m.newAgentCmd(folderIndex, folder, choice.Agent, choice.Persist, "")
+	case "D":
+		choice := m.agentChoices[m.overlayIndex]
+		if choice.IsNew || choice.Persist {
+			m.errMsg = "only agents saved to the folder can be removed"
+			return m, nil
+		}
+		if choice.Agent.Source == config.SourceProject {
+			m.errMsg = choice.Agent.Name + " is defined in " + config.ProjectFile
+			return m, nil
+		}
+		folderIndex := m.overlayFolderIndex
+		m.closeOverlay()
+		m.confirmRemoval = removal{kind: removalAgent, folderIndex: folderIndex, name: choice.Agent.Name}
+		m.errMsg = ""
+		return m, nil
+	case "w":
+		choice := m.agentChoices[m.overlayIndex]
+		folderIndex := m.overlayFolderIndex
+		if choice.IsNew || folderIndex < 0 || folderIndex >= len(m.cfg.Folders) {
+			return m, nil
+		}
+		folder := m.cfg.Folders[folderIndex]
+		if folder.Host != "" {
+			m.errMsg = "worktrees are not supported on remote hosts"
+			return m, nil
+		}
+		if !m.worktrees.IsRepo(folder.Path) {
+			m.errMsg = "folder is not a git repository"
+			return m, nil
+		}
+		index := nextAgentIndex(folder, choice.Agent.Name, m.sessions[folderIndex])
+		m.closeOverlay()
+		m.pendingAgent = choice.Agent
+		m.pendingPersist = choice.Persist
+		m.promptFolderIndex = folderIndex
+		m.openPrompt(promptAgentWorktreeBranch, agentWorktreeBranch(choice.Agent.Name, index), "worktree branch")
+		return m, textinput.Blink
 	}
 
 	return m, nil
@@ -391,11 +639,20 @@ func (m Model) updateKillConfirm(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch strings.ToLower(key.String()) {
 	case "y", "enter":
-		target := m.confirmKillTarget
-		m.confirmKillTarget = ""
-		return m, m.killSessionCmd(target)
+		targets := m.confirmKillTargets
+		m.confirmKillTargets = nil
+		cmd := m.killSessionsCmd(targets, false)
+		return m, cmd
+	case "w":
+		if !m.killTargetsHaveWorktrees() {
+			return m, nil
+		}
+		targets := m.confirmKillTargets
+		m.confirmKillTargets = nil
+		cmd := m.killSessionsCmd(targets, true)
+		return m, cmd
 	case "n", "esc":
-		m.confirmKillTarget = ""
+		m.confirmKillTargets = nil
 		clearCmd := m.setStatus("kill cancelled")
 		return m, clearCmd
 	default:
@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/SarthakJariwala/grove/internal/tmux"
+)
+
+// While tmux pushes changes, sessions are still polled this often to catch
+// anything control mode does not report.
+const watchRefreshInterval = 5 * time.Second
+
+// watchRetryInterval spaces out attempts to connect to tmux in control mode.
+const watchRetryInterval = 5 * time.Second
+
+// changeWatcher is implemented by session managers that can push changes
+// instead of being polled.
+type changeWatcher interface {
+	Watch() error
+	NextChange() (tmux.Changes, bool)
+}
+
+type watchStartedMsg struct {
+	err error
+}
+
+// tmuxChangedMsg carries the changes tmux reported. ok is false once the
+// connection closed.
+type tmuxChangedMsg struct {
+	changes tmux.Changes
+	ok      bool
+}
+
+func (m Model) watchCmd() tea.Cmd {
+	watcher, ok := m.client.(changeWatcher)
+	if !ok {
+		return nil
+	}
+	return func() tea.Msg {
+		return watchStartedMsg{err: watcher.Watch()}
+	}
+}
+
+func (m Model) nextChangeCmd() tea.Cmd {
+	watcher := m.client.(changeWatcher)
+	return func() tea.Msg {
+		changes, ok := watcher.NextChange()
+		return tmuxChangedMsg{changes: changes, ok: ok}
+	}
+}
+
+func (m Model) handleWatchStarted(msg watchStartedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		// Polling carries on; the next attempt waits for the retry interval.
+		m.watchAttempt = time.Now()
+		return m, nil
+	}
+	if m.watching {
+		return m, nil
+	}
+	m.watching = true
+	return m, tea.Batch(m.nextChangeCmd(), m.loadSessionsCmd())
+}
+
+func (m Model) handleTmuxChanged(msg tmuxChangedMsg) (tea.Model, tea.Cmd) {
+	if !msg.ok {
+		m.watching = false
+		m.watchAttempt = time.Now()
+		return m, m.loadSessionsCmd()
+	}
+	cmds := []tea.Cmd{m.nextChangeCmd()}
+	if msg.changes.Sessions {
+		cmds = append(cmds, m.loadSessionsCmd())
+	}
+	if msg.changes.Output && m.detailMode != detailLogs && !m.previewInFlight {
+		cmds = append(cmds, m.beginPreviewCapture(false))
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// handleRefreshTick polls sessions, slowly while tmux pushes changes, and
+// saves the last-seen times when they changed. A tick whose last poll is
+// still loading skips its own. Without a control-mode connection it tries to
+// connect once there are sessions, so grove does not start a tmux server
+// only to watch it.
+func (m Model) handleRefreshTick() (tea.Model, tea.Cmd) {
+	save := m.saveSeenCmd()
+	var poll tea.Cmd
+	if !m.polling {
+		m.polling = true
+		poll = m.pollSessionsCmd()
+	}
+	if m.watching {
+		return m, tea.Batch(tickCmd(watchRefreshInterval), poll, save)
+	}
+	cmds := []tea.Cmd{tickCmd(refreshInterval), poll, save}
+	if len(m.sessions) > 0 && time.Since(m.watchAttempt) >= watchRetryInterval {
+		m.watchAttempt = time.Now()
+		cmds = append(cmds, m.watchCmd())
+	}
+	return m, tea.Batch(cmds...)
+}
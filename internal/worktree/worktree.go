@@ -0,0 +1,97 @@
+package worktree
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Dir is the folder-relative directory that holds grove-managed worktrees.
+const Dir = ".grove/worktrees"
+
+type Client struct{}
+
+var execCommand = exec.Command
+
+func NewClient() *Client {
+	return &Client{}
+}
+
+// Path returns where the worktree for a managed session leaf lives inside
+// the folder checkout.
+func Path(repo, leaf string) string {
+	return filepath.Join(repo, filepath.FromSlash(Dir), leaf)
+}
+
+func (c *Client) IsRepo(dir string) bool {
+	cmd := execCommand("git", "-C", dir, "rev-parse", "--is-inside-work-tree")
+	out, err := cmd.Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+// Add creates a worktree at path checked out on branch. The branch is created
+// from the current HEAD when it does not exist yet.
+func (c *Client) Add(repo, path, branch string) error {
+	if err := ensureIgnored(repo); err != nil {
+		return err
+	}
+
+	args := []string{"-C", repo, "worktree", "add"}
+	if c.BranchExists(repo, branch) {
+		args = append(args, path, branch)
+	} else {
+		args = append(args, "-b", branch, path)
+	}
+
+	cmd := execCommand("git", args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git worktree add: %w (%s)", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Remove deletes the worktree at path. Worktrees with uncommitted changes are
+// left in place and reported as an error.
+func (c *Client) Remove(repo, path string) error {
+	cmd := execCommand("git", "-C", repo, "worktree", "remove", path)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git worktree remove: %w (%s)", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// BranchExists reports whether repo has a local branch named branch.
+func (c *Client) BranchExists(repo, branch string) bool {
+	cmd := execCommand("git", "-C", repo, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	return cmd.Run() == nil
+}
+
+// DeleteBranch deletes branch from repo, merged or not.
+func (c *Client) DeleteBranch(repo, branch string) error {
+	cmd := execCommand("git", "-C", repo, "branch", "-D", branch)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git branch -D: %w (%s)", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ensureIgnored keeps the worktree directory out of the folder's git status.
+func ensureIgnored(repo string) error {
+	dir := filepath.Join(repo, ".grove")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create worktree directory %q: %w", dir, err)
+	}
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignore); err == nil {
+		return nil
+	}
+	if err := os.WriteFile(ignore, []byte("*\n"), 0o644); err != nil {
+		return fmt.Errorf("write %q: %w", ignore, err)
+	}
+	return nil
+}
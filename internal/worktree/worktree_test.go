@@ -0,0 +1,88 @@
+package worktree
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=grove", "-c", "user.email=grove@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v (%s)", args, err, out)
+		}
+	}
+	return repo
+}
+
+func TestPath(t *testing.T) {
+	t.Parallel()
+
+	got := Path("/tmp/api", "agent-codex-1")
+	want := filepath.Join("/tmp/api", ".grove", "worktrees", "agent-codex-1")
+	if got != want {
+		t.Fatalf("Path() = %q, want %q", got, want)
+	}
+}
+
+func TestIsRepo(t *testing.T) {
+	repo := initRepo(t)
+	client := NewClient()
+
+	if !client.IsRepo(repo) {
+		t.Fatalf("IsRepo(%q) = false, want true", repo)
+	}
+	if client.IsRepo(t.TempDir()) {
+		t.Fatal("IsRepo(non-repo) = true, want false")
+	}
+}
+
+func TestAddAndRemove(t *testing.T) {
+	repo := initRepo(t)
+	client := NewClient()
+	path := Path(repo, "agent-codex-1")
+
+	if err := client.Add(repo, path, "grove/codex-1"); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		t.Fatalf("worktree dir stat = (%v, %v), want directory", info, err)
+	}
+	if b, err := os.ReadFile(filepath.Join(repo, ".grove", ".gitignore")); err != nil || string(b) != "*\n" {
+		t.Fatalf(".grove/.gitignore = (%q, %v), want ignore-all", b, err)
+	}
+
+	if err := client.Remove(repo, path); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("worktree dir after Remove() err = %v, want not exist", err)
+	}
+
+	// The branch survives removal, so adding it again checks it out instead
+	// of trying to create it.
+	if err := client.Add(repo, path, "grove/codex-1"); err != nil {
+		t.Fatalf("Add() with existing branch error = %v", err)
+	}
+
+	if err := client.Remove(repo, path); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := client.DeleteBranch(repo, "grove/codex-1"); err != nil {
+		t.Fatalf("DeleteBranch() error = %v", err)
+	}
+	if client.BranchExists(repo, "grove/codex-1") {
+		t.Fatal("BranchExists() = true after DeleteBranch()")
+	}
+}